@@ -0,0 +1,99 @@
+package jsonrpc2
+
+import (
+	"context"
+	"time"
+)
+
+// MethodAffinity is the method served by AffinityHandler and called by
+// ReconnectingClients created with WithAffinity to get an affinity token.
+const MethodAffinity = "rpc.affinity"
+
+// affinityTimeout bounds how long a ReconnectingClient waits for an
+// affinity token after connecting.
+const affinityTimeout = 5 * time.Second
+
+// AffinityResult is the result of MethodAffinity.
+type AffinityResult struct {
+	Token string `json:"token"`
+}
+
+// AffinityHandler returns a Handler for MethodAffinity which replies with
+// the token returned by token, such as the name of the server instance.
+// Servers behind a load balancer opt in to sticky reconnects by registering
+// it:
+//
+//	mux.Handle(jsonrpc2.MethodAffinity, jsonrpc2.AffinityHandler(func(r *jsonrpc2.Request) string {
+//		return os.Getenv("POD_NAME")
+//	}))
+//
+// ReconnectingClients created with WithAffinity present the token when they
+// reconnect, so the load balancer can route them back to the server holding
+// their session or subscription state.
+func AffinityHandler(token func(r *Request) string) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Notification {
+			return
+		}
+		_ = w.WriteMessage(AffinityResult{Token: token(r)})
+	})
+}
+
+type affinityTokenKey struct{}
+
+// WithAffinityToken returns a copy of ctx holding an affinity token.
+func WithAffinityToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, affinityTokenKey{}, token)
+}
+
+// AffinityTokenFromContext returns the affinity token held by ctx, or an
+// empty string if there is none. DialFuncs of ReconnectingClients created
+// with WithAffinity use it to present the token from the previous
+// connection, in whatever way the load balancer routes on, such as a URL
+// query parameter:
+//
+//	dial := func(ctx context.Context) (*jsonrpc2.Client, error) {
+//		u := "wss://api.example.com/rpc"
+//		if token := jsonrpc2.AffinityTokenFromContext(ctx); token != "" {
+//			u += "?affinity=" + url.QueryEscape(token)
+//		}
+//		return websocket.Dial(ctx, u, 10*time.Second, handler)
+//	}
+func AffinityTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(affinityTokenKey{}).(string)
+	return token
+}
+
+// WithAffinity makes the ReconnectingClient call MethodAffinity after each
+// connection is established, and pass the token it returns to the DialFunc
+// when reconnecting; see AffinityTokenFromContext. If the server doesn't
+// serve MethodAffinity, or the call fails, the previous token is kept.
+func WithAffinity() ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		rc.affinity = true
+	}
+}
+
+// AffinityToken returns the affinity token from the most recent connection,
+// or an empty string if there is none.
+func (rc *ReconnectingClient) AffinityToken() string {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+	return rc.affinityToken
+}
+
+// fetchAffinityToken calls MethodAffinity over cli to update the affinity
+// token.
+func (rc *ReconnectingClient) fetchAffinityToken(ctx context.Context, cli *Client) {
+	ctx, cancel := context.WithTimeout(ctx, affinityTimeout)
+	defer cancel()
+
+	res, err := InvokeAs[AffinityResult](ctx, cli, MethodAffinity, nil)
+	if err != nil || res.Token == "" {
+		LevelDebug.Log(rc.log, "msg", "failed to get affinity token", "err", err)
+		return
+	}
+	rc.mut.Lock()
+	rc.affinityToken = res.Token
+	rc.mut.Unlock()
+}
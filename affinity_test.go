@@ -0,0 +1,69 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectingClient_Affinity(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := NewServeMux()
+	mux.Handle(MethodAffinity, AffinityHandler(func(r *Request) string { return "server-a" }))
+
+	clis := make(chan *Client, 2)
+	srv := &Server{Handler: mux, OnClient: func(c *Client) { clis <- c }}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	var (
+		mut    sync.Mutex
+		tokens []string
+	)
+	rc := NewReconnectingClient(func(ctx context.Context) (*Client, error) {
+		mut.Lock()
+		tokens = append(tokens, AffinityTokenFromContext(ctx))
+		mut.Unlock()
+		return Dial(lis.Addr().String(), nil)
+	}, WithAffinity(), WithReconnectBackoff(10*time.Millisecond, time.Second))
+	defer rc.Close()
+
+	require.Eventually(t, func() bool { return rc.AffinityToken() == "server-a" }, 5*time.Second, 10*time.Millisecond)
+
+	// The token from the first connection is presented when reconnecting.
+	require.NoError(t, (<-clis).Close())
+	<-clis
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, []string{"", "server-a"}, tokens)
+}
+
+func TestReconnectingClient_AffinityUnsupported(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := NewServeMux()
+	mux.HandleFunc("ping", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("pong") })
+	srv := &Server{Handler: mux}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	rc := NewReconnectingClient(func(ctx context.Context) (*Client, error) {
+		return Dial(lis.Addr().String(), nil)
+	}, WithAffinity(), WithReconnectPolicy(WaitForReconnect))
+	defer rc.Close()
+
+	// Servers without MethodAffinity still work, without a token.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := rc.Invoke(ctx, "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"pong"`, string(res))
+	require.Equal(t, "", rc.AffinityToken())
+}
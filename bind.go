@@ -0,0 +1,153 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BindNamed decodes params given by name, such as {"a": 1, "b": 2}, into v,
+// which must be a pointer to a struct. Members are matched to fields by
+// their JSON names, and fields may be renamed or made required with jsonrpc
+// tags as in Method:
+//
+//	type SumParams struct {
+//		A int `json:"a" jsonrpc:",required"`
+//		B int `json:"b"`
+//	}
+//
+//	var p SumParams
+//	if err := jsonrpc2.BindNamed(r.Params, &p); err != nil {
+//		_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+//		return
+//	}
+//
+// Params which aren't an object, which are missing required members, or
+// whose members have the wrong type fail with an ErrorInvalidParams Error
+// describing the problem. Missing params are treated as an empty object.
+func BindNamed(params json.RawMessage, v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("jsonrpc2: BindNamed requires a non-nil pointer to a struct, got %T", v)
+	}
+
+	trimmed := strings.TrimSpace(string(params))
+	switch {
+	case trimmed == "" || trimmed == "null":
+		params = json.RawMessage("{}")
+	case trimmed[0] != '{':
+		return Error{Code: ErrorInvalidParams, Message: "params must be given by name"}
+	}
+
+	if err := decodeTypedParams(params, v); err != nil {
+		return invalidParams("", err)
+	}
+	return nil
+}
+
+// BindPositional decodes params given by position, such as [1, "a"], into
+// args, which must be pointers, in order:
+//
+//	var (
+//		a, b int
+//		op   string
+//	)
+//	if err := jsonrpc2.BindPositional(r.Params, &a, &b, &op); err != nil {
+//		_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+//		return
+//	}
+//
+// Params which aren't an array, which don't have exactly len(args) items,
+// or whose items have the wrong type fail with an ErrorInvalidParams Error
+// naming the offending item, such as "invalid params: params[1] must be an
+// integer, got string". Missing params are treated as an empty array.
+func BindPositional(params json.RawMessage, args ...interface{}) error {
+	for i, arg := range args {
+		if rv := reflect.ValueOf(arg); rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("jsonrpc2: BindPositional requires non-nil pointers, got %T for argument %d", arg, i)
+		}
+	}
+
+	var items []json.RawMessage
+	trimmed := strings.TrimSpace(string(params))
+	switch {
+	case trimmed == "" || trimmed == "null":
+	case trimmed[0] != '[':
+		return Error{Code: ErrorInvalidParams, Message: "params must be given by position"}
+	default:
+		if err := json.Unmarshal(params, &items); err != nil {
+			return invalidParams("", err)
+		}
+	}
+
+	if len(items) != len(args) {
+		return Error{
+			Code:    ErrorInvalidParams,
+			Message: fmt.Sprintf("invalid params: expected %d params, got %d", len(args), len(items)),
+		}
+	}
+	for i, item := range items {
+		if err := json.Unmarshal(item, args[i]); err != nil {
+			return invalidParams(fmt.Sprintf("params[%d]", i), err)
+		}
+	}
+	return nil
+}
+
+// invalidParams converts an error from decoding params into an
+// ErrorInvalidParams Error with a message suitable for callers. path is
+// where in params the error happened, such as "params[1]", or empty for
+// params as a whole.
+func invalidParams(path string, err error) error {
+	var rpcErr Error
+	if errors.As(err, &rpcErr) {
+		return err
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		where := path
+		if typeErr.Field != "" {
+			where = strings.TrimPrefix(path+"."+typeErr.Field, ".")
+		}
+		msg := fmt.Sprintf("invalid params: expected %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value)
+		if where != "" {
+			msg = fmt.Sprintf("invalid params: %s must be %s, got %s", where, jsonTypeName(typeErr.Type), typeErr.Value)
+		}
+		return Error{Code: ErrorInvalidParams, Message: msg}
+	}
+	if path != "" {
+		return Error{Code: ErrorInvalidParams, Message: "invalid params: " + path + ": " + err.Error()}
+	}
+	return Error{Code: ErrorInvalidParams, Message: "invalid params: " + err.Error()}
+}
+
+// jsonTypeName describes the JSON values which decode into t.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "a base64 string"
+		}
+		return "an array"
+	case reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return t.String()
+	}
+}
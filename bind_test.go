@@ -0,0 +1,92 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindNamed(t *testing.T) {
+	var p transferParams
+	err := BindNamed(json.RawMessage(`{"from_account": "a", "to_account": "b", "amount": 5}`), &p)
+	require.NoError(t, err)
+	require.Equal(t, transferParams{From: "a", To: "b", Amount: 5}, p)
+
+	tt := []struct {
+		name    string
+		params  string
+		message string
+	}{
+		{"missing", `{"from_account": "a"}`, "missing required params: to_account, amount"},
+		{"no params", ``, "missing required params: from_account, to_account, amount"},
+		{"positional", `["a", "b", 5]`, "params must be given by name"},
+		{"wrong type", `{"from_account": "a", "to_account": "b", "amount": "5"}`, "invalid params: amount must be an integer, got string"},
+		{"wrong type renamed", `{"from_account": 1, "to_account": "b", "amount": 5}`, "invalid params: from_account must be a string, got number"},
+		{"malformed", `{"amount": }`, "invalid params: invalid character '}' looking for beginning of value"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var p transferParams
+			err := BindNamed(json.RawMessage(tc.params), &p)
+			var rpcErr Error
+			require.ErrorAs(t, err, &rpcErr)
+			require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+			require.Equal(t, tc.message, rpcErr.Message)
+		})
+	}
+
+	// Binding requires a pointer to a struct.
+	var nums []int
+	err = BindNamed(json.RawMessage(`{}`), &nums)
+	require.Error(t, err)
+	var rpcErr Error
+	require.False(t, errors.As(err, &rpcErr))
+}
+
+func TestBindPositional(t *testing.T) {
+	var (
+		a, b int
+		op   string
+	)
+	require.NoError(t, BindPositional(json.RawMessage(`[1, 2, "add"]`), &a, &b, &op))
+	require.Equal(t, 1, a)
+	require.Equal(t, 2, b)
+	require.Equal(t, "add", op)
+
+	require.NoError(t, BindPositional(nil))
+
+	var user struct {
+		Name string `json:"name"`
+	}
+	tt := []struct {
+		name    string
+		params  string
+		args    []interface{}
+		message string
+	}{
+		{"too few", `[1, 2]`, []interface{}{&a, &b, &op}, "invalid params: expected 3 params, got 2"},
+		{"too many", `[1, 2, "add", 4]`, []interface{}{&a, &b, &op}, "invalid params: expected 3 params, got 4"},
+		{"no params", ``, []interface{}{&a}, "invalid params: expected 1 params, got 0"},
+		{"named", `{"a": 1}`, []interface{}{&a}, "params must be given by position"},
+		{"wrong type", `[1, "2", "add"]`, []interface{}{&a, &b, &op}, "invalid params: params[1] must be an integer, got string"},
+		{"wrong field type", `[{"name": 5}]`, []interface{}{&user}, "invalid params: params[0].name must be a string, got number"},
+		{"malformed", `[1, }`, []interface{}{&a}, "invalid params: invalid character '}' looking for beginning of value"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := BindPositional(json.RawMessage(tc.params), tc.args...)
+			var rpcErr Error
+			require.ErrorAs(t, err, &rpcErr)
+			require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+			require.Equal(t, tc.message, rpcErr.Message)
+		})
+	}
+
+	// Arguments must be pointers.
+	err := BindPositional(json.RawMessage(`[1]`), a)
+	require.Error(t, err)
+	var rpcErr Error
+	require.False(t, errors.As(err, &rpcErr))
+}
@@ -0,0 +1,154 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServiceNotFound is returned when connecting to a name which isn't
+// registered with a Broker.
+var ErrServiceNotFound = errors.New("jsonrpc2: service not registered")
+
+// Broker lets components in the same process register handlers by name and
+// call each other without sockets, which is useful for modular programs and
+// for tests:
+//
+//	b := jsonrpc2.NewBroker()
+//	b.Register("users", usersMux)
+//
+//	conn := b.Conn("users")
+//	res, err := conn.Invoke(ctx, "get", 42)
+//
+// Conns returned by Conn call handlers directly. Use Dial instead to go
+// through a Client over an in-memory pipe, which exercises the full JSON
+// encoding and allows the handler to call back.
+type Broker struct {
+	mut      sync.RWMutex
+	services map[string]brokerService
+}
+
+type brokerService struct {
+	handler Handler
+	opts    []ClientOpt
+}
+
+// NewBroker creates an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{services: make(map[string]brokerService)}
+}
+
+// Register registers handler under name. opts are passed to the Client
+// serving each connection made with Dial. If name is already registered,
+// Register panics.
+func (b *Broker) Register(name string, handler Handler, opts ...ClientOpt) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if _, exist := b.services[name]; exist {
+		panic("service " + name + " already registered")
+	}
+	b.services[name] = brokerService{handler: handler, opts: opts}
+}
+
+// Unregister removes the handler registered under name. Calls through
+// existing Conns fail with ErrServiceNotFound, while Clients created with
+// Dial keep working until closed.
+func (b *Broker) Unregister(name string) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	delete(b.services, name)
+}
+
+func (b *Broker) lookup(name string) (brokerService, error) {
+	b.mut.RLock()
+	defer b.mut.RUnlock()
+
+	svc, ok := b.services[name]
+	if !ok {
+		return brokerService{}, ErrServiceNotFound
+	}
+	return svc, nil
+}
+
+// Conn returns a Conn which calls the handler registered under name. The
+// name is looked up on each call, so name doesn't need to be registered
+// yet.
+//
+// Params are encoded to JSON, since handlers receive them as JSON, but
+// messages are never framed or sent over a transport. Requests are handled
+// on the calling goroutine with the context passed to Invoke, including
+// metadata attached with WithMetadata, and Request.Client is nil.
+// Notifications are handled on a new goroutine.
+func (b *Broker) Conn(name string) Conn {
+	return &brokerConn{b: b, name: name}
+}
+
+// Dial creates a Client connected over an in-memory pipe to a new Client
+// serving the handler registered under name. handler is invoked for
+// requests the service sends back.
+func (b *Broker) Dial(name string, handler Handler, opts ...ClientOpt) (*Client, error) {
+	svc, err := b.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	srvConn, cliConn := net.Pipe()
+	NewClient(srvConn, svc.handler, svc.opts...)
+	return NewClient(cliConn, handler, opts...), nil
+}
+
+type brokerConn struct {
+	b    *Broker
+	name string
+}
+
+func (c *brokerConn) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	r, h, err := c.request(ctx, method, msg)
+	if err != nil {
+		return nil, err
+	}
+	r.ID = NewNumberID(0)
+	resp := serveRequest(h, r, r.ID)
+	if resp.Error != nil {
+		return nil, *resp.Error
+	}
+	return resp.Result, nil
+}
+
+func (c *brokerConn) Notify(method string, msg interface{}) error {
+	r, h, err := c.request(context.Background(), method, msg)
+	if err != nil {
+		return err
+	}
+	r.Notification = true
+	go h.ServeRPC(notificationWriter{}, r)
+	return nil
+}
+
+func (c *brokerConn) request(ctx context.Context, method string, msg interface{}) (*Request, Handler, error) {
+	svc, err := c.b.lookup(c.name)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := svc.handler
+	if h == nil {
+		h = DefaultHandler
+	}
+	return &Request{
+		Method: method,
+		Params: body,
+		Meta:   MetadataFromContext(ctx),
+
+		ctx:      ctx,
+		received: time.Now(),
+	}, h, nil
+}
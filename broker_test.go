@@ -0,0 +1,75 @@
+package jsonrpc2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroker(t *testing.T) {
+	notified := make(chan string, 1)
+	mux := NewServeMux()
+	mux.HandleFunc("echo", func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Params)
+	})
+	mux.HandleFunc("whoami", func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Meta.Get("identity"))
+	})
+	mux.HandleFunc("log", func(w ResponseWriter, r *Request) {
+		notified <- string(r.Params)
+	})
+
+	b := NewBroker()
+	b.Register("svc", mux)
+	require.Panics(t, func() { b.Register("svc", mux) })
+
+	conn := b.Conn("svc")
+	res, err := conn.Invoke(context.Background(), "echo", []int{1, 2})
+	require.NoError(t, err)
+	require.Equal(t, "[1,2]", string(res))
+
+	ctx := WithMetadata(context.Background(), Metadata{"identity": "alice"})
+	res, err = conn.Invoke(ctx, "whoami", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"alice"`, string(res))
+
+	_, err = conn.Invoke(context.Background(), "missing", nil)
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorMethodNotFound, rpcErr.Code)
+
+	require.NoError(t, conn.Notify("log", "hello"))
+	require.Equal(t, `"hello"`, <-notified)
+
+	b.Unregister("svc")
+	_, err = conn.Invoke(context.Background(), "echo", nil)
+	require.Equal(t, ErrServiceNotFound, err)
+	require.Equal(t, ErrServiceNotFound, b.Conn("other").Notify("log", nil))
+}
+
+func TestBroker_Dial(t *testing.T) {
+	b := NewBroker()
+	b.Register("svc", HandlerFunc(func(w ResponseWriter, r *Request) {
+		// Call back to the caller.
+		res, err := r.Client.Invoke(r.Context(), "name", nil)
+		if err != nil {
+			_ = w.WriteError(ErrorInternal, err)
+			return
+		}
+		_ = w.WriteMessage(res)
+	}))
+
+	cli, err := b.Dial("svc", HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage("caller")
+	}))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "hello", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"caller"`, string(res))
+
+	_, err = b.Dial("other", nil)
+	require.Equal(t, ErrServiceNotFound, err)
+}
@@ -0,0 +1,152 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+// busyData is the data of a busy error.
+type busyData struct {
+	RetryAfterMs int64 `json:"retryAfterMs"`
+	Load         *Load `json:"load,omitempty"`
+}
+
+// Load is a snapshot of a server's load, which servers may send with busy
+// errors so that callers and operators can tell overload apart from network
+// problems.
+type Load struct {
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heapBytes"`
+
+	// InFlight is the number of requests being handled, as counted by the
+	// server.
+	InFlight int64 `json:"inFlight"`
+}
+
+// heapMetric is the runtime metric read for Load.HeapBytes. It is read
+// with runtime/metrics rather than runtime.ReadMemStats, which stops the
+// world and would add to the load of an overloaded server.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// ReadLoad returns a snapshot of the current process's load, with inFlight
+// as the number of requests being handled.
+func ReadLoad(inFlight int64) Load {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+
+	load := Load{Goroutines: runtime.NumGoroutine(), InFlight: inFlight}
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		load.HeapBytes = sample[0].Value.Uint64()
+	}
+	return load
+}
+
+// NewBusyError returns an Error asking the caller to retry after retryAfter.
+// Handlers reply with it using WriteError:
+//
+//	w.WriteError(jsonrpc2.ErrorServerBusy, jsonrpc2.NewBusyError(time.Second))
+//
+// The error is sent as:
+//
+//	{"code": -32000, "message": "server busy", "data": {"retryAfterMs": 1000}}
+func NewBusyError(retryAfter time.Duration) Error {
+	data, _ := json.Marshal(busyData{RetryAfterMs: int64(retryAfter / time.Millisecond)})
+	return Error{Code: ErrorServerBusy, Message: "server busy", Data: data}
+}
+
+// NewBusyErrorWithLoad is like NewBusyError, but also sends load, such as
+// from ReadLoad, in the error's data:
+//
+//	{"code": -32000, "message": "server busy", "data": {"retryAfterMs": 1000, "load": {"goroutines": 5120, "heapBytes": 734003200, "inFlight": 4096}}}
+//
+// Callers read it with BusyLoad. Sending load reveals details of the server
+// to callers, so servers should only opt in when callers are trusted.
+func NewBusyErrorWithLoad(retryAfter time.Duration, load Load) Error {
+	data, _ := json.Marshal(busyData{RetryAfterMs: int64(retryAfter / time.Millisecond), Load: &load})
+	return Error{Code: ErrorServerBusy, Message: "server busy", Data: data}
+}
+
+// BusyLoad returns the load sent with a busy error returned by Invoke. ok is
+// false if err is not a busy error or has no load.
+func BusyLoad(err error) (load Load, ok bool) {
+	var rpcErr Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrorServerBusy {
+		return Load{}, false
+	}
+	var data busyData
+	if err := json.Unmarshal(rpcErr.Data, &data); err != nil || data.Load == nil {
+		return Load{}, false
+	}
+	return *data.Load, true
+}
+
+// RetryAfter returns how long a busy error returned by Invoke asked the
+// caller to wait before retrying. ok is false if err is not a busy error.
+func RetryAfter(err error) (d time.Duration, ok bool) {
+	var rpcErr Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrorServerBusy {
+		return 0, false
+	}
+	var data busyData
+	if err := json.Unmarshal(rpcErr.Data, &data); err != nil || data.RetryAfterMs < 0 {
+		return 0, false
+	}
+	return time.Duration(data.RetryAfterMs) * time.Millisecond, true
+}
+
+// BusyRetrier is a Conn which retries calls that the server rejected with a
+// busy error, waiting as long as the server asked before each retry. Calls
+// fail fast with the busy error if waiting would outlive the deadline of
+// the call's context. Calls made with WithNoRetry are never retried.
+type BusyRetrier struct {
+	// Conn is the connection to invoke calls against.
+	Conn Conn
+
+	// MaxRetries is the maximum number of times a call is retried. If zero,
+	// calls are retried up to 3 times.
+	MaxRetries int
+
+	// Clock is used to wait between retries. If nil, SystemClock is used.
+	Clock Clock
+}
+
+var _ Conn = (*BusyRetrier)(nil)
+
+// Invoke implements Conn.
+func (r *BusyRetrier) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	maxRetries := r.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+	clock := r.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := r.Conn.Invoke(ctx, method, msg)
+		wait, busy := RetryAfter(err)
+		if !busy || attempt >= maxRetries || NoRetry(ctx) {
+			return res, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && clock.Now().Add(wait).After(deadline) {
+			return res, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// Notify implements Conn. Notifications have no response, so they are never
+// retried.
+func (r *BusyRetrier) Notify(method string, msg interface{}) error {
+	return r.Conn.Notify(method, msg)
+}
@@ -0,0 +1,107 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusyError(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteError(ErrorServerBusy, NewBusyError(1500*time.Millisecond))
+	}))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	_, err := cli.Invoke(context.Background(), "work", nil)
+	d, ok := RetryAfter(err)
+	require.True(t, ok)
+	require.Equal(t, 1500*time.Millisecond, d)
+
+	_, ok = RetryAfter(Error{Code: ErrorInternal})
+	require.False(t, ok)
+	_, ok = RetryAfter(errors.New("busy"))
+	require.False(t, ok)
+}
+
+func TestBusyErrorWithLoad(t *testing.T) {
+	load := Load{Goroutines: 5120, HeapBytes: 734003200, InFlight: 4096}
+	err := NewBusyErrorWithLoad(time.Second, load)
+	require.JSONEq(t, `{"retryAfterMs": 1000, "load": {"goroutines": 5120, "heapBytes": 734003200, "inFlight": 4096}}`, string(err.Data))
+
+	got, ok := BusyLoad(err)
+	require.True(t, ok)
+	require.Equal(t, load, got)
+	d, ok := RetryAfter(err)
+	require.True(t, ok)
+	require.Equal(t, time.Second, d)
+
+	// Load is opt-in.
+	_, ok = BusyLoad(NewBusyError(time.Second))
+	require.False(t, ok)
+	_, ok = BusyLoad(Error{Code: ErrorInternal})
+	require.False(t, ok)
+}
+
+func TestReadLoad(t *testing.T) {
+	load := ReadLoad(3)
+	require.NotZero(t, load.Goroutines)
+	require.NotZero(t, load.HeapBytes)
+	require.Equal(t, int64(3), load.InFlight)
+}
+
+// busyConn is a Conn which fails with a busy error until it has been
+// called busy times.
+type busyConn struct {
+	busy  int
+	wait  time.Duration
+	calls int
+}
+
+func (c *busyConn) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	c.calls++
+	if c.calls <= c.busy {
+		return nil, NewBusyError(c.wait)
+	}
+	return json.RawMessage(`"ok"`), nil
+}
+
+func (c *busyConn) Notify(method string, msg interface{}) error { return nil }
+
+func TestBusyRetrier(t *testing.T) {
+	t.Run("retries", func(t *testing.T) {
+		conn := &busyConn{busy: 2, wait: time.Millisecond}
+		res, err := (&BusyRetrier{Conn: conn}).Invoke(context.Background(), "work", nil)
+		require.NoError(t, err)
+		require.Equal(t, `"ok"`, string(res))
+		require.Equal(t, 3, conn.calls)
+	})
+
+	t.Run("max retries", func(t *testing.T) {
+		conn := &busyConn{busy: 5, wait: time.Millisecond}
+		_, err := (&BusyRetrier{Conn: conn, MaxRetries: 2}).Invoke(context.Background(), "work", nil)
+		_, busy := RetryAfter(err)
+		require.True(t, busy)
+		require.Equal(t, 3, conn.calls)
+	})
+
+	t.Run("fails fast past deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		conn := &busyConn{busy: 1, wait: time.Hour}
+		start := time.Now()
+		_, err := (&BusyRetrier{Conn: conn}).Invoke(ctx, "work", nil)
+		_, busy := RetryAfter(err)
+		require.True(t, busy)
+		require.Equal(t, 1, conn.calls)
+		require.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+}
@@ -0,0 +1,157 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Call is an asynchronous call started with Client.Go.
+type Call struct {
+	Method string
+	Params interface{}
+
+	// Result and Err are set once the call completes, before the Call is
+	// sent on Done.
+	Result json.RawMessage
+	Err    error
+
+	// Done receives the Call once it completes. It is buffered, so the
+	// Client never blocks sending on it.
+	Done chan *Call
+
+	finished chan struct{}
+}
+
+func (call *Call) finish(res json.RawMessage, err error) {
+	call.Result, call.Err = res, err
+	close(call.finished)
+	call.Done <- call
+}
+
+// Go invokes method asynchronously and returns the Call, which is sent on
+// its Done channel once it completes, so callers can make many calls at
+// once and wait for them together:
+//
+//	a := cli.Go(ctx, "getUser", "alice")
+//	b := cli.Go(ctx, "getUser", "bob")
+//	for _, call := range []*jsonrpc2.Call{<-a.Done, <-b.Done} {
+//		if call.Err != nil { ... }
+//	}
+//
+// Calls are completed by the Client's read loop rather than by a goroutine
+// per call. A goroutine is only started to watch ctx if it can be
+// cancelled, or to run the call through interceptors, call tags, and the
+// RetryPolicy, which need to wait for the result.
+func (c *Client) Go(ctx context.Context, method string, params interface{}) *Call {
+	call := &Call{
+		Method:   method,
+		Params:   params,
+		Done:     make(chan *Call, 1),
+		finished: make(chan struct{}),
+	}
+
+	if len(c.interceptors) > 0 || len(CallTagsFromContext(ctx)) > 0 || c.retryPolicy != nil {
+		go func() { call.finish(c.Invoke(ctx, method, params)) }()
+		return call
+	}
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		call.finish(nil, err)
+		return call
+	}
+
+	cancel := func() {}
+	if d, ok := c.callTimeout(ctx, method); ok {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	}
+
+	var meta Metadata
+	if c.sendMeta {
+		meta = requestMetadata(ctx, c.clock.Now())
+	}
+
+	msgID, err := c.callID(ctx)
+	if err != nil {
+		cancel()
+		call.finish(nil, err)
+		return call
+	}
+	if err := c.storeCall(msgID, call); err != nil {
+		cancel()
+		call.finish(nil, err)
+		return call
+	}
+
+	err = c.send(txMessage{
+		Objects: []*txObject{{
+			Request: &txRequest{
+				ID:     msgID,
+				Method: method,
+				Params: body,
+				Meta:   meta,
+			},
+		}},
+	})
+	if err != nil {
+		cancel()
+		c.completeCall(msgID, nil, err)
+		return call
+	}
+
+	if ctx.Done() == nil {
+		cancel()
+		return call
+	}
+	go func() {
+		defer cancel()
+		select {
+		case <-call.finished:
+		case <-ctx.Done():
+			if c.completeCall(msgID, nil, ctx.Err()) {
+				c.sendCancel(msgID)
+			}
+		}
+	}()
+	return call
+}
+
+// storeCall registers call as the listener for responses to id. It fails
+// if the read loop has exited, since no response would be delivered.
+func (c *Client) storeCall(id ID, call *Call) error {
+	c.callsMut.Lock()
+	defer c.callsMut.Unlock()
+	if c.callsClosed {
+		return ErrConnClosed
+	}
+	if _, inUse := c.listeners.LoadOrStore(id, call); inUse {
+		return fmt.Errorf("request id %s is already in use", id)
+	}
+	return nil
+}
+
+// completeCall completes the Call waiting for id, if it is still waiting.
+// The listener entry decides which of the response, cancellation, and
+// closing completes the call, and completeCall reports whether it did.
+func (c *Client) completeCall(id ID, res json.RawMessage, err error) bool {
+	lis, ok := c.listeners.LoadAndDelete(id)
+	if ok {
+		lis.(*Call).finish(res, err)
+	}
+	return ok
+}
+
+// failCalls completes the Calls still waiting once the read loop exits.
+func (c *Client) failCalls() {
+	c.callsMut.Lock()
+	c.callsClosed = true
+	c.callsMut.Unlock()
+
+	c.listeners.Range(func(key, value interface{}) bool {
+		if _, ok := value.(*Call); ok {
+			c.completeCall(key.(ID), nil, ErrConnClosed)
+		}
+		return true
+	})
+}
@@ -0,0 +1,96 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Go(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("double", Method(func(ctx context.Context, n int) (int, error) { return n * 2, nil }))
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	calls := make([]*Call, 10)
+	for i := range calls {
+		calls[i] = cli.Go(context.Background(), "double", i)
+	}
+	for i, call := range calls {
+		require.Same(t, call, <-call.Done)
+		require.NoError(t, call.Err)
+		require.Equal(t, strconv.Itoa(i*2), string(call.Result))
+	}
+
+	call := <-cli.Go(context.Background(), "missing", nil).Done
+	var rpcErr Error
+	require.ErrorAs(t, call.Err, &rpcErr)
+	require.Equal(t, ErrorMethodNotFound, rpcErr.Code)
+}
+
+func TestClient_Go_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		<-release
+		_ = w.WriteMessage(true)
+	}))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	call := cli.Go(ctx, "wait", nil)
+	cancel()
+	require.ErrorIs(t, (<-call.Done).Err, context.Canceled)
+
+	// The late response is discarded.
+	close(release)
+	res, err := cli.Invoke(context.Background(), "wait", nil)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(res))
+	require.Empty(t, call.Done)
+}
+
+func TestClient_Go_Closed(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+
+	call := cli.Go(context.Background(), "wait", nil)
+	require.NoError(t, cli.Close())
+	require.ErrorIs(t, (<-call.Done).Err, ErrConnClosed)
+
+	// Calls started once closed fail immediately.
+	require.ErrorIs(t, (<-cli.Go(context.Background(), "wait", nil).Done).Err, ErrConnClosed)
+}
+
+func TestClient_Go_Interceptor(t *testing.T) {
+	var intercepted []string
+	record := func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error) {
+		intercepted = append(intercepted, call.Method)
+		return invoker(ctx, call)
+	}
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage("ok") }))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithClientInterceptor(record))
+	defer cli.Close()
+
+	call := <-cli.Go(context.Background(), "ping", nil).Done
+	require.NoError(t, call.Err)
+	require.Equal(t, `"ok"`, string(call.Result))
+	require.Equal(t, []string{"ping"}, intercepted)
+}
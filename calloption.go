@@ -0,0 +1,142 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CallOption overrides the Client's defaults for individual calls.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout time.Duration
+	id      ID
+	noRetry bool
+	meta    Metadata
+}
+
+// WithCallTimeout bounds how long the call waits for a response, replacing
+// any timeout set for its method with WithMethodTimeouts.
+func WithCallTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithCallID sends the call with id instead of one from the Client's
+// IDGenerator. The ID must not be in use by another pending call, so the
+// option should only be used for a single call.
+func WithCallID(id ID) CallOption {
+	return func(o *callOptions) {
+		o.id = id
+	}
+}
+
+// WithNoRetry stops the call from being retried by a Client's RetryPolicy
+// or by wrappers which retry calls, such as BusyRetrier.
+func WithNoRetry() CallOption {
+	return func(o *callOptions) {
+		o.noRetry = true
+	}
+}
+
+// WithCallMetadata sends md with the call, merged into any metadata from
+// the call's context as by WithMetadata. Like all metadata, it is only sent by Clients created
+// with WithRequestMetadata(true).
+func WithCallMetadata(md Metadata) CallOption {
+	return func(o *callOptions) {
+		merged := make(Metadata, len(o.meta)+len(md))
+		for k, v := range o.meta {
+			merged[k] = v
+		}
+		for k, v := range md {
+			merged[k] = v
+		}
+		o.meta = merged
+	}
+}
+
+type callOptionsKey struct{}
+
+// WithCallOptions returns a copy of ctx which applies opts to calls made
+// with it. Options are carried by the context so that they work through
+// any Conn, including wrappers such as BusyRetrier and ReconnectingClient:
+//
+//	ctx := jsonrpc2.WithCallOptions(ctx, jsonrpc2.WithCallTimeout(time.Second), jsonrpc2.WithNoRetry())
+//	res, err := conn.Invoke(ctx, "charge", params)
+//
+// Options apply to every call made with the returned context, and are
+// applied after any options already held by ctx.
+func WithCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	o := callOptionsFromContext(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// Metadata is carried as the context's metadata, so it is sent like
+	// metadata attached with WithMetadata.
+	if o.meta != nil {
+		ctx = WithMetadata(ctx, o.meta)
+		o.meta = nil
+	}
+	return context.WithValue(ctx, callOptionsKey{}, o)
+}
+
+func callOptionsFromContext(ctx context.Context) callOptions {
+	o, _ := ctx.Value(callOptionsKey{}).(callOptions)
+	return o
+}
+
+// NoRetry reports whether calls made with ctx must not be retried, as set
+// by WithNoRetry. Wrappers which retry calls should check it.
+func NoRetry(ctx context.Context) bool {
+	return callOptionsFromContext(ctx).noRetry
+}
+
+// InvokeWith is like Invoke, but applies opts to the call. It is the same as
+// calling Invoke with WithCallOptions(ctx, opts...).
+func (c *Client) InvokeWith(ctx context.Context, method string, msg interface{}, opts ...CallOption) (json.RawMessage, error) {
+	return c.Invoke(WithCallOptions(ctx, opts...), method, msg)
+}
+
+// NotifyWith is like Notify, but applies opts to the notification.
+// Notifications have no ID or response, so only WithCallMetadata has an
+// effect.
+func (c *Client) NotifyWith(method string, msg interface{}, opts ...CallOption) error {
+	return c.notifyContext(WithCallOptions(context.Background(), opts...), method, msg)
+}
+
+// callTimeout returns the timeout for a call to method made with ctx.
+func (c *Client) callTimeout(ctx context.Context, method string) (time.Duration, bool) {
+	if d := callOptionsFromContext(ctx).timeout; d > 0 {
+		return d, true
+	}
+	if d, ok := c.methodTimeouts[method]; ok {
+		return d, true
+	}
+	return c.defaultCallTimeout(ctx)
+}
+
+// defaultCallTimeout returns the Client's default timeout if ctx has no
+// deadline.
+func (c *Client) defaultCallTimeout(ctx context.Context) (time.Duration, bool) {
+	if c.defaultTimeout <= 0 {
+		return 0, false
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return 0, false
+	}
+	return c.defaultTimeout, true
+}
+
+// callID returns the ID for a call made with ctx.
+func (c *Client) callID(ctx context.Context) (ID, error) {
+	if id := callOptionsFromContext(ctx).id; !id.IsUndefined() {
+		if id.IsNull() {
+			return ID{}, fmt.Errorf("WithCallID can't be used with a null id")
+		}
+		return id, nil
+	}
+	return c.newRequestID()
+}
@@ -0,0 +1,77 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_InvokeWith(t *testing.T) {
+	type seenRequest struct {
+		id   ID
+		meta Metadata
+	}
+	seen := make(chan seenRequest, 1)
+	mux := NewServeMux()
+	mux.HandleFunc("record", func(w ResponseWriter, r *Request) {
+		seen <- seenRequest{id: r.ID, meta: r.Meta}
+		if !r.Notification {
+			_ = w.WriteMessage(true)
+		}
+	})
+	mux.HandleFunc("slow", func(w ResponseWriter, r *Request) {
+		<-r.Context().Done()
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil,
+		WithRequestMetadata(true),
+		WithMethodTimeouts(map[string]time.Duration{"slow": time.Hour}))
+	defer cli.Close()
+
+	ctx := WithMetadata(context.Background(), Metadata{"identity": "alice"})
+
+	_, err := cli.InvokeWith(ctx, "record", nil,
+		WithCallID(NewStringID("charge-42")),
+		WithCallMetadata(Metadata{"tenant": "a"}))
+	require.NoError(t, err)
+	req := <-seen
+	require.Equal(t, NewStringID("charge-42"), req.id)
+	require.Equal(t, Metadata{"identity": "alice", "tenant": "a"}, req.meta)
+
+	// Later calls use the Client's IDGenerator again.
+	_, err = cli.Invoke(ctx, "record", nil)
+	require.NoError(t, err)
+	require.True(t, (<-seen).id.IsNumber())
+
+	require.NoError(t, cli.NotifyWith("record", nil, WithCallMetadata(Metadata{"tenant": "b"})))
+	require.Equal(t, Metadata{"tenant": "b"}, (<-seen).meta)
+
+	// The call timeout replaces the method's timeout.
+	start := time.Now()
+	_, err = cli.InvokeWith(context.Background(), "slow", nil, WithCallTimeout(20*time.Millisecond))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Minute)
+
+	_, err = cli.InvokeWith(context.Background(), "record", nil, WithCallID(NewNullID()))
+	require.Error(t, err)
+}
+
+func TestWithCallOptions_NoRetry(t *testing.T) {
+	conn := &busyConn{busy: 1}
+	r := &BusyRetrier{Conn: conn, Clock: &manualClock{}}
+
+	ctx := WithCallOptions(context.Background(), WithNoRetry())
+	require.True(t, NoRetry(ctx))
+	require.False(t, NoRetry(context.Background()))
+
+	_, err := r.Invoke(ctx, "work", nil)
+	_, busy := RetryAfter(err)
+	require.True(t, busy)
+	require.Equal(t, 1, conn.calls)
+}
@@ -0,0 +1,140 @@
+package jsonrpc2
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// CallTags are key-value pairs describing the caller of an outgoing call,
+// such as the subsystem making it. Unlike Metadata, tags are never sent to
+// the peer; they're only seen by ClientInterceptors and counted in
+// Client.TagStats.
+type CallTags map[string]string
+
+type callTagsKey struct{}
+
+// WithCallTag returns a copy of ctx which tags calls made with it with key
+// and value, so that load on a shared Client can be attributed to parts of
+// an application:
+//
+//	ctx = jsonrpc2.WithCallTag(ctx, "component", "indexer")
+//	res, err := cli.Invoke(ctx, "search", params)
+//
+// Tags apply to calls made with Invoke and Batch.Commit. If ctx already has
+// a tag for key, it is replaced.
+func WithCallTag(ctx context.Context, key, value string) context.Context {
+	old := CallTagsFromContext(ctx)
+	tags := make(CallTags, len(old)+1)
+	for k, v := range old {
+		tags[k] = v
+	}
+	tags[key] = value
+	return context.WithValue(ctx, callTagsKey{}, tags)
+}
+
+// CallTagsFromContext returns the tags attached to ctx with WithCallTag, or
+// nil if there are none. The returned map must not be modified.
+func CallTagsFromContext(ctx context.Context) CallTags {
+	tags, _ := ctx.Value(callTagsKey{}).(CallTags)
+	return tags
+}
+
+// maxTagStats limits the number of distinct tags counted by a Client, so
+// that tags with unbounded values don't grow its stats forever.
+const maxTagStats = 1024
+
+// TagStats counts the calls made with a tag.
+type TagStats struct {
+	Key, Value string
+
+	// Calls is the number of calls made with the tag, counting each batch
+	// committed as one call.
+	Calls int64
+
+	// Errors is the number of calls which failed.
+	Errors int64
+
+	// Pending is the number of calls waiting for a response.
+	Pending int64
+}
+
+// TagStats returns the stats for each tag used in calls made by c, sorted
+// by key and then value. Only the first 1024 distinct tags are counted.
+func (c *Client) TagStats() []TagStats {
+	return c.tagStats.snapshot()
+}
+
+type tagPair struct{ key, value string }
+
+type tagCounters struct {
+	calls, errors, pending atomic.Int64
+}
+
+// tagStats counts calls by tag.
+type tagStats struct {
+	mut      sync.Mutex
+	counters map[tagPair]*tagCounters
+}
+
+// start counts the start of a call with tags, returning the counters to
+// pass to end once it completes.
+func (s *tagStats) start(tags CallTags) []*tagCounters {
+	counters := make([]*tagCounters, 0, len(tags))
+	s.mut.Lock()
+	if s.counters == nil {
+		s.counters = make(map[tagPair]*tagCounters)
+	}
+	for k, v := range tags {
+		tc, ok := s.counters[tagPair{k, v}]
+		if !ok {
+			if len(s.counters) >= maxTagStats {
+				continue
+			}
+			tc = &tagCounters{}
+			s.counters[tagPair{k, v}] = tc
+		}
+		counters = append(counters, tc)
+	}
+	s.mut.Unlock()
+
+	for _, tc := range counters {
+		tc.calls.Inc()
+		tc.pending.Inc()
+	}
+	return counters
+}
+
+func (s *tagStats) end(counters []*tagCounters, err error) {
+	for _, tc := range counters {
+		tc.pending.Dec()
+		if err != nil {
+			tc.errors.Inc()
+		}
+	}
+}
+
+func (s *tagStats) snapshot() []TagStats {
+	s.mut.Lock()
+	stats := make([]TagStats, 0, len(s.counters))
+	for pair, tc := range s.counters {
+		stats = append(stats, TagStats{
+			Key:     pair.key,
+			Value:   pair.value,
+			Calls:   tc.calls.Load(),
+			Errors:  tc.errors.Load(),
+			Pending: tc.pending.Load(),
+		})
+	}
+	s.mut.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Key != stats[j].Key {
+			return stats[i].Key < stats[j].Key
+		}
+		return stats[i].Value < stats[j].Value
+	})
+	return stats
+}
@@ -0,0 +1,73 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCallTag(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, CallTagsFromContext(ctx))
+
+	tagged := WithCallTag(ctx, "component", "indexer")
+	retagged := WithCallTag(WithCallTag(tagged, "component", "search"), "tenant", "a")
+	require.Equal(t, CallTags{"component": "indexer"}, CallTagsFromContext(tagged))
+	require.Equal(t, CallTags{"component": "search", "tenant": "a"}, CallTagsFromContext(retagged))
+}
+
+func TestClient_TagStats(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("ok", func(w ResponseWriter, r *Request) { _ = w.WriteMessage(true) })
+	mux.HandleFunc("fail", func(w ResponseWriter, r *Request) { _ = w.WriteError(ErrorInternal, errors.New("failed")) })
+
+	var seen []CallTags
+	record := func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error) {
+		seen = append(seen, call.Tags)
+		return invoker(ctx, call)
+	}
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithClientInterceptor(record))
+	defer cli.Close()
+
+	indexer := WithCallTag(context.Background(), "component", "indexer")
+	api := WithCallTag(context.Background(), "component", "api")
+
+	_, err := cli.Invoke(indexer, "ok", nil)
+	require.NoError(t, err)
+	_, err = cli.Invoke(indexer, "fail", nil)
+	require.Error(t, err)
+	_, err = cli.Invoke(context.Background(), "ok", nil)
+	require.NoError(t, err)
+
+	b := cli.Batch()
+	_, err = b.Invoke("ok", nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Commit(api))
+
+	require.Equal(t, []TagStats{
+		{Key: "component", Value: "api", Calls: 1},
+		{Key: "component", Value: "indexer", Calls: 2, Errors: 1},
+	}, cli.TagStats())
+	require.Equal(t, []CallTags{
+		{"component": "indexer"},
+		{"component": "indexer"},
+		nil,
+		{"component": "api"},
+	}, seen)
+}
+
+func TestClient_TagStats_Limit(t *testing.T) {
+	var s tagStats
+	for i := 0; i < maxTagStats+10; i++ {
+		s.end(s.start(CallTags{"id": string(rune('a' + i))}), nil)
+	}
+	require.Len(t, s.snapshot(), maxTagStats)
+}
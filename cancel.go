@@ -0,0 +1,119 @@
+package jsonrpc2
+
+import "context"
+
+// WithBaseContext sets the parent of the Client's Context. The Client is
+// closed once ctx is done, cancelling the contexts of the requests it's
+// handling. Servers set it to the context of each connection; see
+// Server.BaseContext and Server.ConnContext.
+func WithBaseContext(ctx context.Context) ClientOpt {
+	return func(c *Client) {
+		c.baseCtx = ctx
+	}
+}
+
+// Context returns the Client's context, which is the parent of the contexts
+// of requests it receives. It is cancelled when the Client closes, and
+// derives from the context set by WithBaseContext, if any.
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// watchBaseContext closes c once its base context is done.
+func (c *Client) watchBaseContext() {
+	if c.baseCtx == nil || c.baseCtx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-c.baseCtx.Done():
+			LevelDebug.Log(c.log, "msg", "closing client after base context was done", "err", c.baseCtx.Err())
+			_ = c.closeTransport()
+		case <-c.done:
+		}
+	}()
+}
+
+// MethodCancelRequest is the notification sent and handled by Clients
+// created with WithCancelRequests to cancel a request. Its params are
+// CancelParams.
+const MethodCancelRequest = "rpc.cancel"
+
+// CancelParams are the params of MethodCancelRequest.
+type CancelParams struct {
+	// ID is the ID of the request to cancel.
+	ID ID `json:"id"`
+}
+
+// WithCancelRequests propagates cancellation between the two sides of a
+// connection, which must both use it. When the context of a call made with
+// Invoke or Go is done before its response arrives, a MethodCancelRequest
+// notification is sent to the other side. When one is received, the
+// context of the request it names is cancelled, so its handler can stop
+// early. The handler's response is still sent, and is dropped by the
+// caller.
+//
+// Cancellations for requests which aren't running, such as ones which
+// already finished, are ignored.
+func WithCancelRequests() ClientOpt {
+	return func(c *Client) {
+		c.cancelRequests = true
+	}
+}
+
+// trackRequests cancels the requests named by MethodCancelRequest
+// notifications in batch, which are removed from it, and gives the batch's
+// other requests their own context so that they can be cancelled. It runs
+// in the read loop, so requests are tracked before a cancellation for them
+// can be read.
+func (c *Client) trackRequests(batch txMessage) txMessage {
+	objs := batch.Objects[:0]
+	for _, obj := range batch.Objects {
+		switch req := obj.Request; {
+		case req == nil:
+		case req.Notification && req.Method == MethodCancelRequest:
+			var params CancelParams
+			if err := decodeParams(req.Params, &params); err != nil {
+				LevelDebug.Log(c.log, "msg", "dropping invalid cancellation", "err", err)
+				continue
+			}
+			if cancel, ok := c.running.Load(params.ID); ok {
+				cancel.(context.CancelFunc)()
+			}
+			continue
+		case !req.Notification:
+			ctx, cancel := context.WithCancel(c.ctx)
+			if _, dup := c.running.LoadOrStore(req.ID, cancel); dup {
+				// Only the first of requests sharing an ID can be cancelled.
+				cancel()
+				break
+			}
+			req.ctx, req.cancel = ctx, cancel
+		}
+		objs = append(objs, obj)
+	}
+	batch.Objects = objs
+	return batch
+}
+
+// untrackRequest stops tracking req once its handler has returned.
+func (c *Client) untrackRequest(req *txRequest) {
+	if req.cancel == nil {
+		return
+	}
+	c.running.Delete(req.ID)
+	req.cancel()
+}
+
+// sendCancel tells the other side to cancel the request with the given ID,
+// whose caller gave up waiting for it.
+func (c *Client) sendCancel(id ID) {
+	if !c.cancelRequests {
+		return
+	}
+	go func() {
+		if err := c.Notify(MethodCancelRequest, CancelParams{ID: id}); err != nil {
+			LevelDebug.Log(c.log, "msg", "failed to send cancellation", "id", id, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,132 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey string
+
+func TestServer_Contexts(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serverCtx := make(chan context.Context, 1)
+	requests := make(chan *Request, 1)
+	srv := Server{
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), ctxKey("server"), "base")
+		},
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			serverCtx <- ctx
+			return context.WithValue(ctx, ctxKey("conn"), conn.RemoteAddr().String())
+		},
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			requests <- r
+			<-r.Context().Done()
+		}),
+	}
+	go srv.Serve(lis)
+
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+	go func() { _, _ = cli.Invoke(context.Background(), "wait", nil) }()
+
+	var r *Request
+	select {
+	case r = <-requests:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "request not received")
+	}
+
+	// Each level sees the values of the levels above it.
+	for _, ctx := range []context.Context{r.Client.Context(), r.Context()} {
+		require.Equal(t, "base", ctx.Value(ctxKey("server")))
+		require.NotEmpty(t, ctx.Value(ctxKey("conn")))
+	}
+	require.NoError(t, r.Context().Err())
+
+	require.NoError(t, srv.Close())
+	for _, ctx := range []context.Context{<-serverCtx, r.Client.Context(), r.Context()} {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "context not cancelled by Close")
+		}
+	}
+}
+
+func TestWithBaseContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("k"), "v"))
+	defer cancel()
+
+	srvConn, cliConn := net.Pipe()
+	defer srvConn.Close()
+	cli := NewClient(cliConn, nil, WithBaseContext(ctx))
+	defer cli.Close()
+	require.Equal(t, "v", cli.Context().Value(ctxKey("k")))
+
+	cancel()
+	select {
+	case <-cli.Done():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "client not closed after base context was done")
+	}
+	require.Error(t, cli.Context().Err())
+}
+
+func TestWithCancelRequests(t *testing.T) {
+	started := make(chan struct{}, 1)
+	cancelled := make(chan error, 1)
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			cancelled <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+	}), WithCancelRequests())
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithCancelRequests())
+	defer cli.Close()
+
+	calls := map[string]func(ctx context.Context) error{
+		"Invoke": func(ctx context.Context) error {
+			_, err := cli.Invoke(ctx, "wait", nil)
+			return err
+		},
+		"Go": func(ctx context.Context) error {
+			return (<-cli.Go(ctx, "wait", nil).Done).Err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				<-started
+				cancel()
+			}()
+			require.ErrorIs(t, call(ctx), context.Canceled)
+			require.ErrorIs(t, <-cancelled, context.Canceled)
+		})
+	}
+
+	// The connection is still usable, and finished requests are no longer
+	// tracked.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _ = cli.Invoke(ctx, "wait", nil)
+	require.ErrorIs(t, <-cancelled, context.Canceled)
+	require.Eventually(t, func() bool {
+		n := 0
+		srv.running.Range(func(_, _ interface{}) bool { n++; return true })
+		return n == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,241 @@
+// Package capture records JSON-RPC 2.0 sessions to a portable file format,
+// so sessions can be shared in bug reports, inspected with the capview
+// command, and replayed against a handler with jsonrpc2test.Contract.
+//
+// Sessions are recorded by wrapping the Framer of a Client:
+//
+//	w, err := capture.NewWriter(file)
+//	f := capture.NewFramer(jsonrpc2.NewStreamFramer(conn), w)
+//	cli := jsonrpc2.NewFramedClient(f, handler)
+//
+// A capture is a stream of JSON lines. The first line identifies the
+// format, and each following line is an Entry holding one frame:
+//
+//	{"format": "jsonrpc2-capture", "version": 1}
+//	{"time": "2021-06-01T10:00:00.000Z", "direction": "sent", "frame": {"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1}}
+//	{"time": "2021-06-01T10:00:00.004Z", "direction": "received", "frame": {"jsonrpc": "2.0", "result": 3, "id": 1}}
+//
+// Frames which aren't JSON objects or arrays, such as invalid JSON sent by a
+// misbehaving peer, are recorded as JSON strings holding the frame.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// Format and Version identify the capture format in the first line of a
+// capture.
+const (
+	Format  = "jsonrpc2-capture"
+	Version = 1
+)
+
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// Direction is the direction a frame was sent in, from the point of view of
+// the side which recorded it.
+type Direction string
+
+const (
+	// Sent frames were written by the recording side.
+	Sent Direction = "sent"
+	// Received frames were read by the recording side.
+	Received Direction = "received"
+)
+
+// Entry is a single frame in a capture.
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Direction Direction       `json:"direction"`
+	Frame     json.RawMessage `json:"frame"`
+}
+
+// Writer writes a capture. Writer is safe for concurrent use.
+type Writer struct {
+	mut   sync.Mutex
+	w     io.Writer
+	clock jsonrpc2.Clock
+}
+
+// NewWriter writes the capture header to w and returns a Writer which
+// appends entries to it.
+func NewWriter(w io.Writer) (*Writer, error) {
+	line, err := json.Marshal(header{Format: Format, Version: Version})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return &Writer{w: w, clock: jsonrpc2.SystemClock}, nil
+}
+
+// SetClock sets the Clock used to timestamp frames recorded with
+// WriteFrame. The default is jsonrpc2.SystemClock.
+func (w *Writer) SetClock(clock jsonrpc2.Clock) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	w.clock = clock
+}
+
+// Write appends e to the capture.
+func (w *Writer) Write(e Entry) error {
+	if !isPayload(e.Frame) {
+		quoted, err := json.Marshal(string(e.Frame))
+		if err != nil {
+			return err
+		}
+		e.Frame = quoted
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+// WriteFrame appends frame to the capture, timestamped with the current
+// time.
+func (w *Writer) WriteFrame(dir Direction, frame []byte) error {
+	w.mut.Lock()
+	now := w.clock.Now()
+	w.mut.Unlock()
+	return w.Write(Entry{Time: now, Direction: dir, Frame: frame})
+}
+
+// isPayload reports whether frame is a JSON object or array, which is
+// recorded as is.
+func isPayload(frame []byte) bool {
+	trimmed := bytes.TrimSpace(frame)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Valid(trimmed)
+}
+
+// Reader reads a capture.
+type Reader struct {
+	s *bufio.Scanner
+}
+
+// maxLineSize is the largest entry a Reader accepts.
+const maxLineSize = 64 << 20
+
+// NewReader reads the capture header from r and returns a Reader for its
+// entries.
+func NewReader(r io.Reader) (*Reader, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineSize)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("capture: missing header")
+	}
+
+	var h header
+	if err := json.Unmarshal(s.Bytes(), &h); err != nil || h.Format != Format {
+		return nil, errors.New("capture: not a jsonrpc2 capture")
+	}
+	if h.Version != Version {
+		return nil, fmt.Errorf("capture: unsupported version %d", h.Version)
+	}
+	return &Reader{s: s}, nil
+}
+
+// Read returns the next entry, or io.EOF once there are none left. Frames
+// which were recorded as JSON strings are returned as the original bytes.
+func (r *Reader) Read() (Entry, error) {
+	for r.s.Scan() {
+		line := r.s.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Entry{}, fmt.Errorf("capture: invalid entry: %w", err)
+		}
+		var raw string
+		if json.Unmarshal(e.Frame, &raw) == nil {
+			e.Frame = json.RawMessage(raw)
+		}
+		return e, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return Entry{}, err
+	}
+	return Entry{}, io.EOF
+}
+
+// ReadAll reads every entry of the capture in r.
+func ReadAll(r io.Reader) ([]Entry, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for {
+		e, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// NewFramer returns a Framer which records every frame read from and
+// written to f with w. Frames are recorded before they are written, and
+// failing to record a frame doesn't fail the read or write.
+//
+// If f implements io.Closer, the returned Framer will also implement
+// io.Closer.
+func NewFramer(f jsonrpc2.Framer, w *Writer) jsonrpc2.Framer {
+	return &framer{f: f, w: w}
+}
+
+type framer struct {
+	f jsonrpc2.Framer
+	w *Writer
+}
+
+func (f *framer) ReadFrame() ([]byte, error) {
+	frame, err := f.f.ReadFrame()
+	if err == nil {
+		_ = f.w.WriteFrame(Received, frame)
+	}
+	return frame, err
+}
+
+func (f *framer) WriteFrame(frame []byte) error {
+	_ = f.w.WriteFrame(Sent, frame)
+	return f.f.WriteFrame(frame)
+}
+
+// Close closes the underlying Framer if it implements io.Closer.
+func (f *framer) Close() error {
+	if c, ok := f.f.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
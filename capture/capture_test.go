@@ -0,0 +1,118 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/crtv-io/jsonrpc2/jsonrpc2test"
+	"github.com/stretchr/testify/require"
+)
+
+func newMux() *jsonrpc2.ServeMux {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("sum", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var nums []int
+		if err := r.DecodeParams(&nums); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		var sum int
+		for _, n := range nums {
+			sum += n
+		}
+		_ = w.WriteMessage(sum)
+	})
+	mux.HandleFunc("log", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {})
+	return mux
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewFramedClient(NewFramer(jsonrpc2.NewStreamFramer(srvConn), w), newMux())
+	cli := jsonrpc2.NewClient(cliConn, nil)
+
+	require.NoError(t, cli.Notify("log", "starting"))
+	res, err := cli.Invoke(context.Background(), "sum", []int{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, "6", string(res))
+	_, err = cli.Invoke(context.Background(), "missing", nil)
+	require.Error(t, err)
+
+	require.NoError(t, cli.Close())
+	require.NoError(t, srv.Close())
+
+	entries, err := ReadAll(&buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	exchanges := Exchanges(entries)
+	require.Len(t, exchanges, 3)
+	require.Equal(t, "log", exchanges[0].Method)
+	require.Nil(t, exchanges[0].Response)
+	require.Equal(t, "sum", exchanges[1].Method)
+	require.Equal(t, json.RawMessage("6"), exchanges[1].Response.Result)
+	require.Equal(t, jsonrpc2.ErrorMethodNotFound, exchanges[2].Response.Error.Code)
+
+	// The session replays cleanly against the same handler.
+	contract := &jsonrpc2test.Contract{Interactions: Interactions(entries, Received)}
+	require.Len(t, contract.Interactions, 3)
+	require.Empty(t, contract.Replay(newMux()))
+}
+
+func TestWriter_InvalidFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+
+	now := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, w.Write(Entry{Time: now, Direction: Received, Frame: []byte(`{"jsonrpc": "2.0", "method": }`)}))
+	require.NoError(t, w.Write(Entry{Time: now, Direction: Received, Frame: []byte(`"just a string"`)}))
+
+	entries, err := ReadAll(&buf)
+	require.NoError(t, err)
+	require.Equal(t, `{"jsonrpc": "2.0", "method": }`, string(entries[0].Frame))
+	require.Equal(t, `"just a string"`, string(entries[1].Frame))
+}
+
+func TestReader_Header(t *testing.T) {
+	_, err := NewReader(strings.NewReader(""))
+	require.Error(t, err)
+	_, err = NewReader(strings.NewReader(`{"format": "other"}` + "\n"))
+	require.Error(t, err)
+	_, err = NewReader(strings.NewReader(`{"format": "jsonrpc2-capture", "version": 2}` + "\n"))
+	require.Error(t, err)
+}
+
+func TestPrint(t *testing.T) {
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, Direction: Sent, Frame: json.RawMessage(`{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1}`)},
+		{Time: start.Add(4 * time.Millisecond), Direction: Received, Frame: json.RawMessage(`{"jsonrpc": "2.0", "result": 3, "id": 1}`)},
+		{Time: start.Add(10 * time.Millisecond), Direction: Sent, Frame: json.RawMessage(`[{"jsonrpc": "2.0", "method": "log", "params": ["hi"]}, {"jsonrpc": "2.0", "method": "x", "id": "a"}]`)},
+		{Time: start.Add(12 * time.Millisecond), Direction: Received, Frame: json.RawMessage(`{"jsonrpc": "2.0", "error": {"code": -32601, "message": "no"}, "id": "a"}`)},
+		{Time: start.Add(20 * time.Millisecond), Direction: Received, Frame: json.RawMessage(`not json`)},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Print(&out, entries))
+	require.Equal(t, strings.Join([]string{
+		`+0.000s  -> sum #1 [1, 2]`,
+		`+0.004s  <- #1 result 3 (4ms)`,
+		`+0.010s  -> batch of 2`,
+		`             log ["hi"]`,
+		`             x #"a"`,
+		`+0.012s  <- #"a" error -32601 "no" (2ms)`,
+		`+0.020s  <- invalid frame "not json"`,
+		``,
+	}, "\n"), out.String())
+}
@@ -0,0 +1,46 @@
+// Command capview prints JSON-RPC 2.0 captures written by the capture
+// package in a human-readable form.
+//
+// Usage:
+//
+//	capview [file]
+//
+// The capture is read from standard input if no file is given.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/crtv-io/jsonrpc2/capture"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "capview:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	in := stdin
+	switch len(args) {
+	case 0:
+	case 1:
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	default:
+		return fmt.Errorf("usage: capview [file]")
+	}
+
+	entries, err := capture.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	return capture.Print(stdout, entries)
+}
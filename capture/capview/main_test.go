@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	in := strings.Join([]string{
+		`{"format": "jsonrpc2-capture", "version": 1}`,
+		`{"time": "2021-06-01T10:00:00Z", "direction": "sent", "frame": {"jsonrpc": "2.0", "method": "ping", "id": 1}}`,
+		`{"time": "2021-06-01T10:00:00.5Z", "direction": "received", "frame": {"jsonrpc": "2.0", "result": "pong", "id": 1}}`,
+	}, "\n")
+
+	var out bytes.Buffer
+	require.NoError(t, run(nil, strings.NewReader(in), &out))
+	require.Equal(t, "+0.000s  -> ping #1\n+0.500s  <- #1 result \"pong\" (500ms)\n", out.String())
+
+	require.Error(t, run([]string{"a", "b"}, nil, &out))
+}
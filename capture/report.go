@@ -0,0 +1,238 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/crtv-io/jsonrpc2/jsonrpc2test"
+)
+
+// Exchange is a request found in a capture, along with its response.
+type Exchange struct {
+	// Direction is the direction the request was sent in.
+	Direction Direction
+
+	Method string
+	ID     jsonrpc2.ID
+
+	// Request and Response are the messages of the exchange. Response is
+	// nil for notifications and for requests which got no response.
+	Request  jsonrpc2.Message
+	Response *jsonrpc2.Message
+
+	// Sent is when the request was recorded, and Duration is the time
+	// until its response was recorded.
+	Sent     time.Time
+	Duration time.Duration
+}
+
+// Exchanges pairs the requests in entries with their responses, which are
+// the responses with the same ID flowing in the other direction. Frames
+// which can't be parsed are skipped.
+func Exchanges(entries []Entry) []Exchange {
+	type key struct {
+		dir Direction
+		id  jsonrpc2.ID
+	}
+
+	var (
+		exchanges []Exchange
+		pending   = make(map[key]int)
+	)
+	for _, e := range entries {
+		msgs, _, err := jsonrpc2.Parse(e.Frame)
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			switch m.Kind {
+			case jsonrpc2.KindRequest, jsonrpc2.KindNotification:
+				if m.Kind == jsonrpc2.KindRequest {
+					pending[key{dir: e.Direction, id: m.ID}] = len(exchanges)
+				}
+				exchanges = append(exchanges, Exchange{
+					Direction: e.Direction,
+					Method:    m.Method,
+					ID:        m.ID,
+					Request:   m,
+					Sent:      e.Time,
+				})
+			case jsonrpc2.KindResponse:
+				k := key{dir: opposite(e.Direction), id: m.ID}
+				i, ok := pending[k]
+				if !ok {
+					continue
+				}
+				delete(pending, k)
+				resp := m
+				exchanges[i].Response = &resp
+				exchanges[i].Duration = e.Time.Sub(exchanges[i].Sent)
+			}
+		}
+	}
+	return exchanges
+}
+
+func opposite(d Direction) Direction {
+	if d == Sent {
+		return Received
+	}
+	return Sent
+}
+
+// Interactions converts the frames in entries into interactions for
+// jsonrpc2test.Contract, so a recorded session can be replayed against a
+// handler. Each frame sent in dir becomes an interaction, with the frame
+// in the other direction holding responses to the same requests as its
+// expected response. Use Received for captures recorded by a server, and
+// Sent for captures recorded by a client.
+func Interactions(entries []Entry, dir Direction) []jsonrpc2test.Interaction {
+	var interactions []jsonrpc2test.Interaction
+	pending := make(map[string]int)
+	for _, e := range entries {
+		ids, isRequest := frameIDs(e.Frame)
+		switch {
+		case e.Direction == dir:
+			in := jsonrpc2test.Interaction{
+				Name:    fmt.Sprintf("frame %d", len(interactions)+1),
+				Request: payload(e.Frame),
+			}
+			if isRequest && ids != "" {
+				pending[ids] = len(interactions)
+			}
+			interactions = append(interactions, in)
+		case !isRequest:
+			if i, ok := pending[ids]; ok {
+				delete(pending, ids)
+				interactions[i].Response = e.Frame
+			}
+		}
+	}
+	return interactions
+}
+
+// frameIDs returns the IDs of the requests or responses in frame, joined so
+// that a request frame and its response frame give the same string.
+// isRequest is false if frame holds responses.
+func frameIDs(frame []byte) (ids string, isRequest bool) {
+	msgs, _, err := jsonrpc2.Parse(frame)
+	if err != nil {
+		// Frames which can't be parsed are replayed as requests, and get
+		// a response with a null ID.
+		return "null", true
+	}
+
+	var parts []string
+	isRequest = true
+	for _, m := range msgs {
+		switch m.Kind {
+		case jsonrpc2.KindRequest:
+			id, _ := json.Marshal(m.ID)
+			parts = append(parts, string(id))
+		case jsonrpc2.KindResponse:
+			isRequest = false
+			id, _ := json.Marshal(m.ID)
+			parts = append(parts, string(id))
+		}
+	}
+	return strings.Join(parts, ","), isRequest
+}
+
+// payload returns frame as an Interaction request, which holds frames that
+// aren't objects or arrays as JSON strings.
+func payload(frame []byte) json.RawMessage {
+	if isPayload(frame) {
+		return json.RawMessage(frame)
+	}
+	quoted, _ := json.Marshal(string(frame))
+	return quoted
+}
+
+// Print writes a human-readable listing of entries to w, with one line per
+// message and the time each request took once its response arrives:
+//
+//	+0.000s  -> sum #1 [1,2]
+//	+0.004s  <- #1 result 3 (4ms)
+func Print(w io.Writer, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	start := entries[0].Time
+
+	type key struct {
+		dir Direction
+		id  jsonrpc2.ID
+	}
+	sent := make(map[key]time.Time)
+
+	for _, e := range entries {
+		arrow := "->"
+		if e.Direction == Received {
+			arrow = "<-"
+		}
+		prefix := fmt.Sprintf("+%.3fs  %s ", e.Time.Sub(start).Seconds(), arrow)
+
+		msgs, batched, err := jsonrpc2.Parse(e.Frame)
+		if err != nil {
+			if _, err := fmt.Fprintf(w, "%sinvalid frame %q\n", prefix, e.Frame); err != nil {
+				return err
+			}
+			continue
+		}
+		if batched {
+			if _, err := fmt.Fprintf(w, "%sbatch of %d\n", prefix, len(msgs)); err != nil {
+				return err
+			}
+			prefix = strings.Repeat(" ", len(prefix)+1)
+		}
+
+		for _, m := range msgs {
+			var line string
+			switch m.Kind {
+			case jsonrpc2.KindRequest:
+				sent[key{dir: e.Direction, id: m.ID}] = e.Time
+				line = fmt.Sprintf("%s %s %s", m.Method, formatID(m.ID), formatParams(m.Params))
+			case jsonrpc2.KindNotification:
+				line = fmt.Sprintf("%s %s", m.Method, formatParams(m.Params))
+			case jsonrpc2.KindResponse:
+				if m.Error != nil {
+					line = fmt.Sprintf("%s error %d %q", formatID(m.ID), m.Error.Code, m.Error.Message)
+				} else {
+					line = fmt.Sprintf("%s result %s", formatID(m.ID), m.Result)
+				}
+				k := key{dir: opposite(e.Direction), id: m.ID}
+				if t, ok := sent[k]; ok {
+					delete(sent, k)
+					line += fmt.Sprintf(" (%s)", e.Time.Sub(t))
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s%s\n", prefix, strings.TrimSpace(line)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatParams returns params, or nothing if the message had none.
+func formatParams(params json.RawMessage) string {
+	if string(params) == "null" {
+		return ""
+	}
+	return string(params)
+}
+
+func formatID(id jsonrpc2.ID) string {
+	switch {
+	case id.IsNull():
+		return "#null"
+	case id.IsString():
+		return fmt.Sprintf("#%q", id.String())
+	default:
+		return "#" + id.String()
+	}
+}
@@ -0,0 +1,187 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"go.uber.org/atomic"
+)
+
+// DefaultMaxChecksumFrameSize is the default limit for the size of frames
+// read by a ChecksumFramer, in bytes.
+const DefaultMaxChecksumFrameSize = 64 << 10
+
+// checksumMagic starts every frame written by a ChecksumFramer. 0xF5 never
+// appears in UTF-8 text, so the magic can't occur inside a JSON payload.
+var checksumMagic = [2]byte{0xF5, 0x4A}
+
+const (
+	// checksumHeaderSize is the size of the magic, the payload length, and
+	// the checksum of both.
+	checksumHeaderSize = 2 + 4 + 4
+	// checksumTrailerSize is the size of the payload checksum.
+	checksumTrailerSize = 4
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumFramer is a Framer for unreliable links, such as serial lines and
+// radios, where bytes may be dropped or mangled in transit. Each frame is
+// written as:
+//
+//	magic (0xF5 0x4A) | length (uint32) | CRC-32C of magic and length | payload | CRC-32C of payload
+//
+// with integers in big-endian order. Frames which fail either checksum are
+// skipped, and ReadFrame resynchronizes by scanning for the next magic, so a
+// corrupted frame loses that frame rather than the whole stream. Corrupted
+// requests are never answered, so callers should use timeouts.
+//
+// Both sides of the link must use a ChecksumFramer:
+//
+//	cli := jsonrpc2.NewFramedClient(jsonrpc2.NewChecksumFramer(port), handler)
+type ChecksumFramer struct {
+	rw      io.ReadWriter
+	maxSize int
+
+	// buf holds bytes read from rw that haven't been consumed yet.
+	buf   []byte
+	frame []byte
+
+	corrupted atomic.Int64
+}
+
+var _ Framer = (*ChecksumFramer)(nil)
+
+// NewChecksumFramer creates a ChecksumFramer over rw. Frames larger than
+// DefaultMaxChecksumFrameSize are treated as corrupt.
+//
+// Each read from rw asks for enough bytes to hold a frame of the maximum
+// size, so rw may also be a datagram connection that delivers one frame per
+// read.
+//
+// If rw implements io.Closer, it will be closed when the ChecksumFramer is
+// closed.
+func NewChecksumFramer(rw io.ReadWriter) *ChecksumFramer {
+	return &ChecksumFramer{rw: rw, maxSize: DefaultMaxChecksumFrameSize}
+}
+
+// SetMaxFrameSize sets the largest frame that ReadFrame accepts, in bytes.
+// Frames claiming to be larger are treated as corrupt. Reads are sized to
+// fit the largest frame, so unlike the other Framers there is always a
+// limit: if n is zero or less, DefaultMaxChecksumFrameSize is used.
+func (f *ChecksumFramer) SetMaxFrameSize(n int) {
+	if n <= 0 {
+		n = DefaultMaxChecksumFrameSize
+	}
+	f.maxSize = n
+}
+
+// Corrupted returns the number of times ReadFrame skipped corrupt data.
+func (f *ChecksumFramer) Corrupted() int64 {
+	return f.corrupted.Load()
+}
+
+// ReadFrame implements Framer.
+func (f *ChecksumFramer) ReadFrame() ([]byte, error) {
+	for {
+		// Skip to the next magic. If there is none, keep a trailing byte
+		// which may be the start of one and read more.
+		if i := bytes.Index(f.buf, checksumMagic[:]); i > 0 {
+			f.skip(i)
+		} else if i < 0 {
+			keep := 0
+			if n := len(f.buf); n > 0 && f.buf[n-1] == checksumMagic[0] {
+				keep = 1
+			}
+			if drop := len(f.buf) - keep; drop > 0 {
+				f.skip(drop)
+			}
+			if err := f.fill(len(f.buf) + 1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := f.fill(checksumHeaderSize); err != nil {
+			return nil, err
+		}
+		hdr := f.buf[:checksumHeaderSize]
+		length := binary.BigEndian.Uint32(hdr[2:6])
+		if crc32.Checksum(hdr[:6], castagnoli) != binary.BigEndian.Uint32(hdr[6:10]) || length > uint32(f.maxSize) {
+			f.skip(1)
+			continue
+		}
+
+		size := checksumHeaderSize + int(length) + checksumTrailerSize
+		if err := f.fill(size); err != nil {
+			return nil, err
+		}
+		payload := f.buf[checksumHeaderSize : checksumHeaderSize+int(length)]
+		if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(f.buf[size-checksumTrailerSize:size]) {
+			f.skip(1)
+			continue
+		}
+
+		f.frame = append(f.frame[:0], payload...)
+		f.buf = f.buf[:copy(f.buf, f.buf[size:])]
+		return f.frame, nil
+	}
+}
+
+// skip discards the first n bytes of the buffer as corrupt.
+func (f *ChecksumFramer) skip(n int) {
+	f.corrupted.Inc()
+	f.buf = f.buf[:copy(f.buf, f.buf[n:])]
+}
+
+// fill reads until the buffer holds at least n bytes.
+func (f *ChecksumFramer) fill(n int) error {
+	readSize := checksumHeaderSize + f.maxSize + checksumTrailerSize
+	for len(f.buf) < n {
+		if cap(f.buf)-len(f.buf) < readSize {
+			grown := make([]byte, len(f.buf), len(f.buf)+readSize)
+			copy(grown, f.buf)
+			f.buf = grown
+		}
+		read, err := f.rw.Read(f.buf[len(f.buf):cap(f.buf)])
+		f.buf = f.buf[:len(f.buf)+read]
+		if err != nil && len(f.buf) < n {
+			if err == io.EOF && len(f.buf) > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFrame implements Framer.
+func (f *ChecksumFramer) WriteFrame(frame []byte) error {
+	if len(frame) > f.maxSize {
+		return fmt.Errorf("frame of %d bytes exceeds limit of %d bytes", len(frame), f.maxSize)
+	}
+
+	buf := make([]byte, checksumHeaderSize, checksumHeaderSize+len(frame)+checksumTrailerSize)
+	copy(buf, checksumMagic[:])
+	binary.BigEndian.PutUint32(buf[2:6], uint32(len(frame)))
+	binary.BigEndian.PutUint32(buf[6:10], crc32.Checksum(buf[:6], castagnoli))
+	buf = append(buf, frame...)
+
+	var sum [checksumTrailerSize]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(frame, castagnoli))
+	buf = append(buf, sum[:]...)
+
+	_, err := f.rw.Write(buf)
+	return err
+}
+
+// Close closes the underlying stream if it implements io.Closer.
+func (f *ChecksumFramer) Close() error {
+	if c, ok := f.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumFramer(t *testing.T) {
+	var frames [][]byte
+	for _, payload := range []string{`{"id": 1}`, `{"id": 2}`, `{"id": 3}`, `{"id": 4}`} {
+		var buf bytes.Buffer
+		require.NoError(t, NewChecksumFramer(&buf).WriteFrame([]byte(payload)))
+		frames = append(frames, buf.Bytes())
+	}
+
+	var stream bytes.Buffer
+	stream.WriteString("line noise")
+	stream.Write(frames[0])
+	// A mangled payload byte.
+	corrupt := append([]byte(nil), frames[1]...)
+	corrupt[len(corrupt)-6] ^= 0xFF
+	stream.Write(corrupt)
+	// A frame with bytes dropped from the middle, which swallows the start
+	// of the next frame until the checksum fails.
+	stream.Write(frames[2][:len(frames[2])-5])
+	stream.Write(frames[3])
+
+	f := NewChecksumFramer(&stream)
+	frame, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"id": 1}`, string(frame))
+
+	frame, err = f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"id": 4}`, string(frame))
+	require.NotZero(t, f.Corrupted())
+
+	_, err = f.ReadFrame()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestChecksumFramer_CorruptLength(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewChecksumFramer(&buf)
+	require.NoError(t, w.WriteFrame([]byte(`{"id": 1}`)))
+	require.NoError(t, w.WriteFrame([]byte(`{"id": 2}`)))
+
+	// Claim a huge length. The header checksum catches it without waiting
+	// for the claimed number of bytes.
+	stream := buf.Bytes()
+	stream[2] = 0x7F
+
+	f := NewChecksumFramer(bytes.NewBuffer(stream))
+	frame, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"id": 2}`, string(frame))
+}
+
+func TestChecksumFramer_MaxFrameSize(t *testing.T) {
+	f := NewChecksumFramer(&bytes.Buffer{})
+	f.SetMaxFrameSize(4)
+	require.Error(t, f.WriteFrame([]byte(`"too long"`)))
+}
+
+func TestChecksumFramer_Client(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewFramedClient(NewChecksumFramer(srvConn), HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Params)
+	}))
+	defer srv.Close()
+	cli := NewFramedClient(NewChecksumFramer(cliConn), nil)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "echo", "hello")
+	require.NoError(t, err)
+	require.Equal(t, `"hello"`, string(res))
+}
@@ -10,8 +10,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/go-kit/kit/log"
-	"github.com/go-kit/kit/log/level"
 	"go.uber.org/atomic"
 )
 
@@ -19,42 +17,240 @@ import (
 type ClientOpt func(*Client)
 
 // WithClientLogger sets the Client to use a logger.
-func WithClientLogger(l log.Logger) ClientOpt {
+func WithClientLogger(l Logger) ClientOpt {
 	return func(c *Client) {
 		if l == nil {
-			l = log.NewNopLogger()
+			l = NewNopLogger()
 		} else {
 			c.log = l
 		}
 	}
 }
 
+// WithMaxRetainedBuffer sets the maximum size in bytes of the read buffer
+// that is kept between messages. Buffers which grow larger than n to fit a
+// message are released after the message is decoded. A negative n retains
+// buffers of any size. The default is 64KiB.
+func WithMaxRetainedBuffer(n int) ClientOpt {
+	return func(c *Client) {
+		if sf, ok := c.tx.f.(*streamFramer); ok {
+			sf.SetMaxRetainedBuffer(n)
+		}
+	}
+}
+
+// WithResponseCoalescing delays sending responses by up to delay so that
+// responses produced close together, such as from concurrent handlers, are
+// sent with fewer writes to the underlying connection. A delay of 0 disables
+// coalescing, which is the default.
+func WithResponseCoalescing(delay time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.coalesceDelay = delay
+	}
+}
+
+// WithConcurrentBatches handles up to n requests of a batch at once, so a
+// slow request doesn't delay the others in its batch. Messages are always
+// handled on their own goroutines, but the requests of a batch are handled
+// one at a time by default.
+//
+// The batch's responses are still sent together, in the order of the
+// requests, once every request has been handled. Notifications in a batch
+// keep their place: they run once the requests before them are done, and
+// before the requests after them start. If n is zero or less, requests
+// are handled one at a time.
+func WithConcurrentBatches(n int) ClientOpt {
+	return func(c *Client) {
+		c.batchConcurrency = n
+	}
+}
+
+// WithClock sets the Clock used by the Client for timers. This allows tests
+// to control the passage of time. The default is SystemClock.
+func WithClock(clock Clock) ClientOpt {
+	return func(c *Client) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// WithIDGenerator sets the function used to create IDs for requests sent by
+// the Client. The default is SequentialIDs.
+func WithIDGenerator(gen IDGenerator) ClientOpt {
+	return func(c *Client) {
+		if gen != nil {
+			c.nextID = gen
+		}
+	}
+}
+
+// WithErrorReplies sets whether the Client replies with an error to messages
+// it can't parse. Clients which only connect to trusted servers may disable
+// error replies so that a malformed message from the server doesn't cause a
+// reply the server can't handle. Error replies are enabled by default.
+func WithErrorReplies(enabled bool) ClientOpt {
+	return func(c *Client) {
+		c.noErrorReplies = !enabled
+	}
+}
+
+// WithMethodTimeouts sets timeouts for individual methods, keyed by method
+// name. The timeout bounds both calls to the method made with Invoke and
+// the context of requests for the method received by the Client, so slow
+// methods can be given longer budgets in one place. Contexts with an earlier
+// deadline are left unchanged.
+func WithMethodTimeouts(timeouts map[string]time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.methodTimeouts = make(map[string]time.Duration, len(timeouts))
+		for method, d := range timeouts {
+			c.methodTimeouts[method] = d
+		}
+	}
+}
+
+// WithDefaultTimeout sets a timeout for calls made with Invoke, Go, and
+// Batch.Commit whose context has no deadline, so calls don't wait forever
+// for a response the server never sends. Timeouts set with WithCallTimeout
+// or WithMethodTimeouts take precedence.
+func WithDefaultTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.defaultTimeout = d
+	}
+}
+
+// WithInvalidMessageLimit sets how many invalid messages in a row the Client
+// accepts from the other side before closing the connection. This stops two
+// misbehaving peers from replying to each other's error replies forever.
+// Error replies are also throttled as invalid messages repeat: after the
+// first 16, only every power of two is replied to. A limit of 0 never
+// closes the connection. The default is 64.
+func WithInvalidMessageLimit(n int) ClientOpt {
+	return func(c *Client) {
+		c.invalidLimit = n
+	}
+}
+
+// WithRequestMetadata sets whether Invoke sends metadata with requests.
+// Metadata includes values attached with WithMetadata and WithPriority and
+// the time left until the deadline of the context passed to Invoke.
+//
+// Metadata is sent in a "meta" member, which is an extension to JSON-RPC 2.0
+// that strict peers reject, so it is disabled by default. Only enable it when
+// the other side is known to support it.
+func WithRequestMetadata(enabled bool) ClientOpt {
+	return func(c *Client) {
+		c.sendMeta = enabled
+	}
+}
+
+// defaultInvalidMessageLimit is the default for WithInvalidMessageLimit.
+const defaultInvalidMessageLimit = 64
+
+// maxCoalescedResponses is the number of queued responses that triggers an
+// immediate flush when response coalescing is enabled.
+const maxCoalescedResponses = 128
+
+// Conn is a connection that RPCs can be invoked over. Conn is implemented by
+// Client.
+type Conn interface {
+	// Invoke invokes an RPC and waits for its response.
+	Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error)
+
+	// Notify sends a notification, which has no response.
+	Notify(method string, msg interface{}) error
+}
+
+var _ Conn = (*Client)(nil)
+
 type Client struct {
-	log log.Logger
+	log Logger
 
 	txMut sync.Mutex
 	tx    *transport
 
 	// listeners holds channels waiting for a response to a specific
-	// message ID. It is implemented a a map of int64 to a chan of
-	// *txObject.
+	// message ID. It is implemented a a map of ID to a chan of
+	// *txObject, or to a *Call for calls started with Go.
 	//
 	// The channels stored in listeners are NEVER closed, but cleaned up
 	// by the Go GC once the goroutine that populated listeners removes
 	// the entry.
 	listeners sync.Map
 
-	nextID  *atomic.Int64
+	nextID  IDGenerator
+	clock   Clock
 	handler Handler
 
+	state         atomic.Int32
+	stateListener func(from, to ConnState)
+
+	noErrorReplies bool
+	sendMeta       bool
+	recover        bool
+	invalidLimit   int
+	methodTimeouts map[string]time.Duration
+	defaultTimeout time.Duration
+	retryPolicy    *RetryPolicy
+	timeEncoding   atomic.Value
+	decodeWorkers  int
+	interceptors   []ClientInterceptor
+	tagStats       tagStats
+
+	batchConcurrency int
+
+	coalesceDelay time.Duration
+	coalesceMut   sync.Mutex
+	coalesced     []txMessage
+
+	// ctx is the base context for requests received by the Client. It is
+	// cancelled once the Client stops reading messages. baseCtx is its
+	// parent, set by WithBaseContext.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	baseCtx context.Context
+
+	// running holds the context.CancelFunc of each request being handled,
+	// by ID, if cancelRequests is set.
+	cancelRequests bool
+	running        sync.Map
+
+	// handlers tracks running handler goroutines. Only processMessages adds
+	// to handlers, so it is safe to wait on once done is closed.
+	handlers sync.WaitGroup
+	inflight atomic.Int64
+	closing  atomic.Bool
+
+	// draining is set by Server.Shutdown so requests which haven't started
+	// are rejected with ErrorShuttingDown.
+	draining atomic.Bool
+
+	// err is why the Client closed, returned by Err.
+	errMut sync.Mutex
+	err    error
+
+	// callsMut guards callsClosed, which is set once Calls started with Go
+	// can no longer complete.
+	callsMut    sync.Mutex
+	callsClosed bool
+
+	// firstMessage is closed once the first message, valid or not, is read.
+	firstMessage chan struct{}
+
 	done chan struct{}
 }
 
 // Dial creates a connection to the target server using TCP. Handler will
 // be invoked for each request received from the other side.
 func Dial(target string, handler Handler, opts ...ClientOpt) (*Client, error) {
+	return DialContext(context.Background(), target, handler, opts...)
+}
+
+// DialContext is like Dial, but the dial must complete before ctx is done.
+// To fail over between several servers, see FailoverDialFunc.
+func DialContext(ctx context.Context, target string, handler Handler, opts ...ClientOpt) (*Client, error) {
 	var d net.Dialer
-	nc, err := d.Dial("tcp", target)
+	nc, err := d.DialContext(ctx, "tcp", target)
 	if err != nil {
 		return nil, fmt.Errorf("failed dialing to server: %w", err)
 	}
@@ -67,31 +263,167 @@ func Dial(target string, handler Handler, opts ...ClientOpt) (*Client, error) {
 //
 // If rw implements io.Closer, it will be closed when the Client is closed.
 func NewClient(rw io.ReadWriter, handler Handler, opts ...ClientOpt) *Client {
+	return newClient(newTransport(rw), handler, opts...)
+}
+
+// NewFramedClient creates a client and starts reading frames from the
+// provided Framer. This allows clients to be used over transports with their
+// own message framing, such as websockets.
+//
+// If f implements io.Closer, it will be closed when the Client is closed.
+func NewFramedClient(f Framer, handler Handler, opts ...ClientOpt) *Client {
+	return newClient(newFramedTransport(f), handler, opts...)
+}
+
+func newClient(tx *transport, handler Handler, opts ...ClientOpt) *Client {
 	if handler == nil {
 		handler = DefaultHandler
 	}
 
 	cli := &Client{
-		log: log.NewNopLogger(),
+		log: NewNopLogger(),
 
-		tx:      newTransport(rw),
+		tx:      tx,
 		handler: handler,
-		nextID:  atomic.NewInt64(0),
+		nextID:  SequentialIDs(),
+		clock:   SystemClock,
+
+		invalidLimit: defaultInvalidMessageLimit,
 
-		done: make(chan struct{}),
+		firstMessage: make(chan struct{}),
+		done:         make(chan struct{}),
 	}
 	for _, o := range opts {
 		o(cli)
 	}
+	if cli.baseCtx == nil {
+		cli.baseCtx = context.Background()
+	}
+	cli.ctx, cli.cancel = context.WithCancel(cli.baseCtx)
+	if cli.recover {
+		cli.handler = Recover(cli.log)(cli.handler)
+	}
+	cli.setState(StateReady)
 	go cli.processMessages()
+	cli.watchBaseContext()
 	return cli
 }
 
-// Close closes the underlying transport.
+// Close closes the underlying transport and waits for the Client to stop
+// reading messages and for all running handlers to return. Contexts of
+// running requests are cancelled so handlers can exit early. Responses
+// written by handlers after Close is called are dropped.
+//
+// Handlers must not call Close on the Client that invoked them, since Close
+// would wait for the handler to return. Use CloseContext to bound the wait.
 func (c *Client) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext is like Close, but stops waiting for handlers once ctx is
+// done. If ctx is done before the handlers return, ctx.Err() is returned.
+func (c *Client) CloseContext(ctx context.Context) error {
+	err := c.closeTransport()
+
+	handled := make(chan struct{})
+	go func() {
+		<-c.done
+		c.handlers.Wait()
+		close(handled)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-handled:
+		return err
+	}
+}
+
+// send writes msg to the transport. ErrConnClosed is returned if the Client
+// has been closed.
+func (c *Client) send(msg txMessage) error {
+	c.txMut.Lock()
+	defer c.txMut.Unlock()
+	if c.closing.Load() {
+		return ErrConnClosed
+	}
+	return c.tx.SendMessage(msg)
+}
+
+// idle reports whether the Client has no running handlers.
+func (c *Client) idle() bool {
+	return c.inflight.Load() == 0
+}
+
+// CloseWithStatus closes the Client like Close, but tells the peer why
+// with code and reason if the transport supports it, such as with a
+// websocket close frame. Other transports are closed without a status.
+//
+// Unlike Close, CloseWithStatus doesn't wait for running handlers, so
+// handlers may use it to close the connection they were invoked by. Use
+// Done to wait for the Client to stop.
+func (c *Client) CloseWithStatus(code int, reason string) error {
+	c.stopSending()
+	if sc, ok := c.tx.f.(statusCloser); ok {
+		return sc.CloseWithStatus(code, reason)
+	}
+	return c.tx.Close()
+}
+
+// statusCloser is implemented by Framers which can tell the peer why they
+// are being closed.
+type statusCloser interface {
+	CloseWithStatus(code int, reason string) error
+}
+
+// closeTransport closes the transport without waiting for handlers.
+func (c *Client) closeTransport() error {
+	c.stopSending()
 	return c.tx.Close()
 }
 
+// stopSending marks the Client as closing before its transport is closed.
+func (c *Client) stopSending() {
+	c.setErr(ErrConnClosed)
+	c.setState(StateClosing)
+	c.closing.Store(true)
+	c.cancel()
+}
+
+// Err returns why the Client closed once Done is closed, and nil before
+// then. ErrConnClosed is returned if the Client was closed locally, and
+// otherwise the error which stopped reading from the transport, such as
+// io.EOF or a transport-specific error like a websocket close status.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+	default:
+		return nil
+	}
+	c.errMut.Lock()
+	defer c.errMut.Unlock()
+	return c.err
+}
+
+// setErr records err as why the Client closed, unless a reason was already
+// recorded.
+func (c *Client) setErr(err error) {
+	c.errMut.Lock()
+	defer c.errMut.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// BufferStats returns statistics about the Client's read buffer.
+func (c *Client) BufferStats() BufferStats {
+	if sf, ok := c.tx.f.(*streamFramer); ok {
+		return sf.BufferStats()
+	}
+	return BufferStats{}
+}
+
 // Done returns a channel that indicates when the client has closed.
 func (c *Client) Done() <-chan struct{} {
 	return c.done
@@ -100,39 +432,158 @@ func (c *Client) Done() <-chan struct{} {
 // processMessages runs in the background and handles incoming messages from
 // the server.
 func (c *Client) processMessages() {
+	defer c.setState(StateClosed)
+	defer c.failCalls()
 	defer close(c.done)
+	defer c.cancel()
+	defer c.setState(StateClosing)
+
+	readMessage := c.tx.ReadMessage
+	if c.decodeWorkers > 0 {
+		// Stop the pipeline once processMessages returns.
+		stop := make(chan struct{})
+		defer close(stop)
+		readMessage = newDecodePipeline(c.tx, c.decodeWorkers, stop).ReadMessage
+	}
+
+	var invalidRun int
+	for first := true; ; {
+		batch, err := readMessage()
+
+		var txErr *transportError
+		isTxErr := errors.As(err, &txErr)
+		if first && (err == nil || isTxErr) {
+			close(c.firstMessage)
+			first = false
+		}
+
+		// Track runs of invalid messages, so that a peer which keeps sending
+		// them is throttled and then disconnected.
+		if isTxErr || (err == nil && allInvalid(batch)) {
+			invalidRun++
+			if c.invalidLimit > 0 && invalidRun > c.invalidLimit {
+				LevelWarn.Log(c.log, "msg", "closing client after too many invalid messages", "count", invalidRun)
+				_ = c.closeTransport()
+				return
+			}
+			if !replyToInvalid(invalidRun) {
+				LevelDebug.Log(c.log, "msg", "throttling replies to invalid messages", "count", invalidRun)
+				continue
+			}
+		} else if err == nil {
+			invalidRun = 0
+		}
 
-	for {
-		batch, err := c.tx.ReadMessage()
 		if err != nil {
-			var txErr *transportError
-			if errors.As(err, &txErr) {
+			if txErr != nil {
+				if c.noErrorReplies {
+					LevelDebug.Log(c.log, "msg", "dropping invalid message", "err", err)
+					continue
+				}
 				c.txMut.Lock()
-				_ = c.tx.SendError(newUndefinedID(), &Error{
-					Code:    ErrorInvalidRequest,
+				_ = c.tx.SendError(NewNullID(), &Error{
+					Code:    errorCode(err),
 					Message: err.Error(),
 				})
 				c.txMut.Unlock()
 				continue
 			}
 
-			level.Info(c.log).Log("msg", "closing client", "err", err)
-			_ = c.Close()
+			LevelInfo.Log(c.log, "msg", "closing client", "err", err)
+			c.setErr(err)
+			_ = c.closeTransport()
 			return
 		}
 
-		go c.handleBatch(batch)
+		if c.cancelRequests {
+			if batch = c.trackRequests(batch); len(batch.Objects) == 0 {
+				continue
+			}
+		}
+
+		c.handlers.Add(1)
+		c.inflight.Inc()
+		go func() {
+			defer c.handlers.Done()
+			defer c.inflight.Dec()
+			c.handleBatch(batch)
+		}()
+	}
+}
+
+// allInvalid reports whether every object in batch failed to decode.
+func allInvalid(batch txMessage) bool {
+	for _, obj := range batch.Objects {
+		if obj.Invalid == nil {
+			return false
+		}
+	}
+	return len(batch.Objects) > 0
+}
+
+// replyToInvalid reports whether to reply to the nth invalid message in a
+// row. The first 16 are always replied to, and then only powers of two.
+func replyToInvalid(n int) bool {
+	return n <= 16 || n&(n-1) == 0
+}
+
+// errorCode returns the error code to reply with for a message which failed
+// to decode. Messages which aren't valid JSON are parse errors; everything
+// else is an invalid request.
+func errorCode(err error) int {
+	if isSyntaxError(err) {
+		return ErrorParse
 	}
+	return ErrorInvalidRequest
 }
 
 func (c *Client) handleBatch(batch txMessage) {
+	// Fast path for single notifications, which never produce a response.
+	if !batch.Batched && len(batch.Objects) == 1 {
+		if req := batch.Objects[0].Request; req != nil && req.Notification {
+			c.handleNotification(req)
+			return
+		}
+	}
+
 	var resp txMessage
 	resp.Batched = batch.Batched
 
+	// With WithConcurrentBatches, requests run on their own goroutines, up
+	// to batchConcurrency at once, and fill in their place in resp.
+	var (
+		running sync.WaitGroup
+		slots   chan struct{}
+	)
+	if c.batchConcurrency > 0 && len(batch.Objects) > 1 {
+		slots = make(chan struct{}, c.batchConcurrency)
+	}
+
 Objects:
 	for _, msg := range batch.Objects {
 		switch {
+		case msg.Invalid != nil:
+			if c.noErrorReplies {
+				LevelDebug.Log(c.log, "msg", "dropping invalid message", "err", msg.Invalid)
+				continue Objects
+			}
+			resp.Objects = append(resp.Objects, &txObject{Response: &txResponse{
+				ID:    invalidID(msg.Invalid),
+				Error: &Error{Code: errorCode(msg.Invalid), Message: msg.Invalid.Error()},
+			}})
+		case msg.Request != nil && slots != nil && !msg.Request.Notification:
+			obj := &txObject{}
+			resp.Objects = append(resp.Objects, obj)
+			slots <- struct{}{}
+			running.Add(1)
+			go func(req *txRequest) {
+				defer running.Done()
+				defer func() { <-slots }()
+				obj.Response = c.handleRequest(req)
+			}(msg.Request)
 		case msg.Request != nil:
+			// Notifications wait for the requests before them.
+			running.Wait()
 			r := c.handleRequest(msg.Request)
 			if r != nil {
 				resp.Objects = append(resp.Objects, &txObject{Response: r})
@@ -142,72 +593,182 @@ Objects:
 
 			// If the response ID is undefined, then it's a generic error.
 			if msgID.IsUndefined() {
-				level.Warn(c.log).Log("msg", "received error message", "msg", msg)
+				LevelWarn.Log(c.log, "msg", "received error message", "msg", msg)
 				continue Objects
 			}
 
 			lis, ok := c.listeners.Load(msgID)
 			if !ok {
 				// The listener either never existed or went away.
-				level.Warn(c.log).Log("msg", "missing listener for message response", "id", msgID)
+				LevelWarn.Log(c.log, "msg", "missing listener for message response", "id", msgID)
+				continue Objects
+			}
+
+			if _, ok := lis.(*Call); ok {
+				res, err := responseResult(msg)
+				c.completeCall(msgID, res, err)
 				continue Objects
 			}
 
 			select {
 			case lis.(chan *txObject) <- msg:
 				// Listener got message, continue as normal
-			case <-time.After(500 * time.Millisecond):
-				level.Warn(c.log).Log("msg", "unresponsive listener", "id", msgID)
+			case <-c.clock.After(500 * time.Millisecond):
+				LevelWarn.Log(c.log, "msg", "unresponsive listener", "id", msgID)
 				break
 			}
 		}
 	}
 
+	running.Wait()
 	if len(resp.Objects) > 0 {
+		c.sendResponse(resp)
+	}
+}
+
+// sendResponse sends a response, queueing it to be coalesced with other
+// responses if enabled.
+func (c *Client) sendResponse(resp txMessage) {
+	if c.coalesceDelay <= 0 {
 		c.txMut.Lock()
 		defer c.txMut.Unlock()
-		if err := c.tx.SendMessage(resp); err != nil {
-			level.Warn(c.log).Log("msg", "error sending message, closing client", "err", err)
+		if c.closing.Load() {
+			LevelDebug.Log(c.log, "msg", "dropping response for closed client")
 			return
 		}
+		if err := c.tx.SendMessage(resp); err != nil {
+			LevelWarn.Log(c.log, "msg", "error sending message, closing client", "err", err)
+		}
+		return
+	}
+
+	c.coalesceMut.Lock()
+	c.coalesced = append(c.coalesced, resp)
+	queued := len(c.coalesced)
+	c.coalesceMut.Unlock()
+
+	switch {
+	case queued >= maxCoalescedResponses:
+		c.flushResponses()
+	case queued == 1:
+		c.clock.AfterFunc(c.coalesceDelay, c.flushResponses)
+	}
+}
+
+// flushResponses sends all queued responses.
+func (c *Client) flushResponses() {
+	c.coalesceMut.Lock()
+	msgs := c.coalesced
+	c.coalesced = nil
+	c.coalesceMut.Unlock()
+
+	if len(msgs) == 0 {
+		return
+	}
+
+	c.txMut.Lock()
+	defer c.txMut.Unlock()
+	if c.closing.Load() {
+		LevelDebug.Log(c.log, "msg", "dropping responses for closed client", "count", len(msgs))
+		return
+	}
+	if err := c.tx.SendMessages(msgs); err != nil {
+		LevelWarn.Log(c.log, "msg", "error sending message, closing client", "err", err)
 	}
 }
 
 // handleRequest handles an individual request.
 func (c *Client) handleRequest(req *txRequest) *txResponse {
-	ww := &responseWriter{
-		notification: req.Notification,
-		resp:         &txResponse{ID: req.ID},
-		set:          atomic.NewBool(false),
+	if req.Notification {
+		c.handleNotification(req)
+		return nil
+	}
+	defer c.untrackRequest(req)
+	if c.draining.Load() {
+		return &txResponse{ID: req.ID, Error: &Error{Code: ErrorShuttingDown, Message: "Shutting down"}}
+	}
+
+	r, cancel := c.newRequest(req)
+	defer cancel()
+	return serveRequest(c.handler, r, req.ID)
+}
+
+// serveRequest invokes h for a request which expects a response and returns
+// the response written by h.
+func serveRequest(h Handler, r *Request, id ID) *txResponse {
+	ww := &responseWriter{resp: &txResponse{ID: id}}
+	h.ServeRPC(ww, r)
+
+	if ww.resp.Result == nil && ww.resp.Error == nil {
+		ww.resp.Result = json.RawMessage("null")
+	}
+	return ww.resp
+}
+
+// handleNotification handles an individual notification. Notifications
+// never have a response, so no responseWriter is allocated for them.
+func (c *Client) handleNotification(req *txRequest) {
+	if c.draining.Load() {
+		LevelDebug.Log(c.log, "msg", "dropping notification during shutdown", "method", req.Method)
+		return
 	}
-	c.handler.ServeRPC(ww, &Request{
+	r, cancel := c.newRequest(req)
+	defer cancel()
+	c.handler.ServeRPC(notificationWriter{}, r)
+}
+
+// newRequest creates the Request passed to handlers for req. cancel must be
+// called once the handler returns.
+func (c *Client) newRequest(req *txRequest) (r *Request, cancel func()) {
+	r = &Request{
 		Notification: req.Notification,
 
 		Method: req.Method,
 		Params: req.Params,
+		ID:     req.ID,
 		Client: c,
-	})
+		Meta:   req.Meta,
 
-	if ww.notification {
-		return nil
+		ctx: c.ctx,
+	}
+	if req.ctx != nil {
+		r.ctx = req.ctx
 	}
 
-	if ww.resp.Result == nil {
-		ww.resp.Result = []byte{}
+	// The receive time is only needed to resolve a timeout sent as metadata.
+	if req.Meta != nil {
+		r.received = c.clock.Now()
 	}
-	return ww.resp
+
+	if d, ok := c.methodTimeouts[req.Method]; ok {
+		r.ctx, cancel = context.WithTimeout(r.Context(), d)
+		return r, cancel
+	}
+	return r, noCancel
+}
+
+func noCancel() {}
+
+var errNotificationResponse = errors.New("cannot write message for notification")
+
+// notificationWriter is the ResponseWriter passed to handlers for
+// notifications. All writes fail.
+type notificationWriter struct{}
+
+func (notificationWriter) WriteMessage(msg interface{}) error {
+	return errNotificationResponse
+}
+
+func (notificationWriter) WriteError(errCode int, err error) error {
+	return errNotificationResponse
 }
 
 type responseWriter struct {
-	notification bool
-	resp         *txResponse
-	set          *atomic.Bool
+	resp *txResponse
+	set  atomic.Bool
 }
 
 func (w *responseWriter) WriteMessage(msg interface{}) error {
-	if w.notification {
-		return fmt.Errorf("cannot write message for notification")
-	}
 	if !w.set.CAS(false, true) {
 		return fmt.Errorf("response already set")
 	}
@@ -222,9 +783,6 @@ func (w *responseWriter) WriteMessage(msg interface{}) error {
 }
 
 func (w *responseWriter) WriteError(errCode int, err error) error {
-	if w.notification {
-		return fmt.Errorf("cannot write message for notification")
-	}
 	if !w.set.CAS(false, true) {
 		return fmt.Errorf("response already set")
 	}
@@ -233,6 +791,12 @@ func (w *responseWriter) WriteError(errCode int, err error) error {
 		Code:    errCode,
 		Message: err.Error(),
 	}
+
+	var rpcErr Error
+	if errors.As(err, &rpcErr) {
+		w.resp.Error.Message = rpcErr.Message
+		w.resp.Error.Data = rpcErr.Data
+	}
 	return nil
 }
 
@@ -246,20 +810,38 @@ func (c *Client) Batch() *Batch {
 // if the other side succesfully handled the notification. An error will be
 // returned for transport-level problems.
 func (c *Client) Notify(method string, msg interface{}) error {
+	return c.notifyContext(context.Background(), method, msg)
+}
+
+// notifyContext sends a notification with the metadata of ctx.
+func (c *Client) notifyContext(ctx context.Context, method string, msg interface{}) error {
+	if len(c.interceptors) == 0 {
+		return c.notify(ctx, method, msg)
+	}
+	call := &ClientCall{Method: method, Params: msg, Notification: true}
+	_, err := c.intercept(ctx, call, c.sendCall)
+	return err
+}
+
+func (c *Client) notify(ctx context.Context, method string, msg interface{}) error {
 	body, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
-	c.txMut.Lock()
-	defer c.txMut.Unlock()
-	return c.tx.SendMessage(txMessage{
+	var meta Metadata
+	if c.sendMeta {
+		meta = MetadataFromContext(ctx)
+	}
+
+	return c.send(txMessage{
 		Batched: false,
 		Objects: []*txObject{{
 			Request: &txRequest{
 				Notification: true,
 				Method:       method,
 				Params:       body,
+				Meta:         meta,
 			},
 		}},
 	})
@@ -269,24 +851,53 @@ func (c *Client) Notify(method string, msg interface{}) error {
 // for a repsonse. Error will be set for RPC-level and transport-level
 // problems.
 //
-// RPC-level errors will be set to the Error object.
-func (c *Client) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+// RPC-level errors will be set to the Error object. If the Client was created
+// with WithRequestMetadata(true), metadata attached to ctx with WithMetadata
+// is sent alongside the request, along with the time left until the deadline
+// of ctx if it has one. Failed calls are retried as set by WithRetryPolicy.
+func (c *Client) Invoke(ctx context.Context, method string, msg interface{}) (res json.RawMessage, err error) {
+	tags := CallTagsFromContext(ctx)
+	if len(tags) > 0 {
+		counters := c.tagStats.start(tags)
+		defer func() { c.tagStats.end(counters, err) }()
+	}
+
+	return c.invokeWithRetry(ctx, method, func(ctx context.Context) (json.RawMessage, error) {
+		if len(c.interceptors) == 0 {
+			return c.invoke(ctx, method, msg)
+		}
+		return c.intercept(ctx, &ClientCall{Method: method, Params: msg, Tags: tags}, c.sendCall)
+	})
+}
+
+func (c *Client) invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
 	body, err := json.Marshal(msg)
 	if err != nil {
 		return nil, err
 	}
 
-	var (
-		msgID = newNumberID(c.nextID.Inc())
+	if d, ok := c.callTimeout(ctx, method); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
 
-		respCh = make(chan *txObject, 1)
-	)
+	var meta Metadata
+	if c.sendMeta {
+		meta = requestMetadata(ctx, c.clock.Now())
+	}
 
-	c.listeners.Store(msgID, respCh)
+	msgID, err := c.callID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	respCh := make(chan *txObject, 1)
+	if _, inUse := c.listeners.LoadOrStore(msgID, respCh); inUse {
+		return nil, fmt.Errorf("request id %s is already in use", msgID)
+	}
 	defer c.listeners.Delete(msgID)
 
-	c.txMut.Lock()
-	err = c.tx.SendMessage(txMessage{
+	err = c.send(txMessage{
 		Batched: false,
 		Objects: []*txObject{{
 			Request: &txRequest{
@@ -294,33 +905,53 @@ func (c *Client) Invoke(ctx context.Context, method string, msg interface{}) (js
 				ID:           msgID,
 				Method:       method,
 				Params:       body,
+				Meta:         meta,
 			},
 		}},
 	})
-	c.txMut.Unlock()
 	if err != nil {
+		markSendFailed(ctx)
 		return nil, err
 	}
 
 	select {
 	case <-ctx.Done():
+		c.sendCancel(msgID)
 		return nil, ctx.Err()
-	case resp := <-respCh:
-		if resp.Response == nil {
-			return nil, fmt.Errorf("unexpected message: no response body")
-		}
-		if resp.Response.Error != nil {
-			return nil, *resp.Response.Error
+	case <-c.done:
+		// The response may have been delivered just before the read loop
+		// exited.
+		select {
+		case resp := <-respCh:
+			return responseResult(resp)
+		default:
+			return nil, ErrConnClosed
 		}
-		return resp.Response.Result, nil
+	case resp := <-respCh:
+		return responseResult(resp)
 	}
 }
 
+// responseResult returns the result or error held by a response to Invoke.
+func responseResult(resp *txObject) (json.RawMessage, error) {
+	if resp.Response == nil {
+		return nil, fmt.Errorf("unexpected message: no response body")
+	}
+	if resp.Response.err != nil {
+		return nil, resp.Response.err
+	}
+	if resp.Response.Error != nil {
+		return nil, *resp.Response.Error
+	}
+	return resp.Response.Result, nil
+}
+
 // Batch is a batch of messages to send to a client. It must be committed with
 // Commit. A Batch can be created through the Batch method on a Client.
 type Batch struct {
-	cli *Client
-	msg txMessage
+	cli   *Client
+	msg   txMessage
+	calls []*ClientCall
 
 	watchers sync.Map
 }
@@ -339,10 +970,21 @@ func (b *Batch) Notify(method string, msg interface{}) error {
 			Params:       body,
 		},
 	})
+	b.calls = append(b.calls, &ClientCall{Method: method, Params: msg, Notification: true})
 
 	return nil
 }
 
+// newRequestID returns the ID for a new request from c's IDGenerator. IDs
+// which can't be matched to a response, null or undefined, are rejected.
+func (c *Client) newRequestID() (ID, error) {
+	id := c.nextID()
+	if id.IsUndefined() || id.IsNull() {
+		return ID{}, fmt.Errorf("IDGenerator returned a null or undefined id")
+	}
+	return id, nil
+}
+
 // Invoke queues an RPC to invoke. The returned *json.RawMessage will be empty until
 // the batch is commited.
 func (b *Batch) Invoke(method string, msg interface{}) (*json.RawMessage, error) {
@@ -351,15 +993,18 @@ func (b *Batch) Invoke(method string, msg interface{}) (*json.RawMessage, error)
 		return nil, err
 	}
 
+	msgID, err := b.cli.newRequestID()
+	if err != nil {
+		return nil, err
+	}
 	var (
-		msgID = newNumberID(b.cli.nextID.Inc())
-
 		result json.RawMessage
 		respCh = make(chan *txObject, 1)
 	)
-
+	if _, inUse := b.cli.listeners.LoadOrStore(msgID, respCh); inUse {
+		return nil, fmt.Errorf("request id %s is already in use", msgID)
+	}
 	b.watchers.Store(msgID, &result)
-	b.cli.listeners.Store(msgID, respCh)
 
 	b.msg.Objects = append(b.msg.Objects, &txObject{
 		Request: &txRequest{
@@ -369,14 +1014,37 @@ func (b *Batch) Invoke(method string, msg interface{}) (*json.RawMessage, error)
 			Params:       body,
 		},
 	})
+	b.calls = append(b.calls, &ClientCall{Method: method, Params: msg})
 
 	return &result, nil
 }
 
 // Commit commits the batch. If the response had any errors, the first error is returned.
-func (b *Batch) Commit(ctx context.Context) error {
+func (b *Batch) Commit(ctx context.Context) (err error) {
+	tags := CallTagsFromContext(ctx)
+	if len(tags) > 0 {
+		counters := b.cli.tagStats.start(tags)
+		defer func() { b.cli.tagStats.end(counters, err) }()
+	}
+
+	if len(b.cli.interceptors) == 0 {
+		return b.commit(ctx)
+	}
+	_, err = b.cli.intercept(ctx, &ClientCall{Batch: b.calls, Tags: tags}, func(ctx context.Context, _ *ClientCall) (json.RawMessage, error) {
+		return nil, b.commit(ctx)
+	})
+	return err
+}
+
+func (b *Batch) commit(ctx context.Context) error {
+	if d, ok := b.cli.defaultCallTimeout(ctx); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
 	b.msg.Batched = true
-	if err := b.cli.tx.SendMessage(b.msg); err != nil {
+	if err := b.cli.send(b.msg); err != nil {
 		return err
 	}
 
@@ -390,31 +1058,36 @@ func (b *Batch) Commit(ctx context.Context) error {
 
 		ch, ok := b.cli.listeners.Load(key)
 		if !ok {
-			return false
+			return true
 		}
 
+		var resp *txObject
 		select {
 		case <-ctx.Done():
-			if firstError != nil {
+			if firstError == nil {
 				firstError = ctx.Err()
 			}
 			return true
-		case resp := <-ch.(chan *txObject):
-			if resp.Response != nil {
-				if firstError != nil {
-					firstError = fmt.Errorf("unexpected message: no response body")
-				}
-				return true
-			}
-			if resp.Response.Error != nil {
-				if firstError != nil {
-					firstError = *resp.Response.Error
+		case <-b.cli.done:
+			select {
+			case resp = <-ch.(chan *txObject):
+			default:
+				if firstError == nil {
+					firstError = ErrConnClosed
 				}
 				return true
 			}
-			*value.(*json.RawMessage) = resp.Response.Result
+		case resp = <-ch.(chan *txObject):
 		}
 
+		result, err := responseResult(resp)
+		if err != nil {
+			if firstError == nil {
+				firstError = err
+			}
+			return true
+		}
+		*value.(*json.RawMessage) = result
 		return true
 	})
 
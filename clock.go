@@ -0,0 +1,77 @@
+package jsonrpc2
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// Clock provides the current time and timers. Clients use the system clock by
+// default; tests may provide their own Clock with WithClock to control time.
+// See jsonrpc2test.FakeClock for an implementation.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for d to elapse and then sends the current time on the
+	// returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc waits for d to elapse and then calls f in its own goroutine.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock.
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if the timer
+	// already fired or was stopped.
+	Stop() bool
+}
+
+// SystemClock is the Clock backed by the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// IDGenerator returns the ID to use for the next request sent by a Client.
+// Generated IDs must be unique for the lifetime of the Client.
+type IDGenerator func() ID
+
+// SequentialIDs returns an IDGenerator which produces number IDs counting up
+// from 1. This is the default IDGenerator for a Client.
+func SequentialIDs() IDGenerator {
+	next := atomic.NewInt64(0)
+	return func() ID { return NewNumberID(next.Inc()) }
+}
+
+// PrefixedIDs returns an IDGenerator which produces string IDs made of
+// prefix and a number counting up from 1, such as "indexer-1", so that
+// requests from different Clients can be told apart in shared logs.
+func PrefixedIDs(prefix string) IDGenerator {
+	next := atomic.NewInt64(0)
+	return func() ID { return NewStringID(prefix + strconv.FormatInt(next.Inc(), 10)) }
+}
+
+// RandomIDs returns an IDGenerator which produces random version 4 UUIDs as
+// string IDs, which are unique across Clients and processes.
+func RandomIDs() IDGenerator {
+	return func() ID {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(fmt.Sprintf("jsonrpc2: reading random id: %v", err))
+		}
+		b[6] = b[6]&0x0f | 0x40 // Version 4.
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant.
+		return NewStringID(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]))
+	}
+}
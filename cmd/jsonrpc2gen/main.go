@@ -0,0 +1,367 @@
+// Command jsonrpc2gen generates a typed JSON-RPC 2.0 client and server
+// registration function from a Go interface.
+//
+// Usage:
+//
+//	jsonrpc2gen -type Greeter [-prefix Greeter.] [-output greeter_jsonrpc2.go] [dir]
+//
+// The interface is looked up in the package in dir, which defaults to the
+// current directory, and the generated file is written to the same package.
+// It is typically run with go:generate:
+//
+//	//go:generate go run github.com/crtv-io/jsonrpc2/cmd/jsonrpc2gen -type Greeter
+//
+//	type Greeter interface {
+//		Greet(ctx context.Context, name string) (string, error)
+//		Reset(ctx context.Context) error
+//	}
+//
+// Every method of the interface must take a context.Context followed by at
+// most one params argument, and return an error optionally preceded by one
+// result. For the interface above, jsonrpc2gen generates:
+//
+//	// GreeterClient implements Greeter by invoking RPCs over a jsonrpc2.Conn.
+//	type GreeterClient struct{ ... }
+//
+//	func NewGreeterClient(conn jsonrpc2.Conn) *GreeterClient
+//
+//	// RegisterGreeter registers the methods of impl with mux.
+//	func RegisterGreeter(mux *jsonrpc2.ServeMux, impl Greeter)
+//
+// Methods are named with the prefix followed by the Go method name, such as
+// "Greeter.Greet". The prefix defaults to the interface name followed by a
+// dot, which matches the names used by ServeMux.RegisterService, and may be
+// set to "" for unprefixed names.
+//
+// The generated client works over any jsonrpc2.Conn, including the
+// service-scoped Conns returned by plugin.Host.Dispense, and the
+// registration function works with the ServeMux given to plugin.Serve, so
+// plugins get typed service bindings on both sides.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "jsonrpc2gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("jsonrpc2gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	typeName := fs.String("type", "", "name of the interface to generate code for (required)")
+	prefix := fs.String("prefix", "", "prefix of method names, which may be empty (default the interface name followed by a dot)")
+	output := fs.String("output", "", "output file (default <type>_jsonrpc2.go in dir)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir := "."
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		dir = fs.Arg(0)
+	default:
+		return fmt.Errorf("usage: jsonrpc2gen -type name [-prefix prefix] [-output file] [dir]")
+	}
+	if *typeName == "" {
+		return fmt.Errorf("-type is required")
+	}
+	prefixSet := false
+	fs.Visit(func(f *flag.Flag) { prefixSet = prefixSet || f.Name == "prefix" })
+	if !prefixSet {
+		*prefix = *typeName + "."
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(*typeName)+"_jsonrpc2.go")
+	}
+
+	src, err := generate(dir, *typeName, *prefix)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*output, src, 0o644)
+}
+
+// method is an interface method to generate code for.
+type method struct {
+	Name string
+
+	// Params and Result are the Go types of the params and result, or
+	// empty if the method has none. ParamsName is the name of the params
+	// argument.
+	Params     string
+	ParamsName string
+	Result     string
+}
+
+// generate returns the generated source for the interface typeName in the
+// package in dir.
+func generate(dir, typeName, prefix string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			iface := findInterface(file, typeName)
+			if iface == nil {
+				continue
+			}
+			methods, pkgNames, err := interfaceMethods(fset, iface)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", typeName, err)
+			}
+			std, other, err := fileImports(file, pkgNames)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", typeName, err)
+			}
+			return render(pkg.Name, typeName, prefix, std, other, methods)
+		}
+	}
+	return nil, fmt.Errorf("interface %s not found in %s", typeName, dir)
+}
+
+func findInterface(file *ast.File, name string) *ast.InterfaceType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != name {
+				continue
+			}
+			if iface, ok := ts.Type.(*ast.InterfaceType); ok {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+// interfaceMethods returns the methods of iface and the names of the
+// packages referenced by their params and results.
+func interfaceMethods(fset *token.FileSet, iface *ast.InterfaceType) ([]method, map[string]bool, error) {
+	pkgNames := make(map[string]bool)
+	var methods []method
+	for _, field := range iface.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			return nil, nil, fmt.Errorf("embedded interfaces are not supported")
+		}
+		name := field.Names[0].Name
+		if !ast.IsExported(name) {
+			return nil, nil, fmt.Errorf("method %s is not exported", name)
+		}
+
+		params, names := flattenFields(ft.Params)
+		var results []ast.Expr
+		if ft.Results != nil {
+			results, _ = flattenFields(ft.Results)
+		}
+		if len(params) < 1 || len(params) > 2 || !isSelector(params[0], "context", "Context") {
+			return nil, nil, fmt.Errorf("method %s must take a context.Context and at most one params argument", name)
+		}
+		if len(results) < 1 || len(results) > 2 || !isIdent(results[len(results)-1], "error") {
+			return nil, nil, fmt.Errorf("method %s must return an error, optionally preceded by a result", name)
+		}
+
+		m := method{Name: name}
+		if len(params) == 2 {
+			if _, ok := params[1].(*ast.Ellipsis); ok {
+				return nil, nil, fmt.Errorf("method %s must not be variadic", name)
+			}
+			m.Params, m.ParamsName = exprString(fset, params[1]), names[1]
+			if reservedNames[m.ParamsName] {
+				m.ParamsName = "params"
+			}
+			collectPackages(params[1], pkgNames)
+		}
+		if len(results) == 2 {
+			m.Result = exprString(fset, results[0])
+			collectPackages(results[0], pkgNames)
+		}
+		methods = append(methods, m)
+	}
+	return methods, pkgNames, nil
+}
+
+// reservedNames are names which can't be used for params in generated
+// methods, either because they're used by the generated code or because
+// they're blank.
+var reservedNames = map[string]bool{"": true, "_": true, "c": true, "ctx": true, "err": true, "jsonrpc2": true, "context": true}
+
+// flattenFields returns the type and name of each parameter in fl,
+// repeating types shared by several names, as in (a, b int). Names are
+// empty for unnamed parameters.
+func flattenFields(fl *ast.FieldList) (types []ast.Expr, names []string) {
+	for _, f := range fl.List {
+		if len(f.Names) == 0 {
+			types, names = append(types, f.Type), append(names, "")
+		}
+		for _, name := range f.Names {
+			types, names = append(types, f.Type), append(names, name.Name)
+		}
+	}
+	return types, names
+}
+
+func isSelector(e ast.Expr, pkg, name string) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == name && isIdent(sel.X, pkg)
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func collectPackages(e ast.Expr, pkgNames map[string]bool) {
+	ast.Inspect(e, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				pkgNames[id.Name] = true
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func exprString(fset *token.FileSet, e ast.Expr) string {
+	var buf bytes.Buffer
+	_ = format.Node(&buf, fset, e)
+	return buf.String()
+}
+
+// fileImports returns the import specs of file for the packages named in
+// pkgNames, formatted for an import block and split into standard library
+// and other packages.
+func fileImports(file *ast.File, pkgNames map[string]bool) (std, other []string, err error) {
+	found := make(map[string]string)
+	isStd := make(map[string]bool)
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		spec := imp.Path.Value
+		if imp.Name != nil {
+			name = imp.Name.Name
+			spec = name + " " + spec
+		}
+		if pkgNames[name] {
+			found[name] = spec
+			isStd[name] = !strings.Contains(strings.Split(path, "/")[0], ".")
+		}
+	}
+
+	std = []string{strconv.Quote("context")}
+	other = []string{strconv.Quote("github.com/crtv-io/jsonrpc2")}
+	for name := range pkgNames {
+		spec, ok := found[name]
+		switch {
+		case !ok:
+			return nil, nil, fmt.Errorf("package %s is not imported", name)
+		case name == "context" || name == "jsonrpc2":
+		case isStd[name]:
+			std = append(std, spec)
+		default:
+			other = append(other, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	return std, other, nil
+}
+
+func render(pkgName, typeName, prefix string, std, other []string, methods []method) ([]byte, error) {
+	var b bytes.Buffer
+	p := func(format string, args ...interface{}) { fmt.Fprintf(&b, format, args...) }
+	client := typeName + "Client"
+
+	p("// Code generated by jsonrpc2gen. DO NOT EDIT.\n\n")
+	p("package %s\n\n", pkgName)
+	p("import (\n")
+	for _, imp := range std {
+		p("\t%s\n", imp)
+	}
+	p("\n")
+	for _, imp := range other {
+		p("\t%s\n", imp)
+	}
+	p(")\n\n")
+
+	p("// %s implements %s by invoking RPCs over a jsonrpc2.Conn.\n", client, typeName)
+	p("type %s struct {\n\tconn jsonrpc2.Conn\n}\n\n", client)
+	p("var _ %s = (*%s)(nil)\n\n", typeName, client)
+	p("// New%s returns a %s which invokes RPCs over conn.\n", client, client)
+	p("func New%s(conn jsonrpc2.Conn) *%s {\n\treturn &%s{conn: conn}\n}\n", client, client, client)
+
+	for _, m := range methods {
+		rpc := strconv.Quote(prefix + m.Name)
+		params, arg := "", "nil"
+		if m.Params != "" {
+			params, arg = ", "+m.ParamsName+" "+m.Params, m.ParamsName
+		}
+		p("\n// %s invokes %s.\n", m.Name, rpc)
+		if m.Result == "" {
+			p("func (c *%s) %s(ctx context.Context%s) error {\n", client, m.Name, params)
+			p("\t_, err := c.conn.Invoke(ctx, %s, %s)\n\treturn err\n}\n", rpc, arg)
+			continue
+		}
+		p("func (c *%s) %s(ctx context.Context%s) (%s, error) {\n", client, m.Name, params, m.Result)
+		p("\treturn jsonrpc2.InvokeAs[%s](ctx, c.conn, %s, %s)\n}\n", m.Result, rpc, arg)
+	}
+
+	p("\n// Register%s registers the methods of impl with mux.\n", typeName)
+	p("func Register%s(mux *jsonrpc2.ServeMux, impl %s) {\n", typeName, typeName)
+	for _, m := range methods {
+		rpc := strconv.Quote(prefix + m.Name)
+		if m.Params != "" && m.Result != "" {
+			p("\tmux.Handle(%s, jsonrpc2.Method(impl.%s))\n", rpc, m.Name)
+			continue
+		}
+
+		params, call := "_ struct{}", "ctx"
+		if m.Params != "" {
+			params, call = "params "+m.Params, "ctx, params"
+		}
+		p("\tmux.Handle(%s, jsonrpc2.Method(func(ctx context.Context, %s) (", rpc, params)
+		if m.Result != "" {
+			p("%s, error) {\n\t\treturn impl.%s(%s)\n\t}))\n", m.Result, m.Name, call)
+		} else {
+			p("interface{}, error) {\n\t\treturn nil, impl.%s(%s)\n\t}))\n", m.Name, call)
+		}
+	}
+	p("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	src, err := generate(filepath.Join("testdata", "greeter"), "Greeter", "greeter.")
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "greeter_jsonrpc2.go.golden")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, src, 0o644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(src))
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(src string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "svc.go"), []byte(src), 0o644))
+	}
+
+	_, err := generate(dir, "Missing", "")
+	require.Error(t, err)
+
+	write("package svc\n\nimport \"context\"\n\ntype Svc interface {\n\tNoContext(n int) error\n}\n\nvar _ context.Context\n")
+	_, err = generate(dir, "Svc", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "NoContext")
+
+	write("package svc\n\nimport \"context\"\n\ntype Svc interface {\n\tNoError(ctx context.Context) int\n}\n")
+	_, err = generate(dir, "Svc", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "NoError")
+
+	write("package svc\n\nimport \"context\"\n\ntype Svc interface {\n\tTwo(ctx context.Context, a, b int) error\n}\n")
+	_, err = generate(dir, "Svc", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Two")
+}
+
+func TestRun_Output(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.go")
+	require.NoError(t, run([]string{"-type", "Greeter", "-output", out, filepath.Join("testdata", "greeter")}, os.Stderr))
+	_, err := os.Stat(out)
+	require.NoError(t, err)
+
+	// An empty prefix leaves method names unprefixed.
+	require.NoError(t, run([]string{"-type", "Greeter", "-prefix", "", "-output", out, filepath.Join("testdata", "greeter")}, os.Stderr))
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Contains(t, string(src), `mux.Handle("Greet", jsonrpc2.Method(impl.Greet))`)
+
+	require.Error(t, run([]string{filepath.Join("testdata", "greeter")}, os.Stderr))
+}
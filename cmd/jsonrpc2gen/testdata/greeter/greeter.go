@@ -0,0 +1,18 @@
+package greeter
+
+import (
+	"context"
+	"time"
+)
+
+type Greeting struct {
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+type Greeter interface {
+	Greet(ctx context.Context, name string) (*Greeting, error)
+	History(ctx context.Context) ([]Greeting, error)
+	Forget(ctx context.Context, before time.Time) error
+	Reset(ctx context.Context) error
+}
@@ -0,0 +1,71 @@
+package jsonrpc2
+
+import (
+	"fmt"
+	"io"
+)
+
+// Codec converts frames between JSON and another encoding of the same data
+// model, such as MessagePack or CBOR. See the codec/msgpack and codec/cbor
+// packages for implementations.
+//
+// Codecs change the encoding used on the wire, which allows talking to peers
+// that send JSON-RPC 2.0 messages in a binary encoding. Handlers still see
+// params and results as JSON, so using a Codec adds the cost of converting
+// each frame rather than replacing the cost of encoding JSON.
+type Codec interface {
+	// Encode converts a JSON frame into the codec's encoding.
+	Encode(frame []byte) ([]byte, error)
+
+	// Decode converts a frame in the codec's encoding into JSON.
+	Decode(frame []byte) ([]byte, error)
+}
+
+// NewCodecFramer returns a Framer which uses c to encode frames written to f
+// and to decode frames read from f. Binary encodings need a Framer which
+// doesn't depend on the content of frames, such as a HeaderFramer:
+//
+//	f := jsonrpc2.NewCodecFramer(jsonrpc2.NewHeaderFramer(conn), msgpack.Codec{})
+//	cli := jsonrpc2.NewFramedClient(f, handler)
+//
+// ReadFrame fails for frames which can't be decoded, which closes a Client
+// using the Framer.
+//
+// If f implements io.Closer, the returned Framer will also implement
+// io.Closer.
+func NewCodecFramer(f Framer, c Codec) Framer {
+	return &codecFramer{f: f, c: c}
+}
+
+type codecFramer struct {
+	f Framer
+	c Codec
+}
+
+func (f *codecFramer) ReadFrame() ([]byte, error) {
+	frame, err := f.f.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := f.c.Decode(frame)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode frame: %w", err)
+	}
+	return decoded, nil
+}
+
+func (f *codecFramer) WriteFrame(frame []byte) error {
+	encoded, err := f.c.Encode(frame)
+	if err != nil {
+		return err
+	}
+	return f.f.WriteFrame(encoded)
+}
+
+// Close closes the underlying Framer if it implements io.Closer.
+func (f *codecFramer) Close() error {
+	if c, ok := f.f.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
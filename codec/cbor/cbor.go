@@ -0,0 +1,313 @@
+// Package cbor implements a jsonrpc2.Codec which sends JSON-RPC 2.0 messages
+// encoded as CBOR (RFC 8949).
+//
+// Only the JSON data model is supported: null, booleans, numbers, strings,
+// arrays, and maps with string keys. Integers which fit in 64 bits are sent
+// as CBOR integers, and other numbers as 64-bit floats. Byte strings, tags,
+// indefinite-length items, and simple values other than false, true, and
+// null can't be represented in JSON and fail to decode.
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// maxDepth limits how deeply arrays and maps may be nested in a decoded
+// frame.
+const maxDepth = 1000
+
+// Major types.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Codec converts frames between JSON and CBOR. It implements jsonrpc2.Codec.
+type Codec struct{}
+
+// Encode converts a JSON frame to CBOR.
+func (Codec) Encode(frame []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(frame))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return appendValue(nil, v)
+}
+
+func appendValue(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if v {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case json.Number:
+		return appendNumber(buf, v)
+	case string:
+		buf = appendHead(buf, majorText, uint64(len(v)))
+		return append(buf, v...), nil
+	case []interface{}:
+		buf = appendHead(buf, majorArray, uint64(len(v)))
+		for _, elem := range v {
+			var err error
+			if buf, err = appendValue(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		// Keys are sorted so that encoding is deterministic.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf = appendHead(buf, majorMap, uint64(len(v)))
+		for _, k := range keys {
+			buf = appendHead(buf, majorText, uint64(len(k)))
+			buf = append(buf, k...)
+			var err error
+			if buf, err = appendValue(buf, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", v)
+	}
+}
+
+func appendNumber(buf []byte, n json.Number) ([]byte, error) {
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return appendHead(buf, majorUint, u), nil
+	}
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil && i < 0 {
+		return appendHead(buf, majorNegInt, uint64(-1-i)), nil
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", n)
+	}
+	var b [9]byte
+	b[0] = 0xfb
+	binary.BigEndian.PutUint64(b[1:], math.Float64bits(f))
+	return append(buf, b[:]...), nil
+}
+
+// appendHead appends the initial byte and argument of an item, using the
+// shortest encoding of arg.
+func appendHead(buf []byte, major byte, arg uint64) []byte {
+	m := major << 5
+	switch {
+	case arg < 24:
+		return append(buf, m|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, m|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return append(buf, m|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		return append(buf, m|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	default:
+		var b [9]byte
+		b[0] = m | 27
+		binary.BigEndian.PutUint64(b[1:], arg)
+		return append(buf, b[:]...)
+	}
+}
+
+// Decode converts a CBOR frame to JSON.
+func (Codec) Decode(frame []byte) ([]byte, error) {
+	d := decoder{buf: frame}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.buf) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after value", len(d.buf))
+	}
+	return json.Marshal(v)
+}
+
+var errShort = errors.New("unexpected end of frame")
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) next(n uint64) ([]byte, error) {
+	if uint64(len(d.buf)) < n {
+		return nil, errShort
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+// head reads the initial byte and argument of an item. For major type 7,
+// arg holds the raw bits of floats and the value of simple values.
+func (d *decoder) head() (major byte, info byte, arg uint64, err error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		b, err := d.next(1 << (info - 24))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, c := range b {
+			arg = arg<<8 | uint64(c)
+		}
+		return major, info, arg, nil
+	case info == 31:
+		return 0, 0, 0, fmt.Errorf("indefinite-length items are not supported")
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid additional information %d", info)
+	}
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("values nested deeper than %d", maxDepth)
+	}
+
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		return json.Number(strconv.FormatUint(arg, 10)), nil
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			// -1-arg doesn't fit in an int64.
+			n := new(big.Int).SetUint64(arg)
+			return json.Number(n.Neg(n.Add(n, big.NewInt(1))).String()), nil
+		}
+		return json.Number(strconv.FormatInt(-1-int64(arg), 10)), nil
+	case majorText:
+		b, err := d.next(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		// Every element takes at least one byte, which bounds the
+		// allocation by the size of the frame.
+		if arg > uint64(len(d.buf)) {
+			return nil, errShort
+		}
+		arr := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case majorMap:
+		if arg > uint64(len(d.buf)/2) {
+			return nil, errShort
+		}
+		obj := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported map key of type %T", k)
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = v
+		}
+		return obj, nil
+	case majorSimple:
+		return simpleValue(info, arg)
+	case majorBytes:
+		return nil, fmt.Errorf("byte strings are not supported")
+	default:
+		return nil, fmt.Errorf("tags are not supported")
+	}
+}
+
+func simpleValue(info byte, arg uint64) (interface{}, error) {
+	var f float64
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22:
+		return nil, nil
+	case 25:
+		f = halfToFloat(uint16(arg))
+	case 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case 27:
+		f = math.Float64frombits(arg)
+	default:
+		return nil, fmt.Errorf("unsupported simple value %d", arg)
+	}
+
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported number %v", f)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// halfToFloat converts an IEEE 754 half-precision float to a float64.
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,103 @@
+package cbor
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_Encode(t *testing.T) {
+	tt := []struct {
+		json   string
+		expect string
+	}{
+		{json: `null`, expect: "f6"},
+		{json: `false`, expect: "f4"},
+		{json: `10`, expect: "0a"},
+		{json: `1000`, expect: "1903e8"},
+		{json: `-1000`, expect: "3903e7"},
+		{json: `1.5`, expect: "fb3ff8000000000000"},
+		{json: `"a"`, expect: "6161"},
+		{json: `[1,[2]]`, expect: "82018102"},
+		{json: `{"b":1,"a":2}`, expect: "a2616102616201"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.json, func(t *testing.T) {
+			out, err := Codec{}.Encode([]byte(tc.json))
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, hex.EncodeToString(out))
+
+			back, err := Codec{}.Decode(out)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.json, string(back))
+		})
+	}
+}
+
+func TestCodec_Decode(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect string
+	}{
+		{input: "f93e00", expect: `1.5`},
+		{input: "fa3fc00000", expect: `1.5`},
+		{input: "3bffffffffffffffff", expect: `-18446744073709551616`},
+		{input: "1b0000000100000000", expect: `4294967296`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			in, err := hex.DecodeString(tc.input)
+			require.NoError(t, err)
+			out, err := Codec{}.Decode(in)
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, string(out))
+		})
+	}
+}
+
+func TestCodec_Decode_Invalid(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "truncated string", input: "6568"},
+		{name: "oversized array", input: "9bffffffffffffffff"},
+		{name: "indefinite array", input: "9f01ff"},
+		{name: "byte string", input: "4100"},
+		{name: "tag", input: "c11a514b67b0"},
+		{name: "non-string key", input: "a10101"},
+		{name: "infinity", input: "f97c00"},
+		{name: "undefined", input: "f7"},
+		{name: "trailing bytes", input: "f6f6"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := hex.DecodeString(tc.input)
+			require.NoError(t, err)
+			_, err = Codec{}.Decode(in)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCodec_Client(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewFramedClient(
+		jsonrpc2.NewCodecFramer(jsonrpc2.NewHeaderFramer(srvConn), Codec{}),
+		jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			_ = w.WriteMessage(r.Params)
+		}),
+	)
+	defer srv.Close()
+	cli := jsonrpc2.NewFramedClient(jsonrpc2.NewCodecFramer(jsonrpc2.NewHeaderFramer(cliConn), Codec{}), nil)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "echo", []interface{}{1.25, "hello", nil})
+	require.NoError(t, err)
+	require.JSONEq(t, `[1.25, "hello", null]`, string(res))
+}
@@ -0,0 +1,325 @@
+// Package msgpack implements a jsonrpc2.Codec which sends JSON-RPC 2.0
+// messages encoded as MessagePack.
+//
+// Only the JSON data model is supported: nil, booleans, numbers, strings,
+// arrays, and maps with string keys. Integers which fit in an int64 or
+// uint64 are sent as MessagePack integers, and other numbers as 64-bit
+// floats. Binary and extension values can't be represented in JSON and fail
+// to decode.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// maxDepth limits how deeply arrays and maps may be nested in a decoded
+// frame.
+const maxDepth = 1000
+
+// Codec converts frames between JSON and MessagePack. It implements
+// jsonrpc2.Codec.
+type Codec struct{}
+
+// Encode converts a JSON frame to MessagePack.
+func (Codec) Encode(frame []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(frame))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return appendValue(nil, v)
+}
+
+func appendValue(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case json.Number:
+		return appendNumber(buf, v)
+	case string:
+		return appendString(buf, v), nil
+	case []interface{}:
+		buf = appendLength(buf, len(v), 0x90, 16, 0xdc, 0xdd)
+		for _, elem := range v {
+			var err error
+			if buf, err = appendValue(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		// Keys are sorted so that encoding is deterministic.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf = appendLength(buf, len(v), 0x80, 16, 0xde, 0xdf)
+		for _, k := range keys {
+			buf = appendString(buf, k)
+			var err error
+			if buf, err = appendValue(buf, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", v)
+	}
+}
+
+func appendNumber(buf []byte, n json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i < 128:
+			return append(buf, byte(i)), nil
+		case i < 0 && i >= -32:
+			return append(buf, byte(int8(i))), nil
+		default:
+			buf = append(buf, 0xd3)
+			return appendUint64(buf, uint64(i)), nil
+		}
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		buf = append(buf, 0xcf)
+		return appendUint64(buf, u), nil
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", n)
+	}
+	buf = append(buf, 0xcb)
+	return appendUint64(buf, math.Float64bits(f)), nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	default:
+		buf = appendLength(buf, n, 0, 0, 0xda, 0xdb)
+	}
+	return append(buf, s...)
+}
+
+// appendLength appends the header for an array, map, or string of length n.
+// Lengths less than fixMax are encoded in the fix byte; otherwise the 16 or
+// 32 bit form is used.
+func appendLength(buf []byte, n int, fix byte, fixMax int, code16, code32 byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, code16)
+		return append(buf, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, code32)
+		return append(buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+func appendUint64(buf []byte, u uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	return append(buf, b[:]...)
+}
+
+// Decode converts a MessagePack frame to JSON.
+func (Codec) Decode(frame []byte) ([]byte, error) {
+	d := decoder{buf: frame}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.buf) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after value", len(d.buf))
+	}
+	return json.Marshal(v)
+}
+
+var errShort = errors.New("unexpected end of frame")
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.buf) < n {
+		return nil, errShort
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("values nested deeper than %d", maxDepth)
+	}
+
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		return json.Number(strconv.Itoa(int(code))), nil
+	case code >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(code)))), nil
+	case code&0xe0 == 0xa0:
+		return d.string(int(code & 0x1f))
+	case code&0xf0 == 0x90:
+		return d.array(int(code&0x0f), depth)
+	case code&0xf0 == 0x80:
+		return d.object(int(code&0x0f), depth)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (code - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend from the encoded size.
+		shift := 64 - 8*uint(size)
+		i := int64(u<<shift) >> shift
+		return json.Number(strconv.FormatInt(i, 10)), nil
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return floatNumber(float64(math.Float32frombits(uint32(u))))
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return floatNumber(math.Float64frombits(u))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.string(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n), depth)
+	default:
+		return nil, fmt.Errorf("unsupported MessagePack type 0x%02x", code)
+	}
+}
+
+func floatNumber(f float64) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported number %v", f)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+func (d *decoder) string(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(n int, depth int) (interface{}, error) {
+	// Every element takes at least one byte, which bounds the allocation by
+	// the size of the frame.
+	if n > len(d.buf) {
+		return nil, errShort
+	}
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func (d *decoder) object(n int, depth int) (interface{}, error) {
+	if n > len(d.buf)/2 {
+		return nil, errShort
+	}
+	obj := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported map key of type %T", k)
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = v
+	}
+	return obj, nil
+}
@@ -0,0 +1,104 @@
+package msgpack
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_Encode(t *testing.T) {
+	tt := []struct {
+		json   string
+		expect string
+	}{
+		{json: `null`, expect: "c0"},
+		{json: `true`, expect: "c3"},
+		{json: `5`, expect: "05"},
+		{json: `-3`, expect: "fd"},
+		{json: `300`, expect: "d3000000000000012c"},
+		{json: `18446744073709551615`, expect: "cfffffffffffffffff"},
+		{json: `1.5`, expect: "cb3ff8000000000000"},
+		{json: `"hi"`, expect: "a26869"},
+		{json: `[1,2]`, expect: "920102"},
+		{json: `{"b":1,"a":2}`, expect: "82a16102a16201"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.json, func(t *testing.T) {
+			out, err := Codec{}.Encode([]byte(tc.json))
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, hex.EncodeToString(out))
+
+			back, err := Codec{}.Decode(out)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.json, string(back))
+		})
+	}
+}
+
+func TestCodec_Decode(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect string
+	}{
+		{input: "cc80", expect: `128`},
+		{input: "d0ff", expect: `-1`},
+		{input: "d1fc18", expect: `-1000`},
+		{input: "ca3fc00000", expect: `1.5`},
+		{input: "d90161", expect: `"a"`},
+		{input: "dc000101", expect: `[1]`},
+		{input: "de0001a16101", expect: `{"a":1}`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			in, err := hex.DecodeString(tc.input)
+			require.NoError(t, err)
+			out, err := Codec{}.Decode(in)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expect, string(out))
+		})
+	}
+}
+
+func TestCodec_Decode_Invalid(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "truncated string", input: "a568"},
+		{name: "oversized array", input: "ddffffffff"},
+		{name: "non-string key", input: "810101"},
+		{name: "binary", input: "c40100"},
+		{name: "NaN", input: "cb7ff8000000000000"},
+		{name: "trailing bytes", input: "c0c0"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			in, err := hex.DecodeString(tc.input)
+			require.NoError(t, err)
+			_, err = Codec{}.Decode(in)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCodec_Client(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewFramedClient(
+		jsonrpc2.NewCodecFramer(jsonrpc2.NewHeaderFramer(srvConn), Codec{}),
+		jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			_ = w.WriteMessage(r.Params)
+		}),
+	)
+	defer srv.Close()
+	cli := jsonrpc2.NewFramedClient(jsonrpc2.NewCodecFramer(jsonrpc2.NewHeaderFramer(cliConn), Codec{}), nil)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "echo", map[string]interface{}{"n": 1, "s": "hello"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"n": 1, "s": "hello"}`, string(res))
+}
@@ -0,0 +1,82 @@
+package jsonrpc2
+
+import (
+	"fmt"
+	"net"
+)
+
+// AllowCIDRs returns a filter for Server.ConnFilter which only accepts
+// connections from addresses within one of the given CIDR ranges, such as
+// "10.0.0.0/8" or "::1/128". Connections from addresses which are not IP
+// addresses, such as Unix sockets, are rejected.
+func AllowCIDRs(cidrs ...string) (func(addr net.Addr) error, error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return func(addr net.Addr) error {
+		ip := addrIP(addr)
+		if ip == nil {
+			return fmt.Errorf("address %s is not an IP address", addr)
+		}
+		if !containsIP(nets, ip) {
+			return fmt.Errorf("address %s is not allowed", ip)
+		}
+		return nil
+	}, nil
+}
+
+// DenyCIDRs returns a filter for Server.ConnFilter which rejects
+// connections from addresses within any of the given CIDR ranges.
+// Connections from addresses which are not IP addresses are accepted.
+func DenyCIDRs(cidrs ...string) (func(addr net.Addr) error, error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return func(addr net.Addr) error {
+		if ip := addrIP(addr); ip != nil && containsIP(nets, ip) {
+			return fmt.Errorf("address %s is denied", ip)
+		}
+		return nil
+	}, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrIP returns the IP address of addr, or nil if addr doesn't have one.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,67 @@
+package jsonrpc2
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowCIDRs(t *testing.T) {
+	filter, err := AllowCIDRs("10.0.0.0/8", "::1/128")
+	require.NoError(t, err)
+
+	tt := []struct {
+		addr    net.Addr
+		allowed bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 80}, true},
+		{&net.TCPAddr{IP: net.ParseIP("::1"), Port: 80}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 80}, false},
+		{&net.UnixAddr{Name: "/tmp/sock", Net: "unix"}, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.addr.String(), func(t *testing.T) {
+			err := filter(tc.addr)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+
+	_, err = AllowCIDRs("10.0.0.0")
+	require.Error(t, err)
+}
+
+func TestDenyCIDRs(t *testing.T) {
+	filter, err := DenyCIDRs("192.168.0.0/16")
+	require.NoError(t, err)
+
+	require.Error(t, filter(&net.TCPAddr{IP: net.ParseIP("192.168.4.5")}))
+	require.NoError(t, filter(&net.TCPAddr{IP: net.ParseIP("10.0.0.1")}))
+	require.NoError(t, filter(&net.UnixAddr{Name: "/tmp/sock", Net: "unix"}))
+}
+
+func TestServer_ConnFilter(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	filter, err := DenyCIDRs("127.0.0.0/8")
+	require.NoError(t, err)
+
+	srv := Server{ConnFilter: filter}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The server should close the connection without reading from it.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+}
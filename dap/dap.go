@@ -0,0 +1,475 @@
+// Package dap implements the framing and message model of the Debug Adapter
+// Protocol (DAP), allowing debugger frontends and backends to be built with
+// jsonrpc2 handlers.
+//
+// DAP is similar to JSON-RPC 2.0, but uses sequence numbers instead of IDs
+// and distinguishes between requests, responses, and events. A Conn maps
+// these onto the jsonrpc2 API:
+//
+//   - Incoming requests are passed to a jsonrpc2.Handler, with the command as
+//     the method and the arguments as the params.
+//   - Incoming events are passed to the same Handler as notifications, with
+//     the event name as the method and the event body as the params.
+//   - Conn.Invoke sends a request and waits for its response.
+//   - Conn.Notify sends an event.
+package dap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/crtv-io/jsonrpc2"
+	"go.uber.org/atomic"
+)
+
+// Message types.
+const (
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeEvent    = "event"
+)
+
+// Message is a DAP protocol message. Which fields are set depends on Type.
+type Message struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"`
+
+	// Command is set for requests and responses.
+	Command string `json:"command,omitempty"`
+	// Arguments is set for requests.
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	// RequestSeq, Success, and Message are set for responses.
+	RequestSeq int    `json:"request_seq,omitempty"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+
+	// Event is set for events.
+	Event string `json:"event,omitempty"`
+
+	// Body is set for responses and events.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, omitting the success field for
+// messages which are not responses.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if m.Type == TypeResponse {
+		return json.Marshal(plain(m))
+	}
+
+	type nonResponse struct {
+		plain
+		Success bool `json:"success,omitempty"`
+	}
+	return json.Marshal(nonResponse{plain: plain(m)})
+}
+
+// ResponseError is returned by Invoke when a request fails.
+type ResponseError struct {
+	Command string
+	Message string
+	Body    json.RawMessage
+}
+
+// Error implements error.
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Command, e.Message)
+}
+
+// ConnOpt is an option function that can be passed to NewConn.
+type ConnOpt func(*Conn)
+
+// WithLogger sets the Conn to use a logger.
+func WithLogger(l jsonrpc2.Logger) ConnOpt {
+	return func(c *Conn) {
+		if l != nil {
+			c.log = l
+		}
+	}
+}
+
+// WithMaxMessageSize sets the largest message the Conn will read, in bytes.
+// The connection is closed if the other side sends a larger message. The
+// default is DefaultMaxMessageSize.
+func WithMaxMessageSize(n int) ConnOpt {
+	return func(c *Conn) {
+		if f, ok := c.f.(*jsonrpc2.HeaderFramer); ok && n > 0 {
+			f.SetMaxFrameSize(n)
+		}
+	}
+}
+
+// WithMaxPendingEvents sets how many received events may wait to be handled.
+// Events can't be refused, so the connection is closed if the other side
+// sends more events while the limit is reached. The default is
+// DefaultMaxPendingEvents.
+func WithMaxPendingEvents(n int) ConnOpt {
+	return func(c *Conn) {
+		if n > 0 {
+			c.maxEvents = n
+		}
+	}
+}
+
+// WithMaxConcurrentRequests sets how many received requests may be handled
+// at once. Requests received while the limit is reached fail without being
+// passed to the handler. The default is DefaultMaxConcurrentRequests.
+func WithMaxConcurrentRequests(n int) ConnOpt {
+	return func(c *Conn) {
+		if n > 0 {
+			c.maxRequests = n
+		}
+	}
+}
+
+// Defaults for the limits of a Conn.
+const (
+	DefaultMaxPendingEvents      = 1024
+	DefaultMaxConcurrentRequests = 64
+)
+
+// Conn is a DAP connection.
+type Conn struct {
+	log     jsonrpc2.Logger
+	handler jsonrpc2.Handler
+
+	maxEvents   int
+	maxRequests int
+	requests    chan struct{}
+
+	f     jsonrpc2.Framer
+	txMut sync.Mutex
+
+	seq     *atomic.Int64
+	pending sync.Map // int -> chan Message
+
+	// Events are handled one at a time in the order they were received.
+	eventMut   sync.Mutex
+	events     []Message
+	eventReady chan struct{}
+
+	done chan struct{}
+}
+
+var _ jsonrpc2.Conn = (*Conn)(nil)
+
+// NewConn creates a Conn and starts reading messages from rw. handler will be
+// invoked for each request and event received. If handler is nil,
+// jsonrpc2.DefaultHandler is used.
+//
+// If rw implements io.Closer, it will be closed when the Conn is closed.
+func NewConn(rw io.ReadWriter, handler jsonrpc2.Handler, opts ...ConnOpt) *Conn {
+	if handler == nil {
+		handler = jsonrpc2.DefaultHandler
+	}
+
+	c := &Conn{
+		log:     jsonrpc2.NewNopLogger(),
+		handler: handler,
+
+		maxEvents:   DefaultMaxPendingEvents,
+		maxRequests: DefaultMaxConcurrentRequests,
+
+		f:   NewFramer(rw),
+		seq: atomic.NewInt64(0),
+
+		eventReady: make(chan struct{}, 1),
+
+		done: make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	c.requests = make(chan struct{}, c.maxRequests)
+	go c.processMessages()
+	go c.processEvents()
+	return c
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	if cl, ok := c.f.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the Conn has closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Invoke sends a request for command and waits for its response. The
+// response body is returned on success. If the request failed, a
+// *ResponseError is returned.
+func (c *Conn) Invoke(ctx context.Context, command string, args interface{}) (json.RawMessage, error) {
+	bb, err := marshalOptional(args)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := int(c.seq.Inc())
+	respCh := make(chan Message, 1)
+	c.pending.Store(seq, respCh)
+	defer c.pending.Delete(seq)
+
+	err = c.send(Message{
+		Seq:       seq,
+		Type:      TypeRequest,
+		Command:   command,
+		Arguments: bb,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, io.ErrClosedPipe
+	case resp := <-respCh:
+		if !resp.Success {
+			return nil, &ResponseError{Command: resp.Command, Message: resp.Message, Body: resp.Body}
+		}
+		return resp.Body, nil
+	}
+}
+
+// Notify sends an event.
+func (c *Conn) Notify(event string, body interface{}) error {
+	bb, err := marshalOptional(body)
+	if err != nil {
+		return err
+	}
+	return c.send(Message{
+		Seq:   int(c.seq.Inc()),
+		Type:  TypeEvent,
+		Event: event,
+		Body:  bb,
+	})
+}
+
+func marshalOptional(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (c *Conn) send(msg Message) error {
+	bb, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.txMut.Lock()
+	defer c.txMut.Unlock()
+	return c.f.WriteFrame(bb)
+}
+
+// processMessages runs in the background and handles incoming messages.
+func (c *Conn) processMessages() {
+	defer close(c.done)
+
+	for {
+		frame, err := c.f.ReadFrame()
+		if err != nil {
+			jsonrpc2.LevelInfo.Log(c.log, "msg", "closing conn", "err", err)
+			_ = c.Close()
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(frame, &msg); err != nil {
+			jsonrpc2.LevelWarn.Log(c.log, "msg", "dropping invalid message", "err", err)
+			continue
+		}
+
+		switch msg.Type {
+		case TypeRequest:
+			select {
+			case c.requests <- struct{}{}:
+				go c.handleRequest(msg)
+			default:
+				c.rejectRequest(msg)
+			}
+		case TypeEvent:
+			if !c.queueEvent(msg) {
+				jsonrpc2.LevelWarn.Log(c.log, "msg", "closing conn: too many pending events")
+				_ = c.Close()
+			}
+		case TypeResponse:
+			ch, ok := c.pending.Load(msg.RequestSeq)
+			if !ok {
+				jsonrpc2.LevelWarn.Log(c.log, "msg", "missing listener for response", "request_seq", msg.RequestSeq)
+				continue
+			}
+			select {
+			case ch.(chan Message) <- msg:
+			default:
+				jsonrpc2.LevelWarn.Log(c.log, "msg", "dropping duplicate response", "request_seq", msg.RequestSeq)
+			}
+		default:
+			jsonrpc2.LevelWarn.Log(c.log, "msg", "dropping message with unknown type", "type", msg.Type)
+		}
+	}
+}
+
+// queueEvent queues an event to be handled. false is returned if too many
+// events are already waiting.
+func (c *Conn) queueEvent(msg Message) bool {
+	c.eventMut.Lock()
+	if len(c.events) >= c.maxEvents {
+		c.eventMut.Unlock()
+		return false
+	}
+	c.events = append(c.events, msg)
+	c.eventMut.Unlock()
+
+	select {
+	case c.eventReady <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// processEvents runs in the background and passes events to the handler in
+// the order they were received. Events are handled outside of
+// processMessages so that handlers may invoke requests.
+func (c *Conn) processEvents() {
+	for {
+		c.eventMut.Lock()
+		if len(c.events) == 0 {
+			c.eventMut.Unlock()
+			select {
+			case <-c.eventReady:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+		msg := c.events[0]
+		c.events[0] = Message{}
+		c.events = c.events[1:]
+		c.eventMut.Unlock()
+
+		c.handler.ServeRPC(discardWriter{}, &jsonrpc2.Request{
+			Notification: true,
+			Method:       msg.Event,
+			Params:       msg.Body,
+		})
+	}
+}
+
+func (c *Conn) handleRequest(req Message) {
+	defer func() { <-c.requests }()
+
+	w := &responseWriter{}
+	c.handler.ServeRPC(w, &jsonrpc2.Request{
+		Method: req.Command,
+		Params: req.Arguments,
+	})
+	c.respond(req, w)
+}
+
+// rejectRequest fails a request received while too many requests are being
+// handled. The response is sent from the read loop, so a peer which floods
+// requests is slowed down rather than spawning goroutines.
+func (c *Conn) rejectRequest(req Message) {
+	w := &responseWriter{}
+	_ = w.WriteError(jsonrpc2.ErrorServerBusy, errors.New("too many concurrent requests"))
+	c.respond(req, w)
+}
+
+// respond sends the response written to w for req.
+func (c *Conn) respond(req Message, w *responseWriter) {
+	resp := Message{
+		Seq:        int(c.seq.Inc()),
+		Type:       TypeResponse,
+		Command:    req.Command,
+		RequestSeq: req.Seq,
+		Success:    w.err == nil,
+		Body:       w.body,
+	}
+	if w.err != nil {
+		resp.Message = w.err.Message
+		resp.Body = w.err.Body
+	}
+
+	if err := c.send(resp); err != nil {
+		jsonrpc2.LevelWarn.Log(c.log, "msg", "failed to send response", "err", err)
+	}
+}
+
+// responseWriter implements jsonrpc2.ResponseWriter for DAP requests.
+type responseWriter struct {
+	mut  sync.Mutex
+	set  bool
+	body json.RawMessage
+	err  *ResponseError
+}
+
+func (w *responseWriter) WriteMessage(msg interface{}) error {
+	bb, err := marshalOptional(msg)
+	if err != nil {
+		return err
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	if w.set {
+		return fmt.Errorf("response already set")
+	}
+	w.set = true
+	w.body = bb
+	return nil
+}
+
+// WriteError writes a failed response. The body of the response holds a DAP
+// Message object with errorCode as its id:
+//
+//	{"error": {"id": 1, "format": "error message"}}
+func (w *responseWriter) WriteError(errorCode int, err error) error {
+	message := err.Error()
+	var rpcErr jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		message = rpcErr.Message
+	}
+
+	body, marshalErr := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"id":     errorCode,
+			"format": message,
+		},
+	})
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	if w.set {
+		return fmt.Errorf("response already set")
+	}
+	w.set = true
+	w.err = &ResponseError{Message: message, Body: body}
+	return nil
+}
+
+// discardWriter is passed to handlers for events, which have no response.
+type discardWriter struct{}
+
+func (discardWriter) WriteMessage(msg interface{}) error {
+	return fmt.Errorf("cannot write message for event")
+}
+
+func (discardWriter) WriteError(errorCode int, err error) error {
+	return fmt.Errorf("cannot write message for event")
+}
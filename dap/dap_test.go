@@ -0,0 +1,260 @@
+package dap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramer(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf)
+
+	require.NoError(t, f.WriteFrame([]byte(`{"seq":1}`)))
+	require.NoError(t, f.WriteFrame([]byte(`{"seq":2}`)))
+	require.Equal(t, "Content-Length: 9\r\n\r\n{\"seq\":1}Content-Length: 9\r\n\r\n{\"seq\":2}", buf.String())
+
+	frame, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"seq":1}`, string(frame))
+
+	frame, err = f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"seq":2}`, string(frame))
+}
+
+func TestMessage_MarshalJSON(t *testing.T) {
+	bb, err := json.Marshal(Message{Seq: 1, Type: TypeEvent, Event: "stopped"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"seq": 1, "type": "event", "event": "stopped"}`, string(bb))
+
+	bb, err = json.Marshal(Message{Seq: 2, Type: TypeResponse, Command: "next", RequestSeq: 1})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"seq": 2, "type": "response", "command": "next", "request_seq": 1, "success": false}`, string(bb))
+}
+
+func TestConn(t *testing.T) {
+	srvEvents := make(chan *jsonrpc2.Request, 1)
+	srvHandler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		if r.Notification {
+			srvEvents <- r
+			return
+		}
+
+		switch r.Method {
+		case "evaluate":
+			var args struct{ Expression string }
+			if err := r.DecodeParams(&args); err != nil {
+				_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+				return
+			}
+			_ = w.WriteMessage(map[string]string{"result": args.Expression})
+		default:
+			_ = w.WriteError(1014, errors.New("unrecognized request"))
+		}
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewConn(srvConn, srvHandler)
+	defer srv.Close()
+	cli := NewConn(cliConn, nil)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("request", func(t *testing.T) {
+		body, err := cli.Invoke(ctx, "evaluate", map[string]string{"expression": "1+1"})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"result": "1+1"}`, string(body))
+	})
+
+	t.Run("failed request", func(t *testing.T) {
+		_, err := cli.Invoke(ctx, "launch", nil)
+
+		var respErr *ResponseError
+		require.True(t, errors.As(err, &respErr))
+		require.Equal(t, "launch", respErr.Command)
+		require.Equal(t, "unrecognized request", respErr.Message)
+		require.JSONEq(t, `{"error": {"id": 1014, "format": "unrecognized request"}}`, string(respErr.Body))
+	})
+
+	t.Run("event", func(t *testing.T) {
+		require.NoError(t, cli.Notify("output", map[string]string{"output": "hello"}))
+
+		select {
+		case ev := <-srvEvents:
+			require.Equal(t, "output", ev.Method)
+			require.JSONEq(t, `{"output": "hello"}`, string(ev.Params))
+		case <-ctx.Done():
+			require.FailNow(t, "timed out waiting for event")
+		}
+	})
+}
+
+func TestFramer_MaxMessageSize(t *testing.T) {
+	buf := bytes.NewBufferString("Content-Length: 1099511627776\r\n\r\n{}")
+	_, err := NewFramer(buf).ReadFrame()
+	require.Error(t, err)
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewConn(srvConn, nil, WithMaxMessageSize(8))
+	defer srv.Close()
+	cli := NewConn(cliConn, nil)
+	defer cli.Close()
+
+	go func() { _ = cli.Notify("output", map[string]string{"output": "too long"}) }()
+
+	select {
+	case <-srv.Done():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "conn not closed after oversized message")
+	}
+}
+
+func TestConn_EventOrder(t *testing.T) {
+	const count = 100
+
+	events := make(chan string, count)
+	srvHandler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var body struct{ N string }
+		_ = r.DecodeParams(&body)
+		events <- body.N
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewConn(srvConn, srvHandler)
+	defer srv.Close()
+	cli := NewConn(cliConn, nil)
+	defer cli.Close()
+
+	go func() {
+		for i := 0; i < count; i++ {
+			_ = cli.Notify("output", map[string]string{"n": strconv.Itoa(i)})
+		}
+	}()
+
+	for i := 0; i < count; i++ {
+		select {
+		case n := <-events:
+			require.Equal(t, strconv.Itoa(i), n)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for event")
+		}
+	}
+}
+
+func TestConn_DuplicateResponse(t *testing.T) {
+	events := make(chan string, 1)
+	cliHandler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		events <- r.Method
+	})
+
+	srvConn, cliConn := net.Pipe()
+	cli := NewConn(cliConn, cliHandler)
+	defer cli.Close()
+
+	// Register a listener that never reads, as if its Invoke had already
+	// stopped waiting.
+	cli.pending.Store(1, make(chan Message, 1))
+
+	peer := NewFramer(srvConn)
+	go func() {
+		for i := 0; i < 2; i++ {
+			resp, _ := json.Marshal(Message{Seq: i + 1, Type: TypeResponse, Command: "next", RequestSeq: 1, Success: true})
+			_ = peer.WriteFrame(resp)
+		}
+		ev, _ := json.Marshal(Message{Seq: 3, Type: TypeEvent, Event: "stopped"})
+		_ = peer.WriteFrame(ev)
+	}()
+
+	select {
+	case ev := <-events:
+		require.Equal(t, "stopped", ev)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "conn read loop blocked on duplicate response")
+	}
+}
+
+func TestConn_MaxConcurrentRequests(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srvHandler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		_ = w.WriteMessage(nil)
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewConn(srvConn, srvHandler, WithMaxConcurrentRequests(1))
+	defer srv.Close()
+	cli := NewConn(cliConn, nil)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cli.Invoke(ctx, "continue", nil)
+		errs <- err
+	}()
+	<-started
+
+	// The second request fails while the first is being handled.
+	_, err := cli.Invoke(ctx, "next", nil)
+	var respErr *ResponseError
+	require.ErrorAs(t, err, &respErr)
+	require.Equal(t, "too many concurrent requests", respErr.Message)
+
+	close(release)
+	require.NoError(t, <-errs)
+
+	// Once it's done, requests are handled again. The slot is only freed
+	// after the response has been sent, so it may take a moment.
+	require.Eventually(t, func() bool {
+		_, err := cli.Invoke(ctx, "next", nil)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConn_MaxPendingEvents(t *testing.T) {
+	release := make(chan struct{})
+	srvHandler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		<-release
+	})
+	defer close(release)
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewConn(srvConn, srvHandler, WithMaxPendingEvents(2))
+	defer srv.Close()
+
+	// The first event is being handled and two more are queued, so the
+	// fourth closes the connection.
+	peer := NewFramer(cliConn)
+	go func() {
+		for i := 0; i < 4; i++ {
+			ev, _ := json.Marshal(Message{Seq: i + 1, Type: TypeEvent, Event: "output"})
+			if err := peer.WriteFrame(ev); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-srv.Done():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "conn should close when too many events are pending")
+	}
+}
@@ -0,0 +1,30 @@
+package dap
+
+import (
+	"io"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// NewFramer returns a jsonrpc2.Framer which reads and writes messages using
+// the DAP base protocol, where each message is prefixed with a
+// Content-Length header:
+//
+//	Content-Length: 119\r\n
+//	\r\n
+//	{"seq": 153, "type": "request", ...}
+//
+// This is the same framing as jsonrpc2.HeaderFramer. Messages larger than
+// DefaultMaxMessageSize are rejected with an error.
+//
+// If rw implements io.Closer, the returned Framer will also implement
+// io.Closer.
+func NewFramer(rw io.ReadWriter) jsonrpc2.Framer {
+	f := jsonrpc2.NewHeaderFramer(rw)
+	f.SetMaxFrameSize(DefaultMaxMessageSize)
+	return f
+}
+
+// DefaultMaxMessageSize is the default limit for the size of messages read
+// by a Framer, in bytes.
+const DefaultMaxMessageSize = 16 << 20
@@ -0,0 +1,107 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"sync"
+)
+
+// WithDecodeWorkers sets the Client to decode messages on n worker
+// goroutines instead of in its read loop. The read loop then only reads
+// frames, so it keeps reading, and notices the connection closing, while
+// large messages are decoded. Messages are still dispatched to handlers in
+// the order they were read, so a small message read after a large one waits
+// for the large one to be decoded, but is itself decoded in parallel.
+//
+// Frames are copied out of the read buffer before being handed to workers,
+// which costs an extra copy per message. Up to 2n frames are buffered
+// before the read loop waits for decoding to catch up. If n is zero or
+// less, messages are decoded in the read loop, which is the default.
+func WithDecodeWorkers(n int) ClientOpt {
+	return func(c *Client) {
+		c.decodeWorkers = n
+	}
+}
+
+type decodeResult struct {
+	msg txMessage
+	err error
+}
+
+type decodeJob struct {
+	frame []byte
+	out   chan<- decodeResult
+}
+
+// decodePipeline reads frames from a transport and decodes them on worker
+// goroutines, delivering the results in the order the frames were read.
+type decodePipeline struct {
+	tx *transport
+
+	// order holds a channel for each frame read, in order, which receives
+	// the frame's decoded message.
+	order chan chan decodeResult
+	jobs  chan decodeJob
+	stop  <-chan struct{}
+}
+
+// newDecodePipeline starts reading frames from tx with n decode workers.
+// The pipeline stops once reading fails or stop is closed.
+func newDecodePipeline(tx *transport, n int, stop <-chan struct{}) *decodePipeline {
+	p := &decodePipeline{
+		tx:    tx,
+		order: make(chan chan decodeResult, 2*n),
+		jobs:  make(chan decodeJob, n),
+		stop:  stop,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range p.jobs {
+				msg, err := decodeMessage(job.frame)
+				job.out <- decodeResult{msg: msg, err: err}
+			}
+		}()
+	}
+	go p.readFrames()
+	return p
+}
+
+func (p *decodePipeline) readFrames() {
+	defer close(p.jobs)
+
+	for {
+		frame, err := p.tx.ReadFrame()
+
+		out := make(chan decodeResult, 1)
+		select {
+		case p.order <- out:
+		case <-p.stop:
+			return
+		}
+
+		var tooLarge *FrameTooLargeError
+		if errors.As(err, &tooLarge) {
+			out <- decodeResult{msg: tooLargeMessage(tooLarge)}
+			continue
+		}
+		if err != nil {
+			out <- decodeResult{err: err}
+			return
+		}
+		select {
+		case p.jobs <- decodeJob{frame: frame, out: out}:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// ReadMessage returns the next message, in the order frames were read. It
+// must not be called after a read error is returned.
+func (p *decodePipeline) ReadMessage() (txMessage, error) {
+	res := <-<-p.order
+	return res.msg, res.err
+}
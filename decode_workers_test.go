@@ -0,0 +1,79 @@
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePipeline_Order(t *testing.T) {
+	srvConn, peerConn := net.Pipe()
+	defer srvConn.Close()
+	defer peerConn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	p := newDecodePipeline(newTransport(srvConn), 4, stop)
+
+	// Alternate large and small messages, so small ones finish decoding
+	// first.
+	large := strings.Repeat("x", 1<<20)
+	go func() {
+		for i := 0; i < 10; i++ {
+			payload := "small"
+			if i%2 == 0 {
+				payload = large
+			}
+			frame := fmt.Sprintf(`{"jsonrpc": "2.0", "method": "%d", "params": %q}`, i, payload)
+			if _, err := peerConn.Write([]byte(frame)); err != nil {
+				return
+			}
+		}
+		_ = peerConn.Close()
+	}()
+
+	for i := 0; i < 10; i++ {
+		msg, err := p.ReadMessage()
+		require.NoError(t, err)
+		require.Len(t, msg.Objects, 1)
+		require.Equal(t, fmt.Sprint(i), msg.Objects[0].Request.Method)
+	}
+	_, err := p.ReadMessage()
+	require.Error(t, err)
+}
+
+func TestWithDecodeWorkers(t *testing.T) {
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Params)
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler, WithDecodeWorkers(4))
+	cli := NewClient(cliConn, nil, WithDecodeWorkers(4))
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "echo", "hello")
+	require.NoError(t, err)
+	require.Equal(t, `"hello"`, string(res))
+
+	// Closing the server is noticed by the client's read loop.
+	require.NoError(t, srv.Close())
+	<-cli.Done()
+}
+
+func TestWithDecodeWorkers_InvalidMessages(t *testing.T) {
+	srvConn, peerConn := net.Pipe()
+	srv := NewClient(srvConn, nil, WithDecodeWorkers(2))
+	defer srv.Close()
+	defer peerConn.Close()
+
+	go func() { _, _ = peerConn.Write([]byte(`{"jsonrpc": "2.0", "method": }`)) }()
+
+	frame, err := NewReader(peerConn).ReadFrame()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32700}, "id": null}`, string(stripErrorMessage(t, frame)))
+}
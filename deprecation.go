@@ -0,0 +1,114 @@
+package jsonrpc2
+
+import (
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// MethodDeprecated is the method of the notification sent by Deprecations to
+// warn callers that they called a deprecated method. Its params are a
+// DeprecationWarning.
+const MethodDeprecated = "rpc.deprecated"
+
+// Deprecation describes why a method is deprecated.
+type Deprecation struct {
+	// Message explains the deprecation, such as when the method will be
+	// removed.
+	Message string `json:"message,omitempty"`
+
+	// Replacement is the method which should be called instead, if any.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// DeprecationWarning is sent as the params of a MethodDeprecated
+// notification.
+type DeprecationWarning struct {
+	Method string `json:"method"`
+	Deprecation
+}
+
+// Deprecations is a Handler which tracks calls to deprecated methods before
+// passing them to Handler. Calls to deprecated methods still succeed, but
+// are counted so API owners can see which deprecated methods are in use.
+//
+// If Notify is set, callers are also sent a MethodDeprecated notification
+// before the response to each call to a deprecated method. Callers can
+// handle it to log the warning:
+//
+//	mux.HandleFunc(jsonrpc2.MethodDeprecated, func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+//		var warning jsonrpc2.DeprecationWarning
+//		if err := r.DecodeParams(&warning); err == nil {
+//			log.Printf("%s is deprecated: %s", warning.Method, warning.Message)
+//		}
+//	})
+type Deprecations struct {
+	// Handler handles all calls, deprecated or not.
+	Handler Handler
+
+	// Notify sets whether callers are sent a MethodDeprecated notification
+	// when they call a deprecated method. Notifications can only be sent to
+	// callers with a connection; see Request.Client.
+	Notify bool
+
+	mut     sync.RWMutex
+	methods map[string]*deprecatedMethod
+}
+
+type deprecatedMethod struct {
+	dep   Deprecation
+	calls atomic.Int64
+}
+
+// NewDeprecations returns a Deprecations which passes calls to h.
+func NewDeprecations(h Handler) *Deprecations {
+	return &Deprecations{Handler: h}
+}
+
+// Deprecate marks method as deprecated. Calling Deprecate again for the same
+// method replaces its Deprecation but keeps its call count.
+func (d *Deprecations) Deprecate(method string, dep Deprecation) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.methods == nil {
+		d.methods = make(map[string]*deprecatedMethod)
+	}
+	if m, ok := d.methods[method]; ok {
+		m.dep = dep
+		return
+	}
+	d.methods[method] = &deprecatedMethod{dep: dep}
+}
+
+// Calls returns the number of calls to deprecated methods, keyed by method.
+// Methods which haven't been called are included with a count of zero.
+func (d *Deprecations) Calls() map[string]int64 {
+	d.mut.RLock()
+	defer d.mut.RUnlock()
+
+	calls := make(map[string]int64, len(d.methods))
+	for method, m := range d.methods {
+		calls[method] = m.calls.Load()
+	}
+	return calls
+}
+
+// ServeRPC implements Handler.
+func (d *Deprecations) ServeRPC(w ResponseWriter, r *Request) {
+	d.mut.RLock()
+	m, ok := d.methods[r.Method]
+	var dep Deprecation
+	if ok {
+		dep = m.dep
+	}
+	d.mut.RUnlock()
+
+	if ok {
+		m.calls.Inc()
+		if d.Notify && r.Client != nil {
+			_ = r.Client.Notify(MethodDeprecated, DeprecationWarning{Method: r.Method, Deprecation: dep})
+		}
+	}
+	d.Handler.ServeRPC(w, r)
+}
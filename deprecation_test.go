@@ -0,0 +1,49 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecations(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("old", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("old") })
+	mux.HandleFunc("new", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("new") })
+
+	deps := NewDeprecations(mux)
+	deps.Notify = true
+	deps.Deprecate("old", Deprecation{Message: "removed in v2", Replacement: "new"})
+	deps.Deprecate("unused", Deprecation{})
+
+	warnings := make(chan DeprecationWarning, 1)
+	cliMux := NewServeMux()
+	cliMux.HandleFunc(MethodDeprecated, func(w ResponseWriter, r *Request) {
+		var warning DeprecationWarning
+		if err := r.DecodeParams(&warning); err == nil {
+			warnings <- warning
+		}
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, deps)
+	defer srv.Close()
+	cli := NewClient(cliConn, cliMux)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "old", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"old"`, string(res))
+	require.Equal(t, DeprecationWarning{
+		Method:      "old",
+		Deprecation: Deprecation{Message: "removed in v2", Replacement: "new"},
+	}, <-warnings)
+
+	_, err = cli.Invoke(context.Background(), "new", nil)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	require.Equal(t, map[string]int64{"old": 1, "unused": 0}, deps.Calls())
+}
@@ -0,0 +1,278 @@
+// Package discovery finds jsonrpc2 servers on the local network with UDP
+// multicast or broadcast.
+//
+// Servers run an Announcer, which answers queries for its service with the
+// address clients should dial. Clients query with a Resolver, which
+// implements jsonrpc2.Resolver so that a Pool can follow the servers it
+// finds:
+//
+//	ann := &discovery.Announcer{Service: "indexer", Addr: ":7000"}
+//	go ann.ListenAndServe(ctx)
+//
+//	r := &discovery.Resolver{Service: "indexer"}
+//	err := pool.Resolve(ctx, r, func(ctx context.Context, addr string) (jsonrpc2.Conn, error) {
+//		return jsonrpc2.Dial(addr, nil)
+//	})
+//
+// Queries and answers are JSON-RPC 2.0 notifications, one per datagram:
+//
+//	{"jsonrpc": "2.0", "method": "discovery.query", "params": {"service": "indexer"}}
+//	{"jsonrpc": "2.0", "method": "discovery.announce", "params": {"service": "indexer", "addr": "10.0.0.5:7000"}}
+//
+// Discovery is unauthenticated: any host on the network can answer queries.
+// Only use it on trusted networks, or authenticate servers after dialing
+// them, such as with TLS.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// DefaultGroup is the default address queries are sent to, a multicast
+// group in the organization-local scope.
+const DefaultGroup = "239.255.74.82:7946"
+
+// Methods of the notifications used by discovery.
+const (
+	MethodQuery    = "discovery.query"
+	MethodAnnounce = "discovery.announce"
+)
+
+// maxDatagramSize is the largest datagram read by discovery.
+const maxDatagramSize = 1472
+
+type queryParams struct {
+	Service string `json:"service"`
+}
+
+type announceParams struct {
+	Service string `json:"service"`
+	Addr    string `json:"addr"`
+}
+
+// Announcer answers queries for a service.
+type Announcer struct {
+	// Service is the name of the service queries must ask for.
+	Service string
+
+	// Addr is the address clients should dial. If its host is empty or
+	// unspecified, such as ":7000", clients use the address the answer
+	// came from.
+	Addr string
+
+	// Group is the address to listen for queries on. It may be a multicast
+	// group, which is joined on every interface, or a port to receive
+	// broadcasts on, such as ":7946". If empty, DefaultGroup is used.
+	Group string
+}
+
+// ListenAndServe listens for queries on the Announcer's group and answers
+// them until ctx is done.
+func (a *Announcer) ListenAndServe(ctx context.Context) error {
+	group := a.Group
+	if group == "" {
+		group = DefaultGroup
+	}
+	gaddr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return fmt.Errorf("failed to resolve group: %w", err)
+	}
+
+	var conn net.PacketConn
+	if gaddr.IP != nil && gaddr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, gaddr)
+	} else {
+		conn, err = net.ListenUDP("udp", gaddr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return a.Serve(ctx, conn)
+}
+
+// Serve answers queries received on conn until ctx is done, and then
+// closes conn. Answers are sent to the address each query came from.
+func (a *Announcer) Serve(ctx context.Context, conn net.PacketConn) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		_ = conn.Close()
+	}()
+
+	answer, err := encode(MethodAnnounce, announceParams{Service: a.Service, Addr: a.Addr})
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		var q queryParams
+		if !decode(buf[:n], MethodQuery, &q) || q.Service != a.Service {
+			continue
+		}
+		_, _ = conn.WriteTo(answer, from)
+	}
+}
+
+// Resolver queries for the addresses of a service's Announcers. Resolver
+// implements jsonrpc2.Resolver.
+type Resolver struct {
+	// Service is the name of the service to query for.
+	Service string
+
+	// Group is the address to send queries to, such as a multicast group
+	// or a broadcast address like "255.255.255.255:7946". If empty,
+	// DefaultGroup is used.
+	Group string
+
+	// Wait is how long to collect answers for. If zero, answers are
+	// collected for 500ms.
+	Wait time.Duration
+}
+
+var _ jsonrpc2.Resolver = (*Resolver)(nil)
+
+// Resolve sends a query and returns the addresses of the Announcers which
+// answered within the Resolver's wait or before ctx is done, sorted. An
+// empty list is returned if no Announcers answered.
+func (r *Resolver) Resolve(ctx context.Context) ([]string, error) {
+	group := r.Group
+	if group == "" {
+		group = DefaultGroup
+	}
+	gaddr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve group: %w", err)
+	}
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	defer conn.Close()
+
+	wait := r.Wait
+	if wait <= 0 {
+		wait = 500 * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	return Query(ctx, conn, gaddr, r.Service)
+}
+
+// Query sends a query for service to group over conn and collects answers
+// until ctx is done. The addresses of the Announcers which answered are
+// returned, sorted. Query sets a read deadline on conn to stop reading.
+func Query(ctx context.Context, conn net.PacketConn, group net.Addr, service string) ([]string, error) {
+	query, err := encode(MethodQuery, queryParams{Service: service})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo(query, group); err != nil {
+		return nil, fmt.Errorf("failed to send query: %w", err)
+	}
+
+	// Clear any deadline left by a previous Query, and unblock reads once
+	// ctx is done.
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetReadDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	found := make(map[string]bool)
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				return nil, err
+			}
+			break
+		}
+
+		var ann announceParams
+		if !decode(buf[:n], MethodAnnounce, &ann) || ann.Service != service {
+			continue
+		}
+		if addr, ok := dialAddr(ann.Addr, from); ok {
+			found[addr] = true
+		}
+	}
+
+	addrs := make([]string, 0, len(found))
+	for addr := range found {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// dialAddr returns the address to dial for an answer from an Announcer
+// advertising advertised. An empty or unspecified host is replaced with
+// the host the answer came from.
+func dialAddr(advertised string, from net.Addr) (string, bool) {
+	host, port, err := net.SplitHostPort(advertised)
+	if err != nil {
+		return "", false
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		udp, ok := from.(*net.UDPAddr)
+		if !ok {
+			return "", false
+		}
+		host = udp.IP.String()
+	}
+	return net.JoinHostPort(host, port), true
+}
+
+func encode(method string, params interface{}) ([]byte, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return jsonrpc2.Encode([]jsonrpc2.Message{{
+		Kind:   jsonrpc2.KindNotification,
+		Method: method,
+		Params: body,
+	}}, false)
+}
+
+// decode decodes the params of a datagram holding a notification for
+// method into v. It reports false for any other datagram.
+func decode(datagram []byte, method string, v interface{}) bool {
+	msgs, batched, err := jsonrpc2.Parse(datagram)
+	if err != nil || batched || len(msgs) != 1 {
+		return false
+	}
+	m := msgs[0]
+	if m.Kind != jsonrpc2.KindNotification || m.Method != method {
+		return false
+	}
+	return json.Unmarshal(m.Params, v) == nil
+}
@@ -0,0 +1,66 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func startAnnouncer(t *testing.T, a *Announcer) net.Addr {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = a.Serve(ctx, conn)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return conn.LocalAddr()
+}
+
+func TestResolver(t *testing.T) {
+	a := startAnnouncer(t, &Announcer{Service: "indexer", Addr: "10.0.0.5:7000"})
+	r := &Resolver{Service: "indexer", Group: a.String(), Wait: 200 * time.Millisecond}
+
+	addrs, err := r.Resolve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.5:7000"}, addrs)
+
+	// Other services don't answer.
+	r.Service = "other"
+	addrs, err = r.Resolve(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, addrs)
+}
+
+func TestQuery(t *testing.T) {
+	// Announcers advertising only a port are dialed at the address the
+	// answer came from.
+	a1 := startAnnouncer(t, &Announcer{Service: "indexer", Addr: ":7000"})
+	a2 := startAnnouncer(t, &Announcer{Service: "indexer", Addr: "10.0.0.6:7000"})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Query each announcer directly, since loopback can't multicast.
+	var addrs []string
+	for _, a := range []net.Addr{a1, a2} {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		found, err := Query(ctx, conn, a, "indexer")
+		cancel()
+		require.NoError(t, err)
+		addrs = append(addrs, found...)
+	}
+	require.Equal(t, []string{"127.0.0.1:7000", "10.0.0.6:7000"}, addrs)
+}
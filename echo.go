@@ -0,0 +1,41 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// MethodEcho is the method served by EchoHandler and called by Ping.
+const MethodEcho = "rpc.echo"
+
+// EchoHandler replies to each request with its params, or null if it had
+// none. Servers opt in to answering Ping by registering it:
+//
+//	mux.Handle(jsonrpc2.MethodEcho, jsonrpc2.EchoHandler)
+var EchoHandler Handler = HandlerFunc(func(w ResponseWriter, r *Request) {
+	if r.Notification {
+		return
+	}
+	if len(r.Params) == 0 {
+		_ = w.WriteMessage(nil)
+		return
+	}
+	_ = w.WriteMessage(json.RawMessage(r.Params))
+})
+
+// Ping invokes MethodEcho over conn and returns the round-trip time. The
+// peer must serve MethodEcho, such as with EchoHandler.
+func Ping(ctx context.Context, conn Conn) (time.Duration, error) {
+	start := time.Now()
+	if _, err := conn.Invoke(ctx, MethodEcho, nil); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// Ping invokes MethodEcho on the other side of the connection and returns
+// the round-trip time. See the package-level Ping.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	return Ping(ctx, c)
+}
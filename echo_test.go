@@ -0,0 +1,32 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle(MethodEcho, EchoHandler)
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	rtt, err := cli.Ping(context.Background())
+	require.NoError(t, err)
+	require.NotZero(t, rtt)
+
+	res, err := cli.Invoke(context.Background(), MethodEcho, map[string]int{"n": 1})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"n": 1}`, string(res))
+
+	// Servers which haven't opted in don't answer.
+	_, err = Ping(context.Background(), srv)
+	require.Error(t, err)
+}
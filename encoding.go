@@ -0,0 +1,89 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// WithEscapeHTML sets whether the Client escapes <, >, and & in strings of
+// messages it sends as \u003c, \u003e, and \u0026, as json.Marshal does.
+// Escaping is enabled by default. Disabling it sends strings as written,
+// for peers which compare raw payloads rather than decoded values.
+func WithEscapeHTML(enabled bool) ClientOpt {
+	return func(c *Client) {
+		c.tx.enc.noEscapeHTML = !enabled
+	}
+}
+
+// WithIndent indents messages sent by the Client as json.MarshalIndent
+// does, which makes them easier to read while debugging. Indented messages
+// span multiple lines, so the other side must read whitespace-delimited
+// JSON, as NewStreamFramer does, rather than one message per line.
+func WithIndent(prefix, indent string) ClientOpt {
+	return func(c *Client) {
+		c.tx.enc.prefix, c.tx.enc.indent = prefix, indent
+	}
+}
+
+// encoderConfig is how a transport formats the frames it sends.
+type encoderConfig struct {
+	noEscapeHTML   bool
+	prefix, indent string
+}
+
+// format applies the config to frame, which was encoded by json.Marshal.
+func (e encoderConfig) format(frame []byte) ([]byte, error) {
+	if e.noEscapeHTML {
+		frame = unescapeHTML(frame)
+	}
+	if e.prefix != "" || e.indent != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, frame, e.prefix, e.indent); err != nil {
+			return nil, err
+		}
+		frame = buf.Bytes()
+	}
+	return frame, nil
+}
+
+// unescapeHTML replaces the escapes json.Marshal writes for <, >, and & in
+// strings with the characters themselves. Escapes are found by scanning
+// strings, so escaped backslashes followed by similar text are kept.
+// Marshalers and json.RawMessage values can't turn off escaping for their
+// contents, so it is undone for the whole frame.
+func unescapeHTML(frame []byte) []byte {
+	if !bytes.Contains(frame, []byte(`\u00`)) {
+		return frame
+	}
+
+	out := make([]byte, 0, len(frame))
+	inString := false
+	for i := 0; i < len(frame); i++ {
+		c := frame[i]
+		switch {
+		case !inString:
+			inString = c == '"'
+		case c == '"':
+			inString = false
+		case c == '\\' && i+1 < len(frame):
+			if frame[i+1] == 'u' && i+6 <= len(frame) {
+				if r, ok := htmlEscapes[string(frame[i+2:i+6])]; ok {
+					out = append(out, r)
+					i += 5
+					continue
+				}
+			}
+			out = append(out, c, frame[i+1])
+			i++
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+var htmlEscapes = map[string]byte{
+	"003c": '<',
+	"003e": '>',
+	"0026": '&',
+}
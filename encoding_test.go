@@ -0,0 +1,80 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnescapeHTML(t *testing.T) {
+	tt := []struct {
+		in, expect string
+	}{
+		{`{"a":"\u003cb\u003e \u0026"}`, `{"a":"<b> &"}`},
+		{`{"\u003c":1}`, `{"<":1}`},
+		// Escaped backslashes followed by similar text are kept.
+		{`["\\u003c", "\\<"]`, `["\\u003c", "\\<"]`},
+		{`["\u00e9", "\"\u003e"]`, `["\u00e9", "\">"]`},
+		{`{"a":1}`, `{"a":1}`},
+	}
+	for _, tc := range tt {
+		require.Equal(t, tc.expect, string(unescapeHTML([]byte(tc.in))), tc.in)
+	}
+}
+
+// readSentFrame returns the first frame cli sends after calling send.
+func readSentFrame(t *testing.T, opts []ClientOpt, send func(cli *Client)) string {
+	t.Helper()
+	peerConn, cliConn := net.Pipe()
+	defer peerConn.Close()
+	cli := NewClient(cliConn, nil, opts...)
+	defer cli.Close()
+
+	go send(cli)
+	scanner := bufio.NewScanner(peerConn)
+	scanner.Split(SplitFrames)
+	require.True(t, scanner.Scan())
+	return scanner.Text()
+}
+
+func TestWithEscapeHTML(t *testing.T) {
+	notify := func(cli *Client) { _ = cli.Notify("note", "<a & b>") }
+
+	frame := readSentFrame(t, nil, notify)
+	require.Contains(t, frame, `"\u003ca \u0026 b\u003e"`)
+
+	frame = readSentFrame(t, []ClientOpt{WithEscapeHTML(false)}, notify)
+	require.Contains(t, frame, `"<a & b>"`)
+}
+
+func TestWithIndent(t *testing.T) {
+	frame := readSentFrame(t, []ClientOpt{WithIndent("", "  ")}, func(cli *Client) {
+		_ = cli.Notify("note", map[string]int{"n": 1})
+	})
+	require.Equal(t, strings.Join([]string{
+		`{`,
+		`  "jsonrpc": "2.0",`,
+		`  "method": "note",`,
+		`  "params": {`,
+		`    "n": 1`,
+		`  }`,
+		`}`,
+	}, "\n"), frame)
+
+	// Indented messages can be read by Clients.
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Params)
+	}), WithIndent("", "\t"))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithIndent("", "\t"), WithEscapeHTML(false))
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "echo", []string{"<a>"})
+	require.NoError(t, err)
+	require.JSONEq(t, `["<a>"]`, string(res))
+}
@@ -2,9 +2,15 @@ package jsonrpc2
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ErrConnClosed is returned when sending a message over a Client which has
+// been closed or has stopped reading messages.
+var ErrConnClosed = errors.New("jsonrpc2: connection closed")
+
 // Error messages used by RPC calls. Error messages from -32768 and -32000 are
 // reserved by the JSON-RPC 2.0 framework.
 const (
@@ -15,6 +21,36 @@ const (
 	ErrorInternal       int = -32603
 )
 
+// Server error codes used by this module. JSON-RPC 2.0 leaves -32000 to
+// -32099 for implementation-defined server errors, and each code in that
+// range is only used for one kind of error:
+//
+//	-32000  ErrorServerBusy
+//	-32001  ErrorTimeout
+//	-32003  ErrorShuttingDown
+//	-32004  schema not found, from schemareg
+//	-32005  ErrorOutcomeUnknown
+//	-32010  grpcbridge.ErrorStatus
+const (
+	// ErrorServerBusy is the error code servers reply with when they are too
+	// overloaded to handle a request. Busy errors created with NewBusyError
+	// tell the caller how long to wait before retrying.
+	ErrorServerBusy int = -32000
+
+	// ErrorTimeout is the error code the Timeout middleware replies with.
+	ErrorTimeout int = -32001
+
+	// ErrorShuttingDown is the error code requests are rejected with when
+	// they arrive after Server.Shutdown has started. The request never ran,
+	// so the caller may safely retry it on another server.
+	ErrorShuttingDown int = -32003
+
+	// ErrorOutcomeUnknown is the error code ExactlyOnce replies with when a
+	// request with the same idempotency key started before the server
+	// crashed and its outcome wasn't recorded.
+	ErrorOutcomeUnknown int = -32005
+)
+
 var errorDesc = map[int]string{
 	ErrorParse:          "Parse error",
 	ErrorInvalidRequest: "Invalid Request",
@@ -41,3 +77,31 @@ func (e Error) Error() string {
 
 	return codeDesc + ": " + e.Message
 }
+
+// joinErrors returns an error wrapping errs, or nil if errs is empty. It
+// mirrors errors.Join, which isn't available before Go 1.20.
+func joinErrors(errs ...error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return joinError(errs)
+}
+
+// joinError is an error wrapping several errors. Its Unwrap method lets
+// errors.Is and errors.As match each of them on Go 1.20 and later.
+type joinError []error
+
+func (e joinError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e joinError) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,278 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MetaIdempotencyKey is the metadata key holding a request's idempotency
+// key, used by ExactlyOnce.
+const MetaIdempotencyKey = "idempotency-key"
+
+// DefaultDedupeWindow is the default for how long responses are
+// remembered by a DedupeStore.
+const DefaultDedupeWindow = 24 * time.Hour
+
+// DefaultMaxDedupeKeys is the default limit for the number of idempotency
+// keys remembered by a DedupeStore.
+const DefaultMaxDedupeKeys = 65536
+
+var (
+	// ErrRequestInProgress is returned by DedupeStore.Claim when a request
+	// with the same key is still running.
+	ErrRequestInProgress = errors.New("jsonrpc2: request in progress")
+
+	// ErrOutcomeUnknown is returned by DedupeStore.Claim when a request
+	// with the same key started but its outcome was lost, such as in a
+	// crash.
+	ErrOutcomeUnknown = errors.New("jsonrpc2: request outcome unknown")
+
+	// ErrDedupeStoreFull is returned by DedupeStore.Claim when the store
+	// can't remember any more keys.
+	ErrDedupeStoreFull = errors.New("jsonrpc2: dedupe store full")
+)
+
+// CachedResponse is the response to a request remembered by a DedupeStore.
+// Exactly one of Result and Error is set.
+type CachedResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// DedupeStore remembers which idempotency keys have been used and the
+// responses to their requests. Implementations must be safe for concurrent
+// use. MemoryDedupeStore keeps keys in memory, and a Journal keeps them on
+// disk so they survive restarts.
+type DedupeStore interface {
+	// Claim claims key before its request runs. It returns nil, nil if key
+	// is new. If key was used before, Claim returns the response to that
+	// request, or ErrRequestInProgress if it is still running, or
+	// ErrOutcomeUnknown if it will never finish.
+	Claim(key string) (*CachedResponse, error)
+
+	// Complete records resp as the response to the request which claimed
+	// key.
+	Complete(key string, resp CachedResponse) error
+}
+
+// ExactlyOnce returns a Handler which runs each request with the same
+// idempotency key at most once, so callers may safely retry requests
+// across timeouts and reconnects. Callers set the key in the request's
+// metadata under MetaIdempotencyKey, which requires WithRequestMetadata:
+//
+//	ctx = jsonrpc2.WithMetadata(ctx, jsonrpc2.Metadata{jsonrpc2.MetaIdempotencyKey: uuid})
+//	res, err := cli.Invoke(ctx, "transfer", params)
+//
+// The first request with a key runs h, and later requests with the key get
+// the same response without running h. While the first request is still
+// running, later requests get a busy error, which BusyRetrier retries. If
+// the first request's outcome was lost, later requests get an
+// ErrorOutcomeUnknown error, and the caller must find out what happened
+// some other way. Requests without a key run h every time.
+//
+// Keys are shared by all methods and callers, so callers should use
+// unique keys such as UUIDs. A request is only deduplicated while store
+// remembers its key.
+func ExactlyOnce(h Handler, store DedupeStore) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		key := r.Meta.Get(MetaIdempotencyKey)
+		if key == "" {
+			h.ServeRPC(w, r)
+			return
+		}
+
+		cached, err := store.Claim(key)
+		switch {
+		case r.Notification && (cached != nil || err != nil):
+			return
+		case cached != nil:
+			if cached.Error != nil {
+				_ = w.WriteError(cached.Error.Code, *cached.Error)
+			} else {
+				_ = w.WriteMessage(cached.Result)
+			}
+			return
+		case errors.Is(err, ErrRequestInProgress), errors.Is(err, ErrDedupeStoreFull):
+			_ = w.WriteError(ErrorServerBusy, NewBusyError(time.Second))
+			return
+		case errors.Is(err, ErrOutcomeUnknown):
+			_ = w.WriteError(ErrorOutcomeUnknown, Error{Message: "request outcome unknown"})
+			return
+		case err != nil:
+			_ = w.WriteError(ErrorInternal, errors.New("failed to claim idempotency key"))
+			return
+		}
+
+		// A handler which panics may have run partially, so the key is
+		// completed with an internal error rather than left claimed.
+		cw := &cachingWriter{w: w}
+		returned := false
+		defer func() {
+			if !returned {
+				cw.resp = CachedResponse{Error: &Error{Code: ErrorInternal, Message: "internal error"}}
+			} else if cw.resp.Result == nil && cw.resp.Error == nil {
+				cw.resp.Result = json.RawMessage("null")
+			}
+			_ = store.Complete(key, cw.resp)
+		}()
+		h.ServeRPC(cw, r)
+		returned = true
+	})
+}
+
+// cachingWriter records the response written by a handler.
+type cachingWriter struct {
+	w    ResponseWriter
+	resp CachedResponse
+}
+
+func (cw *cachingWriter) WriteMessage(msg interface{}) error {
+	res, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := cw.w.WriteMessage(json.RawMessage(res)); err != nil {
+		return err
+	}
+	cw.resp = CachedResponse{Result: res}
+	return nil
+}
+
+func (cw *cachingWriter) WriteError(errCode int, err error) error {
+	if werr := cw.w.WriteError(errCode, err); werr != nil {
+		return werr
+	}
+	rpcErr := Error{Code: errCode, Message: err.Error()}
+	var e Error
+	if errors.As(err, &e) {
+		rpcErr.Message, rpcErr.Data = e.Message, e.Data
+	}
+	cw.resp = CachedResponse{Error: &rpcErr}
+	return nil
+}
+
+// MemoryDedupeStore is a DedupeStore which keeps keys in memory. Keys are
+// forgotten when the process exits, so requests retried after a restart
+// run again; use a Journal to remember keys across restarts.
+type MemoryDedupeStore struct {
+	// Window is how long responses are remembered. If zero,
+	// DefaultDedupeWindow is used.
+	Window time.Duration
+
+	// MaxKeys limits the number of keys remembered, including keys of
+	// running requests. Claims fail with ErrDedupeStoreFull while the limit
+	// is reached. If zero, DefaultMaxDedupeKeys is used.
+	MaxKeys int
+
+	// Clock is used to expire responses. If nil, SystemClock is used.
+	Clock Clock
+
+	mut   sync.Mutex
+	table dedupeTable
+}
+
+var _ DedupeStore = (*MemoryDedupeStore)(nil)
+
+// Claim implements DedupeStore.
+func (s *MemoryDedupeStore) Claim(key string) (*CachedResponse, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.table.claim(key, s.clock().Now(), s.Window, s.MaxKeys)
+}
+
+// Complete implements DedupeStore.
+func (s *MemoryDedupeStore) Complete(key string, resp CachedResponse) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.table.complete(key, resp, s.clock().Now())
+	return nil
+}
+
+func (s *MemoryDedupeStore) clock() Clock {
+	if s.Clock == nil {
+		return SystemClock
+	}
+	return s.Clock
+}
+
+// dedupeTable tracks idempotency keys for a DedupeStore. It isn't safe for
+// concurrent use.
+type dedupeTable struct {
+	keys map[string]*dedupeEntry
+}
+
+// dedupeEntry is the state of an idempotency key. resp is nil while the
+// request is running, and orphaned is set if it will never finish. at is
+// when the request finished, or when it was claimed if it hasn't.
+type dedupeEntry struct {
+	resp     *CachedResponse
+	at       time.Time
+	orphaned bool
+}
+
+// expired reports whether e can be forgotten. Keys of running requests are
+// never forgotten.
+func (e *dedupeEntry) expired(now time.Time, window time.Duration) bool {
+	if e.resp == nil && !e.orphaned {
+		return false
+	}
+	if window <= 0 {
+		window = DefaultDedupeWindow
+	}
+	return !now.Before(e.at.Add(window))
+}
+
+func (t *dedupeTable) claim(key string, now time.Time, window time.Duration, maxKeys int) (*CachedResponse, error) {
+	if t.keys == nil {
+		t.keys = make(map[string]*dedupeEntry)
+	}
+	if e, ok := t.keys[key]; ok && !e.expired(now, window) {
+		switch {
+		case e.orphaned:
+			return nil, ErrOutcomeUnknown
+		case e.resp == nil:
+			return nil, ErrRequestInProgress
+		default:
+			return e.resp, nil
+		}
+	}
+
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxDedupeKeys
+	}
+	if len(t.keys) >= maxKeys {
+		t.prune(now, window)
+	}
+	if len(t.keys) >= maxKeys {
+		return nil, ErrDedupeStoreFull
+	}
+	t.keys[key] = &dedupeEntry{at: now}
+	return nil, nil
+}
+
+func (t *dedupeTable) complete(key string, resp CachedResponse, now time.Time) {
+	if e, ok := t.keys[key]; ok {
+		e.resp, e.at, e.orphaned = &resp, now, false
+	}
+}
+
+// orphan marks the keys of running requests as orphaned, for keys loaded
+// from a previous process.
+func (t *dedupeTable) orphan() {
+	for _, e := range t.keys {
+		if e.resp == nil {
+			e.orphaned = true
+		}
+	}
+}
+
+// prune forgets expired keys.
+func (t *dedupeTable) prune(now time.Time, window time.Duration) {
+	for key, e := range t.keys {
+		if e.expired(now, window) {
+			delete(t.keys, key)
+		}
+	}
+}
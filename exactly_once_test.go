@@ -0,0 +1,152 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func keyedRequest(method, key string, id int) []byte {
+	return []byte(fmt.Sprintf(`{"jsonrpc": "2.0", "method": %q, "meta": {"idempotency-key": %q}, "id": %d}`, method, key, id))
+}
+
+func TestExactlyOnce(t *testing.T) {
+	var calls atomic.Int64
+	h := ExactlyOnce(HandlerFunc(func(w ResponseWriter, r *Request) {
+		n := calls.Inc()
+		if r.Method == "fail" {
+			_ = w.WriteError(ErrorInvalidParams, Error{Message: "bad", Data: []byte(`{"n": 1}`)})
+			return
+		}
+		_ = w.WriteMessage(n)
+	}), &MemoryDedupeStore{})
+
+	for i := 1; i <= 2; i++ {
+		reply, err := ServeFrame(context.Background(), h, keyedRequest("transfer", "k1", i))
+		require.NoError(t, err)
+		require.JSONEq(t, fmt.Sprintf(`{"jsonrpc": "2.0", "result": 1, "id": %d}`, i), string(reply))
+	}
+	require.Equal(t, int64(1), calls.Load())
+
+	// Errors are remembered too.
+	for i := 1; i <= 2; i++ {
+		reply, err := ServeFrame(context.Background(), h, keyedRequest("fail", "k2", i))
+		require.NoError(t, err)
+		require.JSONEq(t, fmt.Sprintf(`{"jsonrpc": "2.0", "error": {"code": -32602, "message": "bad", "data": {"n": 1}}, "id": %d}`, i), string(reply))
+	}
+	require.Equal(t, int64(2), calls.Load())
+
+	// Requests without a key always run.
+	for i := 0; i < 2; i++ {
+		_, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "transfer", "id": 1}`))
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(4), calls.Load())
+}
+
+func TestExactlyOnce_InProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := ExactlyOnce(HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-release
+		_ = w.WriteMessage("done")
+	}), &MemoryDedupeStore{})
+
+	done := make(chan []byte, 1)
+	go func() {
+		reply, _ := ServeFrame(context.Background(), h, keyedRequest("slow", "k", 1))
+		done <- reply
+	}()
+	<-started
+
+	reply, err := ServeFrame(context.Background(), h, keyedRequest("slow", "k", 2))
+	require.NoError(t, err)
+	var resp struct{ Error Error }
+	require.NoError(t, json.Unmarshal(reply, &resp))
+	_, busy := RetryAfter(resp.Error)
+	require.True(t, busy)
+
+	close(release)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "result": "done", "id": 1}`, string(<-done))
+}
+
+func TestMemoryDedupeStore_Limits(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	s := &MemoryDedupeStore{Window: time.Minute, MaxKeys: 1, Clock: clock}
+
+	resp, err := s.Claim("a")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	_, err = s.Claim("b")
+	require.ErrorIs(t, err, ErrDedupeStoreFull)
+
+	require.NoError(t, s.Complete("a", CachedResponse{Result: []byte(`1`)}))
+	_, err = s.Claim("b")
+	require.ErrorIs(t, err, ErrDedupeStoreFull)
+
+	// Once a's response expires, it is forgotten to make room.
+	clock.now = clock.now.Add(time.Minute)
+	resp, err = s.Claim("b")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+}
+
+func TestJournal_DedupeStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+
+	var calls atomic.Int64
+	h := ExactlyOnce(HandlerFunc(func(w ResponseWriter, r *Request) {
+		calls.Inc()
+		_ = w.WriteMessage("ok")
+	}), j)
+
+	_, err = ServeFrame(context.Background(), h, keyedRequest("transfer", "done", 1))
+	require.NoError(t, err)
+
+	// Claim a key without completing it, as if the process crashed while
+	// handling the request.
+	resp, err := j.Claim("crashed")
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	require.NoError(t, j.Close())
+
+	j2, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j2.Close()
+	h = ExactlyOnce(HandlerFunc(func(w ResponseWriter, r *Request) {
+		calls.Inc()
+		_ = w.WriteMessage("ok")
+	}), j2)
+
+	reply, err := ServeFrame(context.Background(), h, keyedRequest("transfer", "done", 2))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "result": "ok", "id": 2}`, string(reply))
+
+	reply, err = ServeFrame(context.Background(), h, keyedRequest("transfer", "crashed", 3))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32005, "message": "request outcome unknown"}, "id": 3}`, string(reply))
+
+	require.Equal(t, int64(1), calls.Load())
+}
+
+func TestExactlyOnce_StoreError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	require.NoError(t, j.Close())
+
+	h := ExactlyOnce(HandlerFunc(func(w ResponseWriter, r *Request) {
+		t.Error("handler must not run")
+	}), j)
+	reply, err := ServeFrame(context.Background(), h, keyedRequest("transfer", "k", 1))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "failed to claim idempotency key"}, "id": 1}`, string(reply))
+}
@@ -0,0 +1,14 @@
+module github.com/crtv-io/jsonrpc2/examples/websocket
+
+go 1.16
+
+require (
+  github.com/crtv-io/jsonrpc2 v0.0.0
+  github.com/crtv-io/jsonrpc2/websocket v0.0.0
+  github.com/gorilla/websocket v1.4.2
+)
+
+replace (
+  github.com/crtv-io/jsonrpc2 => ../../
+  github.com/crtv-io/jsonrpc2/websocket => ../../websocket
+)
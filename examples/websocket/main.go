@@ -13,6 +13,7 @@ import (
 	"net/http"
 
 	"github.com/crtv-io/jsonrpc2"
+	jsonrpc2ws "github.com/crtv-io/jsonrpc2/websocket"
 	"github.com/gorilla/websocket"
 )
 
@@ -50,13 +51,13 @@ func main() {
 			panic(err)
 		}
 
-		// NewWebsocketClient returns a Client which will automatically handle
+		// jsonrpc2ws.NewClient returns a Client which will automatically handle
 		// incoming requests and send them to the provided handler. For our example,
 		// we don't need to do anything with the client, but we could invoke RPC
 		// methods on the client too for bi-directional RPCs.
 		//
-		// If the returned Client from NewWebsocketClient isn't closed, it will
+		// If the returned Client from jsonrpc2ws.NewClient isn't closed, it will
 		// automatically be closed when the websocket connection shuts down.
-		jsonrpc2.NewWebsocketClient(wsConn, mux)
+		jsonrpc2ws.NewClient(wsConn, mux)
 	}))
 }
@@ -0,0 +1,139 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// FailoverOrder is the order in which FailoverDialFunc tries addresses.
+type FailoverOrder int
+
+const (
+	// FailoverSequential tries addresses in the order returned by the
+	// Resolver. Each dial starts after the address of the last connection,
+	// so a lost connection fails over to the next address rather than
+	// redialing the same one first. It is the default.
+	FailoverSequential FailoverOrder = iota
+
+	// FailoverRandom tries addresses in a random order, which spreads
+	// clients across the servers.
+	FailoverRandom
+)
+
+// FailoverOpt is an option function that can be passed to
+// FailoverDialFunc.
+type FailoverOpt func(*failoverDialer)
+
+// WithFailoverOrder sets the order in which addresses are tried. The
+// default is FailoverSequential.
+func WithFailoverOrder(o FailoverOrder) FailoverOpt {
+	return func(f *failoverDialer) {
+		f.order = o
+	}
+}
+
+// WithStickyPrimary makes every dial try the first address returned by the
+// Resolver, the primary, before the others. Connections return to the
+// primary whenever they are redialed while it's up, and only fail over to
+// the other addresses while it's down.
+func WithStickyPrimary() FailoverOpt {
+	return func(f *failoverDialer) {
+		f.stickyPrimary = true
+	}
+}
+
+type failoverDialer struct {
+	resolver      Resolver
+	dial          func(ctx context.Context, addr string) (*Client, error)
+	order         FailoverOrder
+	stickyPrimary bool
+
+	mut  sync.Mutex
+	last string // Address of the last connection.
+}
+
+// FailoverDialFunc returns a DialFunc which connects to the first of the
+// addresses returned by r that dial connects to. Addresses are resolved
+// again on every dial. If every address fails, the errors from dialing
+// each of them are returned.
+//
+// Use it with NewReconnectingClient to fail over to another server when
+// the connection is lost:
+//
+//	dial := jsonrpc2.FailoverDialFunc(jsonrpc2.StaticResolver{"a:8080", "b:8080"}, func(ctx context.Context, addr string) (*jsonrpc2.Client, error) {
+//		return jsonrpc2.DialContext(ctx, addr, handler)
+//	})
+//	rc := jsonrpc2.NewReconnectingClient(dial)
+func FailoverDialFunc(r Resolver, dial func(ctx context.Context, addr string) (*Client, error), opts ...FailoverOpt) DialFunc {
+	f := &failoverDialer{resolver: r, dial: dial}
+	for _, o := range opts {
+		o(f)
+	}
+	return f.Dial
+}
+
+// Dial implements DialFunc.
+func (f *failoverDialer) Dial(ctx context.Context) (*Client, error) {
+	addrs, err := f.resolver.Resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed resolving addresses: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("jsonrpc2: no addresses to dial")
+	}
+
+	var errs []error
+	for _, addr := range f.attemptOrder(addrs) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cli, err := f.dial(ctx, addr)
+		if err == nil {
+			f.mut.Lock()
+			f.last = addr
+			f.mut.Unlock()
+			return cli, nil
+		}
+		errs = append(errs, fmt.Errorf("failed dialing %s: %w", addr, err))
+	}
+	return nil, joinErrors(errs...)
+}
+
+// attemptOrder returns addrs in the order they should be tried.
+func (f *failoverDialer) attemptOrder(addrs []string) []string {
+	f.mut.Lock()
+	last := f.last
+	f.mut.Unlock()
+
+	ordered := make([]string, 0, len(addrs))
+	rest := addrs
+	if f.stickyPrimary {
+		ordered, rest = append(ordered, addrs[0]), addrs[1:]
+	}
+
+	switch f.order {
+	case FailoverRandom:
+		start := len(ordered)
+		ordered = append(ordered, rest...)
+		shuffled := ordered[start:]
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+	default:
+		// Start after the last address, wrapping around to it.
+		start := 0
+		for i, addr := range rest {
+			if addr == last {
+				start = i + 1
+				break
+			}
+		}
+		for i := range rest {
+			ordered = append(ordered, rest[(start+i)%len(rest)])
+		}
+	}
+	return ordered
+}
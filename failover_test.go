@@ -0,0 +1,131 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failoverTestDial returns a dial function which fails for addresses in
+// down, and records every address it is called with.
+func failoverTestDial(t *testing.T, down map[string]bool, attempts *[]string) func(ctx context.Context, addr string) (*Client, error) {
+	return func(ctx context.Context, addr string) (*Client, error) {
+		*attempts = append(*attempts, addr)
+		if down[addr] {
+			return nil, errors.New(addr + " is down")
+		}
+		srvConn, cliConn := net.Pipe()
+		t.Cleanup(func() { _ = srvConn.Close() })
+		cli := NewClient(cliConn, nil)
+		t.Cleanup(func() { _ = cli.Close() })
+		return cli, nil
+	}
+}
+
+func TestFailoverDialFunc(t *testing.T) {
+	var attempts []string
+	down := map[string]bool{"a": true}
+	dial := FailoverDialFunc(StaticResolver{"a", "b", "c"}, failoverTestDial(t, down, &attempts))
+	ctx := context.Background()
+
+	_, err := dial(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, attempts)
+
+	// Redialing fails over to the next address.
+	attempts = nil
+	_, err = dial(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, attempts)
+
+	attempts = nil
+	down["a"], down["b"], down["c"] = true, true, true
+	_, err = dial(ctx)
+	require.Error(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, attempts)
+	require.Contains(t, err.Error(), "c is down")
+}
+
+func TestFailoverDialFunc_StickyPrimary(t *testing.T) {
+	var attempts []string
+	down := map[string]bool{"a": true}
+	dial := FailoverDialFunc(StaticResolver{"a", "b", "c"}, failoverTestDial(t, down, &attempts), WithStickyPrimary())
+	ctx := context.Background()
+
+	_, err := dial(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, attempts)
+
+	// The primary is tried first again, and used once it's back.
+	attempts = nil
+	down["a"] = false
+	_, err = dial(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, attempts)
+
+	// While it's down, the other addresses are tried in turn.
+	attempts = nil
+	down["a"], down["b"] = true, true
+	_, err = dial(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, attempts)
+}
+
+func TestFailoverDialFunc_Random(t *testing.T) {
+	var attempts []string
+	down := map[string]bool{"a": true, "b": true, "c": true, "d": true}
+	dial := FailoverDialFunc(StaticResolver{"a", "b", "c", "d"}, failoverTestDial(t, down, &attempts),
+		WithFailoverOrder(FailoverRandom), WithStickyPrimary())
+
+	_, err := dial(context.Background())
+	require.Error(t, err)
+	require.Equal(t, "a", attempts[0])
+	sort.Strings(attempts)
+	require.Equal(t, []string{"a", "b", "c", "d"}, attempts)
+}
+
+func TestFailoverDialFunc_ReconnectingClient(t *testing.T) {
+	var (
+		addrs []string
+		srvs  []*Server
+	)
+	for _, name := range []string{"primary", "secondary"} {
+		name := name
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		srv := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			_ = w.WriteMessage(name)
+		})}
+		go srv.Serve(lis)
+		defer srv.Close()
+		addrs, srvs = append(addrs, lis.Addr().String()), append(srvs, srv)
+	}
+
+	dial := FailoverDialFunc(StaticResolver(addrs), func(ctx context.Context, addr string) (*Client, error) {
+		return DialContext(ctx, addr, nil)
+	})
+	rc := NewReconnectingClient(dial, WithReconnectPolicy(WaitForReconnect), WithReconnectBackoff(time.Millisecond, 10*time.Millisecond))
+	defer rc.Close()
+
+	server := func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return InvokeAs[string](ctx, rc, "name", nil)
+	}
+	name, err := server()
+	require.NoError(t, err)
+	require.Equal(t, "primary", name)
+
+	// Calls made as the connection is lost may fail, until the secondary
+	// is dialed.
+	require.NoError(t, srvs[0].Close())
+	require.Eventually(t, func() bool {
+		name, err := server()
+		return err == nil && name == "secondary"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,255 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrResponseTooLarge is returned for calls whose response was larger than
+// the limit set with WithMaxResponseSize.
+var ErrResponseTooLarge = errors.New("jsonrpc2: response too large")
+
+// FrameTooLargeError is returned by Reader and HeaderFramer for frames
+// larger than their limit. The frame is skipped without being kept in
+// memory, so later frames can still be read.
+type FrameTooLargeError struct {
+	Size  int
+	Limit int
+
+	// ResponseIDs are the IDs of the responses in the frame, so that the
+	// calls they answer can be failed. RequestIDs are the IDs of the
+	// requests in the frame, so that they can be rejected.
+	ResponseIDs []ID
+	RequestIDs  []ID
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("frame of %d bytes exceeds limit of %d bytes", e.Size, e.Limit)
+}
+
+// WithMaxResponseSize limits the size of messages the Client reads to n
+// bytes. Calls whose response is larger fail with ErrResponseTooLarge, and
+// larger requests are rejected as invalid, instead of the Client buffering
+// arbitrarily large messages. With NewClient and NewHeaderFramer, oversized
+// messages are skipped as they are read, without being kept in memory; with
+// other Framers, they are rejected after being read. If n is zero or less,
+// the limit is left unchanged: NewClient and NewHeaderFramer limit messages
+// to 16MiB by default, and other Framers keep their own limits, if any.
+//
+// Calls are matched to oversized responses by the "id" member, so a
+// response which is too large to be read, and whose ID can't be found, leaves
+// its call waiting until its context is done.
+func WithMaxResponseSize(n int) ClientOpt {
+	return func(c *Client) {
+		if n <= 0 {
+			return
+		}
+		switch f := c.tx.f.(type) {
+		case *streamFramer:
+			f.SetMaxFrameSize(n)
+		case *HeaderFramer:
+			f.SetMaxFrameSize(n)
+		default:
+			c.tx.maxFrameSize = n
+		}
+	}
+}
+
+// tooLargeMessage returns the message delivered to the Client for a frame
+// which was too large to read: a failed response for each call it answered,
+// and an invalid object for each request in it.
+func tooLargeMessage(e *FrameTooLargeError) txMessage {
+	var msg txMessage
+	msg.Batched = len(e.ResponseIDs)+len(e.RequestIDs) > 1
+
+	respErr := fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", ErrResponseTooLarge, e.Size, e.Limit)
+	for _, id := range e.ResponseIDs {
+		msg.Objects = append(msg.Objects, &txObject{Response: &txResponse{ID: id, err: respErr}})
+	}
+	for _, id := range e.RequestIDs {
+		msg.Objects = append(msg.Objects, &txObject{Invalid: &invalidRequestError{ID: id, Err: fmt.Errorf("request too large: %w", e)}})
+	}
+	return msg
+}
+
+// invalidRequestError is the Invalid error of a request which was rejected
+// after its ID was read, so the error reply can be sent to the caller.
+type invalidRequestError struct {
+	ID  ID
+	Err error
+}
+
+func (e *invalidRequestError) Error() string { return e.Err.Error() }
+func (e *invalidRequestError) Unwrap() error { return e.Err }
+
+// invalidID returns the ID to reply to an invalid object with.
+func invalidID(err error) ID {
+	var ire *invalidRequestError
+	if errors.As(err, &ire) {
+		return ire.ID
+	}
+	return NewNullID()
+}
+
+// Limits on what is kept from a skipped frame.
+const (
+	maxSkippedIDs    = 1024
+	maxSkippedIDSize = 128
+	maxSkippedKey    = 16
+)
+
+// skippedFrame finds the IDs of the messages in a frame which is too large
+// to keep in memory. The frame is written to it in pieces as it is read. It
+// only tracks the top-level members of each message, so its memory use
+// doesn't depend on the size of the frame.
+type skippedFrame struct {
+	size int
+
+	depth    int
+	objDepth int // Depth of message objects: 1, or 2 for batches.
+	inString bool
+	escape   bool
+
+	expectKey bool
+	inKey     bool
+	key       []byte
+	lastKey   string
+
+	inID    bool
+	id      []byte
+	idValid bool
+
+	msgID       []byte
+	msgHasID    bool
+	msgIsMethod bool
+
+	responseIDs []ID
+	requestIDs  []ID
+}
+
+func (f *skippedFrame) Write(p []byte) (int, error) {
+	f.size += len(p)
+	for _, c := range p {
+		f.feed(c)
+	}
+	return len(p), nil
+}
+
+// err returns the error for the skipped frame.
+func (f *skippedFrame) err(limit int) *FrameTooLargeError {
+	return &FrameTooLargeError{Size: f.size, Limit: limit, ResponseIDs: f.responseIDs, RequestIDs: f.requestIDs}
+}
+
+func (f *skippedFrame) feed(c byte) {
+	if f.inString {
+		closing := false
+		switch {
+		case f.escape:
+			f.escape = false
+		case c == '\\':
+			f.escape = true
+		case c == '"':
+			f.inString, closing = false, true
+		}
+		if f.inKey {
+			if closing {
+				f.inKey, f.lastKey = false, string(f.key)
+			} else if len(f.key) < maxSkippedKey {
+				f.key = append(f.key, c)
+			}
+			return
+		}
+		f.appendID(c)
+		return
+	}
+
+	switch c {
+	case ' ', '\t', '\r', '\n':
+	case '"':
+		f.inString = true
+		if f.depth == f.objDepth && f.expectKey {
+			f.expectKey, f.inKey, f.key = false, true, f.key[:0]
+			return
+		}
+		f.appendID(c)
+	case ':':
+		if f.depth != f.objDepth {
+			f.appendID(c)
+			return
+		}
+		switch f.lastKey {
+		case "id":
+			f.inID, f.idValid, f.id = true, true, f.id[:0]
+		case "method":
+			f.msgIsMethod = true
+		}
+	case ',':
+		if f.depth == f.objDepth {
+			f.endValue()
+			f.expectKey = true
+			return
+		}
+		f.appendID(c)
+	case '{', '[':
+		if f.depth == 0 {
+			f.objDepth = 1
+			if c == '[' {
+				f.objDepth = 2
+			}
+		}
+		f.depth++
+		if f.depth == f.objDepth && c == '{' {
+			f.msgHasID, f.msgIsMethod, f.expectKey = false, false, true
+			return
+		}
+		f.appendID(c)
+	case '}', ']':
+		if f.depth == f.objDepth && c == '}' {
+			f.endValue()
+			f.endMessage()
+		} else {
+			f.appendID(c)
+		}
+		f.depth--
+	default:
+		f.appendID(c)
+	}
+}
+
+func (f *skippedFrame) appendID(c byte) {
+	if !f.inID {
+		return
+	}
+	if len(f.id) >= maxSkippedIDSize {
+		f.idValid = false
+		return
+	}
+	f.id = append(f.id, c)
+}
+
+// endValue is called at the end of each top-level member of a message.
+func (f *skippedFrame) endValue() {
+	if f.inID {
+		f.inID = false
+		if f.idValid {
+			f.msgID, f.msgHasID = append(f.msgID[:0], f.id...), true
+		}
+	}
+	f.lastKey = ""
+}
+
+func (f *skippedFrame) endMessage() {
+	// Notifications and messages with null IDs can't be answered.
+	if !f.msgHasID || len(f.responseIDs)+len(f.requestIDs) >= maxSkippedIDs {
+		return
+	}
+	var id ID
+	if err := id.UnmarshalJSON(f.msgID); err != nil || id.IsNull() {
+		return
+	}
+	if f.msgIsMethod {
+		f.requestIDs = append(f.requestIDs, id)
+	} else {
+		f.responseIDs = append(f.responseIDs, id)
+	}
+}
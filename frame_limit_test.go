@@ -0,0 +1,188 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkippedFrame(t *testing.T) {
+	tt := []struct {
+		name     string
+		frame    string
+		ids      []ID
+		requests []ID
+	}{
+		{"response", `{"jsonrpc":"2.0","id":1,"result":{"id":2}}`, []ID{NewNumberID(1)}, nil},
+		{"id after result", `{"jsonrpc":"2.0","result":["a","b"],"id":"x"}`, []ID{NewStringID("x")}, nil},
+		{"escaped strings", `{"result":"\"id\":3,\\","id" : 4}`, []ID{NewNumberID(4)}, nil},
+		{"error", `{"jsonrpc":"2.0","error":{"code":1,"message":"m"},"id":5}`, []ID{NewNumberID(5)}, nil},
+		{"null id", `{"jsonrpc":"2.0","error":{"code":1,"message":"m"},"id":null}`, nil, nil},
+		{"request", `{"jsonrpc":"2.0","id":6,"method":"m","params":[1]}`, nil, []ID{NewNumberID(6)}},
+		{"notification", `{"jsonrpc":"2.0","method":"m","params":{"id":7}}`, nil, nil},
+		{
+			"batch",
+			`[{"jsonrpc":"2.0","id":8,"result":[]},{"jsonrpc":"2.0","id":9,"method":"m"},{"id":10,"result":{}}]`,
+			[]ID{NewNumberID(8), NewNumberID(10)}, []ID{NewNumberID(9)},
+		},
+		{"long id", `{"id":"` + strings.Repeat("a", maxSkippedIDSize) + `","result":1}`, nil, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Feed the frame a byte at a time, as it may be split anywhere.
+			var f skippedFrame
+			for i := range tc.frame {
+				_, _ = f.Write([]byte{tc.frame[i]})
+			}
+			err := f.err(1)
+			require.Equal(t, len(tc.frame), err.Size)
+			require.Equal(t, tc.ids, err.ResponseIDs)
+			require.Equal(t, tc.requests, err.RequestIDs)
+		})
+	}
+}
+
+func TestReader_MaxFrameSize(t *testing.T) {
+	big := `{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("x", 64*1024) + `"}`
+	stream := big + "\n" + `{"jsonrpc":"2.0","id":2,"result":1}` + "\n" + `[` + big + `]`
+
+	r := NewReader(iotest.HalfReader(strings.NewReader(stream)))
+	r.SetMaxFrameSize(1024)
+
+	_, err := r.ReadFrame()
+	var tooLarge *FrameTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.Equal(t, len(big), tooLarge.Size)
+	require.Equal(t, []ID{NewNumberID(1)}, tooLarge.ResponseIDs)
+
+	// The large frame was skipped without being buffered.
+	require.Less(t, r.BufferStats().Peak, len(big))
+
+	frame, err := r.ReadFrame()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":1}`, string(frame))
+
+	_, err = r.ReadFrame()
+	require.ErrorAs(t, err, &tooLarge)
+	require.Equal(t, len(big)+2, tooLarge.Size)
+
+	_, err = r.ReadFrame()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestHeaderFramer_SkipsLargeFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewHeaderFramer(&buf)
+	require.NoError(t, w.WriteFrame([]byte(`{"jsonrpc":"2.0","id":1,"result":"too long"}`)))
+	require.NoError(t, w.WriteFrame([]byte(`{}`)))
+
+	f := NewHeaderFramer(&buf)
+	f.SetMaxFrameSize(8)
+	_, err := f.ReadFrame()
+	var tooLarge *FrameTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.Equal(t, []ID{NewNumberID(1)}, tooLarge.ResponseIDs)
+
+	frame, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{}`, string(frame))
+}
+
+func TestMaxFrameSize_Defaults(t *testing.T) {
+	big := `"` + strings.Repeat("x", DefaultMaxStreamFrameSize) + `"`
+
+	// Readers are limited by default.
+	r := NewReader(strings.NewReader(big))
+	_, err := r.ReadFrame()
+	var tooLarge *FrameTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.Equal(t, DefaultMaxStreamFrameSize, tooLarge.Limit)
+
+	// A limit of zero reads frames of any size with both Reader and
+	// HeaderFramer.
+	r = NewReader(strings.NewReader(big))
+	r.SetMaxFrameSize(0)
+	frame, err := r.ReadFrame()
+	require.NoError(t, err)
+	require.Len(t, frame, len(big))
+
+	var buf bytes.Buffer
+	require.NoError(t, NewHeaderFramer(&buf).WriteFrame([]byte(big)))
+	f := NewHeaderFramer(&buf)
+	f.SetMaxFrameSize(0)
+	frame, err = f.ReadFrame()
+	require.NoError(t, err)
+	require.Len(t, frame, len(big))
+}
+
+func TestWithMaxResponseSize(t *testing.T) {
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method == "big" {
+			_ = w.WriteMessage(strings.Repeat("x", 4096))
+			return
+		}
+		_ = w.WriteMessage("ok")
+	})
+
+	tt := []struct {
+		name    string
+		connect func(srvConn, cliConn net.Conn) (srv, cli *Client)
+	}{
+		{"stream", func(srvConn, cliConn net.Conn) (*Client, *Client) {
+			return NewClient(srvConn, handler), NewClient(cliConn, nil, WithMaxResponseSize(1024))
+		}},
+		{"decode workers", func(srvConn, cliConn net.Conn) (*Client, *Client) {
+			return NewClient(srvConn, handler), NewClient(cliConn, nil, WithMaxResponseSize(1024), WithDecodeWorkers(2))
+		}},
+		{"header framer", func(srvConn, cliConn net.Conn) (*Client, *Client) {
+			return NewFramedClient(NewHeaderFramer(srvConn), handler),
+				NewFramedClient(NewHeaderFramer(cliConn), nil, WithMaxResponseSize(1024))
+		}},
+		{"checksum framer", func(srvConn, cliConn net.Conn) (*Client, *Client) {
+			return NewFramedClient(NewChecksumFramer(srvConn), handler),
+				NewFramedClient(NewChecksumFramer(cliConn), nil, WithMaxResponseSize(1024))
+		}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srvConn, cliConn := net.Pipe()
+			srv, cli := tc.connect(srvConn, cliConn)
+			defer srv.Close()
+			defer cli.Close()
+			ctx := context.Background()
+
+			_, err := cli.Invoke(ctx, "big", nil)
+			require.ErrorIs(t, err, ErrResponseTooLarge)
+
+			// The connection is still usable.
+			res, err := cli.Invoke(ctx, "small", nil)
+			require.NoError(t, err)
+			require.JSONEq(t, `"ok"`, string(res))
+		})
+	}
+}
+
+func TestWithMaxResponseSize_Requests(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage("ok")
+	}), WithMaxResponseSize(1024))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+	ctx := context.Background()
+
+	_, err := cli.Invoke(ctx, "echo", strings.Repeat("x", 4096))
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidRequest, rpcErr.Code)
+
+	_, err = cli.Invoke(ctx, "echo", nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,418 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"io"
+
+	"go.uber.org/atomic"
+)
+
+// Framer reads and writes individual JSON-RPC 2.0 payloads, called frames. A
+// frame is a single request, response, or batch encoded as JSON.
+//
+// Framers are the lowest level of the package and do no validation of frames.
+// Use Parse and Encode to convert between frames and Messages.
+//
+// Any Framer can be used for a Client with NewFramedClient. The package
+// provides NewStreamFramer for whitespace-delimited streams, which also
+// reads and writes newline-delimited JSON, NewHeaderFramer for
+// Content-Length framing, and NewChecksumFramer for unreliable links. Other
+// framings, such as length-prefixed binary frames, can be supported by
+// implementing Framer; implementations which also implement io.Closer are
+// closed along with the Client.
+type Framer interface {
+	// ReadFrame returns the next frame. The returned slice is only valid until
+	// the next call to ReadFrame.
+	ReadFrame() ([]byte, error)
+
+	// WriteFrame writes a single frame.
+	WriteFrame(frame []byte) error
+}
+
+// NewStreamFramer returns a Framer which reads and writes a stream of
+// whitespace-delimited JSON values over rw. This is the framing used by
+// NewClient. Frames larger than DefaultMaxStreamFrameSize are rejected with
+// a *FrameTooLargeError.
+//
+// If rw implements io.Closer, the returned Framer will also implement
+// io.Closer.
+func NewStreamFramer(rw io.ReadWriter) Framer {
+	return &streamFramer{Reader: NewReader(rw), Writer: NewWriter(rw), rw: rw}
+}
+
+type streamFramer struct {
+	*Reader
+	*Writer
+	rw io.ReadWriter
+}
+
+// Close closes the underlying stream if it implements io.Closer.
+func (f *streamFramer) Close() error {
+	if c, ok := f.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// DefaultMaxStreamFrameSize is the default limit for the size of frames read
+// by a Reader, in bytes.
+const DefaultMaxStreamFrameSize = 16 << 20
+
+// defaultMaxRetainedBuffer is the default size limit of the read buffer a
+// Reader keeps between frames.
+const defaultMaxRetainedBuffer = 64 * 1024
+
+// minReadSize is the smallest amount of free space the read buffer will have
+// before reading from the underlying stream.
+const minReadSize = 4 * 1024
+
+// Reader reads a stream of whitespace-delimited JSON-RPC 2.0 payloads.
+//
+// Reader keeps a read buffer which is reused between frames. Buffers which
+// grow past 64KiB to fit a frame are released once the frame is consumed.
+type Reader struct {
+	r io.Reader
+
+	// buf holds bytes read from r that haven't been fully consumed yet.
+	// buf[off:] is unread data.
+	buf  []byte
+	off  int
+	scan frameScanner
+
+	maxRetained int
+	stats       *bufferStats
+
+	// maxSize limits the size of frames. skipped is set while the rest of
+	// a frame larger than maxSize is skipped.
+	maxSize int
+	skipped *skippedFrame
+}
+
+// NewReader creates a new Reader reading from r. Frames larger than
+// DefaultMaxStreamFrameSize are rejected.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r:           r,
+		maxRetained: defaultMaxRetainedBuffer,
+		stats:       &bufferStats{},
+		maxSize:     DefaultMaxStreamFrameSize,
+	}
+}
+
+// SetMaxRetainedBuffer sets the maximum size in bytes of the read buffer that
+// is kept between frames. A negative n retains buffers of any size.
+func (r *Reader) SetMaxRetainedBuffer(n int) {
+	r.maxRetained = n
+}
+
+// SetMaxFrameSize sets the largest frame that ReadFrame returns, in bytes.
+// Larger frames are skipped as they're read, without being buffered, and
+// ReadFrame returns a *FrameTooLargeError for them. If n is zero or less,
+// frames of any size are read. As with HeaderFramer, the default is 16MiB.
+func (r *Reader) SetMaxFrameSize(n int) {
+	r.maxSize = n
+}
+
+// BufferStats returns statistics about the Reader's read buffer.
+func (r *Reader) BufferStats() BufferStats {
+	return r.stats.snapshot()
+}
+
+// ReadMessage reads and parses the next payload. See Parse for details.
+func (r *Reader) ReadMessage() (msgs []Message, batched bool, err error) {
+	frame, err := r.ReadFrame()
+	if err != nil {
+		return nil, false, err
+	}
+	defer r.release()
+	return Parse(frame)
+}
+
+// ReadFrame returns the bytes of the next JSON value in the stream. The
+// returned slice is only valid until the next call to ReadFrame.
+func (r *Reader) ReadFrame() ([]byte, error) {
+	r.release()
+
+	for {
+		// Skip over any whitespace between values before scanning.
+		if !r.scan.started {
+			for r.off < len(r.buf) && isSpace(r.buf[r.off]) {
+				r.off++
+			}
+		}
+
+		if n, ok := r.scan.next(r.buf[r.off:]); ok {
+			frame := r.buf[r.off : r.off+n]
+			r.off += n
+			r.scan = frameScanner{}
+			if r.skipped != nil || (r.maxSize > 0 && n > r.maxSize) {
+				return nil, r.skip(frame, true)
+			}
+			return frame, nil
+		}
+
+		// Drop the scanned part of frames which are too large, so they
+		// don't grow the buffer.
+		if r.maxSize > 0 && (r.skipped != nil || len(r.buf)-r.off > r.maxSize) {
+			scanned := r.scan.pos
+			_ = r.skip(r.buf[r.off:r.off+scanned], false)
+			r.off += scanned
+			r.scan.pos = 0
+		}
+
+		if err := r.fill(); err != nil {
+			if errors.Is(err, io.EOF) && len(r.buf) > r.off {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+}
+
+// skip passes data from a frame which is too large to r.skipped. When the
+// frame is done, the error to return for it is returned.
+func (r *Reader) skip(data []byte, done bool) error {
+	if r.skipped == nil {
+		r.skipped = &skippedFrame{}
+	}
+	_, _ = r.skipped.Write(data)
+	if !done {
+		return nil
+	}
+	err := r.skipped.err(r.maxSize)
+	r.skipped = nil
+	return err
+}
+
+// fill reads more data from the underlying stream into r.buf, growing it if
+// needed.
+func (r *Reader) fill() error {
+	// Move unread data to the front of the buffer to reclaim space.
+	if r.off > 0 {
+		n := copy(r.buf, r.buf[r.off:])
+		r.buf = r.buf[:n]
+		r.off = 0
+	}
+
+	if cap(r.buf)-len(r.buf) < minReadSize {
+		newBuf := make([]byte, len(r.buf), 2*cap(r.buf)+minReadSize)
+		copy(newBuf, r.buf)
+		r.buf = newBuf
+		r.stats.observe(cap(r.buf))
+	}
+
+	n, err := r.r.Read(r.buf[len(r.buf):cap(r.buf)])
+	r.buf = r.buf[:len(r.buf)+n]
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return err
+}
+
+// release drops the read buffer if it has been fully drained and has grown
+// larger than the configured maximum.
+func (r *Reader) release() {
+	if r.off < len(r.buf) {
+		return
+	}
+	r.buf, r.off = r.buf[:0], 0
+
+	if r.maxRetained >= 0 && cap(r.buf) > r.maxRetained {
+		r.buf = nil
+		r.stats.releases.Inc()
+		r.stats.observe(0)
+	}
+}
+
+// Writer writes JSON-RPC 2.0 payloads to a stream, separating each payload
+// with a newline.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter creates a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteMessage encodes and writes msgs. See Encode for details.
+func (w *Writer) WriteMessage(msgs []Message, batched bool) error {
+	frame, err := Encode(msgs, batched)
+	if err != nil {
+		return err
+	}
+	return w.WriteFrame(frame)
+}
+
+// WriteFrame writes frame followed by a newline. The frame is written with
+// a single call to Write on the underlying writer.
+func (w *Writer) WriteFrame(frame []byte) error {
+	buf := make([]byte, len(frame)+1)
+	copy(buf, frame)
+	buf[len(frame)] = '\n'
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// WriteFrames writes each frame followed by a newline. All frames are written
+// with a single call to Write on the underlying writer, which allows many
+// small frames to be sent with one syscall.
+func (w *Writer) WriteFrames(frames [][]byte) error {
+	var size int
+	for _, f := range frames {
+		size += len(f) + 1
+	}
+
+	buf := make([]byte, 0, size)
+	for _, f := range frames {
+		buf = append(buf, f...)
+		buf = append(buf, '\n')
+	}
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// SplitFrames is a bufio.SplitFunc which splits a stream of
+// whitespace-delimited JSON values into frames. It may be used with a
+// bufio.Scanner, or called directly by applications which read data in their
+// own event loop.
+func SplitFrames(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for advance < len(data) && isSpace(data[advance]) {
+		advance++
+	}
+
+	var scan frameScanner
+	if n, ok := scan.next(data[advance:]); ok {
+		return advance + n, data[advance : advance+n], nil
+	}
+
+	if atEOF && advance < len(data) {
+		return len(data), data[advance:], io.ErrUnexpectedEOF
+	}
+	return advance, nil, nil
+}
+
+// frameScanner finds the boundaries of a single top-level JSON value in a
+// stream of bytes. It keeps its state between calls so that data can be
+// scanned incrementally as it arrives.
+type frameScanner struct {
+	started  bool
+	scalar   bool
+	depth    int
+	inString bool
+	escape   bool
+
+	// pos is the number of bytes of the current frame already scanned.
+	pos int
+}
+
+// next continues scanning data, which must start at the beginning of the
+// frame and contain at least as many bytes as were given in previous calls.
+// When a full value has been found, next returns its length and true.
+func (s *frameScanner) next(data []byte) (n int, ok bool) {
+	if !s.started {
+		if len(data) == 0 {
+			return 0, false
+		}
+		s.started = true
+		s.pos = 1
+
+		switch data[0] {
+		case '{', '[':
+			s.depth = 1
+		case '"':
+			s.inString = true
+		default:
+			s.scalar = true
+		}
+	}
+
+	for ; s.pos < len(data); s.pos++ {
+		c := data[s.pos]
+
+		switch {
+		case s.scalar:
+			// Scalars (and garbage) end at the first delimiter.
+			if isSpace(c) || isDelim(c) {
+				return s.pos, true
+			}
+		case s.inString:
+			if s.escape {
+				s.escape = false
+			} else if c == '\\' {
+				s.escape = true
+			} else if c == '"' {
+				s.inString = false
+				if s.depth == 0 {
+					return s.pos + 1, true
+				}
+			}
+		default:
+			switch c {
+			case '"':
+				s.inString = true
+			case '{', '[':
+				s.depth++
+			case '}', ']':
+				s.depth--
+				if s.depth == 0 {
+					return s.pos + 1, true
+				}
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '{', '}', '[', ']', ',', ':', '"':
+		return true
+	}
+	return false
+}
+
+// BufferStats reports the state of the read buffer used by a Client.
+type BufferStats struct {
+	// Retained is the current capacity of the read buffer in bytes.
+	Retained int
+	// Peak is the largest capacity the read buffer has grown to in bytes.
+	Peak int
+	// Releases is the number of times the read buffer was dropped for growing
+	// past the maximum retained size.
+	Releases uint64
+}
+
+type bufferStats struct {
+	retained atomic.Int64
+	peak     atomic.Int64
+	releases atomic.Uint64
+}
+
+func (s *bufferStats) observe(size int) {
+	s.retained.Store(int64(size))
+	for {
+		peak := s.peak.Load()
+		if int64(size) <= peak || s.peak.CAS(peak, int64(size)) {
+			return
+		}
+	}
+}
+
+func (s *bufferStats) snapshot() BufferStats {
+	return BufferStats{
+		Retained: int(s.retained.Load()),
+		Peak:     int(s.peak.Load()),
+		Releases: s.releases.Load(),
+	}
+}
@@ -0,0 +1,92 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFrames(t *testing.T) {
+	input := `{"jsonrpc": "2.0", "method": "a"}  [{"jsonrpc": "2.0", "method": "b"}]` + "\n" +
+		`{"jsonrpc": "2.0", "method": "c", "params": ["\"}"]}`
+
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	scanner.Split(SplitFrames)
+
+	var frames []string
+	for scanner.Scan() {
+		frames = append(frames, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, []string{
+		`{"jsonrpc": "2.0", "method": "a"}`,
+		`[{"jsonrpc": "2.0", "method": "b"}]`,
+		`{"jsonrpc": "2.0", "method": "c", "params": ["\"}"]}`,
+	}, frames)
+}
+
+func TestSplitFrames_Partial(t *testing.T) {
+	data := []byte(`  {"jsonrpc": "2.0", "met`)
+
+	advance, token, err := SplitFrames(data, false)
+	require.NoError(t, err)
+	require.Nil(t, token)
+	require.Equal(t, 2, advance)
+
+	_, _, err = SplitFrames(data, true)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReaderWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteMessage([]Message{
+		{Kind: KindNotification, Method: "hello", Params: json.RawMessage(`[]`)},
+	}, false))
+	require.NoError(t, w.WriteMessage([]Message{
+		{Kind: KindResponse, ID: NewNumberID(1), Result: json.RawMessage(`5`)},
+	}, true))
+
+	r := NewReader(&buf)
+
+	msgs, batched, err := r.ReadMessage()
+	require.NoError(t, err)
+	require.False(t, batched)
+	require.Equal(t, []Message{
+		{Kind: KindNotification, Method: "hello", Params: json.RawMessage(`[]`)},
+	}, msgs)
+
+	msgs, batched, err = r.ReadMessage()
+	require.NoError(t, err)
+	require.True(t, batched)
+	require.Equal(t, []Message{
+		{Kind: KindResponse, ID: NewNumberID(1), Result: json.RawMessage(`5`)},
+	}, msgs)
+
+	_, _, err = r.ReadMessage()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestWriter_WriteFrames(t *testing.T) {
+	var cw countingWriter
+	w := NewWriter(&cw)
+	require.NoError(t, w.WriteFrames([][]byte{[]byte(`{"a":1}`), []byte(`[2]`)}))
+	require.Equal(t, "{\"a\":1}\n[2]\n", cw.buf.String())
+	require.Equal(t, 1, cw.writes)
+}
+
+type countingWriter struct {
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.buf.Write(p)
+}
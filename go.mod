@@ -1,11 +1,18 @@
 module github.com/crtv-io/jsonrpc2
 
-go 1.16
+go 1.18
 
 require (
   github.com/go-kit/kit v0.10.0
-  github.com/gorilla/websocket v1.4.2
   github.com/prometheus/client_golang v1.3.0
   github.com/stretchr/testify v1.7.0
   go.uber.org/atomic v1.7.0
 )
+
+require (
+  github.com/davecgh/go-spew v1.1.1 // indirect
+  github.com/go-logfmt/logfmt v0.5.0 // indirect
+  github.com/go-stack/stack v1.8.0 // indirect
+  github.com/pmezard/go-difflib v1.0.0 // indirect
+  gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
@@ -0,0 +1,212 @@
+// Package grpcbridge maps JSON-RPC 2.0 methods onto gRPC methods, allowing
+// existing JSON-RPC clients to consume gRPC backends.
+//
+// grpcbridge does not depend on gRPC and does not make gRPC calls itself. It
+// maps JSON-RPC method names to gRPC method names, propagates cancellation
+// and deadlines, and maps gRPC status codes to JSON-RPC errors. The call is
+// made by an Invoker provided by the application, which must resolve the
+// protobuf message types for each method and exchange them as protobuf JSON.
+// An Invoker built on a *grpc.ClientConn and protojson looks roughly like:
+//
+//	invoker := grpcbridge.InvokerFunc(func(ctx context.Context, method string, req json.RawMessage) (json.RawMessage, error) {
+//		in, out := newRequest(method), newResponse(method) // application-defined lookup
+//		if err := protojson.Unmarshal(req, in); err != nil {
+//			return nil, &grpcbridge.Status{Code: grpcbridge.InvalidArgument, Message: err.Error()}
+//		}
+//		if err := conn.Invoke(ctx, method, in, out); err != nil {
+//			st := status.Convert(err)
+//			return nil, &grpcbridge.Status{Code: grpcbridge.Code(st.Code()), Message: st.Message()}
+//		}
+//		return protojson.Marshal(out)
+//	})
+package grpcbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// Invoker invokes a unary gRPC method. req is the request message encoded as
+// protobuf JSON, and the response message must also be returned as protobuf
+// JSON. method is the full gRPC method name, such as
+// "/users.v1.UserService/GetUser".
+//
+// Errors should be returned as a *Status so they can be mapped to JSON-RPC
+// errors. Other errors are treated as Unavailable.
+type Invoker interface {
+	Invoke(ctx context.Context, method string, req json.RawMessage) (json.RawMessage, error)
+}
+
+// InvokerFunc implements Invoker.
+type InvokerFunc func(ctx context.Context, method string, req json.RawMessage) (json.RawMessage, error)
+
+// Invoke implements Invoker.
+func (f InvokerFunc) Invoke(ctx context.Context, method string, req json.RawMessage) (json.RawMessage, error) {
+	return f(ctx, method, req)
+}
+
+// Bridge is a jsonrpc2.Handler which forwards requests to gRPC methods.
+//
+// The params of each request must be a JSON object, which is passed to the
+// Invoker as the request message. Notifications are forwarded and their
+// responses discarded.
+type Bridge struct {
+	// Invoker used to call gRPC methods.
+	Invoker Invoker
+
+	// Methods maps JSON-RPC method names to full gRPC method names:
+	//
+	//	map[string]string{
+	//		"getUser": "/users.v1.UserService/GetUser",
+	//	}
+	Methods map[string]string
+}
+
+// ServeRPC implements jsonrpc2.Handler.
+func (b *Bridge) ServeRPC(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+	method, ok := b.Methods[r.Method]
+	if !ok {
+		if !r.Notification {
+			w.WriteError(jsonrpc2.ErrorMethodNotFound, fmt.Errorf("method %s not found", r.Method))
+		}
+		return
+	}
+
+	params := r.Params
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	} else if params[0] != '{' {
+		if !r.Notification {
+			w.WriteError(jsonrpc2.ErrorInvalidParams, fmt.Errorf("params must be an object"))
+		}
+		return
+	}
+
+	ctx := r.Context()
+	if deadline, ok := r.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	resp, err := b.Invoker.Invoke(ctx, method, params)
+	if r.Notification {
+		return
+	}
+	if err != nil {
+		rpcErr := ToError(err)
+		w.WriteError(rpcErr.Code, rpcErr)
+		return
+	}
+	w.WriteMessage(resp)
+}
+
+// Code is a gRPC status code. The values match the codes defined by gRPC.
+type Code uint32
+
+// gRPC status codes.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = map[Code]string{
+	OK:                 "OK",
+	Canceled:           "CANCELLED",
+	Unknown:            "UNKNOWN",
+	InvalidArgument:    "INVALID_ARGUMENT",
+	DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	NotFound:           "NOT_FOUND",
+	AlreadyExists:      "ALREADY_EXISTS",
+	PermissionDenied:   "PERMISSION_DENIED",
+	ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	FailedPrecondition: "FAILED_PRECONDITION",
+	Aborted:            "ABORTED",
+	OutOfRange:         "OUT_OF_RANGE",
+	Unimplemented:      "UNIMPLEMENTED",
+	Internal:           "INTERNAL",
+	Unavailable:        "UNAVAILABLE",
+	DataLoss:           "DATA_LOSS",
+	Unauthenticated:    "UNAUTHENTICATED",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("CODE(%d)", uint32(c))
+}
+
+// Status is an error returned by a gRPC method.
+type Status struct {
+	Code    Code
+	Message string
+}
+
+// Error implements error.
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", s.Code, s.Message)
+}
+
+// ErrorStatus is the JSON-RPC error code used for gRPC statuses which have no
+// equivalent JSON-RPC error. The gRPC code is held in the error's data. It is
+// listed with the other server error codes of the jsonrpc2 package.
+const ErrorStatus int = -32010
+
+// ToError converts an error from an Invoker into a JSON-RPC 2.0 error.
+//
+// InvalidArgument maps to ErrorInvalidParams, Unimplemented maps to
+// ErrorMethodNotFound, and Unknown, Internal, and DataLoss map to
+// ErrorInternal. All other codes map to ErrorStatus.
+//
+// The data of the returned error is an object holding the original gRPC code:
+//
+//	{"grpcCode": 5, "grpcStatus": "NOT_FOUND"}
+func ToError(err error) jsonrpc2.Error {
+	var st *Status
+	if !errors.As(err, &st) {
+		st = &Status{Code: Unavailable, Message: err.Error()}
+	}
+
+	var code int
+	switch st.Code {
+	case InvalidArgument:
+		code = jsonrpc2.ErrorInvalidParams
+	case Unimplemented:
+		code = jsonrpc2.ErrorMethodNotFound
+	case Unknown, Internal, DataLoss:
+		code = jsonrpc2.ErrorInternal
+	default:
+		code = ErrorStatus
+	}
+
+	data, _ := json.Marshal(struct {
+		Code   Code   `json:"grpcCode"`
+		Status string `json:"grpcStatus"`
+	}{st.Code, st.Code.String()})
+
+	return jsonrpc2.Error{
+		Code:    code,
+		Message: st.Message,
+		Data:    data,
+	}
+}
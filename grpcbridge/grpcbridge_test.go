@@ -0,0 +1,117 @@
+package grpcbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBridge(t *testing.T) {
+	invoker := InvokerFunc(func(ctx context.Context, method string, req json.RawMessage) (json.RawMessage, error) {
+		switch method {
+		case "/users.v1.UserService/GetUser":
+			var in struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(req, &in); err != nil {
+				return nil, &Status{Code: InvalidArgument, Message: err.Error()}
+			}
+			if in.ID != "1" {
+				return nil, &Status{Code: NotFound, Message: "user not found"}
+			}
+			return json.RawMessage(`{"id": "1", "name": "Alice"}`), nil
+		default:
+			return nil, &Status{Code: Unimplemented, Message: "unknown method"}
+		}
+	})
+
+	left, right := net.Pipe()
+	srv := jsonrpc2.NewClient(right, &Bridge{
+		Invoker: invoker,
+		Methods: map[string]string{
+			"getUser":    "/users.v1.UserService/GetUser",
+			"deleteUser": "/users.v1.UserService/DeleteUser",
+		},
+	})
+	defer srv.Close()
+	cli := jsonrpc2.NewClient(left, nil)
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	resp, err := cli.Invoke(ctx, "getUser", map[string]string{"id": "1"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": "1", "name": "Alice"}`, string(resp))
+
+	_, err = cli.Invoke(ctx, "getUser", map[string]string{"id": "2"})
+	require.Equal(t, jsonrpc2.Error{
+		Code:    ErrorStatus,
+		Message: "user not found",
+		Data:    json.RawMessage(`{"grpcCode":5,"grpcStatus":"NOT_FOUND"}`),
+	}, err)
+
+	_, err = cli.Invoke(ctx, "getUser", []string{"1"})
+	var rpcErr jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorInvalidParams, rpcErr.Code)
+
+	_, err = cli.Invoke(ctx, "deleteUser", map[string]string{"id": "1"})
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorMethodNotFound, rpcErr.Code)
+
+	_, err = cli.Invoke(ctx, "listUsers", nil)
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorMethodNotFound, rpcErr.Code)
+}
+
+func TestToError(t *testing.T) {
+	tt := []struct {
+		err    error
+		expect int
+	}{
+		{err: &Status{Code: InvalidArgument}, expect: jsonrpc2.ErrorInvalidParams},
+		{err: &Status{Code: Unimplemented}, expect: jsonrpc2.ErrorMethodNotFound},
+		{err: &Status{Code: Internal}, expect: jsonrpc2.ErrorInternal},
+		{err: &Status{Code: PermissionDenied}, expect: ErrorStatus},
+		{err: fmt.Errorf("wrapped: %w", &Status{Code: NotFound}), expect: ErrorStatus},
+		{err: fmt.Errorf("connection refused"), expect: ErrorStatus},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			require.Equal(t, tc.expect, ToError(tc.err).Code)
+		})
+	}
+}
+
+func TestBridge_Deadline(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	invoker := InvokerFunc(func(ctx context.Context, method string, req json.RawMessage) (json.RawMessage, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		return json.RawMessage(`{}`), nil
+	})
+
+	left, right := net.Pipe()
+	srv := jsonrpc2.NewClient(right, &Bridge{
+		Invoker: invoker,
+		Methods: map[string]string{"getUser": "/users.v1.UserService/GetUser"},
+	})
+	defer srv.Close()
+	cli := jsonrpc2.NewClient(left, nil, jsonrpc2.WithRequestMetadata(true))
+	defer cli.Close()
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	_, err := cli.Invoke(ctx, "getUser", nil)
+	require.NoError(t, err)
+	require.WithinDuration(t, deadline, <-deadlines, time.Second)
+}
@@ -1,7 +1,10 @@
 package jsonrpc2
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
+	"time"
 )
 
 // Handler handles an individual RPC call.
@@ -22,7 +25,8 @@ type ResponseWriter interface {
 	// not be marshaled to JSON.
 	WriteMessage(msg interface{}) error
 
-	// WriteError writes an error response to the caller.
+	// WriteError writes an error response to the caller. If err is an Error,
+	// its Message and Data will be sent to the caller.
 	WriteError(errorCode int, err error) error
 }
 
@@ -33,7 +37,94 @@ type Request struct {
 
 	Method string
 	Params json.RawMessage
+
+	// ID is the ID of the request. It is undefined for notifications.
+	ID ID
+
+	// Client is the connection the request was received over, which can be
+	// used to call back to the other side. It is nil for requests served
+	// without a connection, such as by ServeFrame.
 	Client *Client
+
+	// Meta holds metadata sent alongside the request, if any.
+	Meta Metadata
+
+	ctx context.Context
+
+	// received is when the request was read, used as the start of the
+	// caller's timeout.
+	received time.Time
+}
+
+// Context returns the request's context. For requests received by a Client,
+// it derives from the Client's Context, so it is cancelled when the Client
+// closes, and it is cancelled when the caller gives up on the request if
+// WithCancelRequests is used. Use WithContext to change the context.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of r with its context changed to ctx.
+// Middleware can use WithContext to attach values or deadlines to a request
+// before passing it to the next Handler.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+	return r2
+}
+
+// ParamsLen returns the size in bytes of the raw request params. It returns 0
+// if the request had no params. ParamsLen can be used to reject oversized
+// requests before decoding them.
+//
+// Params are copied out of the connection's read buffer when a message is
+// read, since handlers run after the buffer has been reused for later
+// messages. ParamsLen only avoids the cost of decoding params, not of copying
+// them.
+func (r *Request) ParamsLen() int {
+	return len(r.Params)
+}
+
+// DecodeParams unmarshals the request params into v. If the request had no
+// params, v is left unmodified and nil is returned. DecodeParams decodes from
+// the copy held in Params, so it may be called after ServeRPC returns.
+func (r *Request) DecodeParams(v interface{}) error {
+	return decodeParams(r.Params, v)
+}
+
+// Deadline returns the deadline propagated by the caller, if any. Handlers may
+// use the deadline to give up on or reduce work that the caller won't wait
+// for. The deadline is measured from when the request was received, so it
+// doesn't account for the time the request spent in transit.
+func (r *Request) Deadline() (deadline time.Time, ok bool) {
+	v := r.Meta.Get(MetaTimeout)
+	if v == "" {
+		return time.Time{}, false
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	received := r.received
+	if received.IsZero() {
+		received = time.Now()
+	}
+	return received.Add(timeout), true
+}
+
+// Priority returns the priority set by the caller with WithPriority. It
+// returns 0 if no priority was set.
+func (r *Request) Priority() int {
+	p, _ := strconv.Atoi(r.Meta.Get(MetaPriority))
+	return p
 }
 
 // HandlerFunc implements Handler.
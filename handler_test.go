@@ -0,0 +1,49 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_DecodeParams(t *testing.T) {
+	t.Run("params", func(t *testing.T) {
+		req := &Request{Params: json.RawMessage(`[1, 2, 3]`)}
+		require.Equal(t, 9, req.ParamsLen())
+
+		var nums []int
+		require.NoError(t, req.DecodeParams(&nums))
+		require.Equal(t, []int{1, 2, 3}, nums)
+	})
+
+	t.Run("no params", func(t *testing.T) {
+		req := &Request{}
+		require.Equal(t, 0, req.ParamsLen())
+
+		nums := []int{1}
+		require.NoError(t, req.DecodeParams(&nums))
+		require.Equal(t, []int{1}, nums)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		req := &Request{Params: json.RawMessage(`{}`)}
+
+		var nums []int
+		require.Error(t, req.DecodeParams(&nums))
+	})
+}
+
+func TestRequest_WithContext(t *testing.T) {
+	type key struct{}
+
+	req := &Request{Method: "ping"}
+	require.Equal(t, context.Background(), req.Context())
+
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	req2 := req.WithContext(ctx)
+	require.Equal(t, "value", req2.Context().Value(key{}))
+	require.Equal(t, "ping", req2.Method)
+	require.Equal(t, context.Background(), req.Context())
+}
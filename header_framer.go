@@ -0,0 +1,107 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxHeaderFrameSize is the default limit for the size of frames read
+// by a HeaderFramer, in bytes.
+const DefaultMaxHeaderFrameSize = 16 << 20
+
+// HeaderFramer is a Framer which reads and writes frames prefixed with a
+// Content-Length header, as used by the Language Server Protocol base
+// protocol:
+//
+//	Content-Length: 52\r\n
+//	\r\n
+//	{"jsonrpc": "2.0", "method": "initialized", "params": {}}
+//
+// Other headers, such as Content-Type, are ignored when reading and never
+// written. Use it with NewFramedClient to talk to header-framed peers:
+//
+//	cli := jsonrpc2.NewFramedClient(jsonrpc2.NewHeaderFramer(conn), handler)
+type HeaderFramer struct {
+	rw      io.ReadWriter
+	r       *bufio.Reader
+	buf     []byte
+	maxSize int
+}
+
+var _ Framer = (*HeaderFramer)(nil)
+
+// NewHeaderFramer creates a HeaderFramer over rw. Frames larger than
+// DefaultMaxHeaderFrameSize are rejected with an error.
+//
+// If rw implements io.Closer, it will be closed when the HeaderFramer is
+// closed.
+func NewHeaderFramer(rw io.ReadWriter) *HeaderFramer {
+	return &HeaderFramer{rw: rw, r: bufio.NewReader(rw), maxSize: DefaultMaxHeaderFrameSize}
+}
+
+// SetMaxFrameSize sets the largest frame that ReadFrame accepts, in bytes.
+// Larger frames are skipped without being buffered, and ReadFrame returns a
+// *FrameTooLargeError for them. If n is zero or less, frames of any size are
+// read, as with Reader.SetMaxFrameSize.
+func (f *HeaderFramer) SetMaxFrameSize(n int) {
+	f.maxSize = n
+}
+
+// ReadFrame implements Framer.
+func (f *HeaderFramer) ReadFrame() ([]byte, error) {
+	hdr, err := textproto.NewReader(f.r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	lengthText := strings.TrimSpace(hdr.Get("Content-Length"))
+	if lengthText == "" {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	length, err := strconv.Atoi(lengthText)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", lengthText)
+	}
+	if f.maxSize > 0 && length > f.maxSize {
+		skipped := &skippedFrame{}
+		if _, err := io.CopyN(skipped, f.r, int64(length)); err != nil {
+			return nil, err
+		}
+		return nil, skipped.err(f.maxSize)
+	}
+
+	if cap(f.buf) < length {
+		f.buf = make([]byte, length)
+	}
+	f.buf = f.buf[:length]
+	if _, err := io.ReadFull(f.r, f.buf); err != nil {
+		return nil, err
+	}
+	return f.buf, nil
+}
+
+// WriteFrame implements Framer.
+func (f *HeaderFramer) WriteFrame(frame []byte) error {
+	header := "Content-Length: " + strconv.Itoa(len(frame)) + "\r\n\r\n"
+	buf := make([]byte, 0, len(header)+len(frame))
+	buf = append(buf, header...)
+	buf = append(buf, frame...)
+
+	_, err := f.rw.Write(buf)
+	return err
+}
+
+// Close closes the underlying stream if it implements io.Closer.
+func (f *HeaderFramer) Close() error {
+	if c, ok := f.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderFramer(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewHeaderFramer(&buf)
+
+	require.NoError(t, f.WriteFrame([]byte(`{"id":1}`)))
+	require.Equal(t, "Content-Length: 8\r\n\r\n{\"id\":1}", buf.String())
+
+	// Other headers are ignored.
+	buf.WriteString("Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 8\r\n\r\n{\"id\":2}")
+
+	frame, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"id":1}`, string(frame))
+
+	frame, err = f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, `{"id":2}`, string(frame))
+}
+
+func TestHeaderFramer_Invalid(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+	}{
+		{name: "missing length", input: "Content-Type: application/json\r\n\r\n{}"},
+		{name: "invalid length", input: "Content-Length: -1\r\n\r\n{}"},
+		{name: "too large", input: "Content-Length: 1099511627776\r\n\r\n{}"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewHeaderFramer(bytes.NewBufferString(tc.input)).ReadFrame()
+			require.Error(t, err)
+		})
+	}
+
+	f := NewHeaderFramer(bytes.NewBufferString("Content-Length: 8\r\n\r\n{\"id\":1}"))
+	f.SetMaxFrameSize(4)
+	_, err := f.ReadFrame()
+	require.Error(t, err)
+}
+
+func TestHeaderFramer_Client(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewFramedClient(NewHeaderFramer(srvConn), HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Method)
+	}))
+	defer srv.Close()
+	cli := NewFramedClient(NewHeaderFramer(cliConn), nil)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := cli.Invoke(ctx, "initialize", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"initialize"`, string(res))
+}
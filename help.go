@@ -0,0 +1,175 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MethodHelp is the method served by the Handler returned by
+// ServeMux.HelpHandler.
+const MethodHelp = "rpc.help"
+
+// MethodDoc documents a method for callers, such as interactive clients
+// which list a server's methods.
+type MethodDoc struct {
+	// Method is the name of the method. It is set by ServeMux when the
+	// doc is returned.
+	Method string `json:"method"`
+
+	Description string `json:"description,omitempty"`
+
+	// Params and Result describe the method's params and result in prose,
+	// such as "[a, b int]" or "the created user".
+	Params string `json:"params,omitempty"`
+	Result string `json:"result,omitempty"`
+
+	Examples []MethodExample `json:"examples,omitempty"`
+}
+
+// MethodExample is an example call to a method.
+type MethodExample struct {
+	Description string          `json:"description,omitempty"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+}
+
+// Document attaches doc to method, replacing any doc attached before.
+// method doesn't need to be registered yet.
+func (m *ServeMux) Document(method string, doc MethodDoc) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.docs == nil {
+		m.docs = make(map[string]MethodDoc)
+	}
+	doc.Method = method
+	m.docs[method] = doc
+}
+
+// Doc returns the doc attached to method. ok is false if method isn't
+// registered. Registered methods without a doc return a MethodDoc with only
+// Method set.
+func (m *ServeMux) Doc(method string) (doc MethodDoc, ok bool) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	if _, ok := m.routes[method]; !ok {
+		return MethodDoc{}, false
+	}
+	if doc, ok := m.docs[method]; ok {
+		return doc, true
+	}
+	return MethodDoc{Method: method}, true
+}
+
+// Docs returns the docs of every registered method, sorted by method.
+func (m *ServeMux) Docs() []MethodDoc {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	docs := make([]MethodDoc, 0, len(m.routes))
+	for method := range m.routes {
+		doc, ok := m.docs[method]
+		if !ok {
+			doc = MethodDoc{Method: method}
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Method < docs[j].Method })
+	return docs
+}
+
+// MethodInfo describes a method registered with a ServeMux.
+type MethodInfo struct {
+	MethodDoc
+
+	// ParamsType and ResultType are the Go types of the method's params
+	// and result, if it was registered with a TypedHandler, and nil
+	// otherwise.
+	ParamsType reflect.Type
+	ResultType reflect.Type
+}
+
+// Methods returns the docs and types of every registered method, sorted by
+// method.
+func (m *ServeMux) Methods() []MethodInfo {
+	docs := m.Docs()
+
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	infos := make([]MethodInfo, len(docs))
+	for i, doc := range docs {
+		infos[i].MethodDoc = doc
+		if th, ok := m.typed[doc.Method]; ok {
+			infos[i].ParamsType, infos[i].ResultType = th.ParamsType(), th.ResultType()
+		}
+	}
+	return infos
+}
+
+type helpParams struct {
+	Method string `json:"method"`
+}
+
+// HelpHandler returns a Handler which serves the docs of m's methods.
+// Servers opt in to serving help by registering it:
+//
+//	mux.Handle(jsonrpc2.MethodHelp, mux.HelpHandler())
+//
+// Called with a method, as either {"method": "sum"} or ["sum"], the
+// handler replies with that method's MethodDoc. Called without params, it
+// replies with the docs of every registered method, as returned by Docs.
+func (m *ServeMux) HelpHandler() Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Notification {
+			return
+		}
+
+		var p helpParams
+		if err := decodeHelpParams(r.Params, &p); err != nil {
+			_ = w.WriteError(ErrorInvalidParams, err)
+			return
+		}
+		if p.Method == "" {
+			_ = w.WriteMessage(m.Docs())
+			return
+		}
+
+		doc, ok := m.Doc(p.Method)
+		if !ok {
+			_ = w.WriteError(ErrorInvalidParams, fmt.Errorf("method %s not found", p.Method))
+			return
+		}
+		_ = w.WriteMessage(doc)
+	})
+}
+
+// decodeHelpParams decodes params given by name or by position.
+func decodeHelpParams(params json.RawMessage, p *helpParams) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if params[0] != '[' {
+		return json.Unmarshal(params, p)
+	}
+
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("expected at most 1 param, got %d", len(args))
+	}
+	if len(args) == 1 {
+		p.Method = args[0]
+	}
+	return nil
+}
+
+// Document attaches doc to method, prefixed with the group's prefix.
+func (g *ServiceGroup) Document(method string, doc MethodDoc) {
+	g.mux.Document(g.prefix+method, doc)
+}
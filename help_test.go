@@ -0,0 +1,98 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeMux_HelpHandler(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("sum", func(w ResponseWriter, r *Request) {})
+	mux.Document("sum", MethodDoc{
+		Description: "Adds numbers.",
+		Params:      "[a, b int]",
+		Examples: []MethodExample{
+			{Params: json.RawMessage(`[1,2]`), Result: json.RawMessage(`3`)},
+		},
+	})
+	mux.Group("users.").HandleFunc("get", func(w ResponseWriter, r *Request) {})
+	mux.Handle(MethodHelp, mux.HelpHandler())
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	res, err := cli.Invoke(ctx, MethodHelp, []string{"sum"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"method": "sum",
+		"description": "Adds numbers.",
+		"params": "[a, b int]",
+		"examples": [{"params": [1,2], "result": 3}]
+	}`, string(res))
+
+	res, err = cli.Invoke(ctx, MethodHelp, map[string]string{"method": "users.get"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"method": "users.get"}`, string(res))
+
+	res, err = cli.Invoke(ctx, MethodHelp, nil)
+	require.NoError(t, err)
+	var docs []MethodDoc
+	require.NoError(t, json.Unmarshal(res, &docs))
+	var methods []string
+	for _, doc := range docs {
+		methods = append(methods, doc.Method)
+	}
+	require.Equal(t, []string{MethodHelp, "sum", "users.get"}, methods)
+
+	_, err = cli.Invoke(ctx, MethodHelp, []string{"missing"})
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+}
+
+func TestServeMux_Document_Unregistered(t *testing.T) {
+	mux := NewServeMux()
+	mux.Document("later", MethodDoc{Description: "Registered later."})
+
+	_, ok := mux.Doc("later")
+	require.False(t, ok)
+
+	mux.HandleFunc("later", func(w ResponseWriter, r *Request) {})
+	doc, ok := mux.Doc("later")
+	require.True(t, ok)
+	require.Equal(t, MethodDoc{Method: "later", Description: "Registered later."}, doc)
+}
+
+func TestServeMux_Methods(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("sum", Method(func(ctx context.Context, nums []int) (int, error) { return 0, nil }))
+	g := mux.Group("users.")
+	g.Use(func(next Handler) Handler { return HandlerFunc(next.ServeRPC) })
+	g.Handle("get", Method(func(ctx context.Context, id string) (*struct{ Name string }, error) { return nil, nil }))
+	mux.HandleFunc("untyped", func(w ResponseWriter, r *Request) {})
+
+	infos := mux.Methods()
+	require.Len(t, infos, 3)
+
+	require.Equal(t, "sum", infos[0].Method)
+	require.Equal(t, reflect.TypeOf([]int(nil)), infos[0].ParamsType)
+	require.Equal(t, reflect.TypeOf(0), infos[0].ResultType)
+
+	// Types are kept through group middleware.
+	require.Equal(t, "users.get", infos[2].Method)
+	require.Equal(t, reflect.TypeOf(""), infos[2].ParamsType)
+
+	require.Equal(t, "untyped", infos[1].Method)
+	require.Nil(t, infos[1].ParamsType)
+	require.Nil(t, infos[1].ResultType)
+}
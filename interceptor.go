@@ -0,0 +1,90 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ClientCall describes an outgoing call passed to a ClientInterceptor.
+type ClientCall struct {
+	// Method and Params are the method and params of the call. Interceptors
+	// may change them before passing the call on. Both are empty for batch
+	// commits.
+	Method string
+	Params interface{}
+
+	// Notification is true for calls made with Notify, which have no result.
+	Notification bool
+
+	// Batch holds the calls in a batch being committed, and is nil for
+	// calls made with Invoke and Notify. The calls describe the batch;
+	// changing them has no effect, since the batch has already been
+	// encoded.
+	Batch []*ClientCall
+
+	// Tags are the tags of the call's context, set with WithCallTag, and
+	// are nil for notifications. Calls in a batch have no tags of their
+	// own; the batch has the tags of the context it was committed with.
+	Tags CallTags
+}
+
+// Invoker sends an outgoing call and returns its result. Notifications and
+// batch commits have no result.
+type Invoker func(ctx context.Context, call *ClientCall) (json.RawMessage, error)
+
+// ClientInterceptor intercepts outgoing calls made with Invoke, Notify, and
+// Batch.Commit. It must call invoker to send the call, and may inspect or
+// change the call and context beforehand and the result afterwards, which
+// allows adding logging, metrics, retries, or credentials without wrapping
+// the Client:
+//
+//	logCalls := func(ctx context.Context, call *jsonrpc2.ClientCall, invoker jsonrpc2.Invoker) (json.RawMessage, error) {
+//		start := time.Now()
+//		res, err := invoker(ctx, call)
+//		log.Printf("%s took %s: %v", call.Method, time.Since(start), err)
+//		return res, err
+//	}
+//	cli := jsonrpc2.NewClient(conn, handler, jsonrpc2.WithClientInterceptor(logCalls))
+//
+// Calling invoker again for Invoke and Notify sends the call again; calls
+// made with Invoke get a new ID each time. The invoker of a batch commit
+// must be called at most once.
+//
+// The context of notifications is context.Background, since Notify doesn't
+// take a context, or holds the options passed to NotifyWith.
+type ClientInterceptor func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error)
+
+// WithClientInterceptor adds an interceptor for outgoing calls. Interceptors
+// run in the order they were added, so the first interceptor added is the
+// outermost.
+func WithClientInterceptor(i ClientInterceptor) ClientOpt {
+	return func(c *Client) {
+		if i != nil {
+			c.interceptors = append(c.interceptors, i)
+		}
+	}
+}
+
+// intercept runs call through the Client's interceptors and then base.
+func (c *Client) intercept(ctx context.Context, call *ClientCall, base Invoker) (json.RawMessage, error) {
+	return c.nextInvoker(0, base)(ctx, call)
+}
+
+// nextInvoker returns an Invoker which runs the interceptors from i onwards
+// before calling base.
+func (c *Client) nextInvoker(i int, base Invoker) Invoker {
+	if i == len(c.interceptors) {
+		return base
+	}
+	return func(ctx context.Context, call *ClientCall) (json.RawMessage, error) {
+		return c.interceptors[i](ctx, call, c.nextInvoker(i+1, base))
+	}
+}
+
+// sendCall is the Invoker for calls made with Invoke and Notify.
+func (c *Client) sendCall(ctx context.Context, call *ClientCall) (json.RawMessage, error) {
+	if call.Notification {
+		return nil, c.notify(ctx, call.Method, call.Params)
+	}
+	return c.invoke(ctx, call.Method, call.Params)
+}
@@ -0,0 +1,85 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithClientInterceptor(t *testing.T) {
+	notified := make(chan string, 1)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Notification {
+			notified <- string(r.Params)
+			return
+		}
+		_ = w.WriteMessage(r.Params)
+	})
+
+	var calls []string
+	record := func(name string) ClientInterceptor {
+		return func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error) {
+			calls = append(calls, fmt.Sprintf("%s:%s:%v:%d", name, call.Method, call.Notification, len(call.Batch)))
+			return invoker(ctx, call)
+		}
+	}
+	rewrite := func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error) {
+		if call.Batch == nil {
+			call.Params = "rewritten"
+		}
+		return invoker(ctx, call)
+	}
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil,
+		WithClientInterceptor(record("outer")),
+		WithClientInterceptor(record("inner")),
+		WithClientInterceptor(rewrite),
+	)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "echo", "hello")
+	require.NoError(t, err)
+	require.Equal(t, `"rewritten"`, string(res))
+
+	require.NoError(t, cli.Notify("log", "hello"))
+	require.Equal(t, `"rewritten"`, <-notified)
+
+	b := cli.Batch()
+	batchRes, err := b.Invoke("echo", 1)
+	require.NoError(t, err)
+	require.NoError(t, b.Notify("log", 2))
+	require.NoError(t, b.Commit(context.Background()))
+	require.Equal(t, "1", string(*batchRes))
+	require.Equal(t, "2", <-notified)
+
+	require.Equal(t, []string{
+		"outer:echo:false:0", "inner:echo:false:0",
+		"outer:log:true:0", "inner:log:true:0",
+		"outer::false:2", "inner::false:2",
+	}, calls)
+}
+
+func TestWithClientInterceptor_ShortCircuit(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, nil)
+	defer srv.Close()
+
+	denied := Error{Code: ErrorInvalidRequest, Message: "denied"}
+	cli := NewClient(cliConn, nil, WithClientInterceptor(func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error) {
+		if call.Method == "admin" {
+			return nil, denied
+		}
+		return invoker(ctx, call)
+	}))
+	defer cli.Close()
+
+	_, err := cli.Invoke(context.Background(), "admin", nil)
+	require.Equal(t, denied, err)
+}
@@ -0,0 +1,27 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// InvokeAs invokes method on conn with params and decodes the result into a
+// T, saving callers from decoding the json.RawMessage returned by Invoke:
+//
+//	sum, err := jsonrpc2.InvokeAs[int](ctx, cli, "sum", []int{1, 2, 3})
+//
+// Errors returned by Invoke are returned as is. If the result can't be
+// decoded into a T, the zero T and a decoding error are returned.
+func InvokeAs[T any](ctx context.Context, conn Conn, method string, params interface{}) (T, error) {
+	var v T
+	res, err := conn.Invoke(ctx, method, params)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(res, &v); err != nil {
+		var zero T
+		return zero, fmt.Errorf("failed to decode result of %s: %w", method, err)
+	}
+	return v, nil
+}
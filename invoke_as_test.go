@@ -0,0 +1,48 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvokeAs(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+
+	mux := NewServeMux()
+	mux.HandleFunc("point", func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(point{X: 1, Y: 2})
+	})
+	mux.HandleFunc("fail", func(w ResponseWriter, r *Request) {
+		_ = w.WriteError(ErrorInvalidParams, Error{Message: "bad"})
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	p, err := InvokeAs[point](context.Background(), cli, "point", nil)
+	require.NoError(t, err)
+	require.Equal(t, point{X: 1, Y: 2}, p)
+
+	ptr, err := InvokeAs[*point](context.Background(), cli, "point", nil)
+	require.NoError(t, err)
+	require.Equal(t, &point{X: 1, Y: 2}, ptr)
+
+	_, err = InvokeAs[point](context.Background(), cli, "fail", nil)
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+
+	n, err := InvokeAs[int](context.Background(), cli, "point", nil)
+	var typeErr *json.UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	require.Zero(t, n)
+}
@@ -0,0 +1,439 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// compactAfter is how many records are appended to a Journal before it is
+// rewritten to hold only the requests in flight.
+const compactAfter = 4096
+
+// maxJournalRecord is the largest record read from a journal file.
+const maxJournalRecord = 64 << 20
+
+// JournalEntry is a request recorded in a Journal.
+type JournalEntry struct {
+	// Seq identifies the entry within the journal.
+	Seq uint64
+
+	// Time is when the request was accepted.
+	Time time.Time
+
+	Method string
+	ID     ID
+	Params json.RawMessage
+	Meta   Metadata
+}
+
+// journalRecord is a line of a journal file. Begin records hold a request,
+// and end records mark the request with the same Seq as finished. Claim and
+// result records hold the idempotency keys of a DedupeStore.
+type journalRecord struct {
+	Type   string          `json:"type"`
+	Seq    uint64          `json:"seq"`
+	Time   time.Time       `json:"time,omitempty"`
+	Method string          `json:"method,omitempty"`
+	ID     *ID             `json:"id,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Meta   Metadata        `json:"meta,omitempty"`
+
+	// Error is the error code the request finished with, if any.
+	Error int `json:"error,omitempty"`
+
+	Key      string          `json:"key,omitempty"`
+	Response *CachedResponse `json:"response,omitempty"`
+}
+
+// Journal is a write-ahead log of the requests a server accepts, used to
+// find which requests were in flight when the server crashed. Requests are
+// journaled by the Journal's Middleware:
+//
+//	j, err := jsonrpc2.OpenJournal("/var/lib/agent/requests.journal")
+//	for _, e := range j.InFlight() {
+//		// e may or may not have completed before the crash.
+//		reconcile(e)
+//		_ = j.Acknowledge(e)
+//	}
+//	handler := jsonrpc2.Chain(mux, j.Middleware())
+//
+// A request is written to the journal and synced to disk before its
+// handler runs, and is marked finished once the handler returns. A request
+// which is in the journal but not finished may have been partially
+// handled, which allows at-most-once handling of requests across restarts.
+// Syncing each request serializes accepting requests on the disk, so only
+// journal requests which need it.
+//
+// Notifications are not journaled.
+//
+// A Journal is also a DedupeStore for ExactlyOnce which remembers keys
+// across restarts. Keys are synced to disk before their request runs, so
+// a request claimed before a crash is never run again: if its response
+// wasn't recorded, retries get ErrorOutcomeUnknown.
+type Journal struct {
+	path string
+
+	mut      sync.Mutex
+	f        *os.File
+	nextSeq  uint64
+	live     map[uint64]journalRecord
+	appended int
+	inFlight []JournalEntry
+	closed   bool
+
+	keys          dedupeTable
+	dedupeWindow  time.Duration
+	maxDedupeKeys int
+}
+
+var _ DedupeStore = (*Journal)(nil)
+
+// OpenJournal opens the journal at path, creating it if it doesn't exist.
+// Requests left in flight by the previous process are returned by
+// InFlight.
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{path: path, live: make(map[uint64]journalRecord)}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	for _, rec := range j.live {
+		j.inFlight = append(j.inFlight, rec.entry())
+	}
+	// Requests which claimed a key in the previous process will never
+	// finish.
+	j.keys.orphan()
+	sort.Slice(j.inFlight, func(a, b int) bool { return j.inFlight[a].Seq < j.inFlight[b].Seq })
+
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// load reads the records in the journal file. A truncated final record,
+// left by a crash while it was being written, is ignored.
+func (j *Journal) load() error {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, maxJournalRecord)
+	for s.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			continue
+		}
+		switch rec.Type {
+		case "begin":
+			j.live[rec.Seq] = rec
+		case "end":
+			delete(j.live, rec.Seq)
+		case "claim":
+			if j.keys.keys == nil {
+				j.keys.keys = make(map[string]*dedupeEntry)
+			}
+			j.keys.keys[rec.Key] = &dedupeEntry{at: rec.Time}
+			continue
+		case "result":
+			if rec.Response != nil {
+				if j.keys.keys == nil {
+					j.keys.keys = make(map[string]*dedupeEntry)
+				}
+				j.keys.keys[rec.Key] = &dedupeEntry{resp: rec.Response, at: rec.Time}
+			}
+			continue
+		}
+		if rec.Seq >= j.nextSeq {
+			j.nextSeq = rec.Seq + 1
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the journal file to hold only the live records, and
+// opens it for appending.
+func (j *Journal) compact() error {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+
+	seqs := make([]uint64, 0, len(j.live))
+	for seq := range j.live {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(a, b int) bool { return seqs[a] < seqs[b] })
+
+	records := make([]journalRecord, 0, len(seqs)+len(j.keys.keys))
+	for _, seq := range seqs {
+		records = append(records, j.live[seq])
+	}
+	j.keys.prune(time.Now(), j.dedupeWindow)
+	for key, e := range j.keys.keys {
+		if e.resp == nil {
+			records = append(records, journalRecord{Type: "claim", Key: key, Time: e.at})
+		} else {
+			records = append(records, journalRecord{Type: "result", Key: key, Time: e.at, Response: e.resp})
+		}
+	}
+
+	w := bufio.NewWriter(f)
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err == nil {
+			_, _ = w.Write(append(line, '\n'))
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, j.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+
+	af, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	if j.f != nil {
+		_ = j.f.Close()
+	}
+	j.f = af
+	j.appended = 0
+	return nil
+}
+
+// InFlight returns the requests which were in flight when the previous
+// process using the journal stopped, and which haven't been acknowledged.
+func (j *Journal) InFlight() []JournalEntry {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	return append([]JournalEntry(nil), j.inFlight...)
+}
+
+// Acknowledge removes e, an entry returned by InFlight, from the journal.
+func (j *Journal) Acknowledge(e JournalEntry) error {
+	j.mut.Lock()
+	for i := range j.inFlight {
+		if j.inFlight[i].Seq == e.Seq {
+			j.inFlight = append(j.inFlight[:i], j.inFlight[i+1:]...)
+			break
+		}
+	}
+	j.mut.Unlock()
+	return j.finish(e.Seq, 0)
+}
+
+// Close closes the journal file. Requests still in flight stay in the
+// journal.
+func (j *Journal) Close() error {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	j.closed = true
+	return j.f.Close()
+}
+
+// begin records that r was accepted and syncs the journal.
+func (j *Journal) begin(r *Request) (uint64, error) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	if j.closed {
+		return 0, os.ErrClosed
+	}
+
+	id := r.ID
+	rec := journalRecord{
+		Type:   "begin",
+		Seq:    j.nextSeq,
+		Time:   time.Now(),
+		Method: r.Method,
+		ID:     &id,
+		Params: r.Params,
+		Meta:   r.Meta,
+	}
+	if err := j.append(rec); err != nil {
+		return 0, err
+	}
+	if err := j.f.Sync(); err != nil {
+		return 0, err
+	}
+	j.nextSeq++
+	j.live[rec.Seq] = rec
+	return rec.Seq, nil
+}
+
+// finish records that the request seq finished with errCode, which is 0 if
+// it succeeded. End records aren't synced: if one is lost, the request is
+// reported as in flight, which is safe for at-most-once handling.
+func (j *Journal) finish(seq uint64, errCode int) error {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	if j.closed {
+		return os.ErrClosed
+	}
+	if _, ok := j.live[seq]; !ok {
+		return nil
+	}
+	if err := j.append(journalRecord{Type: "end", Seq: seq, Error: errCode}); err != nil {
+		return err
+	}
+	delete(j.live, seq)
+	return j.maybeCompact()
+}
+
+// maybeCompact compacts the journal once enough records were appended
+// since it was last compacted.
+func (j *Journal) maybeCompact() error {
+	if j.appended >= compactAfter && j.appended > 2*(len(j.live)+len(j.keys.keys)) {
+		return j.compact()
+	}
+	return nil
+}
+
+// SetDedupeLimits sets how long the Journal remembers the responses to
+// requests with idempotency keys, and how many keys it remembers. Zero
+// values use DefaultDedupeWindow and DefaultMaxDedupeKeys.
+func (j *Journal) SetDedupeLimits(window time.Duration, maxKeys int) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	j.dedupeWindow, j.maxDedupeKeys = window, maxKeys
+}
+
+// Claim implements DedupeStore. New keys are synced to the journal before
+// Claim returns.
+func (j *Journal) Claim(key string) (*CachedResponse, error) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	if j.closed {
+		return nil, os.ErrClosed
+	}
+
+	now := time.Now()
+	resp, err := j.keys.claim(key, now, j.dedupeWindow, j.maxDedupeKeys)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+	err = j.append(journalRecord{Type: "claim", Key: key, Time: now})
+	if err == nil {
+		err = j.f.Sync()
+	}
+	if err != nil {
+		delete(j.keys.keys, key)
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Complete implements DedupeStore. The response isn't synced: if it is
+// lost, retries get ErrOutcomeUnknown.
+func (j *Journal) Complete(key string, resp CachedResponse) error {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	if j.closed {
+		return os.ErrClosed
+	}
+
+	now := time.Now()
+	j.keys.complete(key, resp, now)
+	if err := j.append(journalRecord{Type: "result", Key: key, Time: now, Response: &resp}); err != nil {
+		return err
+	}
+	return j.maybeCompact()
+}
+
+func (j *Journal) append(rec journalRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.appended++
+	return nil
+}
+
+func (rec journalRecord) entry() JournalEntry {
+	e := JournalEntry{
+		Seq:    rec.Seq,
+		Time:   rec.Time,
+		Method: rec.Method,
+		Params: rec.Params,
+		Meta:   rec.Meta,
+	}
+	if rec.ID != nil {
+		e.ID = *rec.ID
+	}
+	return e
+}
+
+// Middleware returns Middleware which journals requests before passing
+// them to the next Handler. If a request can't be journaled, it is
+// rejected with ErrorInternal without being handled.
+func (j *Journal) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			if r.Notification {
+				next.ServeRPC(w, r)
+				return
+			}
+
+			seq, err := j.begin(r)
+			if err != nil {
+				_ = w.WriteError(ErrorInternal, errors.New("failed to journal request"))
+				return
+			}
+			// Requests whose handler panics are finished with ErrorInternal,
+			// without recovering so the panic keeps its stack.
+			jw := &journalWriter{w: w}
+			returned := false
+			defer func() {
+				code := jw.code
+				if !returned {
+					code = ErrorInternal
+				}
+				_ = j.finish(seq, code)
+			}()
+			next.ServeRPC(jw, r)
+			returned = true
+		})
+	}
+}
+
+// journalWriter records the error code written by a handler.
+type journalWriter struct {
+	w    ResponseWriter
+	code int
+}
+
+func (jw *journalWriter) WriteMessage(msg interface{}) error {
+	return jw.w.WriteMessage(msg)
+}
+
+func (jw *journalWriter) WriteError(errCode int, err error) error {
+	jw.code = errCode
+	return jw.w.WriteError(errCode, err)
+}
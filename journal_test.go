@@ -0,0 +1,120 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	require.Empty(t, j.InFlight())
+
+	release := make(chan struct{})
+	h := j.Middleware()(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method == "hang" {
+			<-release
+		}
+		_ = w.WriteMessage("ok")
+	}))
+
+	reply, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "done", "id": 1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "result": "ok", "id": 1}`, string(reply))
+	_, err = ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "notify"}`))
+	require.NoError(t, err)
+
+	// Leave a request in flight and simulate a crash by reopening the
+	// journal without closing it.
+	go func() {
+		_, _ = ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "hang", "params": [1], "id": "a"}`))
+	}()
+	require.Eventually(t, func() bool {
+		j.mut.Lock()
+		defer j.mut.Unlock()
+		return len(j.live) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	j2, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j2.Close()
+
+	inFlight := j2.InFlight()
+	require.Len(t, inFlight, 1)
+	require.Equal(t, "hang", inFlight[0].Method)
+	require.Equal(t, NewStringID("a"), inFlight[0].ID)
+	require.Equal(t, json.RawMessage(`[1]`), inFlight[0].Params)
+
+	close(release)
+	require.NoError(t, j.Close())
+
+	// Acknowledged entries aren't reported again.
+	require.NoError(t, j2.Acknowledge(inFlight[0]))
+	require.Empty(t, j2.InFlight())
+	require.NoError(t, j2.Close())
+
+	j3, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j3.Close()
+	require.Empty(t, j3.InFlight())
+}
+
+func TestJournal_TruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"type": "begin", "seq": 3, "method": "a", "id": 1}`+"\n"+
+			`{"type": "begin", "seq": 4, "meth`), 0o600))
+
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+	require.Len(t, j.InFlight(), 1)
+
+	// New requests are numbered after the recovered ones.
+	seq, err := j.begin(&Request{Method: "b", ID: NewNumberID(2)})
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), seq)
+}
+
+func TestJournal_Compaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+
+	h := j.Middleware()(HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteError(ErrorInvalidParams, Error{Message: "nope"})
+	}))
+	for i := 0; i < compactAfter; i++ {
+		_, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "x", "id": 1}`))
+		require.NoError(t, err)
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Less(t, info.Size(), int64(64<<10))
+}
+
+func TestJournal_Panic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.journal")
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+
+	h := Recover(nil)(j.Middleware()(HandlerFunc(func(w ResponseWriter, r *Request) {
+		panic("boom")
+	})))
+	_, err = ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "x", "id": 1}`))
+	require.NoError(t, err)
+
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	require.Empty(t, j.live)
+}
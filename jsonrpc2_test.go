@@ -1,13 +1,19 @@
 package jsonrpc2
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 func TestJSONRPC2(t *testing.T) {
@@ -48,3 +54,497 @@ func TestJSONRPC2(t *testing.T) {
 	require.NoError(t, json.Unmarshal(resp, &res))
 	require.Equal(t, 3+5+7, res)
 }
+
+func TestNotification(t *testing.T) {
+	type result struct {
+		req      *Request
+		writeErr error
+	}
+	results := make(chan result, 1)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		results <- result{req: r, writeErr: w.WriteMessage("ignored")}
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	require.NoError(t, cli.Notify("log", "hello"))
+
+	res := <-results
+	require.True(t, res.req.Notification)
+	require.Equal(t, "log", res.req.Method)
+	require.Error(t, res.writeErr)
+}
+
+func TestRequest_Context(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-r.Context().Done()
+		cancelled <- r.Context().Err()
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler)
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	go func() { _, _ = cli.Invoke(context.Background(), "wait", nil) }()
+	<-started
+
+	require.NoError(t, srv.Close())
+	require.Equal(t, context.Canceled, <-cancelled)
+}
+
+func TestWithMethodTimeouts(t *testing.T) {
+	// The client gives up before the server so the server's late reply can't
+	// race with the call timing out.
+	srvTimeouts := map[string]time.Duration{"slow": 100 * time.Millisecond}
+	cliTimeouts := map[string]time.Duration{"slow": 50 * time.Millisecond}
+
+	handlerErrs := make(chan error, 1)
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method == "slow" {
+			<-r.Context().Done()
+			handlerErrs <- r.Context().Err()
+		}
+		_, hasDeadline := r.Context().Deadline()
+		_ = w.WriteMessage(hasDeadline)
+	}), WithMethodTimeouts(srvTimeouts))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithMethodTimeouts(cliTimeouts))
+	defer cli.Close()
+
+	// The handler's context times out, and so does the call.
+	_, err := cli.Invoke(context.Background(), "slow", nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Equal(t, context.DeadlineExceeded, <-handlerErrs)
+
+	// Other methods have no timeout.
+	res, err := cli.Invoke(context.Background(), "fast", nil)
+	require.NoError(t, err)
+	require.Equal(t, "false", string(res))
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	// The server never replies, as if it dropped the requests.
+	drop := HandlerFunc(func(w ResponseWriter, r *Request) { <-r.Context().Done() })
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, drop)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithDefaultTimeout(50*time.Millisecond))
+	defer cli.Close()
+
+	_, err := cli.Invoke(context.Background(), "drop", nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	call := <-cli.Go(context.Background(), "drop", nil).Done
+	require.Equal(t, context.DeadlineExceeded, call.Err)
+
+	b := cli.Batch()
+	_, err = b.Invoke("drop", nil)
+	require.NoError(t, err)
+	require.Equal(t, context.DeadlineExceeded, b.Commit(context.Background()))
+
+	// Method timeouts take precedence over the default.
+	slowConn, slowCliConn := net.Pipe()
+	slowSrv := NewClient(slowConn, drop)
+	defer slowSrv.Close()
+	slowCli := NewClient(slowCliConn, nil,
+		WithDefaultTimeout(time.Hour),
+		WithMethodTimeouts(map[string]time.Duration{"drop": 20 * time.Millisecond}))
+	defer slowCli.Close()
+	_, err = slowCli.Invoke(context.Background(), "drop", nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestClient_CloseContext(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	returned := atomic.NewBool(false)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-release
+		_ = w.WriteMessage("late")
+		returned.Store(true)
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler)
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	go func() { _, _ = cli.Invoke(context.Background(), "wait", nil) }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, srv.CloseContext(ctx))
+
+	close(release)
+	require.NoError(t, srv.Close())
+	require.True(t, returned.Load())
+}
+
+func TestClient_ErrConnClosed(t *testing.T) {
+	t.Run("closed", func(t *testing.T) {
+		srvConn, cliConn := net.Pipe()
+		srv := NewClient(srvConn, nil)
+		defer srv.Close()
+		cli := NewClient(cliConn, nil)
+		require.NoError(t, cli.Close())
+
+		_, err := cli.Invoke(context.Background(), "ping", nil)
+		require.ErrorIs(t, err, ErrConnClosed)
+		require.ErrorIs(t, cli.Notify("ping", nil), ErrConnClosed)
+	})
+
+	t.Run("peer closed while waiting", func(t *testing.T) {
+		started := make(chan struct{})
+		srvConn, cliConn := net.Pipe()
+		srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+			close(started)
+			<-r.Context().Done()
+		}))
+		cli := NewClient(cliConn, nil)
+		defer cli.Close()
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := cli.Invoke(context.Background(), "wait", nil)
+			errs <- err
+		}()
+		<-started
+
+		require.NoError(t, srv.Close())
+		require.ErrorIs(t, <-errs, ErrConnClosed)
+		require.ErrorIs(t, cli.Notify("ping", nil), ErrConnClosed)
+	})
+}
+
+func TestClient_Err(t *testing.T) {
+	t.Run("closed", func(t *testing.T) {
+		srvConn, cliConn := net.Pipe()
+		srv := NewClient(srvConn, nil)
+		defer srv.Close()
+		cli := NewClient(cliConn, nil)
+		require.NoError(t, cli.Err())
+
+		require.NoError(t, cli.Close())
+		require.Equal(t, ErrConnClosed, cli.Err())
+	})
+
+	t.Run("peer closed", func(t *testing.T) {
+		srvConn, cliConn := net.Pipe()
+		srv := NewClient(srvConn, nil)
+		cli := NewClient(cliConn, nil)
+		defer cli.Close()
+
+		require.NoError(t, srv.Close())
+		<-cli.Done()
+		require.Equal(t, io.EOF, cli.Err())
+	})
+}
+
+// statusFramer records the status it was closed with.
+type statusFramer struct {
+	Framer
+	code   int
+	reason string
+}
+
+func (f *statusFramer) CloseWithStatus(code int, reason string) error {
+	f.code, f.reason = code, reason
+	return f.Framer.(io.Closer).Close()
+}
+
+func TestClient_CloseWithStatus(t *testing.T) {
+	started := make(chan struct{})
+	srvConn, cliConn := net.Pipe()
+	f := &statusFramer{Framer: NewStreamFramer(srvConn)}
+	srv := NewFramedClient(f, HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		// Handlers may close the Client they were invoked by.
+		_ = r.Client.CloseWithStatus(4000, "go away")
+	}))
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	require.NoError(t, cli.Notify("bye", nil))
+	<-started
+	<-srv.Done()
+	require.Equal(t, 4000, f.code)
+	require.Equal(t, "go away", f.reason)
+	require.Equal(t, ErrConnClosed, srv.Err())
+}
+
+func TestBatch_Commit(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method == "fail" {
+			_ = w.WriteError(ErrorInternal, errors.New("failed"))
+			return
+		}
+		_ = w.WriteMessage(r.Method)
+	}))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	b := cli.Batch()
+	a, err := b.Invoke("a", nil)
+	require.NoError(t, err)
+	c, err := b.Invoke("c", nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Commit(context.Background()))
+	require.Equal(t, `"a"`, string(*a))
+	require.Equal(t, `"c"`, string(*c))
+
+	b = cli.Batch()
+	_, err = b.Invoke("fail", nil)
+	require.NoError(t, err)
+	var rpcErr Error
+	require.ErrorAs(t, b.Commit(context.Background()), &rpcErr)
+	require.Equal(t, ErrorInternal, rpcErr.Code)
+}
+
+func TestClient_InvalidMessages(t *testing.T) {
+	tt := []struct {
+		name   string
+		frame  string
+		expect string
+	}{
+		{
+			name:   "invalid json",
+			frame:  `{"jsonrpc": "2.0", "method": }`,
+			expect: `{"jsonrpc": "2.0", "error": {"code": -32700}, "id": null}`,
+		},
+		{
+			name:   "invalid request",
+			frame:  `{"jsonrpc": "2.0", "method": 1}`,
+			expect: `{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}`,
+		},
+		{
+			name:   "empty batch",
+			frame:  `[]`,
+			expect: `{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}`,
+		},
+		{
+			name:   "invalid batch entry",
+			frame:  `[1]`,
+			expect: `[{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}]`,
+		},
+		{
+			name:   "invalid batch entry with id",
+			frame:  `[{"jsonrpc": "1.0", "method": "a", "id": 7}]`,
+			expect: `[{"jsonrpc": "2.0", "error": {"code": -32600}, "id": 7}]`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srvConn, peerConn := net.Pipe()
+			srv := NewClient(srvConn, nil)
+			defer srv.Close()
+			defer peerConn.Close()
+
+			go func() { _, _ = peerConn.Write([]byte(tc.frame)) }()
+
+			frame, err := NewReader(peerConn).ReadFrame()
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expect, string(stripErrorMessage(t, frame)))
+		})
+	}
+
+	t.Run("error replies disabled", func(t *testing.T) {
+		srvConn, peerConn := net.Pipe()
+		srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+			_ = w.WriteMessage(r.Method)
+		}), WithErrorReplies(false))
+		defer srv.Close()
+		defer peerConn.Close()
+
+		go func() {
+			_, _ = peerConn.Write([]byte(`{"jsonrpc": "2.0", "method": }`))
+			_, _ = peerConn.Write([]byte(`{"jsonrpc": "2.0", "method": "ping", "id": 1}`))
+		}()
+
+		// The first reply should be for the valid request.
+		frame, err := NewReader(peerConn).ReadFrame()
+		require.NoError(t, err)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "result": "ping", "id": 1}`, string(frame))
+	})
+}
+
+func TestClient_InvalidMessageLimit(t *testing.T) {
+	srvConn, peerConn := net.Pipe()
+	srv := NewClient(srvConn, nil, WithInvalidMessageLimit(40))
+	defer srv.Close()
+	defer peerConn.Close()
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := peerConn.Write([]byte(`{"jsonrpc": "2.0", "method": 1}`)); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Replies are sent for 1-16 and 32 before the connection is closed on
+	// the 41st message.
+	r := NewReader(peerConn)
+	var replies int
+	for {
+		if _, err := r.ReadFrame(); err != nil {
+			break
+		}
+		replies++
+	}
+	require.Equal(t, 17, replies)
+
+	select {
+	case <-srv.Done():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "client not closed")
+	}
+}
+
+// stripErrorMessage removes error messages from a response frame so it can be
+// compared without depending on the exact wording of decode errors.
+func stripErrorMessage(t *testing.T, frame []byte) []byte {
+	t.Helper()
+
+	strip := func(v map[string]interface{}) {
+		if e, ok := v["error"].(map[string]interface{}); ok {
+			delete(e, "message")
+		}
+	}
+
+	var v interface{}
+	require.NoError(t, json.Unmarshal(frame, &v))
+	switch v := v.(type) {
+	case map[string]interface{}:
+		strip(v)
+	case []interface{}:
+		for _, elem := range v {
+			strip(elem.(map[string]interface{}))
+		}
+	}
+
+	out, err := json.Marshal(v)
+	require.NoError(t, err)
+	return out
+}
+
+func BenchmarkClient_HandleNotification(b *testing.B) {
+	cli := &Client{handler: HandlerFunc(func(w ResponseWriter, r *Request) {})}
+	batch := txMessage{Objects: []*txObject{{
+		Request: &txRequest{Notification: true, Method: "log", Params: json.RawMessage(`"hello"`)},
+	}}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cli.handleBatch(batch)
+	}
+}
+
+func TestResponseCoalescing(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	counted := &countingConn{Conn: srvConn}
+
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Params)
+	})
+	srv := NewClient(counted, handler, WithResponseCoalescing(50*time.Millisecond))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	const calls = 20
+
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		go func(i int) {
+			res, err := cli.Invoke(context.Background(), "echo", i)
+			if err == nil && string(res) != fmt.Sprint(i) {
+				err = fmt.Errorf("call %d: unexpected result %s", i, res)
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < calls; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	require.Less(t, int(counted.writes.Load()), calls)
+}
+
+type countingConn struct {
+	net.Conn
+	writes atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.writes.Inc()
+	return c.Conn.Write(p)
+}
+
+func TestWithConcurrentBatches(t *testing.T) {
+	var (
+		mut    sync.Mutex
+		events []string
+	)
+	fastDone := make(chan struct{})
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch r.Method {
+		case "slow":
+			// Only finishes early if fast runs at the same time.
+			select {
+			case <-fastDone:
+			case <-time.After(5 * time.Second):
+			}
+		case "fast":
+			defer close(fastDone)
+		}
+		mut.Lock()
+		events = append(events, r.Method)
+		mut.Unlock()
+		_ = w.WriteMessage(r.Method)
+	})
+
+	srvConn, peerConn := net.Pipe()
+	defer peerConn.Close()
+	srv := NewClient(srvConn, handler, WithConcurrentBatches(4))
+	defer srv.Close()
+
+	go func() {
+		_, _ = peerConn.Write([]byte(`[
+			{"jsonrpc":"2.0","id":1,"method":"slow"},
+			{"jsonrpc":"2.0","id":2,"method":"fast"},
+			{"jsonrpc":"2.0","method":"note"},
+			{"jsonrpc":"2.0","id":3,"method":"after"}
+		]`))
+	}()
+
+	start := time.Now()
+	scanner := bufio.NewScanner(peerConn)
+	scanner.Split(SplitFrames)
+	require.True(t, scanner.Scan())
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// Responses are sent together in the order of the requests, and the
+	// notification ran between the requests before and after it.
+	require.JSONEq(t, `[
+		{"jsonrpc":"2.0","id":1,"result":"slow"},
+		{"jsonrpc":"2.0","id":2,"result":"fast"},
+		{"jsonrpc":"2.0","id":3,"result":"after"}
+	]`, scanner.Text())
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, []string{"fast", "slow", "note", "after"}, events)
+}
@@ -0,0 +1,267 @@
+package jsonrpc2http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"go.uber.org/atomic"
+)
+
+// DefaultMaxResponseBytes is the default limit for the size of response
+// bodies read by a Client.
+const DefaultMaxResponseBytes = 16 << 20
+
+// ClientOpt is an option function that can be passed to NewClient.
+type ClientOpt func(*Client)
+
+// WithHTTPClient sets the http.Client used to send requests. The default is
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) ClientOpt {
+	return func(c *Client) {
+		if hc != nil {
+			c.hc = hc
+		}
+	}
+}
+
+// WithRoundTripper sets the http.RoundTripper used to send requests.
+func WithRoundTripper(rt http.RoundTripper) ClientOpt {
+	return func(c *Client) {
+		c.hc = &http.Client{Transport: rt}
+	}
+}
+
+// WithHeader sets a header to send with each request, such as an
+// Authorization header.
+func WithHeader(key, value string) ClientOpt {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithTimeout sets the maximum amount of time to wait for each HTTP request,
+// including reading the response. If zero, only the context passed to Invoke
+// bounds the request.
+func WithTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithMaxResponseBytes limits the size of response bodies. Calls with larger
+// responses fail. The default is DefaultMaxResponseBytes.
+func WithMaxResponseBytes(n int64) ClientOpt {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxResponse = n
+		}
+	}
+}
+
+// Client is a jsonrpc2.Conn which sends each call as an HTTP POST to a JSON-RPC
+// 2.0 server. Unlike a jsonrpc2.Client, the server can't call back to a
+// Client.
+type Client struct {
+	url         string
+	hc          *http.Client
+	header      http.Header
+	timeout     time.Duration
+	maxResponse int64
+
+	lastID atomic.Int64
+}
+
+var _ jsonrpc2.Conn = (*Client)(nil)
+
+// NewClient creates a Client which sends calls to url.
+func NewClient(url string, opts ...ClientOpt) *Client {
+	c := &Client{
+		url:         url,
+		hc:          http.DefaultClient,
+		header:      make(http.Header),
+		maxResponse: DefaultMaxResponseBytes,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Invoke invokes an RPC and waits for its response. RPC-level errors are
+// returned as a jsonrpc2.Error.
+func (c *Client) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	b := c.Batch()
+	res, err := b.Invoke(method, msg)
+	if err != nil {
+		return nil, err
+	}
+	b.batched = false
+	if err := b.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+// Notify sends a notification. The server's reply to the HTTP request is
+// waited for, but there is no way of knowing if the server successfully
+// handled the notification.
+func (c *Client) Notify(method string, msg interface{}) error {
+	b := c.Batch()
+	if err := b.Notify(method, msg); err != nil {
+		return err
+	}
+	b.batched = false
+	return b.Commit(context.Background())
+}
+
+// Batch creates a new request batch, which is sent as a single HTTP request.
+func (c *Client) Batch() *Batch {
+	return &Batch{cli: c, batched: true, results: make(map[jsonrpc2.ID]*json.RawMessage)}
+}
+
+// Batch is a batch of calls sent as a single HTTP request. It must be
+// committed with Commit.
+type Batch struct {
+	cli     *Client
+	batched bool
+	msgs    []jsonrpc2.Message
+	results map[jsonrpc2.ID]*json.RawMessage
+}
+
+// Notify adds a notification to the batch.
+func (b *Batch) Notify(method string, msg interface{}) error {
+	params, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	b.msgs = append(b.msgs, jsonrpc2.Message{
+		Kind:   jsonrpc2.KindNotification,
+		Method: method,
+		Params: params,
+	})
+	return nil
+}
+
+// Invoke queues an RPC to invoke. The returned *json.RawMessage will be empty
+// until the batch is committed.
+func (b *Batch) Invoke(method string, msg interface{}) (*json.RawMessage, error) {
+	params, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	id := jsonrpc2.NewNumberID(b.cli.lastID.Inc())
+	var result json.RawMessage
+	b.results[id] = &result
+	b.msgs = append(b.msgs, jsonrpc2.Message{
+		Kind:   jsonrpc2.KindRequest,
+		ID:     id,
+		Method: method,
+		Params: params,
+	})
+	return &result, nil
+}
+
+// Commit sends the batch and waits for the responses. If any call failed,
+// the first error is returned.
+func (b *Batch) Commit(ctx context.Context) error {
+	if len(b.msgs) == 0 {
+		return nil
+	}
+	payload, err := jsonrpc2.Encode(b.msgs, b.batched)
+	if err != nil {
+		return err
+	}
+
+	reply, err := b.cli.post(ctx, payload)
+	if err != nil {
+		return err
+	}
+	if len(b.results) == 0 {
+		return nil
+	}
+	if len(reply) == 0 {
+		return fmt.Errorf("server sent no responses")
+	}
+
+	msgs, _, err := jsonrpc2.Parse(reply)
+	if err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+
+	var firstError error
+	for _, m := range msgs {
+		if m.Kind != jsonrpc2.KindResponse {
+			continue
+		}
+		result, ok := b.results[m.ID]
+		if !ok {
+			// Errors for requests the server couldn't parse have a null ID.
+			if m.Error != nil && firstError == nil {
+				firstError = *m.Error
+			}
+			continue
+		}
+		delete(b.results, m.ID)
+
+		if m.Error != nil {
+			if firstError == nil {
+				firstError = *m.Error
+			}
+			continue
+		}
+		*result = m.Result
+	}
+	if firstError == nil && len(b.results) > 0 {
+		firstError = fmt.Errorf("server sent no response for %d requests", len(b.results))
+	}
+	return firstError
+}
+
+// post sends payload to the server and returns the body of the reply, which
+// is empty if the server had nothing to reply with.
+func (c *Client) post(ctx context.Context, payload []byte) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	for k, vv := range c.header {
+		req.Header[k] = vv
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxResponse+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(body)) > c.maxResponse {
+		return nil, fmt.Errorf("response larger than %d bytes", c.maxResponse)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return body, nil
+}
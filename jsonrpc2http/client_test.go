@@ -0,0 +1,88 @@
+package jsonrpc2http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	notified := make(chan string, 1)
+
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("echo", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Params)
+	})
+	mux.HandleFunc("fail", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteError(jsonrpc2.ErrorInvalidParams, errors.New("bad params"))
+	})
+	mux.HandleFunc("log", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		notified <- string(r.Params)
+	})
+
+	rpc := &Handler{Handler: mux}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		rpc.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli := NewClient(srv.URL, WithHTTPClient(srv.Client()), WithHeader("Authorization", "Bearer token"))
+
+	t.Run("invoke", func(t *testing.T) {
+		res, err := cli.Invoke(ctx, "echo", "hello")
+		require.NoError(t, err)
+		require.Equal(t, `"hello"`, string(res))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := cli.Invoke(ctx, "fail", nil)
+		var rpcErr jsonrpc2.Error
+		require.True(t, errors.As(err, &rpcErr))
+		require.Equal(t, jsonrpc2.ErrorInvalidParams, rpcErr.Code)
+	})
+
+	t.Run("notify", func(t *testing.T) {
+		require.NoError(t, cli.Notify("log", "hi"))
+		require.Equal(t, `"hi"`, <-notified)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		b := cli.Batch()
+		a, err := b.Invoke("echo", 1)
+		require.NoError(t, err)
+		c, err := b.Invoke("echo", 2)
+		require.NoError(t, err)
+		require.NoError(t, b.Notify("log", "batch"))
+		require.NoError(t, b.Commit(ctx))
+
+		require.Equal(t, "1", string(*a))
+		require.Equal(t, "2", string(*c))
+		require.Equal(t, `"batch"`, <-notified)
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		cli := NewClient(srv.URL, WithHTTPClient(srv.Client()))
+		_, err := cli.Invoke(ctx, "echo", "hello")
+		require.Error(t, err)
+	})
+
+	t.Run("response too large", func(t *testing.T) {
+		cli := NewClient(srv.URL, WithHTTPClient(srv.Client()), WithHeader("Authorization", "Bearer token"), WithMaxResponseBytes(64))
+		_, err := cli.Invoke(ctx, "echo", strings.Repeat("a", 128))
+		require.Error(t, err)
+	})
+}
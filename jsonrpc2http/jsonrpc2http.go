@@ -0,0 +1,107 @@
+// Package jsonrpc2http serves JSON-RPC 2.0 over HTTP POST.
+//
+// Each HTTP request holds a single JSON-RPC 2.0 request, notification, or
+// batch in its body, and the reply is written to the HTTP response body.
+// This allows a jsonrpc2.Handler to be called by clients which expect plain
+// HTTP rather than a persistent connection, such as curl:
+//
+//	http.Handle("/rpc", &jsonrpc2http.Handler{Handler: mux})
+//
+//	$ curl -d '{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1}' localhost:8080/rpc
+//	{"jsonrpc":"2.0","result":3,"id":1}
+//
+// Since there is no connection to call back over, Request.Client is nil for
+// requests served by a Handler.
+//
+// Client calls HTTP-only JSON-RPC 2.0 servers through the jsonrpc2.Conn
+// interface, sending each call or batch as its own POST.
+//
+// SSEHandler and DialSSE provide bidirectional sessions over HTTP, with
+// messages to the client sent as Server-Sent Events and messages to the
+// server sent as POSTs.
+package jsonrpc2http
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// DefaultMaxBodyBytes is the default limit for the size of request bodies.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Handler is an http.Handler which serves JSON-RPC 2.0 requests sent as HTTP
+// POST requests.
+//
+// Requests and batches are answered with 200 OK and the JSON-RPC 2.0 reply as
+// the body, including when the reply is an error. Payloads which need no
+// reply, such as notifications, are answered with 204 No Content.
+type Handler struct {
+	// Handler is invoked for each JSON-RPC request. Its context is the
+	// context of the HTTP request.
+	Handler jsonrpc2.Handler
+
+	// MaxBodyBytes limits the size of request bodies. Requests with larger
+	// bodies are rejected with 413 Request Entity Too Large. If zero,
+	// DefaultMaxBodyBytes is used.
+	MaxBodyBytes int64
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, ok := readBody(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+
+	handler := h.Handler
+	if handler == nil {
+		handler = jsonrpc2.DefaultHandler
+	}
+	reply, err := jsonrpc2.ServeFrame(r.Context(), handler, body)
+	if err != nil {
+		http.Error(w, "failed to encode reply", http.StatusInternalServerError)
+		return
+	}
+	if reply == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(reply)
+}
+
+// readBody reads a JSON request body of at most maxBody bytes, or
+// DefaultMaxBodyBytes if maxBody is zero. If the body can't be read, an
+// error is written to w and ok is false.
+func readBody(w http.ResponseWriter, r *http.Request, maxBody int64) (body []byte, ok bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+			return nil, false
+		}
+	}
+
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		if int64(len(body)) >= maxBody {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
@@ -0,0 +1,140 @@
+package jsonrpc2http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	notified := make(chan string, 2)
+
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("sum", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var nums []int
+		if err := r.DecodeParams(&nums); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		var sum int
+		for _, n := range nums {
+			sum += n
+		}
+		_ = w.WriteMessage(sum)
+	})
+	mux.HandleFunc("log", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		notified <- string(r.Params)
+	})
+	mux.HandleFunc("client", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Client == nil)
+	})
+	mux.HandleFunc("empty", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {})
+
+	srv := httptest.NewServer(&Handler{Handler: mux, MaxBodyBytes: 128})
+	defer srv.Close()
+
+	tt := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		expect      string
+	}{
+		{
+			name:   "request",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1}`,
+			status: http.StatusOK,
+			expect: `{"jsonrpc": "2.0", "result": 3, "id": 1}`,
+		},
+		{
+			name:   "batch",
+			method: "POST",
+			body:   `[{"jsonrpc": "2.0", "method": "sum", "params": [1], "id": "a"}, {"jsonrpc": "2.0", "method": "log", "params": "hi"}]`,
+			status: http.StatusOK,
+			expect: `[{"jsonrpc": "2.0", "result": 1, "id": "a"}]`,
+		},
+		{
+			name:   "no result",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": "empty", "id": 1}`,
+			status: http.StatusOK,
+			expect: `{"jsonrpc": "2.0", "result": null, "id": 1}`,
+		},
+		{
+			name:   "no client",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": "client", "id": 1}`,
+			status: http.StatusOK,
+			expect: `{"jsonrpc": "2.0", "result": true, "id": 1}`,
+		},
+		{
+			name:   "notification",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": "log", "params": "hi"}`,
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "method not found",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": "missing", "id": 1}`,
+			status: http.StatusOK,
+			expect: `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "method missing not found"}, "id": 1}`,
+		},
+		{
+			name:   "parse error",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": }`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "wrong method",
+			method: "GET",
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "wrong content type",
+			method:      "POST",
+			contentType: "text/plain",
+			body:        `{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1}`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "body too large",
+			method: "POST",
+			body:   `{"jsonrpc": "2.0", "method": "sum", "params": [` + strings.Repeat("1, ", 64) + `1], "id": 1}`,
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			resp, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tc.status, resp.StatusCode)
+			if tc.expect != "" {
+				body := new(strings.Builder)
+				_, err := io.Copy(body, resp.Body)
+				require.NoError(t, err)
+				require.JSONEq(t, tc.expect, body.String())
+			}
+		})
+	}
+
+	require.Equal(t, `"hi"`, <-notified)
+	require.Equal(t, `"hi"`, <-notified)
+}
@@ -0,0 +1,381 @@
+package jsonrpc2http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// sseSessionEvent is the type of the first event sent on an SSE stream,
+// whose data is the session ID.
+const sseSessionEvent = "session"
+
+// sseQueueSize is how many POSTed frames are buffered for a session before
+// further POSTs wait for the session to read them.
+const sseQueueSize = 16
+
+// SSEHandler is an http.Handler which serves bidirectional JSON-RPC 2.0
+// sessions to clients, such as browsers, which can't open raw sockets or
+// websockets.
+//
+// A client opens a session with a GET request, which is answered with a
+// stream of Server-Sent Events. The first event has the type "session" and
+// the session ID as its data. Every later event holds one JSON-RPC 2.0
+// message sent to the client:
+//
+//	event: session
+//	data: 5f2b...
+//
+//	data: {"jsonrpc":"2.0","result":3,"id":1}
+//
+// The client sends messages as POST requests to the same URL with the
+// session ID in the "session" query parameter. POSTs are answered with 202
+// Accepted, and any responses are delivered over the event stream. Each
+// session is served by a jsonrpc2.Client, so the server can also call the
+// client. The session ends when the event stream is closed.
+type SSEHandler struct {
+	// Handler is invoked for each request received in a session.
+	Handler jsonrpc2.Handler
+
+	// ClientOpts are passed to the Client created for each session.
+	ClientOpts []jsonrpc2.ClientOpt
+
+	// OnClient may be provided to use the Client of new sessions, such as to
+	// call the browser.
+	OnClient func(c *jsonrpc2.Client)
+
+	// MaxBodyBytes limits the size of POSTed messages. If zero,
+	// DefaultMaxBodyBytes is used.
+	MaxBodyBytes int64
+
+	mut      sync.Mutex
+	sessions map[string]*sseSession
+}
+
+// ServeHTTP implements http.Handler.
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.serveStream(w, r)
+	case http.MethodPost:
+		h.servePost(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *SSEHandler) serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	s := &sseSession{
+		w:        w,
+		flusher:  flusher,
+		incoming: make(chan []byte, sseQueueSize),
+		done:     make(chan struct{}),
+	}
+
+	// Register the session before sending its ID so the client can POST as
+	// soon as it has the ID.
+	h.mut.Lock()
+	if h.sessions == nil {
+		h.sessions = make(map[string]*sseSession)
+	}
+	h.sessions[id] = s
+	h.mut.Unlock()
+
+	defer func() {
+		h.mut.Lock()
+		delete(h.sessions, id)
+		h.mut.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := s.writeEvent(sseSessionEvent, []byte(id)); err != nil {
+		return
+	}
+
+	handler := h.Handler
+	if handler == nil {
+		handler = jsonrpc2.DefaultHandler
+	}
+	cli := jsonrpc2.NewFramedClient(s, handler, h.ClientOpts...)
+	if h.OnClient != nil {
+		go h.OnClient(cli)
+	}
+
+	select {
+	case <-r.Context().Done():
+	case <-cli.Done():
+	}
+	// Close waits for the session to stop writing to w, which must not be
+	// used once ServeHTTP returns.
+	_ = cli.Close()
+}
+
+func (h *SSEHandler) servePost(w http.ResponseWriter, r *http.Request) {
+	h.mut.Lock()
+	s, ok := h.sessions[r.URL.Query().Get("session")]
+	h.mut.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	body, ok := readBody(w, r, h.MaxBodyBytes)
+	if !ok {
+		return
+	}
+
+	select {
+	case s.incoming <- body:
+		w.WriteHeader(http.StatusAccepted)
+	case <-s.done:
+		http.Error(w, "session closed", http.StatusGone)
+	case <-r.Context().Done():
+	}
+}
+
+func newSessionID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// sseSession is a jsonrpc2.Framer which reads POSTed frames and writes frames
+// as events to an SSE stream.
+type sseSession struct {
+	incoming chan []byte
+
+	writeMut sync.Mutex
+	w        http.ResponseWriter
+	flusher  http.Flusher
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (s *sseSession) ReadFrame() ([]byte, error) {
+	select {
+	case frame := <-s.incoming:
+		return frame, nil
+	case <-s.done:
+		return nil, io.EOF
+	}
+}
+
+func (s *sseSession) WriteFrame(frame []byte) error {
+	return s.writeEvent("", frame)
+}
+
+func (s *sseSession) writeEvent(event string, data []byte) error {
+	s.writeMut.Lock()
+	defer s.writeMut.Unlock()
+
+	select {
+	case <-s.done:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	var buf bytes.Buffer
+	if event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// Close stops the session. Once Close returns, the session no longer writes
+// to the stream.
+func (s *sseSession) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	// Wait for any write in progress to finish.
+	s.writeMut.Lock()
+	s.writeMut.Unlock()
+	return nil
+}
+
+// DialSSE opens a session with an SSEHandler at url and returns a Client for
+// it. ctx bounds how long to wait for the session to be opened. handler
+// will be invoked for each request the server sends over the session.
+// Messages are POSTed with http.DefaultClient.
+func DialSSE(ctx context.Context, url string, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) (*jsonrpc2.Client, error) {
+	// The stream outlives ctx, so it has its own context which is cancelled
+	// when the Client is closed.
+	streamCtx, cancel := context.WithCancel(context.Background())
+
+	type dialResult struct {
+		f   *sseClientFramer
+		err error
+	}
+	dialed := make(chan dialResult, 1)
+	go func() {
+		f, err := dialSSE(streamCtx, url)
+		dialed <- dialResult{f: f, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		cancel()
+		if res := <-dialed; res.f != nil {
+			_ = res.f.body.Close()
+		}
+		return nil, ctx.Err()
+	case res := <-dialed:
+		if res.err != nil {
+			cancel()
+			return nil, res.err
+		}
+		res.f.cancel = cancel
+		return jsonrpc2.NewFramedClient(res.f, handler, opts...), nil
+	}
+}
+
+func dialSSE(ctx context.Context, rawURL string) (*sseClientFramer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	f := &sseClientFramer{body: resp.Body, r: bufio.NewReader(resp.Body)}
+	event, data, err := f.readEvent()
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if event != sseSessionEvent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("expected session event, got %q", event)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("session", string(data))
+	u.RawQuery = q.Encode()
+	f.postURL = u.String()
+	return f, nil
+}
+
+// sseClientFramer is a jsonrpc2.Framer which reads frames from an SSE stream
+// and POSTs written frames.
+type sseClientFramer struct {
+	body    io.ReadCloser
+	r       *bufio.Reader
+	postURL string
+	cancel  context.CancelFunc
+}
+
+func (f *sseClientFramer) ReadFrame() ([]byte, error) {
+	for {
+		event, data, err := f.readEvent()
+		if err != nil {
+			return nil, err
+		}
+		if event == "" || event == "message" {
+			return data, nil
+		}
+	}
+}
+
+// readEvent reads the next event from the stream. Comments and fields other
+// than event and data are ignored.
+func (f *sseClientFramer) readEvent() (event string, data []byte, err error) {
+	var (
+		lines   [][]byte
+		hasData bool
+	)
+	for {
+		line, err := f.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			if hasData {
+				return event, bytes.Join(lines, []byte("\n")), nil
+			}
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			v := strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+			lines = append(lines, []byte(v))
+			hasData = true
+		}
+	}
+}
+
+func (f *sseClientFramer) WriteFrame(frame []byte) error {
+	resp, err := http.Post(f.postURL, "application/json", bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return nil
+}
+
+func (f *sseClientFramer) Close() error {
+	f.cancel()
+	err := f.body.Close()
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
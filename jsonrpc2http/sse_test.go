@@ -0,0 +1,68 @@
+package jsonrpc2http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEHandler(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("echo", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Params)
+	})
+
+	// The server calls back to the browser once it connects.
+	callbacks := make(chan string, 1)
+	sse := &SSEHandler{
+		Handler: mux,
+		OnClient: func(c *jsonrpc2.Client) {
+			res, err := c.Invoke(context.Background(), "whoami", nil)
+			if err != nil {
+				callbacks <- err.Error()
+				return
+			}
+			callbacks <- string(res)
+		},
+	}
+	srv := httptest.NewServer(sse)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := DialSSE(ctx, srv.URL, jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage("browser")
+	}))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		res, err := cli.Invoke(ctx, "echo", "hello")
+		require.NoError(t, err)
+		require.JSONEq(t, `"hello"`, string(res))
+	}
+	require.Equal(t, `"browser"`, <-callbacks)
+
+	require.NoError(t, cli.Close())
+	require.Eventually(t, func() bool {
+		sse.mut.Lock()
+		defer sse.mut.Unlock()
+		return len(sse.sessions) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSSEHandler_UnknownSession(t *testing.T) {
+	srv := httptest.NewServer(&SSEHandler{})
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"?session=missing", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
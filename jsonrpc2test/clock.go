@@ -0,0 +1,108 @@
+package jsonrpc2test
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// FakeClock is a jsonrpc2.Clock whose time only moves when Advance is
+// called. Timers fire synchronously during Advance, in the order they are
+// due, which makes tests of timeouts reproducible without sleeping.
+type FakeClock struct {
+	mut    sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+var _ jsonrpc2.Clock = (*FakeClock)(nil)
+
+// NewFakeClock creates a FakeClock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements jsonrpc2.Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.now
+}
+
+// After implements jsonrpc2.Clock.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.addTimer(d, func(now time.Time) { ch <- now })
+	return ch
+}
+
+// AfterFunc implements jsonrpc2.Clock. Unlike time.AfterFunc, f is called in
+// the goroutine calling Advance.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) jsonrpc2.Timer {
+	return c.addTimer(d, func(time.Time) { f() })
+}
+
+// Advance moves the clock forward by d, firing any timers which become due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mut.Lock()
+	target := c.now.Add(d)
+	c.mut.Unlock()
+
+	for {
+		c.mut.Lock()
+		if len(c.timers) == 0 || c.timers[0].when.After(target) {
+			c.now = target
+			c.mut.Unlock()
+			return
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+		c.mut.Unlock()
+
+		t.fire(t.when)
+	}
+}
+
+// Timers returns the number of timers which haven't fired or been stopped.
+// Tests can use it to wait for code under test to start a timer before
+// calling Advance.
+func (c *FakeClock) Timers() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) addTimer(d time.Duration, fire func(time.Time)) *fakeTimer {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), fire: fire}
+	c.timers = append(c.timers, t)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	return t
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	fire  func(now time.Time)
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
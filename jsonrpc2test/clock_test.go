@@ -0,0 +1,76 @@
+package jsonrpc2test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, "a") })
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	after := clock.After(3 * time.Second)
+
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+
+	clock.Advance(2 * time.Second)
+	require.Equal(t, []string{"a", "b"}, fired)
+	require.Equal(t, start.Add(2*time.Second), clock.Now())
+	require.Equal(t, 1, clock.Timers())
+
+	clock.Advance(time.Second)
+	require.Equal(t, start.Add(3*time.Second), <-after)
+	require.Equal(t, 0, clock.Timers())
+}
+
+func TestWithClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	cli := NewClient(t, jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Method)
+	}), jsonrpc2.WithClock(clock), jsonrpc2.WithResponseCoalescing(time.Hour))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cli.Invoke(testContext(t), "ping", nil)
+		done <- err
+	}()
+
+	// The response is held until the coalescing timer fires.
+	require.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("response sent before timer fired")
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	require.NoError(t, <-done)
+}
+
+func TestWithIDGenerator(t *testing.T) {
+	a, b := FramerPipe()
+
+	var n int
+	cli := jsonrpc2.NewFramedClient(a, nil, jsonrpc2.WithIDGenerator(func() jsonrpc2.ID {
+		n++
+		return jsonrpc2.NewStringID(fmt.Sprintf("req-%d", n))
+	}))
+	defer cli.Close()
+
+	go func() { _, _ = cli.Invoke(context.Background(), "ping", nil) }()
+
+	frame, err := b.ReadFrame()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "method": "ping", "params": null, "id": "req-1"}`, string(frame))
+}
@@ -0,0 +1,291 @@
+package jsonrpc2test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// Interaction is a recorded request and the response expected for it.
+type Interaction struct {
+	// Name identifies the interaction in reports.
+	Name string `json:"name"`
+
+	// Request is the raw payload sent to the handler. It may be a single
+	// request, a notification, or a batch. If Request is a JSON string, the
+	// contents of the string are sent instead, which allows payloads that
+	// aren't valid JSON to be recorded.
+	Request json.RawMessage `json:"request"`
+
+	// Response is the raw payload expected in return. It must be empty if no
+	// response is expected, such as for notifications.
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// LoadCorpus reads a corpus of interactions encoded as a JSON array.
+func LoadCorpus(r io.Reader) ([]Interaction, error) {
+	var corpus []Interaction
+	if err := json.NewDecoder(r).Decode(&corpus); err != nil {
+		return nil, fmt.Errorf("failed to decode corpus: %w", err)
+	}
+	return corpus, nil
+}
+
+// Mismatch describes an interaction whose response didn't match the
+// recorded response.
+type Mismatch struct {
+	Interaction Interaction
+	// Got is the response received. It is nil if no response was received.
+	Got json.RawMessage
+	// Err is set if the interaction could not be replayed.
+	Err error
+}
+
+func (m Mismatch) String() string {
+	if m.Err != nil {
+		return fmt.Sprintf("%s: %v", m.Interaction.Name, m.Err)
+	}
+	return fmt.Sprintf("%s: expected response %s, got %s", m.Interaction.Name, m.Interaction.Response, m.Got)
+}
+
+// Contract replays a corpus of recorded interactions against a handler to
+// verify that it still behaves as consumers expect.
+//
+// Responses are compared as JSON values, so whitespace and the order of
+// object keys don't matter. The responses within a batch may be in any
+// order.
+type Contract struct {
+	Interactions []Interaction
+
+	// Timeout is how long to wait for each response. Defaults to 5s.
+	Timeout time.Duration
+
+	// IgnoreErrorMessages ignores the message of error objects when
+	// comparing responses. Error codes must still match.
+	IgnoreErrorMessages bool
+}
+
+// Replay replays each interaction in order against handler over an
+// in-memory connection created with FramerPipe and returns all mismatches.
+// Interactions are sent one at a time, waiting for the response to each
+// before sending the next.
+//
+// Responses are matched to interactions by their IDs. Responses that arrive
+// after their interaction timed out, and responses to interactions which
+// expect none, are reported as mismatches if they arrive before Replay
+// returns.
+func (c *Contract) Replay(handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) []Mismatch {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	f, serverFramer := FramerPipe()
+	server := jsonrpc2.NewFramedClient(serverFramer, handler, opts...)
+	defer server.Close()
+
+	// Read frames in the background so that waiting for a response can time
+	// out.
+	frames := make(chan json.RawMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			frame, err := f.ReadFrame()
+			if err != nil {
+				return
+			}
+			select {
+			case frames <- frame:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var (
+		mismatches []Mismatch
+		sent       []Interaction
+	)
+
+	// unexpected records a frame which doesn't belong to the current
+	// interaction, blaming the sent interaction it most likely belongs to.
+	unexpected := func(frame json.RawMessage) {
+		blame := sent[len(sent)-1]
+		for i := len(sent) - 1; i >= 0; i-- {
+			if len(sent[i].Response) == 0 {
+				blame = sent[i]
+				break
+			}
+		}
+		ids := responseIDs(frame)
+		for i := len(sent) - 1; i >= 0; i-- {
+			if len(sent[i].Response) > 0 && ids == responseIDs(sent[i].Response) {
+				blame = sent[i]
+				break
+			}
+		}
+		mismatches = append(mismatches, Mismatch{Interaction: blame, Got: frame, Err: fmt.Errorf("unexpected response %s", frame)})
+	}
+
+	// drain records any frames which have already arrived.
+	drain := func() {
+		for {
+			select {
+			case frame := <-frames:
+				unexpected(frame)
+			default:
+				return
+			}
+		}
+	}
+
+	for _, in := range c.Interactions {
+		if len(sent) > 0 {
+			drain()
+		}
+
+		if err := f.WriteFrame(requestPayload(in.Request)); err != nil {
+			mismatches = append(mismatches, Mismatch{Interaction: in, Err: fmt.Errorf("failed to send request: %w", err)})
+			return mismatches
+		}
+		sent = append(sent, in)
+		if len(in.Response) == 0 {
+			continue
+		}
+
+		var (
+			got     json.RawMessage
+			ids     = responseIDs(in.Response)
+			expired = time.After(timeout)
+		)
+	Wait:
+		for {
+			select {
+			case frame := <-frames:
+				if responseIDs(frame) != ids {
+					unexpected(frame)
+					continue
+				}
+				got = frame
+				break Wait
+			case <-expired:
+				break Wait
+			}
+		}
+
+		if got == nil {
+			mismatches = append(mismatches, Mismatch{Interaction: in, Err: fmt.Errorf("no response received")})
+			continue
+		}
+		if !jsonEqual(in.Response, got, c.IgnoreErrorMessages) {
+			mismatches = append(mismatches, Mismatch{Interaction: in, Got: got})
+		}
+	}
+	if len(sent) > 0 {
+		drain()
+	}
+	return mismatches
+}
+
+// Run replays the contract against handler and reports each mismatch as a
+// test error.
+func (c *Contract) Run(t TB, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) {
+	t.Helper()
+	for _, m := range c.Replay(handler, opts...) {
+		t.Errorf("%s", m)
+	}
+}
+
+// requestPayload returns the payload to send for a recorded request.
+func requestPayload(req json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(req, &s); err == nil {
+		return []byte(s)
+	}
+	return req
+}
+
+// responseIDs returns a key identifying the IDs of a response or batch of
+// responses, ignoring their order. Payloads which aren't valid JSON have no
+// IDs.
+func responseIDs(payload []byte) string {
+	var v interface{}
+	if json.Unmarshal(payload, &v) != nil {
+		return ""
+	}
+
+	idOf := func(v interface{}) string {
+		obj, _ := v.(map[string]interface{})
+		bb, _ := json.Marshal(obj["id"])
+		return string(bb)
+	}
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return idOf(v)
+	}
+	ids := make([]string, 0, len(list))
+	for _, elem := range list {
+		ids = append(ids, idOf(elem))
+	}
+	sort.Strings(ids)
+	return "[" + strings.Join(ids, ",") + "]"
+}
+
+// jsonEqual reports whether a and b hold equal JSON values. Top-level arrays
+// are compared without regard to order.
+func jsonEqual(a, b []byte, ignoreErrorMessages bool) bool {
+	var av, bv interface{}
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return bytes.Equal(a, b)
+	}
+	if ignoreErrorMessages {
+		stripErrorMessages(av)
+		stripErrorMessages(bv)
+	}
+
+	aList, aOk := av.([]interface{})
+	bList, bOk := bv.([]interface{})
+	if !aOk || !bOk {
+		return reflect.DeepEqual(av, bv)
+	}
+	if len(aList) != len(bList) {
+		return false
+	}
+
+	used := make([]bool, len(bList))
+Outer:
+	for _, a := range aList {
+		for i, b := range bList {
+			if !used[i] && reflect.DeepEqual(a, b) {
+				used[i] = true
+				continue Outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// stripErrorMessages removes the message of each error object in a decoded
+// response or batch of responses.
+func stripErrorMessages(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			stripErrorMessages(elem)
+		}
+	case map[string]interface{}:
+		if e, ok := v["error"].(map[string]interface{}); ok {
+			delete(e, "message")
+		}
+	}
+}
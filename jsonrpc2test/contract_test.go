@@ -0,0 +1,129 @@
+package jsonrpc2test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContract(t *testing.T) {
+	corpus, err := LoadCorpus(strings.NewReader(`[
+		{
+			"name": "subtract",
+			"request": {"jsonrpc": "2.0", "method": "subtract", "params": [42, 23], "id": 1},
+			"response": {"jsonrpc": "2.0", "result": 19, "id": 1}
+		},
+		{
+			"name": "notification",
+			"request": {"jsonrpc": "2.0", "method": "subtract", "params": [1, 1]}
+		},
+		{
+			"name": "batch",
+			"request": [
+				{"jsonrpc": "2.0", "method": "subtract", "params": [5, 1], "id": "a"},
+				{"jsonrpc": "2.0", "method": "subtract", "params": [9, 1], "id": "b"}
+			],
+			"response": [
+				{"jsonrpc": "2.0", "result": 8, "id": "b"},
+				{"jsonrpc": "2.0", "result": 4, "id": "a"}
+			]
+		},
+		{
+			"name": "wrong result",
+			"request": {"jsonrpc": "2.0", "method": "subtract", "params": [1, 1], "id": 2},
+			"response": {"jsonrpc": "2.0", "result": 1, "id": 2}
+		}
+	]`))
+	require.NoError(t, err)
+
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("subtract", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var nums []int
+		if err := r.DecodeParams(&nums); err != nil || len(nums) != 2 {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		_ = w.WriteMessage(nums[0] - nums[1])
+	})
+
+	c := &Contract{Interactions: corpus}
+	mismatches := c.Replay(mux)
+	require.Len(t, mismatches, 1)
+	require.Equal(t, "wrong result", mismatches[0].Interaction.Name)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "result": 0, "id": 2}`, string(mismatches[0].Got))
+}
+
+func TestContract_UnexpectedResponses(t *testing.T) {
+	corpus, err := LoadCorpus(strings.NewReader(`[
+		{
+			"name": "slow",
+			"request": {"jsonrpc": "2.0", "method": "slow", "id": 1},
+			"response": {"jsonrpc": "2.0", "result": "slow", "id": 1}
+		},
+		{
+			"name": "recorded without response",
+			"request": {"jsonrpc": "2.0", "method": "ping", "id": 2}
+		},
+		{
+			"name": "after",
+			"request": {"jsonrpc": "2.0", "method": "after", "id": 3},
+			"response": {"jsonrpc": "2.0", "result": "after", "id": 3}
+		}
+	]`))
+	require.NoError(t, err)
+
+	// The slow response is released once it has timed out, and written
+	// before the response to "after", so that it arrives while waiting for
+	// a later interaction.
+	var (
+		release  = make(chan struct{})
+		slowDone = make(chan struct{})
+	)
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		switch r.Method {
+		case "slow":
+			<-release
+			_ = w.WriteMessage(r.Method)
+			close(slowDone)
+		case "ping":
+			_ = w.WriteMessage(r.Method)
+			close(release)
+		case "after":
+			<-slowDone
+			_ = w.WriteMessage(r.Method)
+		}
+	})
+
+	c := &Contract{Interactions: corpus, Timeout: 100 * time.Millisecond}
+	mismatches := c.Replay(handler)
+
+	var got []string
+	for _, m := range mismatches {
+		got = append(got, m.String())
+	}
+	require.ElementsMatch(t, []string{
+		"slow: no response received",
+		`slow: unexpected response {"jsonrpc":"2.0","result":"slow","id":1}`,
+		`recorded without response: unexpected response {"jsonrpc":"2.0","result":"ping","id":2}`,
+	}, got)
+}
+
+func TestNewClient(t *testing.T) {
+	cli := NewClient(t, jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Method)
+	}))
+
+	res, err := cli.Invoke(testContext(t), "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"ping"`, string(res))
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
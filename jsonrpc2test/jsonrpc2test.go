@@ -0,0 +1,91 @@
+// Package jsonrpc2test provides utilities for testing JSON-RPC 2.0 handlers
+// and clients.
+package jsonrpc2test
+
+import (
+	"io"
+	"sync"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// TB is the subset of testing.TB used by this package.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// FramerPipe creates a pair of connected in-memory Framers. Frames written to
+// one Framer are read from the other, exactly as written. Closing either
+// Framer closes both.
+func FramerPipe() (a, b jsonrpc2.Framer) {
+	var (
+		ab = make(chan []byte)
+		ba = make(chan []byte)
+
+		p = &pipe{closed: make(chan struct{})}
+	)
+	return &pipeFramer{pipe: p, in: ba, out: ab}, &pipeFramer{pipe: p, in: ab, out: ba}
+}
+
+type pipe struct {
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+type pipeFramer struct {
+	*pipe
+	in  <-chan []byte
+	out chan<- []byte
+}
+
+func (f *pipeFramer) ReadFrame() ([]byte, error) {
+	select {
+	case frame := <-f.in:
+		return frame, nil
+	case <-f.closed:
+		return nil, io.EOF
+	}
+}
+
+func (f *pipeFramer) WriteFrame(frame []byte) error {
+	frame = append([]byte(nil), frame...)
+	select {
+	case f.out <- frame:
+		return nil
+	case <-f.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (f *pipeFramer) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+// Pipe creates a pair of Clients connected over an in-memory connection.
+// server will invoke handler for each request received from client.
+// clientHandler handles requests sent by the server to the client and may
+// be nil.
+func Pipe(handler, clientHandler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) (client, server *jsonrpc2.Client) {
+	clientFramer, serverFramer := FramerPipe()
+	server = jsonrpc2.NewFramedClient(serverFramer, handler, opts...)
+	client = jsonrpc2.NewFramedClient(clientFramer, clientHandler, opts...)
+	return client, server
+}
+
+// NewClient serves handler over an in-memory connection and returns a Client
+// connected to it. Both ends of the connection are closed when the test
+// finishes.
+func NewClient(t TB, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) *jsonrpc2.Client {
+	t.Helper()
+
+	client, server := Pipe(handler, nil, opts...)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client
+}
@@ -0,0 +1,91 @@
+package jsonrpc2test
+
+import (
+	"bytes"
+	_ "embed" // Used for the spec corpus.
+	"fmt"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+//go:embed testdata/spec.json
+var specCorpus []byte
+
+// SpecCorpus returns the examples from the JSON-RPC 2.0 specification as a
+// corpus of interactions, including each of the error cases. The examples
+// expect the methods implemented by SpecHandler.
+func SpecCorpus() []Interaction {
+	corpus, err := LoadCorpus(bytes.NewReader(specCorpus))
+	if err != nil {
+		panic(err)
+	}
+	return corpus
+}
+
+// SpecHandler returns a Handler implementing the methods used in the
+// examples of the JSON-RPC 2.0 specification:
+//
+//   - subtract, with positional params [minuend, subtrahend] or named params
+//     {"minuend", "subtrahend"}.
+//   - sum, which sums its positional params.
+//   - get_data, which returns ["hello", 5].
+//   - update, notify_hello, and notify_sum, which are only sent as
+//     notifications and do nothing.
+func SpecHandler() jsonrpc2.Handler {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("subtract", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var positional []int
+		if err := r.DecodeParams(&positional); err == nil {
+			if len(positional) != 2 {
+				_ = w.WriteError(jsonrpc2.ErrorInvalidParams, fmt.Errorf("expected 2 params"))
+				return
+			}
+			_ = w.WriteMessage(positional[0] - positional[1])
+			return
+		}
+
+		var named struct {
+			Minuend    int `json:"minuend"`
+			Subtrahend int `json:"subtrahend"`
+		}
+		if err := r.DecodeParams(&named); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		_ = w.WriteMessage(named.Minuend - named.Subtrahend)
+	})
+	mux.HandleFunc("sum", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var nums []int
+		if err := r.DecodeParams(&nums); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		var sum int
+		for _, n := range nums {
+			sum += n
+		}
+		_ = w.WriteMessage(sum)
+	})
+	mux.HandleFunc("get_data", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage([]interface{}{"hello", 5})
+	})
+	for _, method := range []string{"update", "notify_hello", "notify_sum"} {
+		mux.HandleFunc(method, func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {})
+	}
+	return mux
+}
+
+// RunSpec replays SpecCorpus against handler and reports each response which
+// doesn't match the specification as a test error. Error messages are not
+// compared, since the specification leaves them up to the implementation.
+//
+// handler must implement the methods of SpecHandler. RunSpec is typically
+// used to check that middleware preserves conformance by wrapping
+// SpecHandler:
+//
+//	jsonrpc2test.RunSpec(t, myMiddleware(jsonrpc2test.SpecHandler()))
+func RunSpec(t TB, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) {
+	t.Helper()
+	c := &Contract{Interactions: SpecCorpus(), IgnoreErrorMessages: true}
+	c.Run(t, handler, opts...)
+}
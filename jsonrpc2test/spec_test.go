@@ -0,0 +1,7 @@
+package jsonrpc2test
+
+import "testing"
+
+func TestRunSpec(t *testing.T) {
+	RunSpec(t, SpecHandler())
+}
@@ -0,0 +1,92 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// MethodSchemas holds the schemas a method's params and result are
+// validated against. Either may be nil to skip validating it.
+type MethodSchemas struct {
+	Params *Schema
+	Result *Schema
+}
+
+// Middleware returns jsonrpc2.Middleware which validates requests against
+// the schemas for their method in schemas. Methods without schemas aren't
+// validated.
+//
+// Requests whose params don't match are rejected with an
+// ErrorInvalidParams error, whose data is a ValidationError, without
+// calling the next handler. Requests without params are validated as null.
+// Invalid notifications are dropped.
+//
+// Results are only validated for methods with a Result schema. Results
+// which don't match are replaced with an ErrorInternal error whose data is
+// a ValidationError, since they indicate a bug in the handler.
+func Middleware(schemas map[string]MethodSchemas) jsonrpc2.Middleware {
+	copied := make(map[string]MethodSchemas, len(schemas))
+	for method, s := range schemas {
+		copied[method] = s
+	}
+
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			s, ok := copied[r.Method]
+			if !ok {
+				next.ServeRPC(w, r)
+				return
+			}
+
+			if s.Params != nil {
+				params := r.Params
+				if len(params) == 0 {
+					params = json.RawMessage("null")
+				}
+				if err := s.Params.Validate(params); err != nil {
+					if !r.Notification {
+						_ = w.WriteError(jsonrpc2.ErrorInvalidParams, validationError("params do not match schema", err))
+					}
+					return
+				}
+			}
+
+			if s.Result != nil && !r.Notification {
+				w = &resultValidator{ResponseWriter: w, schema: s.Result}
+			}
+			next.ServeRPC(w, r)
+		})
+	}
+}
+
+// validationError returns a jsonrpc2.Error with msg, and with err as its
+// data if err is a ValidationError.
+func validationError(msg string, err error) jsonrpc2.Error {
+	rpcErr := jsonrpc2.Error{Message: msg}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		rpcErr.Data, _ = json.Marshal(verr)
+	} else {
+		rpcErr.Message += ": " + err.Error()
+	}
+	return rpcErr
+}
+
+// resultValidator validates results written by a handler.
+type resultValidator struct {
+	jsonrpc2.ResponseWriter
+	schema *Schema
+}
+
+func (w *resultValidator) WriteMessage(msg interface{}) error {
+	result, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := w.schema.Validate(result); err != nil {
+		return w.ResponseWriter.WriteError(jsonrpc2.ErrorInternal, validationError("result does not match schema", err))
+	}
+	return w.ResponseWriter.WriteMessage(json.RawMessage(result))
+}
@@ -0,0 +1,74 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.Use(Middleware(map[string]MethodSchemas{
+		"transfer": {
+			Params: MustCompile(`{
+				"type": "object",
+				"properties": {"amount": {"type": "integer", "minimum": 1}},
+				"required": ["amount"]
+			}`),
+			Result: MustCompile(`{"type": "string"}`),
+		},
+	}))
+	var calls int
+	mux.HandleFunc("transfer", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		calls++
+		var p struct{ Amount int }
+		_ = r.DecodeParams(&p)
+		if p.Amount > 100 {
+			_ = w.WriteMessage(p.Amount) // Doesn't match the result schema.
+			return
+		}
+		_ = w.WriteMessage("ok")
+	})
+	mux.HandleFunc("other", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(true)
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := jsonrpc2.NewClient(cliConn, nil)
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	res, err := cli.Invoke(ctx, "transfer", map[string]int{"amount": 5})
+	require.NoError(t, err)
+	require.Equal(t, `"ok"`, string(res))
+
+	_, err = cli.Invoke(ctx, "transfer", map[string]int{"amount": 0})
+	var rpcErr jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorInvalidParams, rpcErr.Code)
+	require.Equal(t, "params do not match schema", rpcErr.Message)
+	require.JSONEq(t, `{"violations": [{"path": "/amount", "message": "must be >= 1"}]}`, string(rpcErr.Data))
+	require.Equal(t, 1, calls)
+
+	_, err = cli.Invoke(ctx, "transfer", nil)
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorInvalidParams, rpcErr.Code)
+
+	_, err = cli.Invoke(ctx, "transfer", map[string]int{"amount": 500})
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorInternal, rpcErr.Code)
+	var verr ValidationError
+	require.NoError(t, json.Unmarshal(rpcErr.Data, &verr))
+	require.Equal(t, []Violation{{Path: "", Message: "must be of type string, got number"}}, verr.Violations)
+
+	res, err = cli.Invoke(ctx, "other", "anything")
+	require.NoError(t, err)
+	require.Equal(t, "true", string(res))
+}
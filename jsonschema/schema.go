@@ -0,0 +1,387 @@
+// Package jsonschema validates JSON-RPC 2.0 params and results against JSON
+// Schemas.
+//
+// Schemas are compiled with Compile, which supports the commonly used
+// subset of JSON Schema draft 2020-12: type, enum, const, the numeric,
+// string, array, and object constraints, allOf, anyOf, oneOf, not, and
+// $ref to locations within the same schema, such as "#/$defs/user".
+// Keywords it doesn't know, including format, are ignored.
+//
+// Middleware validates the params of incoming requests, and optionally the
+// results handlers write, against per-method schemas:
+//
+//	transfer := jsonschema.MustCompile(`{
+//		"type": "object",
+//		"properties": {"amount": {"type": "integer", "minimum": 1}},
+//		"required": ["amount"]
+//	}`)
+//	mux.Use(jsonschema.Middleware(map[string]jsonschema.MethodSchemas{
+//		"transfer": {Params: transfer},
+//	}))
+//
+// Requests with invalid params are rejected with an ErrorInvalidParams error
+// whose data lists the violations:
+//
+//	{"code": -32602, "message": "params do not match schema", "data": {"violations": [{"path": "/amount", "message": "must be >= 1"}]}}
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema. It is safe for concurrent use.
+type Schema struct {
+	// always and never are set for the boolean schemas true and false.
+	always, never bool
+
+	types    []string
+	enum     []interface{}
+	cnst     interface{}
+	hasConst bool
+
+	minimum, maximum                   *big.Float
+	exclusiveMinimum, exclusiveMaximum *big.Float
+	multipleOf                         json.Number
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	items                        *Schema
+	prefixItems                  []*Schema
+	minItems, maxItems           *int
+	uniqueItems                  bool
+	properties                   map[string]*Schema
+	required                     []string
+	additionalProperties         *Schema
+	minProperties, maxProperties *int
+
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+	ref                 *Schema
+}
+
+// Compile compiles the JSON Schema doc.
+func Compile(doc json.RawMessage) (*Schema, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	c := &compiler{root: root, cache: make(map[string]*Schema)}
+	return c.compile(root, "#")
+}
+
+// MustCompile is like Compile but panics if doc can't be compiled. It is
+// intended for schemas written as string literals.
+func MustCompile(doc string) *Schema {
+	s, err := Compile(json.RawMessage(doc))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// decode decodes JSON, keeping numbers as json.Number so they're compared
+// exactly.
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// compiler compiles the schemas in a document, caching them by location so
+// that $refs, including recursive ones, share a Schema.
+type compiler struct {
+	root  interface{}
+	cache map[string]*Schema
+}
+
+func (c *compiler) compile(node interface{}, ptr string) (*Schema, error) {
+	if s, ok := c.cache[ptr]; ok {
+		return s, nil
+	}
+	s := &Schema{}
+	c.cache[ptr] = s
+
+	switch node := node.(type) {
+	case bool:
+		s.always, s.never = node, !node
+		return s, nil
+	case map[string]interface{}:
+		if err := c.compileObject(s, node, ptr); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%s: schema must be an object or boolean", ptr)
+	}
+}
+
+func (c *compiler) compileObject(s *Schema, m map[string]interface{}, ptr string) error {
+	var err error
+	sub := func(key string) (*Schema, error) {
+		v, ok := m[key]
+		if !ok {
+			return nil, nil
+		}
+		return c.compile(v, ptr+"/"+escapePointer(key))
+	}
+	subList := func(key string) ([]*Schema, error) {
+		v, ok := m[key]
+		if !ok {
+			return nil, nil
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s/%s: must be an array", ptr, key)
+		}
+		out := make([]*Schema, len(list))
+		for i, item := range list {
+			if out[i], err = c.compile(item, ptr+"/"+key+"/"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	number := func(key string) (*big.Float, error) {
+		v, ok := m[key]
+		if !ok {
+			return nil, nil
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s/%s: must be a number", ptr, key)
+		}
+		return toFloat(n), nil
+	}
+	count := func(key string) (*int, error) {
+		f, err := number(key)
+		if err != nil || f == nil {
+			return nil, err
+		}
+		n, acc := f.Int64()
+		if !f.IsInt() || f.Sign() < 0 || acc != big.Exact || n > math.MaxInt32 {
+			return nil, fmt.Errorf("%s/%s: must be a non-negative integer", ptr, key)
+		}
+		c := int(n)
+		return &c, nil
+	}
+
+	if ref, ok := m["$ref"]; ok {
+		refPtr, ok := ref.(string)
+		if !ok || !strings.HasPrefix(refPtr, "#") {
+			return fmt.Errorf("%s/$ref: only references within the schema, starting with #, are supported", ptr)
+		}
+		target, err := resolvePointer(c.root, refPtr)
+		if err != nil {
+			return fmt.Errorf("%s/$ref: %w", ptr, err)
+		}
+		if s.ref, err = c.compile(target, refPtr); err != nil {
+			return err
+		}
+	}
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s/type: must be a string or array of strings", ptr)
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return fmt.Errorf("%s/type: must be a string or array of strings", ptr)
+	}
+
+	if v, ok := m["enum"]; ok {
+		if s.enum, ok = v.([]interface{}); !ok {
+			return fmt.Errorf("%s/enum: must be an array", ptr)
+		}
+	}
+	s.cnst, s.hasConst = m["const"]
+
+	if s.minimum, err = number("minimum"); err != nil {
+		return err
+	}
+	if s.maximum, err = number("maximum"); err != nil {
+		return err
+	}
+	if s.exclusiveMinimum, err = number("exclusiveMinimum"); err != nil {
+		return err
+	}
+	if s.exclusiveMaximum, err = number("exclusiveMaximum"); err != nil {
+		return err
+	}
+	if v, ok := m["multipleOf"]; ok {
+		n, ok := v.(json.Number)
+		if !ok || toFloat(n).Sign() <= 0 {
+			return fmt.Errorf("%s/multipleOf: must be a number greater than 0", ptr)
+		}
+		s.multipleOf = n
+	}
+
+	if s.minLength, err = count("minLength"); err != nil {
+		return err
+	}
+	if s.maxLength, err = count("maxLength"); err != nil {
+		return err
+	}
+	if v, ok := m["pattern"]; ok {
+		pattern, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s/pattern: must be a string", ptr)
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s/pattern: %w", ptr, err)
+		}
+	}
+
+	if s.items, err = sub("items"); err != nil {
+		return err
+	}
+	if s.prefixItems, err = subList("prefixItems"); err != nil {
+		return err
+	}
+	if s.minItems, err = count("minItems"); err != nil {
+		return err
+	}
+	if s.maxItems, err = count("maxItems"); err != nil {
+		return err
+	}
+	s.uniqueItems, _ = m["uniqueItems"].(bool)
+
+	if v, ok := m["properties"]; ok {
+		props, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s/properties: must be an object", ptr)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, prop := range props {
+			if s.properties[name], err = c.compile(prop, ptr+"/properties/"+escapePointer(name)); err != nil {
+				return err
+			}
+		}
+	}
+	if v, ok := m["required"]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s/required: must be an array of strings", ptr)
+		}
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("%s/required: must be an array of strings", ptr)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	if s.additionalProperties, err = sub("additionalProperties"); err != nil {
+		return err
+	}
+	if s.minProperties, err = count("minProperties"); err != nil {
+		return err
+	}
+	if s.maxProperties, err = count("maxProperties"); err != nil {
+		return err
+	}
+
+	if s.allOf, err = subList("allOf"); err != nil {
+		return err
+	}
+	if s.anyOf, err = subList("anyOf"); err != nil {
+		return err
+	}
+	if s.oneOf, err = subList("oneOf"); err != nil {
+		return err
+	}
+	if s.not, err = sub("not"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// resolvePointer returns the value in root at the JSON pointer fragment
+// ref, such as "#/$defs/user".
+func resolvePointer(root interface{}, ref string) (interface{}, error) {
+	node := root
+	ptr := strings.TrimPrefix(ref, "#")
+	if ptr == "" {
+		return node, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			node = v
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+	}
+	return node, nil
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(tok string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(tok, "~", "~0"), "/", "~1")
+}
+
+// numberPrec is the precision numbers are compared with.
+const numberPrec = 256
+
+// toFloat converts n for comparison. big.Float is used rather than big.Rat
+// because parsing numbers with huge exponents, such as 1e1000000000, into a
+// big.Rat allocates memory proportional to the exponent.
+func toFloat(n json.Number) *big.Float {
+	f, ok := new(big.Float).SetPrec(numberPrec).SetString(string(n))
+	if !ok {
+		return new(big.Float).SetPrec(numberPrec)
+	}
+	return f
+}
+
+// maxExactExponent bounds the exponent of numbers converted to big.Rat.
+const maxExactExponent = 400
+
+// toRat converts n exactly, which is needed to check multipleOf with
+// decimals such as 0.1. ok is false if n's exponent is too large to convert
+// safely.
+func toRat(n json.Number) (r *big.Rat, ok bool) {
+	str := string(n)
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(str[i+1:])
+		if err != nil || exp > maxExactExponent || exp < -maxExactExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(str)
+}
@@ -0,0 +1,418 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxViolations limits the number of violations reported for a value, so
+// that errors sent to callers stay small.
+const maxViolations = 16
+
+// maxRefDepth limits how many $refs may be followed while validating a
+// value, which stops schemas that refer to themselves without descending
+// into the value from recursing forever.
+const maxRefDepth = 512
+
+// Violation is a way in which a value doesn't match a schema.
+type Violation struct {
+	// Path is a JSON pointer to the part of the value which doesn't match,
+	// such as "/users/0/name", or "" for the whole value.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by Validate when a value doesn't match a
+// schema.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+
+	// Truncated is set if there were more violations than reported.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		path := v.Path
+		if path == "" {
+			path = "value"
+		}
+		msgs[i] = path + ": " + v.Message
+	}
+	msg := strings.Join(msgs, "; ")
+	if e.Truncated {
+		msg += "; ..."
+	}
+	return msg
+}
+
+// Validate checks that value matches s. If it doesn't, a *ValidationError
+// listing the violations is returned.
+func (s *Schema) Validate(value json.RawMessage) error {
+	v, err := decode(value)
+	if err != nil {
+		return err
+	}
+	var vr validator
+	vr.validate(s, v, "")
+	if len(vr.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: vr.violations, Truncated: vr.truncated}
+}
+
+// maxCachedSchemas limits the number of schemas cached by a Validator.
+const maxCachedSchemas = 256
+
+// NewValidator returns a function which validates value against the JSON
+// Schema document schema, such as for schemareg.Validate:
+//
+//	v := &schemareg.Validate{Handler: mux, Registry: reg, Validator: jsonschema.NewValidator()}
+//
+// Compiled schemas are cached by their document, up to a limit.
+func NewValidator() func(schema, value json.RawMessage) error {
+	var (
+		mut   sync.Mutex
+		cache = make(map[string]*Schema)
+	)
+	return func(schema, value json.RawMessage) error {
+		mut.Lock()
+		s, ok := cache[string(schema)]
+		mut.Unlock()
+		if !ok {
+			var err error
+			if s, err = Compile(schema); err != nil {
+				return err
+			}
+			mut.Lock()
+			if len(cache) >= maxCachedSchemas {
+				cache = make(map[string]*Schema)
+			}
+			cache[string(schema)] = s
+			mut.Unlock()
+		}
+		return s.Validate(value)
+	}
+}
+
+// validator collects the violations of a value.
+type validator struct {
+	violations []Violation
+	truncated  bool
+	refDepth   int
+}
+
+func (vr *validator) addf(path, format string, args ...interface{}) {
+	if len(vr.violations) >= maxViolations {
+		vr.truncated = true
+		return
+	}
+	vr.violations = append(vr.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether v matches s, without recording violations.
+func (vr *validator) matches(s *Schema, v interface{}, path string) bool {
+	sub := validator{refDepth: vr.refDepth}
+	sub.validate(s, v, path)
+	return len(sub.violations) == 0
+}
+
+func (vr *validator) validate(s *Schema, v interface{}, path string) {
+	switch {
+	case s.always:
+		return
+	case s.never:
+		vr.addf(path, "is not allowed")
+		return
+	}
+
+	if s.ref != nil {
+		if vr.refDepth >= maxRefDepth {
+			vr.addf(path, "schema references are nested too deeply")
+			return
+		}
+		vr.refDepth++
+		vr.validate(s.ref, v, path)
+		vr.refDepth--
+	}
+
+	if len(s.types) > 0 && !hasType(s.types, v) {
+		vr.addf(path, "must be of type %s, got %s", strings.Join(s.types, " or "), typeName(v))
+		return
+	}
+	if s.enum != nil && !inEnum(s.enum, v) {
+		vr.addf(path, "must be one of the allowed values")
+	}
+	if s.hasConst && !equal(s.cnst, v) {
+		vr.addf(path, "must equal the constant value")
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		vr.validateNumber(s, v, path)
+	case string:
+		vr.validateString(s, v, path)
+	case []interface{}:
+		vr.validateArray(s, v, path)
+	case map[string]interface{}:
+		vr.validateObject(s, v, path)
+	}
+
+	for _, sub := range s.allOf {
+		vr.validate(sub, v, path)
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if vr.matches(sub, v, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			vr.addf(path, "must match at least one schema in anyOf")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		var matched int
+		for _, sub := range s.oneOf {
+			if vr.matches(sub, v, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			vr.addf(path, "must match exactly one schema in oneOf, matched %d", matched)
+		}
+	}
+	if s.not != nil && vr.matches(s.not, v, path) {
+		vr.addf(path, "must not match the schema in not")
+	}
+}
+
+func (vr *validator) validateNumber(s *Schema, n json.Number, path string) {
+	f := toFloat(n)
+	if s.minimum != nil && f.Cmp(s.minimum) < 0 {
+		vr.addf(path, "must be >= %s", s.minimum.Text('g', -1))
+	}
+	if s.maximum != nil && f.Cmp(s.maximum) > 0 {
+		vr.addf(path, "must be <= %s", s.maximum.Text('g', -1))
+	}
+	if s.exclusiveMinimum != nil && f.Cmp(s.exclusiveMinimum) <= 0 {
+		vr.addf(path, "must be > %s", s.exclusiveMinimum.Text('g', -1))
+	}
+	if s.exclusiveMaximum != nil && f.Cmp(s.exclusiveMaximum) >= 0 {
+		vr.addf(path, "must be < %s", s.exclusiveMaximum.Text('g', -1))
+	}
+	if s.multipleOf != "" && !isMultiple(n, s.multipleOf) {
+		vr.addf(path, "must be a multiple of %s", s.multipleOf)
+	}
+}
+
+// isMultiple reports whether n is a multiple of m. Numbers are compared
+// exactly where possible, so that 0.3 is a multiple of 0.1.
+func isMultiple(n, m json.Number) bool {
+	nr, nok := toRat(n)
+	mr, mok := toRat(m)
+	if nok && mok {
+		return new(big.Rat).Quo(nr, mr).IsInt()
+	}
+	return new(big.Float).SetPrec(numberPrec).Quo(toFloat(n), toFloat(m)).IsInt()
+}
+
+func (vr *validator) validateString(s *Schema, str, path string) {
+	if s.minLength != nil || s.maxLength != nil {
+		n := utf8.RuneCountInString(str)
+		if s.minLength != nil && n < *s.minLength {
+			vr.addf(path, "must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			vr.addf(path, "must be at most %d characters", *s.maxLength)
+		}
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		vr.addf(path, "must match pattern %q", s.pattern.String())
+	}
+}
+
+func (vr *validator) validateArray(s *Schema, items []interface{}, path string) {
+	if s.minItems != nil && len(items) < *s.minItems {
+		vr.addf(path, "must have at least %d items", *s.minItems)
+	}
+	if s.maxItems != nil && len(items) > *s.maxItems {
+		vr.addf(path, "must have at most %d items", *s.maxItems)
+	}
+	if s.uniqueItems && !unique(items) {
+		vr.addf(path, "items must be unique")
+	}
+
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s/%d", path, i)
+		switch {
+		case i < len(s.prefixItems):
+			vr.validate(s.prefixItems[i], item, itemPath)
+		case s.items != nil:
+			vr.validate(s.items, item, itemPath)
+		}
+	}
+}
+
+func (vr *validator) validateObject(s *Schema, obj map[string]interface{}, path string) {
+	if s.minProperties != nil && len(obj) < *s.minProperties {
+		vr.addf(path, "must have at least %d properties", *s.minProperties)
+	}
+	if s.maxProperties != nil && len(obj) > *s.maxProperties {
+		vr.addf(path, "must have at most %d properties", *s.maxProperties)
+	}
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			vr.addf(path+"/"+escapePointer(name), "is required")
+		}
+	}
+
+	// Properties are visited in order so violations are reported in a
+	// stable order.
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propPath := path + "/" + escapePointer(name)
+		if prop, ok := s.properties[name]; ok {
+			vr.validate(prop, obj[name], propPath)
+		} else if s.additionalProperties != nil {
+			vr.validate(s.additionalProperties, obj[name], propPath)
+		}
+	}
+}
+
+func hasType(types []string, v interface{}) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if n, ok := v.(json.Number); ok && toFloat(n).IsInt() {
+				return true
+			}
+		case typeName(v):
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if equal(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// unique reports whether items has no equal values. Values are compared by
+// a canonical encoding, so that large arrays are checked in linear time.
+func unique(items []interface{}) bool {
+	seen := make(map[string]struct{}, len(items))
+	var b strings.Builder
+	for _, item := range items {
+		b.Reset()
+		writeCanonical(&b, item)
+		if _, ok := seen[b.String()]; ok {
+			return false
+		}
+		seen[b.String()] = struct{}{}
+	}
+	return true
+}
+
+// writeCanonical writes an encoding of v which is the same for all values
+// equal to v.
+func writeCanonical(b *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case json.Number:
+		b.WriteString(toFloat(v).Text('g', -1))
+	case []interface{}:
+		b.WriteByte('[')
+		for _, item := range v {
+			writeCanonical(b, item)
+			b.WriteByte(',')
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for _, k := range keys {
+			key, _ := json.Marshal(k)
+			b.Write(key)
+			b.WriteByte(':')
+			writeCanonical(b, v[k])
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	default:
+		enc, _ := json.Marshal(v)
+		b.Write(enc)
+	}
+}
+
+// equal reports whether a and b are equal JSON values. Numbers are equal if
+// they have the same value, so 1 equals 1.0.
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		return ok && toFloat(a).Cmp(toFloat(b)) == 0
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
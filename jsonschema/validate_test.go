@@ -0,0 +1,108 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		schema string
+		value  string
+		want   []Violation
+	}{
+		{"type", `{"type": "string"}`, `1`, []Violation{{"", "must be of type string, got number"}}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"integer", `{"type": "integer"}`, `1.0`, nil},
+		{"not integer", `{"type": "integer"}`, `1.5`, []Violation{{"", "must be of type integer, got number"}}},
+		{"enum", `{"enum": [1, "a"]}`, `1.0`, nil},
+		{"not in enum", `{"enum": [1, "a"]}`, `"b"`, []Violation{{"", "must be one of the allowed values"}}},
+		{"const", `{"const": {"a": [1]}}`, `{"a": [1]}`, nil},
+		{"minimum", `{"minimum": 1}`, `0`, []Violation{{"", "must be >= 1"}}},
+		{"exclusive maximum", `{"exclusiveMaximum": 10}`, `10`, []Violation{{"", "must be < 10"}}},
+		{"multipleOf decimal", `{"multipleOf": 0.1}`, `0.3`, nil},
+		{"not multipleOf", `{"multipleOf": 2}`, `3`, []Violation{{"", "must be a multiple of 2"}}},
+		{"huge exponent", `{"maximum": 10}`, `1e1000000000`, []Violation{{"", "must be <= 10"}}},
+		{"length", `{"minLength": 2, "maxLength": 3}`, `"é"`, []Violation{{"", "must be at least 2 characters"}}},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"A"`, []Violation{{"", `must match pattern "^[a-z]+$"`}}},
+		{"items", `{"items": {"type": "integer"}, "maxItems": 2}`, `[1, "a", 3]`, []Violation{
+			{"", "must have at most 2 items"},
+			{"/1", "must be of type integer, got string"},
+		}},
+		{"prefixItems", `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}}`, `["a", 1]`, nil},
+		{"uniqueItems", `{"uniqueItems": true}`, `[{"a": 1}, {"a": 1.0}]`, []Violation{{"", "items must be unique"}}},
+		{"object", `{
+			"properties": {"name": {"type": "string"}, "a/b": {"type": "integer"}},
+			"required": ["name", "age"],
+			"additionalProperties": false
+		}`, `{"name": 1, "a/b": "x", "extra": true}`, []Violation{
+			{"/age", "is required"},
+			{"/a~1b", "must be of type integer, got string"},
+			{"/extra", "is not allowed"},
+			{"/name", "must be of type string, got number"},
+		}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, []Violation{{"", "must match at least one schema in anyOf"}}},
+		{"oneOf", `{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, `1`, []Violation{{"", "must match exactly one schema in oneOf, matched 2"}}},
+		{"not", `{"not": {"type": "null"}}`, `null`, []Violation{{"", "must not match the schema in not"}}},
+		{"ref", `{
+			"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}, "v": {"type": "integer"}}}},
+			"$ref": "#/$defs/node"
+		}`, `{"v": 1, "next": {"v": 2, "next": {"v": "x"}}}`, []Violation{{"/next/next/v", "must be of type integer, got string"}}},
+		{"self ref", `{"$ref": "#"}`, `1`, []Violation{{"", "schema references are nested too deeply"}}},
+		{"false", `false`, `1`, []Violation{{"", "is not allowed"}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Compile(json.RawMessage(tc.schema))
+			require.NoError(t, err)
+
+			err = s.Validate(json.RawMessage(tc.value))
+			if tc.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			require.Equal(t, tc.want, verr.Violations)
+		})
+	}
+}
+
+func TestSchema_Validate_Truncated(t *testing.T) {
+	s := MustCompile(`{"items": {"type": "string"}}`)
+	err := s.Validate(json.RawMessage(`[` + strings.Repeat(`1,`, 20) + `1]`))
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Violations, maxViolations)
+	require.True(t, verr.Truncated)
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, schema := range []string{
+		`1`,
+		`{"type": 1}`,
+		`{"minLength": -1}`,
+		`{"multipleOf": 0}`,
+		`{"pattern": "("}`,
+		`{"$ref": "#/missing"}`,
+		`{"$ref": "other.json"}`,
+		`{"properties": []}`,
+	} {
+		_, err := Compile(json.RawMessage(schema))
+		require.Error(t, err, schema)
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	validate := NewValidator()
+	schema := json.RawMessage(`{"type": "integer"}`)
+	require.NoError(t, validate(schema, json.RawMessage(`1`)))
+	require.Error(t, validate(schema, json.RawMessage(`"a"`)))
+	require.Error(t, validate(json.RawMessage(`{"type": 1}`), json.RawMessage(`1`)))
+}
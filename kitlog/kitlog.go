@@ -0,0 +1,45 @@
+// Package kitlog adapts go-kit loggers for use with jsonrpc2.
+//
+// go-kit loggers can be passed to jsonrpc2 directly, but go-kit's level
+// filters don't recognize the levels jsonrpc2 logs entries with. Wrap the
+// logger with New to log levels as go-kit level values instead:
+//
+//	logger := level.NewFilter(log.NewLogfmtLogger(os.Stderr), level.AllowInfo())
+//	cli := jsonrpc2.NewClient(conn, handler, jsonrpc2.WithClientLogger(kitlog.New(logger)))
+package kitlog
+
+import (
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// New returns a jsonrpc2.Logger which logs to l, with entry levels replaced
+// by go-kit level values.
+func New(l log.Logger) jsonrpc2.Logger {
+	return jsonrpc2.LoggerFunc(func(keyvals ...interface{}) error {
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			lvl, ok := keyvals[i+1].(jsonrpc2.Level)
+			if !ok || keyvals[i] != jsonrpc2.LevelKey {
+				continue
+			}
+			kv := append([]interface{}(nil), keyvals...)
+			kv[i], kv[i+1] = level.Key(), value(lvl)
+			return l.Log(kv...)
+		}
+		return l.Log(keyvals...)
+	})
+}
+
+func value(lvl jsonrpc2.Level) level.Value {
+	switch lvl {
+	case jsonrpc2.LevelDebug:
+		return level.DebugValue()
+	case jsonrpc2.LevelInfo:
+		return level.InfoValue()
+	case jsonrpc2.LevelWarn:
+		return level.WarnValue()
+	default:
+		return level.ErrorValue()
+	}
+}
@@ -0,0 +1,24 @@
+package kitlog
+
+import (
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	var logged []interface{}
+	l := New(log.LoggerFunc(func(kv ...interface{}) error {
+		logged = kv
+		return nil
+	}))
+
+	jsonrpc2.LevelWarn.Log(l, "msg", "hello")
+	require.Equal(t, []interface{}{level.Key(), level.WarnValue(), "msg", "hello"}, logged)
+
+	require.NoError(t, l.Log("msg", "no level"))
+	require.Equal(t, []interface{}{"msg", "no level"}, logged)
+}
@@ -0,0 +1,61 @@
+package jsonrpc2
+
+// Logger logs structured entries as alternating keys and values, such as
+// "msg", "closing client", "err", err. Logger has the same method as
+// go-kit's log.Logger, so go-kit loggers can be used directly; use the
+// kitlog package to have go-kit's level filters see entry levels.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+// LoggerFunc is an adapter to allow the use of ordinary functions as
+// Loggers.
+type LoggerFunc func(keyvals ...interface{}) error
+
+// Log calls f(keyvals...).
+func (f LoggerFunc) Log(keyvals ...interface{}) error {
+	return f(keyvals...)
+}
+
+// NewNopLogger returns a Logger which discards all entries.
+func NewNopLogger() Logger {
+	return LoggerFunc(func(...interface{}) error { return nil })
+}
+
+// LevelKey is the key entries are logged with their Level under.
+const LevelKey = "level"
+
+// Level is the severity of a log entry.
+type Level int
+
+// Levels of log entries, from least to most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (lvl Level) String() string {
+	switch lvl {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Log logs keyvals to l at lvl, with lvl under LevelKey. Nothing is logged
+// if l is nil.
+func (lvl Level) Log(l Logger, keyvals ...interface{}) {
+	if l == nil {
+		return
+	}
+	_ = l.Log(append([]interface{}{LevelKey, lvl}, keyvals...)...)
+}
@@ -0,0 +1,190 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MessageKind is the kind of a Message.
+type MessageKind int
+
+const (
+	// KindRequest is a request which expects a response.
+	KindRequest MessageKind = iota + 1
+	// KindNotification is a request which does not expect a response.
+	KindNotification
+	// KindResponse is a response to a request.
+	KindResponse
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case KindRequest:
+		return "request"
+	case KindNotification:
+		return "notification"
+	case KindResponse:
+		return "response"
+	default:
+		return fmt.Sprintf("MessageKind(%d)", int(k))
+	}
+}
+
+// Message is a single JSON-RPC 2.0 request, notification, or response object
+// as sent over the wire. Message allows tools such as proxies, recorders, and
+// routers to work with messages directly without using a Client.
+type Message struct {
+	Kind MessageKind
+
+	// ID of the message. ID is undefined for notifications, and may be null
+	// for responses to requests that could not be parsed.
+	ID ID
+
+	// Method and Params are set for requests and notifications. Meta holds
+	// optional request metadata.
+	Method string
+	Params json.RawMessage
+	Meta   Metadata
+
+	// Result or Error is set for responses.
+	Result json.RawMessage
+	Error  *Error
+}
+
+// Parse parses a JSON-RPC 2.0 payload. batched will be true if the payload
+// was a batch of messages, even if the batch only had one message.
+func Parse(bb []byte) (msgs []Message, batched bool, err error) {
+	var tm txMessage
+	if err := unmarshalTxMessage(bb, &tm); err != nil {
+		return nil, false, err
+	}
+
+	msgs = make([]Message, 0, len(tm.Objects))
+	for _, obj := range tm.Objects {
+		if obj.Invalid != nil {
+			return nil, false, obj.Invalid
+		}
+		msgs = append(msgs, messageFromObject(obj))
+	}
+	return msgs, tm.Batched, nil
+}
+
+// ServeFrame handles a single JSON-RPC 2.0 payload with h and returns the
+// payload to reply with. A nil reply is returned if no reply is needed, such
+// as when the payload only held notifications. Payloads which can't be
+// parsed get an error reply, as they would from a Client.
+//
+// ServeFrame allows h to be served over request-response transports such as
+// HTTP, where there is no connection to call back over. Requests are handled
+// in order, with ctx as their context and a nil Client. Responses in the
+// payload are ignored.
+func ServeFrame(ctx context.Context, h Handler, frame []byte) ([]byte, error) {
+	var tm txMessage
+	if err := unmarshalTxMessage(frame, &tm); err != nil {
+		return json.Marshal(&txResponse{
+			ID:    NewNullID(),
+			Error: &Error{Code: errorCode(err), Message: err.Error()},
+		})
+	}
+
+	received := time.Now()
+	resp := txMessage{Batched: tm.Batched}
+	for _, obj := range tm.Objects {
+		switch {
+		case obj.Invalid != nil:
+			resp.Objects = append(resp.Objects, &txObject{Response: &txResponse{
+				ID:    invalidID(obj.Invalid),
+				Error: &Error{Code: errorCode(obj.Invalid), Message: obj.Invalid.Error()},
+			}})
+		case obj.Request != nil:
+			r := &Request{
+				Notification: obj.Request.Notification,
+
+				Method: obj.Request.Method,
+				Params: obj.Request.Params,
+				ID:     obj.Request.ID,
+				Meta:   obj.Request.Meta,
+
+				ctx:      ctx,
+				received: received,
+			}
+			if r.Notification {
+				h.ServeRPC(notificationWriter{}, r)
+				continue
+			}
+			resp.Objects = append(resp.Objects, &txObject{Response: serveRequest(h, r, obj.Request.ID)})
+		}
+	}
+
+	if len(resp.Objects) == 0 {
+		return nil, nil
+	}
+	return marshalTxMessage(&resp)
+}
+
+// Encode encodes msgs as a JSON-RPC 2.0 payload. If batched is false, msgs
+// must contain exactly one message.
+func Encode(msgs []Message, batched bool) ([]byte, error) {
+	tm := txMessage{
+		Batched: batched,
+		Objects: make([]*txObject, 0, len(msgs)),
+	}
+	for _, m := range msgs {
+		obj, err := m.txObject()
+		if err != nil {
+			return nil, err
+		}
+		tm.Objects = append(tm.Objects, obj)
+	}
+	return marshalTxMessage(&tm)
+}
+
+func messageFromObject(obj *txObject) Message {
+	switch {
+	case obj.Request != nil:
+		kind := KindRequest
+		if obj.Request.Notification {
+			kind = KindNotification
+		}
+		return Message{
+			Kind:   kind,
+			ID:     obj.Request.ID,
+			Method: obj.Request.Method,
+			Params: obj.Request.Params,
+			Meta:   obj.Request.Meta,
+		}
+	default:
+		return Message{
+			Kind:   KindResponse,
+			ID:     obj.Response.ID,
+			Result: obj.Response.Result,
+			Error:  obj.Response.Error,
+		}
+	}
+}
+
+func (m Message) txObject() (*txObject, error) {
+	switch m.Kind {
+	case KindRequest, KindNotification:
+		if m.Kind == KindRequest && m.ID.IsUndefined() {
+			return nil, fmt.Errorf("request must have an id")
+		}
+		return &txObject{Request: &txRequest{
+			Notification: m.Kind == KindNotification,
+			ID:           m.ID,
+			Method:       m.Method,
+			Params:       m.Params,
+			Meta:         m.Meta,
+		}}, nil
+	case KindResponse:
+		return &txObject{Response: &txResponse{
+			ID:     m.ID,
+			Result: m.Result,
+			Error:  m.Error,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("invalid message kind %s", m.Kind)
+	}
+}
@@ -0,0 +1,80 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	msgs, batched, err := Parse([]byte(`[
+		{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "id": 1},
+		{"jsonrpc": "2.0", "method": "notify", "params": {}},
+		{"jsonrpc": "2.0", "result": 3, "id": "2"},
+		{"jsonrpc": "2.0", "error": {"code": -32601, "message": "not found"}, "id": null}
+	]`))
+	require.NoError(t, err)
+	require.True(t, batched)
+	require.Equal(t, []Message{
+		{Kind: KindRequest, ID: NewNumberID(1), Method: "sum", Params: json.RawMessage(`[1, 2]`)},
+		{Kind: KindNotification, Method: "notify", Params: json.RawMessage(`{}`)},
+		{Kind: KindResponse, ID: NewStringID("2"), Result: json.RawMessage(`3`)},
+		{Kind: KindResponse, ID: NewNullID(), Error: &Error{Code: ErrorMethodNotFound, Message: "not found"}},
+	}, msgs)
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("single", func(t *testing.T) {
+		bb, err := Encode([]Message{
+			{Kind: KindNotification, Method: "notify", Params: json.RawMessage(`[]`)},
+		}, false)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "method": "notify", "params": []}`, string(bb))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		bb, err := Encode([]Message{
+			{Kind: KindRequest, ID: NewNumberID(1), Method: "sum", Params: json.RawMessage(`[1]`)},
+			{Kind: KindResponse, ID: NewStringID("a"), Result: json.RawMessage(`true`)},
+		}, true)
+		require.NoError(t, err)
+		require.JSONEq(t, `[
+			{"jsonrpc": "2.0", "method": "sum", "params": [1], "id": 1},
+			{"jsonrpc": "2.0", "result": true, "id": "a"}
+		]`, string(bb))
+	})
+
+	t.Run("request without id", func(t *testing.T) {
+		_, err := Encode([]Message{{Kind: KindRequest, Method: "sum"}}, false)
+		require.Error(t, err)
+	})
+
+	t.Run("multiple unbatched", func(t *testing.T) {
+		_, err := Encode([]Message{
+			{Kind: KindNotification, Method: "a"},
+			{Kind: KindNotification, Method: "b"},
+		}, false)
+		require.Error(t, err)
+	})
+}
+
+func TestServeFrame_InvalidBatchEntry(t *testing.T) {
+	h := HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Method)
+	})
+
+	// Invalid entries are replied to with their ID when it can be read.
+	reply, err := ServeFrame(context.Background(), h, []byte(`[
+		{"jsonrpc": "2.0", "method": "ping", "id": 1},
+		{"jsonrpc": "1.0", "method": "ping", "id": 2},
+		5
+	]`))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"jsonrpc": "2.0", "result": "ping", "id": 1},
+		{"jsonrpc": "2.0", "error": {"code": -32600}, "id": 2},
+		{"jsonrpc": "2.0", "error": {"code": -32600}, "id": null}
+	]`, string(stripErrorMessage(t, reply)))
+}
@@ -0,0 +1,82 @@
+package jsonrpc2
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Metadata keys set by Client.Invoke.
+const (
+	// MetaTimeout holds the time remaining until the deadline of the caller's
+	// context, formatted as a Go duration such as "1.5s". A relative timeout
+	// is sent instead of the deadline itself so that the clocks of the two
+	// peers don't need to agree.
+	MetaTimeout = "timeout"
+	// MetaPriority holds the priority set with WithPriority.
+	MetaPriority = "priority"
+)
+
+// Metadata is a set of key-value pairs sent alongside a request, similar to
+// HTTP headers. Metadata is sent in the "meta" member of the request object:
+//
+//	{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "meta": {"identity": "alice"}, "id": 1}
+//
+// Metadata is an extension to JSON-RPC 2.0. Peers which don't support it may
+// reject requests which carry metadata, so Clients only send metadata when
+// created with WithRequestMetadata(true).
+type Metadata map[string]string
+
+// Get returns the value for key, or an empty string if key isn't set.
+func (md Metadata) Get(key string) string {
+	return md[key]
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns a copy of ctx with md attached. Requests invoked with
+// the returned context carry md. If ctx already has metadata, md is merged
+// into it, with values in md taking precedence.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	merged := make(Metadata, len(md))
+	for k, v := range MetadataFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// MetadataFromContext returns the metadata attached to ctx with
+// WithMetadata, or nil if there is none.
+func MetadataFromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// WithPriority returns a copy of ctx which sends priority as metadata with
+// requests. Handlers can read the priority with Request.Priority to decide
+// how to handle the request; the meaning of priorities is up to the
+// application.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return WithMetadata(ctx, Metadata{MetaPriority: strconv.Itoa(priority)})
+}
+
+// requestMetadata returns the metadata to send with a request invoked with
+// ctx at now, including the time left until the deadline of ctx if it has
+// one.
+func requestMetadata(ctx context.Context, now time.Time) Metadata {
+	md := MetadataFromContext(ctx)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return md
+	}
+
+	withTimeout := make(Metadata, len(md)+1)
+	for k, v := range md {
+		withTimeout[k] = v
+	}
+	withTimeout[MetaTimeout] = deadline.Sub(now).String()
+	return withTimeout
+}
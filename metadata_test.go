@@ -0,0 +1,124 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMetadata(t *testing.T) {
+	ctx := WithMetadata(context.Background(), Metadata{"a": "1", "b": "2"})
+	ctx = WithMetadata(ctx, Metadata{"b": "3"})
+	require.Equal(t, Metadata{"a": "1", "b": "3"}, MetadataFromContext(ctx))
+
+	require.Nil(t, MetadataFromContext(context.Background()))
+}
+
+func TestMetadata_Invoke(t *testing.T) {
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(r.Meta)
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithRequestMetadata(true))
+	defer cli.Close()
+
+	ctx := WithMetadata(context.Background(), Metadata{"identity": "alice"})
+	res, err := cli.Invoke(ctx, "whoami", nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"identity": "alice"}`, string(res))
+}
+
+func TestRequest_DeadlineAndPriority(t *testing.T) {
+	type result struct {
+		deadline time.Time
+		ok       bool
+		priority int
+	}
+	results := make(chan result, 1)
+	handler := HandlerFunc(func(w ResponseWriter, r *Request) {
+		deadline, ok := r.Deadline()
+		results <- result{deadline: deadline, ok: ok, priority: r.Priority()}
+		_ = w.WriteMessage(nil)
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, handler)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithRequestMetadata(true))
+	defer cli.Close()
+
+	t.Run("none", func(t *testing.T) {
+		_, err := cli.Invoke(context.Background(), "test", nil)
+		require.NoError(t, err)
+
+		res := <-results
+		require.False(t, res.ok)
+		require.Equal(t, 0, res.priority)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		deadline := time.Now().Add(time.Minute)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		ctx = WithPriority(ctx, 7)
+
+		_, err := cli.Invoke(ctx, "test", nil)
+		require.NoError(t, err)
+
+		// The deadline is rebuilt from the timeout on the receiving side, so
+		// it may be slightly later than the original.
+		res := <-results
+		require.True(t, res.ok)
+		require.WithinDuration(t, deadline, res.deadline, time.Second)
+		require.Equal(t, 7, res.priority)
+	})
+}
+
+func TestInvoke_NoMetadataByDefault(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	defer srvConn.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = WithMetadata(ctx, Metadata{"identity": "alice"})
+	go func() { _, _ = cli.Invoke(ctx, "ping", nil) }()
+
+	// Requests must match the plain JSON-RPC 2.0 wire format, which strict
+	// peers decode with unknown fields disallowed.
+	frame, err := NewReader(srvConn).ReadFrame()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "method": "ping", "params": null, "id": 1}`, string(frame))
+
+	var req struct {
+		Version string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+		ID      ID              `json:"id"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(frame))
+	dec.DisallowUnknownFields()
+	require.NoError(t, dec.Decode(&req))
+}
+
+func TestRequest_Deadline(t *testing.T) {
+	received := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Request{Meta: Metadata{MetaTimeout: "1.5s"}, received: received}
+
+	deadline, ok := r.Deadline()
+	require.True(t, ok)
+	require.Equal(t, received.Add(1500*time.Millisecond), deadline)
+
+	r.Meta[MetaTimeout] = "soon"
+	_, ok = r.Deadline()
+	require.False(t, ok)
+}
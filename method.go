@@ -0,0 +1,101 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// TypedHandler is a Handler which knows the Go types of its params and
+// result, such as the handlers returned by Method and registered by
+// RegisterService. ServeMux reports the types of TypedHandlers through
+// Methods, so tools like the openrpc package can describe them.
+type TypedHandler interface {
+	Handler
+	ParamsType() reflect.Type
+	ResultType() reflect.Type
+}
+
+// typedHandler adds types to a Handler.
+type typedHandler struct {
+	Handler
+	params, result reflect.Type
+}
+
+func (h typedHandler) ParamsType() reflect.Type { return h.params }
+func (h typedHandler) ResultType() reflect.Type { return h.result }
+
+// Method returns a Handler which calls fn with the request's context and
+// params decoded into a P, and replies with the R it returns:
+//
+//	mux.Handle("sum", jsonrpc2.Method(func(ctx context.Context, nums []int) (int, error) {
+//		var sum int
+//		for _, n := range nums {
+//			sum += n
+//		}
+//		return sum, nil
+//	}))
+//
+// Requests whose params can't be decoded into a P get an
+// ErrorInvalidParams error without calling fn, and requests without params
+// call fn with the zero P. If P is a struct, or a pointer to one, its
+// fields may be renamed or made required with jsonrpc tags:
+//
+//	type TransferParams struct {
+//		From   string `jsonrpc:"from_account,required"`
+//		To     string `jsonrpc:"to_account,required"`
+//		Amount int    `json:"amount" jsonrpc:",required"`
+//		Memo   string `json:"memo"`
+//	}
+//
+// Requests missing required params get an ErrorInvalidParams error whose
+// data is a MissingParams listing them. The returned Handler is a
+// TypedHandler. If fn returns an Error, it is sent with its own
+// code, and other errors are sent with ErrorInternal. Notifications call fn
+// and discard its result.
+func Method[P, R any](fn func(ctx context.Context, params P) (R, error)) Handler {
+	h := HandlerFunc(func(w ResponseWriter, r *Request) {
+		var params P
+		if err := decodeTypedParams(r.Params, &params); err != nil {
+			if !r.Notification {
+				_ = w.WriteError(ErrorInvalidParams, err)
+			}
+			return
+		}
+
+		res, err := fn(r.Context(), params)
+		if r.Notification {
+			return
+		}
+		writeResult(w, res, err)
+	})
+	return typedHandler{
+		Handler: h,
+		params:  reflect.TypeOf((*P)(nil)).Elem(),
+		result:  reflect.TypeOf((*R)(nil)).Elem(),
+	}
+}
+
+// writeResult replies with res, or with err if it is non-nil. If err is an
+// Error, it is sent with its own code, and other errors are sent with
+// ErrorInternal.
+func writeResult(w ResponseWriter, res interface{}, err error) {
+	if err != nil {
+		var rpcErr Error
+		if errors.As(err, &rpcErr) {
+			_ = w.WriteError(rpcErr.Code, err)
+		} else {
+			_ = w.WriteError(ErrorInternal, err)
+		}
+		return
+	}
+	// Encode the result first, so that an error can still be sent if it
+	// can't be encoded.
+	body, err := json.Marshal(res)
+	if err != nil {
+		_ = w.WriteError(ErrorInternal, err)
+		return
+	}
+	_ = w.WriteMessage(json.RawMessage(body))
+}
@@ -0,0 +1,61 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethod(t *testing.T) {
+	type params struct {
+		A, B int
+	}
+	var notified []int
+
+	mux := NewServeMux()
+	mux.Handle("add", Method(func(ctx context.Context, p params) (int, error) {
+		return p.A + p.B, nil
+	}))
+	mux.Handle("div", Method(func(ctx context.Context, p params) (int, error) {
+		if p.B == 0 {
+			return 0, Error{Code: ErrorInvalidParams, Message: "division by zero"}
+		}
+		return p.A / p.B, nil
+	}))
+	mux.Handle("fail", Method(func(ctx context.Context, p *params) (interface{}, error) {
+		return nil, errors.New("disk full")
+	}))
+	mux.Handle("record", Method(func(ctx context.Context, n int) (struct{}, error) {
+		notified = append(notified, n)
+		return struct{}{}, nil
+	}))
+	mux.Handle("unencodable", Method(func(ctx context.Context, _ struct{}) (func(), error) {
+		return func() {}, nil
+	}))
+
+	tt := []struct {
+		name, req, resp string
+	}{
+		{"result", `{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`, `{"jsonrpc": "2.0", "result": 3, "id": 1}`},
+		{"no params", `{"jsonrpc": "2.0", "method": "add", "id": 1}`, `{"jsonrpc": "2.0", "result": 0, "id": 1}`},
+		{"invalid params", `{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1}`, `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "json: cannot unmarshal array into Go value of type jsonrpc2.params"}, "id": 1}`},
+		{"rpc error", `{"jsonrpc": "2.0", "method": "div", "params": {"A": 1}, "id": 1}`, `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "division by zero"}, "id": 1}`},
+		{"other error", `{"jsonrpc": "2.0", "method": "fail", "id": 1}`, `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "disk full"}, "id": 1}`},
+		{"notification", `{"jsonrpc": "2.0", "method": "record", "params": 7}`, ``},
+		{"unencodable result", `{"jsonrpc": "2.0", "method": "unencodable", "id": 1}`, `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "json: unsupported type: func()"}, "id": 1}`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reply, err := ServeFrame(context.Background(), mux, []byte(tc.req))
+			require.NoError(t, err)
+			if tc.resp == "" {
+				require.Nil(t, reply)
+				return
+			}
+			require.JSONEq(t, tc.resp, string(reply))
+		})
+	}
+	require.Equal(t, []int{7}, notified)
+}
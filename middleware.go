@@ -0,0 +1,18 @@
+package jsonrpc2
+
+// Middleware wraps a Handler to add behavior shared by many handlers, such
+// as authentication, logging, or recovering from panics.
+type Middleware func(Handler) Handler
+
+// Chain wraps h with mw. The first middleware is the outermost, so it sees
+// each request first:
+//
+//	h := jsonrpc2.Chain(handler, logRequests, requireAuth)
+//
+// is the same as logRequests(requireAuth(handler)).
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
@@ -0,0 +1,88 @@
+package jsonrpc2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(w ResponseWriter, r *Request) {
+				order = append(order, name)
+				next.ServeRPC(w, r)
+			})
+		}
+	}
+
+	h := Chain(HandlerFunc(func(w ResponseWriter, r *Request) {
+		order = append(order, "handler")
+	}), tag("a"), tag("b"))
+	_, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "x"}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "handler"}, order)
+}
+
+func TestServeMux_Use(t *testing.T) {
+	var seen []string
+	record := func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			seen = append(seen, r.Method)
+			next.ServeRPC(w, r)
+		})
+	}
+	deny := func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			_ = w.WriteError(ErrorInvalidRequest, Error{Message: "denied"})
+		})
+	}
+
+	mux := NewServeMux()
+	mux.HandleFunc("ping", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("pong") })
+	admin := mux.Group("admin.")
+	admin.Use(deny)
+	admin.HandleFunc("reset", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("reset") })
+	mux.Use(record)
+
+	reply, err := ServeFrame(context.Background(), mux, []byte(`{"jsonrpc": "2.0", "method": "ping", "id": 1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "result": "pong", "id": 1}`, string(reply))
+
+	reply, err = ServeFrame(context.Background(), mux, []byte(`{"jsonrpc": "2.0", "method": "admin.reset", "id": 2}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "denied"}, "id": 2}`, string(reply))
+
+	// Mux middleware also sees methods which aren't registered.
+	_, err = ServeFrame(context.Background(), mux, []byte(`{"jsonrpc": "2.0", "method": "missing", "id": 3}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ping", "admin.reset", "missing"}, seen)
+}
+
+func TestServiceGroup_UseNested(t *testing.T) {
+	var seen []string
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(w ResponseWriter, r *Request) {
+				seen = append(seen, name+":"+r.Method)
+				next.ServeRPC(w, r)
+			})
+		}
+	}
+
+	mux := NewServeMux()
+	g := mux.Group("a.")
+	g.Use(tag("outer"))
+	nested := g.Group("b.")
+	nested.Use(tag("inner"))
+	nested.HandleFunc("x", func(w ResponseWriter, r *Request) {})
+	g.HandleFunc("y", func(w ResponseWriter, r *Request) {})
+
+	for _, method := range []string{"a.b.x", "a.y"} {
+		_, err := ServeFrame(context.Background(), mux, []byte(`{"jsonrpc": "2.0", "method": "`+method+`"}`))
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"outer:a.b.x", "inner:a.b.x", "outer:a.y"}, seen)
+}
@@ -0,0 +1,162 @@
+package jsonrpc2
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metadata keys used by NonceGuard.
+const (
+	MetaIdentity = "identity"
+	MetaNonce    = "nonce"
+)
+
+// nonceReorderWindow is how many nonces, counting down from the highest
+// nonce accepted for an identity, are tracked by NonceGuard.
+const nonceReorderWindow = 64
+
+// NonceGuard is a Handler which rejects replayed requests. Each request must
+// carry an identity and a nonce in its metadata. Each nonce is only accepted
+// once per identity, and must be greater than the highest nonce accepted for
+// the identity minus 64. Requests sent concurrently may be handled out of
+// order, so nonces slightly below the highest are still accepted. Rejected
+// requests receive an ErrorInvalidRequest error; rejected notifications are
+// dropped. Callers using a Client must enable WithRequestMetadata.
+//
+// NonceGuard only prevents replays; it does not authenticate the identity.
+// Deployments should use it alongside request signing, where the nonce is
+// covered by the signature.
+type NonceGuard struct {
+	// Handler is invoked for requests which pass the check.
+	Handler Handler
+
+	// Window enables timestamp nonces. When set, nonces are Unix timestamps in
+	// milliseconds and must also be within Window of the current time. This
+	// bounds the memory used by the guard, since identities which haven't
+	// been seen for longer than Window can be forgotten.
+	//
+	// When Window is zero, nonces may be any increasing integer, and the
+	// nonces seen for each identity are kept forever.
+	Window time.Duration
+
+	// Identity returns the identity of a request. If nil, the "identity"
+	// metadata key is used.
+	Identity func(r *Request) string
+
+	// Clock is used to get the current time when Window is set. If nil,
+	// SystemClock is used.
+	Clock Clock
+
+	mut       sync.Mutex
+	seen      map[string]*nonceWindow
+	lastPrune time.Time
+}
+
+// nonceWindow tracks the nonces accepted for an identity. Bit i of used is
+// set if the nonce highest-i has been accepted.
+type nonceWindow struct {
+	highest int64
+	used    uint64
+}
+
+// accept marks nonce as used, returning an error if it has already been used
+// or is too old to tell.
+func (nw *nonceWindow) accept(nonce int64) error {
+	if nonce > nw.highest {
+		if shift := uint64(nonce) - uint64(nw.highest); shift < nonceReorderWindow {
+			nw.used = nw.used<<shift | 1
+		} else {
+			nw.used = 1
+		}
+		nw.highest = nonce
+		return nil
+	}
+
+	offset := uint64(nw.highest) - uint64(nonce)
+	if offset >= nonceReorderWindow {
+		return fmt.Errorf("nonce too old")
+	}
+	if nw.used&(1<<offset) != 0 {
+		return fmt.Errorf("nonce already used")
+	}
+	nw.used |= 1 << offset
+	return nil
+}
+
+// ServeRPC implements Handler.
+func (g *NonceGuard) ServeRPC(w ResponseWriter, r *Request) {
+	if err := g.check(r); err != nil {
+		if !r.Notification {
+			_ = w.WriteError(ErrorInvalidRequest, err)
+		}
+		return
+	}
+	g.Handler.ServeRPC(w, r)
+}
+
+func (g *NonceGuard) check(r *Request) error {
+	identity := r.Meta.Get(MetaIdentity)
+	if g.Identity != nil {
+		identity = g.Identity(r)
+	}
+	if identity == "" {
+		return fmt.Errorf("missing identity")
+	}
+
+	rawNonce := r.Meta.Get(MetaNonce)
+	if rawNonce == "" {
+		return fmt.Errorf("missing nonce")
+	}
+	nonce, err := strconv.ParseInt(rawNonce, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid nonce %q", rawNonce)
+	}
+
+	clock := g.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	now := clock.Now()
+	if g.Window > 0 {
+		// Compare in milliseconds so that large nonces can't overflow.
+		nowMs := now.UnixNano() / int64(time.Millisecond)
+		windowMs := int64(g.Window / time.Millisecond)
+		if nonce < nowMs-windowMs || nonce > nowMs+windowMs {
+			return fmt.Errorf("nonce outside of allowed window")
+		}
+	}
+
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	if g.seen == nil {
+		g.seen = make(map[string]*nonceWindow)
+	}
+	if nw, ok := g.seen[identity]; ok {
+		if err := nw.accept(nonce); err != nil {
+			return err
+		}
+	} else {
+		g.seen[identity] = &nonceWindow{highest: nonce, used: 1}
+	}
+
+	if g.Window > 0 && now.Sub(g.lastPrune) > g.Window {
+		g.prune(now)
+	}
+	return nil
+}
+
+// prune forgets identities whose highest nonce is outside of the window.
+// Any new nonce for those identities must be within the window, and so is
+// already greater than the forgotten nonces.
+func (g *NonceGuard) prune(now time.Time) {
+	oldest := now.Add(-g.Window).UnixNano() / int64(time.Millisecond)
+	for identity, nw := range g.seen {
+		if nw.highest < oldest {
+			delete(g.seen, identity)
+		}
+	}
+	g.lastPrune = now
+}
@@ -0,0 +1,136 @@
+package jsonrpc2
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceGuard(t *testing.T) {
+	g := &NonceGuard{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(true)
+	})}
+
+	tt := []struct {
+		name   string
+		meta   Metadata
+		expect bool
+	}{
+		{"first nonce", Metadata{"identity": "alice", "nonce": "1"}, true},
+		{"increasing nonce", Metadata{"identity": "alice", "nonce": "5"}, true},
+		{"replayed nonce", Metadata{"identity": "alice", "nonce": "5"}, false},
+		{"unused older nonce", Metadata{"identity": "alice", "nonce": "3"}, true},
+		{"replayed older nonce", Metadata{"identity": "alice", "nonce": "1"}, false},
+		{"far ahead nonce", Metadata{"identity": "alice", "nonce": "100"}, true},
+		{"nonce below window", Metadata{"identity": "alice", "nonce": "36"}, false},
+		{"nonce within window", Metadata{"identity": "alice", "nonce": "37"}, true},
+		{"other identity", Metadata{"identity": "bob", "nonce": "3"}, true},
+		{"missing identity", Metadata{"nonce": "10"}, false},
+		{"missing nonce", Metadata{"identity": "alice"}, false},
+		{"invalid nonce", Metadata{"identity": "alice", "nonce": "x"}, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &recordingWriter{}
+			g.ServeRPC(w, &Request{Method: "test", Meta: tc.meta})
+			require.Equal(t, tc.expect, w.err == nil)
+		})
+	}
+}
+
+func TestNonceGuard_Window(t *testing.T) {
+	g := &NonceGuard{
+		Window: time.Minute,
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			_ = w.WriteMessage(true)
+		}),
+	}
+
+	nonceAt := func(ts time.Time) Metadata {
+		ms := ts.UnixNano() / int64(time.Millisecond)
+		return Metadata{"identity": "alice", "nonce": strconv.FormatInt(ms, 10)}
+	}
+
+	w := &recordingWriter{}
+	g.ServeRPC(w, &Request{Meta: nonceAt(time.Now().Add(-2 * time.Minute))})
+	require.Error(t, w.err, "stale timestamp should be rejected")
+
+	w = &recordingWriter{}
+	g.ServeRPC(w, &Request{Meta: nonceAt(time.Now())})
+	require.NoError(t, w.err)
+}
+
+func TestNonceGuard_WindowLargeNonce(t *testing.T) {
+	g := &NonceGuard{
+		Window: time.Minute,
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			_ = w.WriteMessage(true)
+		}),
+	}
+
+	nowMs := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, nonce := range []int64{
+		math.MaxInt64,
+		// Converted to nanoseconds, this nonce wraps around to the current time.
+		nowMs + 1<<58,
+	} {
+		w := &recordingWriter{}
+		g.ServeRPC(w, &Request{Meta: Metadata{"identity": "alice", "nonce": strconv.FormatInt(nonce, 10)}})
+		require.Errorf(t, w.err, "nonce %d should be rejected", nonce)
+	}
+}
+
+func TestNonceGuard_Concurrent(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, &NonceGuard{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage(true)
+	})})
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithRequestMetadata(true))
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Requests are handled concurrently, so they may reach the guard in a
+	// different order than their nonces.
+	const count = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 1; i <= count; i++ {
+		wg.Add(1)
+		go func(nonce int) {
+			defer wg.Done()
+			ctx := WithMetadata(ctx, Metadata{MetaIdentity: "alice", MetaNonce: strconv.Itoa(nonce)})
+			_, err := cli.Invoke(ctx, "test", nil)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+type recordingWriter struct {
+	msg interface{}
+	err error
+}
+
+func (w *recordingWriter) WriteMessage(msg interface{}) error {
+	w.msg = msg
+	return nil
+}
+
+func (w *recordingWriter) WriteError(errCode int, err error) error {
+	w.err = err
+	return nil
+}
@@ -0,0 +1,274 @@
+// Package openrpc describes the methods of a jsonrpc2.ServeMux as an
+// OpenRPC document (https://spec.open-rpc.org), for tooling such as API
+// explorers and client generators.
+//
+// Methods registered with type information, using jsonrpc2.Method or
+// ServeMux.RegisterService, are described with JSON Schemas generated from
+// their params and result types. Descriptions come from
+// ServeMux.Document. Servers opt in to serving the document over the
+// rpc.discover method by registering Handler:
+//
+//	mux.Handle(openrpc.MethodDiscover, openrpc.Handler(mux, openrpc.Info{
+//		Title:   "Accounts",
+//		Version: "1.4.0",
+//	}))
+package openrpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// MethodDiscover is the method OpenRPC reserves for serving a server's
+// document.
+const MethodDiscover = "rpc.discover"
+
+// Version is the version of the OpenRPC specification documents follow.
+const Version = "1.3.2"
+
+// Document is an OpenRPC document.
+type Document struct {
+	OpenRPC    string      `json:"openrpc"`
+	Info       Info        `json:"info"`
+	Methods    []Method    `json:"methods"`
+	Components *Components `json:"components,omitempty"`
+}
+
+// Info describes the API served.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Method describes a method.
+type Method struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Params      []ContentDescriptor `json:"params"`
+	Result      *ContentDescriptor  `json:"result,omitempty"`
+
+	// ParamStructure is "by-name" for methods whose params are a struct,
+	// and empty otherwise.
+	ParamStructure string `json:"paramStructure,omitempty"`
+}
+
+// ContentDescriptor describes a param or result.
+type ContentDescriptor struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Components holds the schemas of named struct types, which schemas refer
+// to with $ref.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is the subset of JSON Schema generated from Go types. The empty
+// Schema matches any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Generate returns the OpenRPC document describing the methods registered
+// with mux.
+//
+// Methods without type information are listed with no params or result.
+// Params which are a struct, or a pointer to one, are described by name,
+// one param per field, honoring json and jsonrpc struct tags. Other params
+// are described as a single param named "params".
+func Generate(mux *jsonrpc2.ServeMux, info Info) *Document {
+	g := &generator{names: make(map[reflect.Type]string), schemas: make(map[string]*Schema)}
+	doc := &Document{OpenRPC: Version, Info: info, Methods: []Method{}}
+
+	for _, mi := range mux.Methods() {
+		m := Method{Name: mi.Method, Description: mi.Description, Params: []ContentDescriptor{}}
+		if pt := mi.ParamsType; pt != nil {
+			if st := derefType(pt); st.Kind() == reflect.Struct {
+				m.ParamStructure = "by-name"
+				for _, f := range g.fields(st, true) {
+					m.Params = append(m.Params, ContentDescriptor{Name: f.name, Required: f.required, Schema: f.schema})
+				}
+			} else {
+				m.Params = append(m.Params, ContentDescriptor{Name: "params", Schema: g.schema(pt)})
+			}
+		}
+		if mi.ResultType != nil {
+			m.Result = &ContentDescriptor{Name: "result", Schema: g.schema(mi.ResultType)}
+		}
+		doc.Methods = append(doc.Methods, m)
+	}
+
+	if len(g.schemas) > 0 {
+		doc.Components = &Components{Schemas: g.schemas}
+	}
+	return doc
+}
+
+// Handler returns a Handler which replies with the document generated for
+// mux. The document is generated for each request, so it includes methods
+// registered after Handler is called.
+func Handler(mux *jsonrpc2.ServeMux, info Info) jsonrpc2.Handler {
+	return jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		if r.Notification {
+			return
+		}
+		_ = w.WriteMessage(Generate(mux, info))
+	})
+}
+
+var (
+	typeOfTime       = reflect.TypeOf(time.Time{})
+	typeOfRawMessage = reflect.TypeOf(json.RawMessage(nil))
+	typeOfMarshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator generates schemas, placing named struct types in components.
+type generator struct {
+	names   map[reflect.Type]string
+	schemas map[string]*Schema
+}
+
+// field is a JSON object member of a struct.
+type field struct {
+	name     string
+	required bool
+	schema   *Schema
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	t = derefType(t)
+	switch {
+	case t == typeOfTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == typeOfRawMessage:
+		return &Schema{}
+	case t.Implements(typeOfMarshaler) || reflect.PtrTo(t).Implements(typeOfMarshaler):
+		// Types with their own encoding can't be described.
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes byte slices as base64 strings.
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// component returns the name of the component schema for the named struct
+// type t, generating it if needed.
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	// Types from different packages may share a name, and the names of
+	// generic types include their type arguments.
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, t.Name())
+	name := base
+	for i := 2; g.schemas[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	// The name is reserved before generating the schema, so recursive types
+	// refer to themselves.
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range g.fields(t, false) {
+		s.Properties[f.name] = f.schema
+		if f.required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return s
+}
+
+// fields returns the JSON object members of the struct type t, in field
+// order. Fields of embedded structs without a JSON name are promoted, as
+// encoding/json does. If params is set, t is a params type, whose own
+// fields may be renamed or required with jsonrpc tags as in
+// jsonrpc2.Method.
+func (g *generator) fields(t reflect.Type, params bool) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if sf.Anonymous && jsonName == "" && derefType(sf.Type).Kind() == reflect.Struct {
+			fields = append(fields, g.fields(derefType(sf.Type), false)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := field{name: sf.Name, schema: g.schema(sf.Type)}
+		if jsonName != "" {
+			f.name = jsonName
+		}
+		if tag, ok := sf.Tag.Lookup("jsonrpc"); ok && params {
+			name, opts, _ := strings.Cut(tag, ",")
+			if name != "" {
+				f.name = name
+			}
+			f.required = opts == "required"
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
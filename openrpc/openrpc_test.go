@@ -0,0 +1,100 @@
+package openrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+type account struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Tags    []string  `json:"tags,omitempty"`
+	Parent  *account  `json:"parent,omitempty"`
+	secret  string
+}
+
+type transferParams struct {
+	From   string `jsonrpc:"from_account,required"`
+	Amount int    `json:"amount" jsonrpc:",required"`
+	Memo   string `json:"memo"`
+}
+
+type accounts struct{}
+
+func (accounts) Get(ctx context.Context, id []string) (*account, error) { return nil, nil }
+
+func TestGenerate(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.Handle("transfer", jsonrpc2.Method(func(ctx context.Context, p *transferParams) (bool, error) {
+		return true, nil
+	}))
+	mux.Document("transfer", jsonrpc2.MethodDoc{Description: "Moves money."})
+	require.NoError(t, mux.RegisterService("accounts", accounts{}))
+	mux.HandleFunc("untyped", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {})
+
+	doc := Generate(mux, Info{Title: "Bank", Version: "1.0.0"})
+	body, err := json.Marshal(doc)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"openrpc": "1.3.2",
+		"info": {"title": "Bank", "version": "1.0.0"},
+		"methods": [
+			{
+				"name": "accounts.Get",
+				"params": [{"name": "params", "schema": {"type": "array", "items": {"type": "string"}}}],
+				"result": {"name": "result", "schema": {"$ref": "#/components/schemas/account"}}
+			},
+			{
+				"name": "transfer",
+				"description": "Moves money.",
+				"paramStructure": "by-name",
+				"params": [
+					{"name": "from_account", "required": true, "schema": {"type": "string"}},
+					{"name": "amount", "required": true, "schema": {"type": "integer"}},
+					{"name": "memo", "schema": {"type": "string"}}
+				],
+				"result": {"name": "result", "schema": {"type": "boolean"}}
+			},
+			{"name": "untyped", "params": []}
+		],
+		"components": {"schemas": {
+			"account": {
+				"type": "object",
+				"properties": {
+					"id": {"type": "string"},
+					"created": {"type": "string", "format": "date-time"},
+					"tags": {"type": "array", "items": {"type": "string"}},
+					"parent": {"$ref": "#/components/schemas/account"}
+				}
+			}
+		}}
+	}`, string(body))
+}
+
+func TestHandler(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.Handle(MethodDiscover, Handler(mux, Info{Title: "Test", Version: "0.1.0"}))
+
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := jsonrpc2.NewClient(cliConn, nil)
+	defer cli.Close()
+
+	// Methods registered after the handler are included.
+	mux.Handle("sum", jsonrpc2.Method(func(ctx context.Context, nums []int) (int, error) { return 0, nil }))
+
+	doc, err := jsonrpc2.InvokeAs[Document](context.Background(), cli, MethodDiscover, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Test", doc.Info.Title)
+	require.Len(t, doc.Methods, 2)
+	require.Equal(t, MethodDiscover, doc.Methods[0].Name)
+	require.Equal(t, "sum", doc.Methods[1].Name)
+	require.Equal(t, "array", doc.Methods[1].Params[0].Schema.Type)
+}
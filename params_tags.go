@@ -0,0 +1,157 @@
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// paramsTag is the struct tag read by decodeTypedParams.
+const paramsTag = "jsonrpc"
+
+// MissingParams is the data of the ErrorInvalidParams error sent by typed
+// handlers, such as Method and RegisterService, when required params are
+// missing.
+type MissingParams struct {
+	Missing []string `json:"missing"`
+}
+
+// paramsField is a field of a params struct with a jsonrpc tag.
+type paramsField struct {
+	index    int
+	name     string // Name of the field in params.
+	renamed  bool   // Whether name was set by the jsonrpc tag.
+	required bool
+}
+
+// paramsFieldCache caches the paramsFields of struct types, keyed by
+// reflect.Type.
+var paramsFieldCache sync.Map
+
+// paramsFields returns the fields of the struct type t which have a jsonrpc
+// tag.
+func paramsFields(t reflect.Type) []paramsField {
+	if cached, ok := paramsFieldCache.Load(t); ok {
+		return cached.([]paramsField)
+	}
+
+	var fields []paramsField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup(paramsTag)
+		if !ok || !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		f := paramsField{index: i, name: name, renamed: name != "", required: opts == "required"}
+		if !f.renamed {
+			f.name = jsonFieldName(sf)
+		}
+		fields = append(fields, f)
+	}
+	paramsFieldCache.Store(t, fields)
+	return fields
+}
+
+// jsonFieldName returns the name encoding/json uses for sf.
+func jsonFieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// decodeTypedParams decodes params into v, a pointer, for typed handlers.
+//
+// If v points to a struct, its fields may be tagged with
+// `jsonrpc:"name,required"`. A name renames the field in params, replacing
+// its JSON name, and required makes requests which omit the field, or set
+// it to null, fail with an ErrorInvalidParams error whose data is a
+// MissingParams listing every missing field. Either part may be omitted,
+// as in `jsonrpc:",required"`. Tags are only read from the struct's own
+// fields, and only apply to params given by name.
+func decodeTypedParams(params json.RawMessage, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	st := rv.Type()
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return decodeParams(params, v)
+	}
+	fields := paramsFields(st)
+	if len(fields) == 0 {
+		return decodeParams(params, v)
+	}
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(st))
+		}
+		rv = rv.Elem()
+	}
+
+	trimmed := strings.TrimSpace(string(params))
+	var named map[string]json.RawMessage
+	switch {
+	case trimmed == "" || trimmed == "null":
+	case trimmed[0] == '{':
+		if err := json.Unmarshal(params, &named); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(params, v); err != nil {
+			return err
+		}
+	default:
+		// Params given by position can't be checked by name.
+		return decodeParams(params, v)
+	}
+
+	var missing []string
+	for _, f := range fields {
+		field := rv.Field(f.index)
+		if f.renamed && named != nil {
+			// A renamed field may have been matched by its JSON name, so it
+			// is reset and only decoded from its new name.
+			field.Set(reflect.Zero(field.Type()))
+		}
+
+		raw, ok := named[f.name]
+		if !ok || string(raw) == "null" {
+			if f.required {
+				missing = append(missing, f.name)
+			}
+			continue
+		}
+		if f.renamed {
+			if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+				// Report the field by its name in params.
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &typeErr) && typeErr.Field == "" {
+					typeErr.Field = f.name
+				}
+				return err
+			}
+		}
+	}
+	if len(missing) > 0 {
+		data, _ := json.Marshal(MissingParams{Missing: missing})
+		return Error{
+			Code:    ErrorInvalidParams,
+			Message: "missing required params: " + strings.Join(missing, ", "),
+			Data:    data,
+		}
+	}
+	return nil
+}
+
+// decodeParams decodes params into v, leaving v unchanged if there are no
+// params.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(params, v)
+}
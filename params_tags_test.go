@@ -0,0 +1,80 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type transferParams struct {
+	From   string `jsonrpc:"from_account,required"`
+	To     string `jsonrpc:"to_account,required"`
+	Amount int    `json:"amount" jsonrpc:",required"`
+	Memo   string `json:"memo"`
+}
+
+func TestDecodeTypedParams(t *testing.T) {
+	var p transferParams
+	err := decodeTypedParams(json.RawMessage(`{"from_account": "a", "to_account": "b", "amount": 5, "memo": "hi"}`), &p)
+	require.NoError(t, err)
+	require.Equal(t, transferParams{From: "a", To: "b", Amount: 5, Memo: "hi"}, p)
+
+	// Renamed fields aren't decoded from their JSON names.
+	p = transferParams{}
+	err = decodeTypedParams(json.RawMessage(`{"From": "x", "from_account": "a", "to_account": "b", "amount": 5}`), &p)
+	require.NoError(t, err)
+	require.Equal(t, "a", p.From)
+
+	var rpcErr Error
+	err = decodeTypedParams(json.RawMessage(`{"to_account": "b", "amount": null, "From": "a"}`), &p)
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+	require.JSONEq(t, `{"missing": ["from_account", "amount"]}`, string(rpcErr.Data))
+
+	err = decodeTypedParams(nil, &p)
+	require.ErrorAs(t, err, &rpcErr)
+	require.JSONEq(t, `{"missing": ["from_account", "to_account", "amount"]}`, string(rpcErr.Data))
+
+	// Pointers to structs are allocated.
+	var pp *transferParams
+	err = decodeTypedParams(json.RawMessage(`{"from_account": "a", "to_account": "b", "amount": 1}`), &pp)
+	require.NoError(t, err)
+	require.Equal(t, "a", pp.From)
+
+	// Types without tags decode as usual.
+	var nums []int
+	require.NoError(t, decodeTypedParams(json.RawMessage(`[1, 2]`), &nums))
+	require.Equal(t, []int{1, 2}, nums)
+}
+
+func TestMethod_RequiredParams(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("transfer", Method(func(ctx context.Context, p transferParams) (int, error) {
+		return p.Amount, nil
+	}))
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	res, err := cli.Invoke(context.Background(), "transfer", map[string]interface{}{
+		"from_account": "a", "to_account": "b", "amount": 7,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "7", string(res))
+
+	_, err = cli.Invoke(context.Background(), "transfer", map[string]interface{}{"amount": 7})
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+	require.Equal(t, "missing required params: from_account, to_account", rpcErr.Message)
+
+	var missing MissingParams
+	require.NoError(t, json.Unmarshal(rpcErr.Data, &missing))
+	require.Equal(t, []string{"from_account", "to_account"}, missing.Missing)
+}
@@ -0,0 +1,154 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Step is a call made by a Pipeline.
+type Step struct {
+	// Method is the method to invoke.
+	Method string
+
+	// Params returns the params of the call given the results of the
+	// previous stage, in the order its steps were added. prev is nil for the
+	// first stage. If Params is nil, the call is sent with null params.
+	Params func(prev []json.RawMessage) (interface{}, error)
+}
+
+// Pipeline runs a sequence of dependent calls, where the results of each
+// stage are used to build the params of the next. Each stage has one or more
+// independent steps which run concurrently. All calls share the context
+// passed to Run, and the pipeline stops at the first error.
+//
+//	res, err := jsonrpc2.NewPipeline(cli).
+//		Then("user.lookup", func([]json.RawMessage) (interface{}, error) {
+//			return "alice", nil
+//		}).
+//		Then("orders.list", func(prev []json.RawMessage) (interface{}, error) {
+//			var user struct{ ID int }
+//			err := json.Unmarshal(prev[0], &user)
+//			return user.ID, err
+//		}).
+//		Run(ctx)
+type Pipeline struct {
+	conn    Conn
+	batched bool
+	stages  [][]Step
+}
+
+// NewPipeline creates an empty Pipeline which invokes calls over conn.
+func NewPipeline(conn Conn) *Pipeline {
+	return &Pipeline{conn: conn}
+}
+
+// Then adds a stage with a single call to method.
+func (p *Pipeline) Then(method string, params func(prev []json.RawMessage) (interface{}, error)) *Pipeline {
+	return p.ThenAll(Step{Method: method, Params: params})
+}
+
+// ThenAll adds a stage of independent steps, which run concurrently. The next
+// stage receives their results in the order they were given.
+func (p *Pipeline) ThenAll(steps ...Step) *Pipeline {
+	if len(steps) > 0 {
+		p.stages = append(p.stages, steps)
+	}
+	return p
+}
+
+// Batched sets whether stages with more than one step are sent as a single
+// batch. Batching requires the Pipeline's Conn to be a *Client or *Pool;
+// other Conns always invoke steps individually.
+func (p *Pipeline) Batched(enabled bool) *Pipeline {
+	p.batched = enabled
+	return p
+}
+
+// Run runs the stages in order and returns the results of the last stage.
+// If a step fails, Run returns its error without running later stages.
+func (p *Pipeline) Run(ctx context.Context) ([]json.RawMessage, error) {
+	var prev []json.RawMessage
+	for i, steps := range p.stages {
+		params := make([]interface{}, len(steps))
+		for j, s := range steps {
+			if s.Params == nil {
+				continue
+			}
+			v, err := s.Params(prev)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline stage %d: %s: %w", i, s.Method, err)
+			}
+			params[j] = v
+		}
+
+		results, err := p.runStage(ctx, steps, params)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %d: %w", i, err)
+		}
+		prev = results
+	}
+	return prev, nil
+}
+
+func (p *Pipeline) runStage(ctx context.Context, steps []Step, params []interface{}) ([]json.RawMessage, error) {
+	if len(steps) == 1 {
+		res, err := p.conn.Invoke(ctx, steps[0].Method, params[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", steps[0].Method, err)
+		}
+		return []json.RawMessage{res}, nil
+	}
+
+	if b, ok := newBatch(p.conn); ok && p.batched {
+		results := make([]*json.RawMessage, len(steps))
+		for j, s := range steps {
+			res, err := b.Invoke(s.Method, params[j])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Method, err)
+			}
+			results[j] = res
+		}
+		if err := b.Commit(ctx); err != nil {
+			return nil, err
+		}
+		out := make([]json.RawMessage, len(results))
+		for j, res := range results {
+			out[j] = *res
+		}
+		return out, nil
+	}
+
+	// Cancel the remaining steps once one fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		out      = make([]json.RawMessage, len(steps))
+	)
+	for j, s := range steps {
+		wg.Add(1)
+		go func(j int, s Step) {
+			defer wg.Done()
+			res, err := p.conn.Invoke(ctx, s.Method, params[j])
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("%s: %w", s.Method, err)
+					cancel()
+				})
+				return
+			}
+			out[j] = res
+		}(j, s)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func newPipelineTestClient(t *testing.T, h Handler) *Client {
+	t.Helper()
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, h)
+	t.Cleanup(func() { _ = srv.Close() })
+	cli := NewClient(cliConn, nil)
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func TestPipeline(t *testing.T) {
+	cli := newPipelineTestClient(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		var n int
+		_ = json.Unmarshal(r.Params, &n)
+		switch r.Method {
+		case "double":
+			_ = w.WriteMessage(n * 2)
+		case "inc":
+			_ = w.WriteMessage(n + 1)
+		}
+	}))
+
+	param := func(n int) func([]json.RawMessage) (interface{}, error) {
+		return func([]json.RawMessage) (interface{}, error) { return n, nil }
+	}
+	sum := func(prev []json.RawMessage) (interface{}, error) {
+		var total int
+		for _, res := range prev {
+			var n int
+			if err := json.Unmarshal(res, &n); err != nil {
+				return nil, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+
+	for _, batched := range []bool{false, true} {
+		res, err := NewPipeline(cli).
+			Batched(batched).
+			ThenAll(Step{Method: "double", Params: param(1)}, Step{Method: "inc", Params: param(10)}).
+			Then("double", sum).
+			Then("inc", sum).
+			Run(context.Background())
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, "27", string(res[0]))
+	}
+}
+
+func TestPipeline_Error(t *testing.T) {
+	var calls, never atomic.Int64
+	cli := newPipelineTestClient(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		calls.Inc()
+		if r.Method == "never" {
+			never.Inc()
+		}
+		if r.Method == "fail" {
+			_ = w.WriteError(ErrorInternal, errors.New("failed"))
+			return
+		}
+		_ = w.WriteMessage(true)
+	}))
+
+	for _, batched := range []bool{false, true} {
+		calls.Store(0)
+		_, err := NewPipeline(cli).
+			Batched(batched).
+			ThenAll(Step{Method: "ok"}, Step{Method: "fail"}).
+			Then("never", nil).
+			Run(context.Background())
+
+		var rpcErr Error
+		require.True(t, errors.As(err, &rpcErr))
+		require.Equal(t, ErrorInternal, rpcErr.Code)
+		// The "ok" step may still be running when Run returns, so only
+		// check that the next stage never ran.
+		require.Zero(t, never.Load())
+	}
+
+	// Errors building params also stop the pipeline.
+	calls.Store(0)
+	paramsErr := errors.New("bad params")
+	_, err := NewPipeline(cli).
+		Then("ok", nil).
+		Then("never", func([]json.RawMessage) (interface{}, error) { return nil, paramsErr }).
+		Run(context.Background())
+	require.ErrorIs(t, err, paramsErr)
+	require.Equal(t, int64(1), calls.Load())
+}
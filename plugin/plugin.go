@@ -0,0 +1,271 @@
+// Package plugin implements a small plugin system on top of JSON-RPC 2.0.
+//
+// Plugins are separate executables which call Serve to expose one or more
+// services over their stdin and stdout. Hosts launch plugins with NewHost,
+// which supervises the plugin process, restarting it if it crashes, and
+// performs a version handshake each time the process starts.
+//
+// The plugin side:
+//
+//	func main() {
+//		mux := jsonrpc2.NewServeMux()
+//		mux.HandleFunc("greet", greet)
+//
+//		plugin.Serve(plugin.ServeConfig{
+//			Handshake: handshake,
+//			Services:  map[string]jsonrpc2.Handler{"greeter": mux},
+//		})
+//	}
+//
+// The host side:
+//
+//	host := plugin.NewHost(func() *exec.Cmd {
+//		return exec.Command("./greeter-plugin")
+//	}, handshake)
+//	defer host.Close()
+//
+//	greeter, err := host.Dispense(ctx, "greeter")
+//	res, err := greeter.Invoke(ctx, "greet", "world")
+//
+// Dispense returns a jsonrpc2.Conn scoped to a single service, which typed
+// clients for the service can be built on top of. The jsonrpc2gen command
+// generates a typed client and registration function from a Go interface
+// shared by the host and plugin:
+//
+//	//go:generate go run github.com/crtv-io/jsonrpc2/cmd/jsonrpc2gen -type Greeter -prefix ""
+//
+//	// Plugin side:
+//	mux := jsonrpc2.NewServeMux()
+//	shared.RegisterGreeter(mux, greeterImpl{})
+//
+//	// Host side:
+//	conn, err := host.Dispense(ctx, "greeter")
+//	greeter := shared.NewGreeterClient(conn)
+//	msg, err := greeter.Greet(ctx, "world")
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/crtv-io/jsonrpc2/subprocess"
+)
+
+// handshakeMethod is the RPC the host invokes on a plugin after it starts.
+const handshakeMethod = "plugin.handshake"
+
+// ErrPluginExited is returned when a plugin exits while an RPC is in flight.
+var ErrPluginExited = errors.New("plugin exited")
+
+// HandshakeConfig is used by hosts and plugins to check that they are
+// compatible. Hosts and plugins must use the same HandshakeConfig.
+type HandshakeConfig struct {
+	// ProtocolVersion is the version of the services exposed by the plugin.
+	// Plugins with a different version are rejected by the host.
+	ProtocolVersion int
+
+	// MagicCookieKey and MagicCookieValue are set as an environment variable
+	// by the host. Serve fails if the variable is not set, which prevents
+	// plugins from being run directly by users.
+	MagicCookieKey   string
+	MagicCookieValue string
+}
+
+// handshakeParams are the params of the handshake sent by the host.
+type handshakeParams struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// Info describes a running plugin. It is returned by the plugin during the
+// handshake.
+type Info struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	Services        []string `json:"services"`
+}
+
+// HostOpt is an option function that can be passed to NewHost.
+type HostOpt func(*Host)
+
+// WithHostLogger sets the Host to use a logger.
+func WithHostLogger(l jsonrpc2.Logger) HostOpt {
+	return func(h *Host) {
+		h.procOpts = append(h.procOpts, subprocess.WithLogger(l))
+	}
+}
+
+// WithHostHandler sets the Handler invoked for requests sent by the plugin
+// to the host.
+func WithHostHandler(handler jsonrpc2.Handler) HostOpt {
+	return func(h *Host) {
+		h.handler = handler
+	}
+}
+
+// WithProcessOpts sets options for the subprocess.Process used to run the
+// plugin.
+func WithProcessOpts(opts ...subprocess.Opt) HostOpt {
+	return func(h *Host) {
+		h.procOpts = append(h.procOpts, opts...)
+	}
+}
+
+// Host launches and supervises a plugin.
+type Host struct {
+	handshake HandshakeConfig
+	handler   jsonrpc2.Handler
+	procOpts  []subprocess.Opt
+
+	proc *subprocess.Process
+
+	mut        sync.Mutex
+	handshaked *jsonrpc2.Client
+	info       Info
+}
+
+// NewHost creates a Host and starts the plugin in the background. newCmd is
+// called to create the command each time the plugin is started.
+func NewHost(newCmd func() *exec.Cmd, handshake HandshakeConfig, opts ...HostOpt) *Host {
+	h := &Host{handshake: handshake}
+	for _, o := range opts {
+		o(h)
+	}
+
+	h.proc = subprocess.New(func() *exec.Cmd {
+		cmd := newCmd()
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		if handshake.MagicCookieKey != "" {
+			cmd.Env = append(cmd.Env, handshake.MagicCookieKey+"="+handshake.MagicCookieValue)
+		}
+		return cmd
+	}, h.handler, h.procOpts...)
+	return h
+}
+
+// Close stops the plugin.
+func (h *Host) Close() error {
+	return h.proc.Close()
+}
+
+// Health returns the health of the plugin process.
+func (h *Host) Health() subprocess.Health {
+	return h.proc.Health()
+}
+
+// Info waits for the plugin to be running and returns the Info from its
+// handshake.
+func (h *Host) Info(ctx context.Context) (Info, error) {
+	_, info, err := h.client(ctx)
+	return info, err
+}
+
+// Dispense returns a jsonrpc2.Conn for invoking methods of a service exposed
+// by the plugin. Dispense fails if the plugin does not expose the service.
+//
+// The returned Conn remains usable across plugin restarts.
+func (h *Host) Dispense(ctx context.Context, service string) (jsonrpc2.Conn, error) {
+	info, err := h.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range info.Services {
+		if s == service {
+			return &serviceConn{host: h, service: service}, nil
+		}
+	}
+	return nil, fmt.Errorf("plugin does not provide service %s", service)
+}
+
+// client returns the Client for the running plugin, performing the
+// handshake if it hasn't been done for the current process yet.
+func (h *Host) client(ctx context.Context) (*jsonrpc2.Client, Info, error) {
+	cli, err := h.proc.Client(ctx)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	h.mut.Lock()
+	if h.handshaked == cli {
+		info := h.info
+		h.mut.Unlock()
+		return cli, info, nil
+	}
+	h.mut.Unlock()
+
+	res, err := invoke(ctx, cli, handshakeMethod, handshakeParams{ProtocolVersion: h.handshake.ProtocolVersion})
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("plugin handshake failed: %w", err)
+	}
+	var info Info
+	if err := json.Unmarshal(res, &info); err != nil {
+		return nil, Info{}, fmt.Errorf("plugin handshake failed: %w", err)
+	}
+	if info.ProtocolVersion != h.handshake.ProtocolVersion {
+		return nil, Info{}, fmt.Errorf("plugin protocol version %d does not match host version %d", info.ProtocolVersion, h.handshake.ProtocolVersion)
+	}
+
+	h.mut.Lock()
+	h.handshaked, h.info = cli, info
+	h.mut.Unlock()
+	return cli, info, nil
+}
+
+// invoke invokes an RPC against cli, failing with ErrPluginExited if the
+// plugin exits before responding.
+func invoke(ctx context.Context, cli *jsonrpc2.Client, method string, msg interface{}) (json.RawMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-cli.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	res, err := cli.Invoke(ctx, method, msg)
+	if err != nil {
+		select {
+		case <-cli.Done():
+			return nil, ErrPluginExited
+		default:
+		}
+	}
+	return res, err
+}
+
+// serviceConn is a jsonrpc2.Conn for a single service of a plugin.
+type serviceConn struct {
+	host    *Host
+	service string
+}
+
+func (c *serviceConn) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	cli, _, err := c.host.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, cli, c.service+"."+method, msg)
+}
+
+func (c *serviceConn) Notify(method string, msg interface{}) error {
+	return c.host.proc.Notify(c.service+"."+method, msg)
+}
+
+func serviceNames(services map[string]jsonrpc2.Handler) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/crtv-io/jsonrpc2/subprocess"
+	"github.com/stretchr/testify/require"
+)
+
+var testHandshake = HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "JSONRPC2_TEST_PLUGIN",
+	MagicCookieValue: "greeter",
+}
+
+// TestHelperProcess isn't a real test. It's used as the plugin process for
+// other tests in this package.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv(testHandshake.MagicCookieKey) == "" {
+		return
+	}
+
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("greet", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var name string
+		_ = r.DecodeParams(&name)
+		_ = w.WriteMessage("hello, " + name)
+	})
+	mux.HandleFunc("panic", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		panic("oops")
+	})
+	mux.HandleFunc("exit", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		os.Exit(3)
+	})
+
+	err := Serve(ServeConfig{
+		Handshake: testHandshake,
+		Services:  map[string]jsonrpc2.Handler{"greeter": mux},
+	})
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func helperCommand() *exec.Cmd {
+	return exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+}
+
+func TestHost(t *testing.T) {
+	host := NewHost(helperCommand, testHandshake, WithProcessOpts(
+		subprocess.WithBackoff(10*time.Millisecond, time.Second),
+	))
+	defer host.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := host.Info(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Info{ProtocolVersion: 1, Services: []string{"greeter"}}, info)
+
+	_, err = host.Dispense(ctx, "missing")
+	require.Error(t, err)
+
+	greeter, err := host.Dispense(ctx, "greeter")
+	require.NoError(t, err)
+
+	res, err := greeter.Invoke(ctx, "greet", "world")
+	require.NoError(t, err)
+	require.JSONEq(t, `"hello, world"`, string(res))
+
+	t.Run("panics are recovered", func(t *testing.T) {
+		_, err := greeter.Invoke(ctx, "panic", nil)
+
+		var rpcErr jsonrpc2.Error
+		require.True(t, errors.As(err, &rpcErr))
+		require.Equal(t, jsonrpc2.ErrorInternal, rpcErr.Code)
+	})
+
+	t.Run("crashes are isolated", func(t *testing.T) {
+		_, err := greeter.Invoke(ctx, "exit", nil)
+		require.Equal(t, ErrPluginExited, err)
+
+		res, err := greeter.Invoke(ctx, "greet", "again")
+		require.NoError(t, err)
+		require.JSONEq(t, `"hello, again"`, string(res))
+		require.Equal(t, 1, host.Health().Restarts)
+	})
+}
+
+func TestHost_VersionMismatch(t *testing.T) {
+	hs := testHandshake
+	hs.ProtocolVersion = 2
+
+	host := NewHost(helperCommand, hs)
+	defer host.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := host.Info(ctx)
+	require.Error(t, err)
+}
+
+func TestServe_MagicCookie(t *testing.T) {
+	os.Unsetenv(testHandshake.MagicCookieKey)
+	require.Error(t, Serve(ServeConfig{Handshake: testHandshake}))
+}
+
+func TestHandshake_Params(t *testing.T) {
+	bb, err := json.Marshal(handshakeParams{ProtocolVersion: 1})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"protocolVersion": 1}`, string(bb))
+
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewClient(srvConn, newServiceHandler(ServeConfig{Handshake: testHandshake}))
+	defer srv.Close()
+	cli := jsonrpc2.NewClient(cliConn, nil)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := cli.Invoke(ctx, handshakeMethod, handshakeParams{ProtocolVersion: 1})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"protocolVersion": 1, "services": []}`, string(res))
+
+	// Params must be an object, so a bare version is rejected.
+	_, err = cli.Invoke(ctx, handshakeMethod, 1)
+	var rpcErr jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, jsonrpc2.ErrorInvalidParams, rpcErr.Code)
+}
@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// ServeConfig configures a plugin served with Serve.
+type ServeConfig struct {
+	Handshake HandshakeConfig
+
+	// Services exposed by the plugin, keyed by name. A request for method
+	// "name.method" is passed to the Handler for service "name" with a method
+	// of "method".
+	Services map[string]jsonrpc2.Handler
+
+	// Logger to use. Logs must not be written to stdout, which is used for
+	// communicating with the host.
+	Logger jsonrpc2.Logger
+}
+
+// Serve serves the plugin over stdin and stdout until the host closes the
+// connection. Panics in service handlers are recovered and returned to the
+// host as errors.
+//
+// Serve returns an error if the plugin was not launched by a host with a
+// matching HandshakeConfig.
+func Serve(cfg ServeConfig) error {
+	if cfg.Handshake.MagicCookieKey != "" && os.Getenv(cfg.Handshake.MagicCookieKey) != cfg.Handshake.MagicCookieValue {
+		return fmt.Errorf("this binary is a plugin and is not meant to be executed directly")
+	}
+
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}
+
+	opts := []jsonrpc2.ClientOpt{jsonrpc2.WithRecovery(true)}
+	if cfg.Logger != nil {
+		opts = append(opts, jsonrpc2.WithClientLogger(cfg.Logger))
+	}
+	cli := jsonrpc2.NewClient(rw, newServiceHandler(cfg), opts...)
+	<-cli.Done()
+	return nil
+}
+
+// serviceHandler routes requests to the services of a plugin.
+type serviceHandler struct {
+	info     Info
+	services map[string]jsonrpc2.Handler
+}
+
+func newServiceHandler(cfg ServeConfig) *serviceHandler {
+	return &serviceHandler{
+		info: Info{
+			ProtocolVersion: cfg.Handshake.ProtocolVersion,
+			Services:        serviceNames(cfg.Services),
+		},
+		services: cfg.Services,
+	}
+}
+
+func (h *serviceHandler) ServeRPC(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+	if r.Method == handshakeMethod {
+		var params handshakeParams
+		if err := r.DecodeParams(&params); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		_ = w.WriteMessage(h.info)
+		return
+	}
+
+	dot := strings.Index(r.Method, ".")
+	if dot < 0 {
+		h.notFound(w, r)
+		return
+	}
+	svc, ok := h.services[r.Method[:dot]]
+	if !ok {
+		h.notFound(w, r)
+		return
+	}
+
+	req := *r
+	req.Method = r.Method[dot+1:]
+	svc.ServeRPC(w, &req)
+}
+
+func (h *serviceHandler) notFound(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+	if !r.Notification {
+		_ = w.WriteError(jsonrpc2.ErrorMethodNotFound, fmt.Errorf("method %s not found", r.Method))
+	}
+}
@@ -0,0 +1,341 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ErrNoMembers is returned by a Pool which has no members to send calls to.
+var ErrNoMembers = errors.New("jsonrpc2: pool has no members")
+
+// PoolOpt is an option function that can be passed to NewPool.
+type PoolOpt func(*Pool)
+
+// WithBalancer sets the Balancer used to pick the member for each call. The
+// default is a LatencyBalancer.
+func WithBalancer(b Balancer) PoolOpt {
+	return func(p *Pool) {
+		if b != nil {
+			p.balancer = b
+		}
+	}
+}
+
+// WithLatencyDecay sets how quickly the latency of pool members adapts to
+// new measurements. Measurements older than about d have little weight.
+// The default is 10 seconds.
+func WithLatencyDecay(d time.Duration) PoolOpt {
+	return func(p *Pool) {
+		if d > 0 {
+			p.decay = d
+		}
+	}
+}
+
+// WithPoolClock sets the Clock used to measure latency.
+func WithPoolClock(clock Clock) PoolOpt {
+	return func(p *Pool) {
+		if clock != nil {
+			p.clock = clock
+		}
+	}
+}
+
+// Pool is a Conn which spreads calls over a set of connections to
+// equivalent backends. A Balancer picks the member which handles each call.
+type Pool struct {
+	balancer    Balancer
+	decay       time.Duration
+	clock       Clock
+	batchPolicy BatchPolicy
+	dialer      func(ctx context.Context, addr string) (Conn, error)
+	evict       bool
+	maxFailures int64
+
+	// ctx is cancelled when the pool is closed, which stops redialing.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// members is replaced rather than modified when members are added or
+	// removed, so it can be used after mut is released.
+	mut     sync.RWMutex
+	members []*PoolMember
+}
+
+var _ Conn = (*Pool)(nil)
+
+// NewPool creates a Pool which sends calls to conns.
+func NewPool(conns []Conn, opts ...PoolOpt) *Pool {
+	p := &Pool{
+		balancer: &LatencyBalancer{},
+		decay:    10 * time.Second,
+		clock:    SystemClock,
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	for _, o := range opts {
+		o(p)
+	}
+	for _, c := range conns {
+		p.Add(c)
+	}
+	return p
+}
+
+// Members returns the members of the pool.
+func (p *Pool) Members() []*PoolMember {
+	return append([]*PoolMember(nil), p.currentMembers()...)
+}
+
+func (p *Pool) currentMembers() []*PoolMember {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.members
+}
+
+// Add adds conn to the pool and returns its member.
+func (p *Pool) Add(conn Conn) *PoolMember {
+	return p.add(&PoolMember{Conn: conn, pool: p})
+}
+
+func (p *Pool) add(m *PoolMember) *PoolMember {
+	m.removed = make(chan struct{})
+
+	p.mut.Lock()
+	members := make([]*PoolMember, 0, len(p.members)+1)
+	p.members = append(append(members, p.members...), m)
+	p.mut.Unlock()
+
+	if p.evict {
+		p.watch(m)
+	}
+	return m
+}
+
+// Remove removes m from the pool, and reports whether m was a member. Calls
+// already sent to m are unaffected, and m's Conn isn't closed.
+func (p *Pool) Remove(m *PoolMember) bool {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	for i, cur := range p.members {
+		if cur == m {
+			members := make([]*PoolMember, 0, len(p.members)-1)
+			members = append(members, p.members[:i]...)
+			p.members = append(members, p.members[i+1:]...)
+			close(m.removed)
+			return true
+		}
+	}
+	return false
+}
+
+// Invoke invokes an RPC on the member picked by the pool's Balancer.
+func (p *Pool) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	m := p.balancer.Pick(p.currentMembers())
+	if m == nil {
+		return nil, ErrNoMembers
+	}
+
+	m.pending.Inc()
+	defer m.pending.Dec()
+
+	start := p.clock.Now()
+	res, err := m.Conn.Invoke(ctx, method, msg)
+	m.observe(p.clock.Now().Sub(start), err)
+	return res, err
+}
+
+// Notify sends a notification to the member picked by the pool's Balancer.
+func (p *Pool) Notify(method string, msg interface{}) error {
+	m := p.balancer.Pick(p.currentMembers())
+	if m == nil {
+		return ErrNoMembers
+	}
+	return m.Conn.Notify(method, msg)
+}
+
+// Ping pings every member with Ping, which updates their latency without
+// waiting for calls to be sent to them. Members must serve MethodEcho. The
+// first error is returned.
+func (p *Pool) Ping(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, m := range p.currentMembers() {
+		wg.Add(1)
+		go func(m *PoolMember) {
+			defer wg.Done()
+			rtt, err := Ping(ctx, m.Conn)
+			m.observe(rtt, err)
+			if err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+		}(m)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// PoolMember is a connection in a Pool, along with the statistics used to
+// balance calls to it.
+type PoolMember struct {
+	Conn Conn
+
+	// Addr is the address the member was dialed at by Pool.Resolve. It is
+	// empty for members which were added directly.
+	Addr string
+
+	pool     *Pool
+	pending  atomic.Int64
+	failures atomic.Int64 // Calls failed in a row without a response.
+	redial   bool         // Set for members dialed by Pool.Dial.
+	removed  chan struct{}
+
+	mut      sync.Mutex
+	latency  float64 // Exponentially weighted moving average, in nanoseconds.
+	measured time.Time
+}
+
+// Pending returns the number of calls waiting for a response from the
+// member.
+func (m *PoolMember) Pending() int64 {
+	return m.pending.Load()
+}
+
+// Latency returns the moving average of the member's latency, or 0 if its
+// latency hasn't been measured.
+func (m *PoolMember) Latency() time.Duration {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return time.Duration(m.latency)
+}
+
+// failurePenalty is the latency recorded for calls which fail without a
+// response, so that failing members are avoided.
+const failurePenalty = time.Second
+
+// observe adds a latency measurement to the member's moving average.
+// Measurements are weighted by how much time has passed since the last one,
+// so the average adapts quickly after idle periods.
+func (m *PoolMember) observe(d time.Duration, err error) {
+	var rpcErr Error
+	if err != nil && !errors.As(err, &rpcErr) {
+		// Only responses measure the backend; other errors, such as the
+		// connection closing, are penalized.
+		if d < failurePenalty {
+			d = failurePenalty
+		}
+		if n := m.failures.Inc(); m.pool.maxFailures > 0 && n >= m.pool.maxFailures {
+			defer m.pool.evictMember(m)
+		}
+	} else {
+		m.failures.Store(0)
+	}
+
+	now := m.pool.clock.Now()
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.measured.IsZero() {
+		m.latency = float64(d)
+	} else {
+		elapsed := now.Sub(m.measured)
+		w := math.Exp(-float64(elapsed) / float64(m.pool.decay))
+		m.latency = m.latency*w + float64(d)*(1-w)
+	}
+	m.measured = now
+}
+
+// Balancer picks the member of a Pool to send a call to.
+type Balancer interface {
+	// Pick returns the member to use from members, or nil if none can be
+	// used. Pick must be safe for concurrent use.
+	Pick(members []*PoolMember) *PoolMember
+}
+
+// LatencyBalancer is a Balancer which prefers members with low latency and
+// few pending calls. It compares two random members and picks the one with
+// the lower cost, where cost is the member's average latency multiplied by
+// its pending calls plus one. Members whose latency hasn't been measured yet
+// are preferred so they are measured.
+type LatencyBalancer struct {
+	mut sync.Mutex
+	rnd *rand.Rand
+}
+
+// Pick implements Balancer.
+func (b *LatencyBalancer) Pick(members []*PoolMember) *PoolMember {
+	switch len(members) {
+	case 0:
+		return nil
+	case 1:
+		return members[0]
+	}
+
+	b.mut.Lock()
+	if b.rnd == nil {
+		b.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	i := b.rnd.Intn(len(members))
+	j := b.rnd.Intn(len(members) - 1)
+	b.mut.Unlock()
+	if j >= i {
+		j++
+	}
+
+	a, c := members[i], members[j]
+	if latencyCost(c) < latencyCost(a) {
+		return c
+	}
+	return a
+}
+
+func latencyCost(m *PoolMember) float64 {
+	return float64(m.Latency()) * float64(m.Pending()+1)
+}
+
+// RoundRobinBalancer is a Balancer which picks members in turn.
+type RoundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+// Pick implements Balancer.
+func (b *RoundRobinBalancer) Pick(members []*PoolMember) *PoolMember {
+	if len(members) == 0 {
+		return nil
+	}
+	return members[(b.next.Inc()-1)%uint64(len(members))]
+}
+
+// LeastPendingBalancer is a Balancer which picks the member with the fewest
+// pending calls. Ties are broken in turn, so idle members share calls.
+type LeastPendingBalancer struct {
+	next atomic.Uint64
+}
+
+// Pick implements Balancer.
+func (b *LeastPendingBalancer) Pick(members []*PoolMember) *PoolMember {
+	if len(members) == 0 {
+		return nil
+	}
+	start := int((b.next.Inc() - 1) % uint64(len(members)))
+
+	var best *PoolMember
+	for i := range members {
+		m := members[(start+i)%len(members)]
+		if best == nil || m.Pending() < best.Pending() {
+			best = m
+		}
+	}
+	return best
+}
@@ -0,0 +1,212 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// BatchPolicy is how a Pool sends the calls in a PoolBatch to its members.
+type BatchPolicy int
+
+const (
+	// BatchPin sends every call in a batch to a single member, picked by the
+	// pool's Balancer, as one batch. It is the default.
+	BatchPin BatchPolicy = iota
+
+	// BatchSplit spreads the calls in a batch over the pool's members,
+	// picking the member for each call with the pool's Balancer. Each member
+	// is sent its share of the calls as one batch.
+	BatchSplit
+)
+
+// WithBatchPolicy sets how batches created with Pool.Batch are sent to
+// members. The default is BatchPin.
+func WithBatchPolicy(policy BatchPolicy) PoolOpt {
+	return func(p *Pool) {
+		p.batchPolicy = policy
+	}
+}
+
+// Batch creates a new request batch which is sent to the pool's members
+// according to its BatchPolicy.
+func (p *Pool) Batch() *PoolBatch {
+	return &PoolBatch{pool: p}
+}
+
+// PoolBatch is a batch of messages to send to a Pool. It must be committed
+// with Commit. Results are written in place as with Batch, regardless of
+// which members the calls were sent to.
+type PoolBatch struct {
+	pool  *Pool
+	calls []*poolBatchCall
+}
+
+type poolBatchCall struct {
+	method string
+	params json.RawMessage
+	result *json.RawMessage // nil for notifications.
+}
+
+// Notify adds a notification request to the batch.
+func (b *PoolBatch) Notify(method string, msg interface{}) error {
+	params, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	b.calls = append(b.calls, &poolBatchCall{method: method, params: params})
+	return nil
+}
+
+// Invoke queues an RPC to invoke. The returned *json.RawMessage will be empty
+// until the batch is committed.
+func (b *PoolBatch) Invoke(method string, msg interface{}) (*json.RawMessage, error) {
+	params, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	call := &poolBatchCall{method: method, params: params, result: new(json.RawMessage)}
+	b.calls = append(b.calls, call)
+	return call.result, nil
+}
+
+// Commit sends the batch and waits for its responses. If any call fails, the
+// error from the member sent the earliest failing share of the batch is
+// returned.
+func (b *PoolBatch) Commit(ctx context.Context) error {
+	if len(b.calls) == 0 {
+		return nil
+	}
+	shares, err := b.assign()
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(shares))
+	var wg sync.WaitGroup
+	for i, s := range shares {
+		wg.Add(1)
+		go func(i int, s *poolBatchShare) {
+			defer wg.Done()
+			defer s.member.pending.Sub(int64(len(s.calls)))
+
+			start := b.pool.clock.Now()
+			errs[i] = sendPoolBatch(ctx, s.member.Conn, s.calls)
+			s.member.observe(b.pool.clock.Now().Sub(start), errs[i])
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poolBatchShare is the calls in a batch sent to one member.
+type poolBatchShare struct {
+	member *PoolMember
+	calls  []*poolBatchCall
+}
+
+// assign picks the members to send the batch's calls to. Shares are ordered
+// by their earliest call, and members' pending counts include the calls
+// assigned to them so that BatchSplit balances calls as they're assigned.
+func (b *PoolBatch) assign() ([]*poolBatchShare, error) {
+	members := b.pool.currentMembers()
+
+	if b.pool.batchPolicy != BatchSplit {
+		m := b.pool.balancer.Pick(members)
+		if m == nil {
+			return nil, ErrNoMembers
+		}
+		m.pending.Add(int64(len(b.calls)))
+		return []*poolBatchShare{{member: m, calls: b.calls}}, nil
+	}
+
+	var (
+		shares   []*poolBatchShare
+		byMember = make(map[*PoolMember]*poolBatchShare)
+	)
+	for _, call := range b.calls {
+		m := b.pool.balancer.Pick(members)
+		if m == nil {
+			for _, s := range shares {
+				s.member.pending.Sub(int64(len(s.calls)))
+			}
+			return nil, ErrNoMembers
+		}
+		m.pending.Inc()
+
+		s, ok := byMember[m]
+		if !ok {
+			s = &poolBatchShare{member: m}
+			byMember[m] = s
+			shares = append(shares, s)
+		}
+		s.calls = append(s.calls, call)
+	}
+	return shares, nil
+}
+
+// sendPoolBatch sends calls to conn, as a batch if conn supports batching
+// and otherwise one at a time.
+func sendPoolBatch(ctx context.Context, conn Conn, calls []*poolBatchCall) error {
+	b, ok := newBatch(conn)
+	if !ok {
+		var firstErr error
+		for _, call := range calls {
+			var err error
+			if call.result == nil {
+				err = conn.Notify(call.method, call.params)
+			} else {
+				*call.result, err = conn.Invoke(ctx, call.method, call.params)
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	results := make([]*json.RawMessage, len(calls))
+	for i, call := range calls {
+		var err error
+		if call.result == nil {
+			err = b.Notify(call.method, call.params)
+		} else {
+			results[i], err = b.Invoke(call.method, call.params)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err := b.Commit(ctx)
+	for i, res := range results {
+		if res != nil {
+			*calls[i].result = *res
+		}
+	}
+	return err
+}
+
+// batch is implemented by Batch and PoolBatch.
+type batch interface {
+	Notify(method string, msg interface{}) error
+	Invoke(method string, msg interface{}) (*json.RawMessage, error)
+	Commit(ctx context.Context) error
+}
+
+// newBatch creates a batch for conn, and reports whether conn supports
+// batching.
+func newBatch(conn Conn) (batch, bool) {
+	switch conn := conn.(type) {
+	case *Client:
+		return conn.Batch(), true
+	case *Pool:
+		return conn.Batch(), true
+	}
+	return nil, false
+}
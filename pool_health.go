@@ -0,0 +1,143 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Backoff between attempts to redial an evicted member.
+const (
+	minRedialBackoff = 100 * time.Millisecond
+	maxRedialBackoff = 30 * time.Second
+)
+
+// WithEviction removes members from the pool once they stop working: when
+// their Conn closes, for Conns with a Done method such as *Client, or when
+// maxFailures calls in a row fail without a response. If maxFailures is 0,
+// members are only removed when their Conn closes. Removed members are
+// closed if their Conn implements io.Closer, and redialed if they were added
+// with Dial. Members aren't evicted by default.
+func WithEviction(maxFailures int) PoolOpt {
+	return func(p *Pool) {
+		p.evict = true
+		if maxFailures > 0 {
+			p.maxFailures = int64(maxFailures)
+		}
+	}
+}
+
+// WithDialer sets the function used by Dial to connect to addresses.
+func WithDialer(dial func(ctx context.Context, addr string) (Conn, error)) PoolOpt {
+	return func(p *Pool) {
+		p.dialer = dial
+	}
+}
+
+// DialPool dials n connections to addr and returns a Pool of them. Members
+// which are evicted, as set by WithEviction, are redialed until the pool is
+// closed.
+func DialPool(ctx context.Context, addr string, n int, dial func(ctx context.Context, addr string) (Conn, error), opts ...PoolOpt) (*Pool, error) {
+	p := NewPool(nil, append(opts, WithDialer(dial))...)
+	for i := 0; i < n; i++ {
+		if _, err := p.Dial(ctx, addr); err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Dial connects to addr with the pool's dialer, set by WithDialer, and adds
+// the connection to the pool. If the member is evicted, the pool redials
+// addr in the background, with backoff, until it succeeds or the pool is
+// closed. Dialed members are left alone by Resolve.
+func (p *Pool) Dial(ctx context.Context, addr string) (*PoolMember, error) {
+	if p.dialer == nil {
+		return nil, errors.New("jsonrpc2: pool has no dialer")
+	}
+	conn, err := p.dialer(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing %s: %w", addr, err)
+	}
+	return p.add(&PoolMember{Conn: conn, Addr: addr, redial: true, pool: p}), nil
+}
+
+// Close stops redialing evicted members, and removes and closes the pool's
+// members whose Conn implements io.Closer.
+func (p *Pool) Close() error {
+	p.cancel()
+
+	var firstErr error
+	for _, m := range p.currentMembers() {
+		if !p.Remove(m) {
+			continue
+		}
+		if c, ok := m.Conn.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// watch evicts m once its Conn closes. It stops watching when m is removed
+// from the pool.
+func (p *Pool) watch(m *PoolMember) {
+	d, ok := m.Conn.(interface{ Done() <-chan struct{} })
+	if !ok {
+		return
+	}
+	go func() {
+		select {
+		case <-d.Done():
+			p.evictMember(m)
+		case <-m.removed:
+		}
+	}()
+}
+
+// evictMember removes m from the pool, closes it, and starts redialing its
+// address if it was added with Dial.
+func (p *Pool) evictMember(m *PoolMember) {
+	if !p.Remove(m) {
+		return
+	}
+	if c, ok := m.Conn.(io.Closer); ok {
+		_ = c.Close()
+	}
+	if m.redial && p.ctx.Err() == nil {
+		go p.redial(m.Addr)
+	}
+}
+
+// redial dials addr until it succeeds, waiting longer after each failure,
+// and adds the connection to the pool.
+func (p *Pool) redial(addr string) {
+	backoff := minRedialBackoff
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.clock.After(backoff):
+		}
+
+		conn, err := p.dialer(p.ctx, addr)
+		if err == nil {
+			p.add(&PoolMember{Conn: conn, Addr: addr, redial: true, pool: p})
+			// Close may have missed the new member.
+			if p.ctx.Err() != nil {
+				_ = p.Close()
+			}
+			return
+		}
+
+		backoff *= 2
+		if backoff > maxRedialBackoff {
+			backoff = maxRedialBackoff
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// stubConn is a Conn which answers every call after delay.
+type stubConn struct {
+	delay time.Duration
+	err   error
+	calls atomic.Int64
+}
+
+func (c *stubConn) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	c.calls.Inc()
+	time.Sleep(c.delay)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return json.RawMessage(`true`), nil
+}
+
+func (c *stubConn) Notify(method string, msg interface{}) error {
+	c.calls.Inc()
+	return c.err
+}
+
+// manualClock is a Clock whose time only changes when advanced.
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time                         { return c.now }
+func (c *manualClock) After(d time.Duration) <-chan time.Time { panic("not implemented") }
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) Timer {
+	panic("not implemented")
+}
+
+func TestPool_PrefersFasterMember(t *testing.T) {
+	fast, slow := &stubConn{}, &stubConn{delay: 20 * time.Millisecond}
+	p := NewPool([]Conn{slow, fast})
+
+	for i := 0; i < 20; i++ {
+		_, err := p.Invoke(context.Background(), "call", nil)
+		require.NoError(t, err)
+	}
+
+	// Each member is called until it has been measured, after which the
+	// faster member is always picked.
+	require.LessOrEqual(t, slow.calls.Load(), int64(1))
+	require.Equal(t, int64(20), fast.calls.Load()+slow.calls.Load())
+}
+
+func TestPoolMember_Observe(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	p := NewPool([]Conn{&stubConn{}}, WithPoolClock(clock), WithLatencyDecay(time.Second))
+	m := p.Members()[0]
+
+	m.observe(100*time.Millisecond, nil)
+	require.Equal(t, 100*time.Millisecond, m.Latency())
+
+	// A measurement right after the last one barely moves the average.
+	clock.now = clock.now.Add(time.Millisecond)
+	m.observe(10*time.Millisecond, nil)
+	require.InDelta(t, float64(100*time.Millisecond), float64(m.Latency()), float64(time.Millisecond))
+
+	// After a long gap, the new measurement dominates.
+	clock.now = clock.now.Add(10 * time.Second)
+	m.observe(10*time.Millisecond, nil)
+	require.InDelta(t, float64(10*time.Millisecond), float64(m.Latency()), float64(time.Millisecond))
+
+	// Failures without a response are penalized, but RPC errors are not.
+	clock.now = clock.now.Add(10 * time.Second)
+	m.observe(10*time.Millisecond, Error{Code: ErrorInternal})
+	require.InDelta(t, float64(10*time.Millisecond), float64(m.Latency()), float64(time.Millisecond))
+	clock.now = clock.now.Add(10 * time.Second)
+	m.observe(10*time.Millisecond, errors.New("broken pipe"))
+	require.InDelta(t, float64(failurePenalty), float64(m.Latency()), float64(time.Millisecond))
+}
+
+func TestLatencyBalancer_Pending(t *testing.T) {
+	p := NewPool([]Conn{&stubConn{}, &stubConn{}})
+	fast, slow := p.Members()[0], p.Members()[1]
+	fast.observe(10*time.Millisecond, nil)
+	slow.observe(50*time.Millisecond, nil)
+
+	var b LatencyBalancer
+	require.Equal(t, fast, b.Pick(p.Members()))
+
+	// Enough pending calls on the faster member make the slower one cheaper.
+	fast.pending.Store(10)
+	require.Equal(t, slow, b.Pick(p.Members()))
+
+	require.Nil(t, b.Pick(nil))
+}
+
+func TestPool_Empty(t *testing.T) {
+	p := NewPool(nil)
+	_, err := p.Invoke(context.Background(), "call", nil)
+	require.ErrorIs(t, err, ErrNoMembers)
+	require.ErrorIs(t, p.Notify("call", nil), ErrNoMembers)
+}
+
+// newBatchCountingClient returns a Client whose server answers each call with
+// its method, and counts the batches it receives.
+func newBatchCountingClient(t *testing.T, batches *atomic.Int64) *Client {
+	t.Helper()
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method == "fail" {
+			_ = w.WriteError(ErrorInternal, errors.New("failed"))
+			return
+		}
+		_ = w.WriteMessage(r.Method)
+	}))
+	t.Cleanup(func() { _ = srv.Close() })
+	cli := NewClient(cliConn, nil, WithClientInterceptor(func(ctx context.Context, call *ClientCall, invoker Invoker) (json.RawMessage, error) {
+		if call.Batch != nil {
+			batches.Inc()
+		}
+		return invoker(ctx, call)
+	}))
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func TestPoolBatch_Pin(t *testing.T) {
+	var batches [2]atomic.Int64
+	p := NewPool([]Conn{newBatchCountingClient(t, &batches[0]), newBatchCountingClient(t, &batches[1])})
+
+	b := p.Batch()
+	a, err := b.Invoke("a", nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Notify("n", nil))
+	c, err := b.Invoke("c", nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Commit(context.Background()))
+
+	require.Equal(t, `"a"`, string(*a))
+	require.Equal(t, `"c"`, string(*c))
+	require.Equal(t, int64(1), batches[0].Load()+batches[1].Load())
+	for _, m := range p.Members() {
+		require.Zero(t, m.Pending())
+	}
+}
+
+func TestPoolBatch_Split(t *testing.T) {
+	var batches [2]atomic.Int64
+	p := NewPool(
+		[]Conn{newBatchCountingClient(t, &batches[0]), newBatchCountingClient(t, &batches[1])},
+		WithBalancer(&RoundRobinBalancer{}),
+		WithBatchPolicy(BatchSplit),
+	)
+
+	b := p.Batch()
+	var results []*json.RawMessage
+	for _, method := range []string{"a", "b", "c", "d"} {
+		res, err := b.Invoke(method, nil)
+		require.NoError(t, err)
+		results = append(results, res)
+	}
+	require.NoError(t, b.Commit(context.Background()))
+
+	for i, method := range []string{"a", "b", "c", "d"} {
+		require.Equal(t, `"`+method+`"`, string(*results[i]))
+	}
+	require.Equal(t, int64(1), batches[0].Load())
+	require.Equal(t, int64(1), batches[1].Load())
+
+	// Errors from any member are returned.
+	b = p.Batch()
+	_, err := b.Invoke("ok", nil)
+	require.NoError(t, err)
+	_, err = b.Invoke("fail", nil)
+	require.NoError(t, err)
+	var rpcErr Error
+	require.True(t, errors.As(b.Commit(context.Background()), &rpcErr))
+	require.Equal(t, ErrorInternal, rpcErr.Code)
+}
+
+func TestPoolBatch_UnbatchedMember(t *testing.T) {
+	conn := &stubConn{}
+	p := NewPool([]Conn{conn})
+
+	b := p.Batch()
+	res, err := b.Invoke("call", nil)
+	require.NoError(t, err)
+	require.NoError(t, b.Notify("note", nil))
+	require.NoError(t, b.Commit(context.Background()))
+	require.Equal(t, "true", string(*res))
+	require.Equal(t, int64(2), conn.calls.Load())
+
+	require.NoError(t, NewPool(nil).Batch().Commit(context.Background()))
+	b = NewPool(nil).Batch()
+	require.NoError(t, b.Notify("note", nil))
+	require.ErrorIs(t, b.Commit(context.Background()), ErrNoMembers)
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	p := NewPool([]Conn{&stubConn{}, &stubConn{}, &stubConn{}})
+	members := p.Members()
+
+	var b RoundRobinBalancer
+	for i := 0; i < 6; i++ {
+		require.Equal(t, members[i%3], b.Pick(members))
+	}
+	require.Nil(t, b.Pick(nil))
+}
+
+func TestLeastPendingBalancer(t *testing.T) {
+	p := NewPool([]Conn{&stubConn{}, &stubConn{}, &stubConn{}})
+	members := p.Members()
+	members[0].pending.Store(2)
+	members[1].pending.Store(1)
+	members[2].pending.Store(1)
+
+	// Ties are taken in turn.
+	var b LeastPendingBalancer
+	seen := map[*PoolMember]bool{}
+	for i := 0; i < 3; i++ {
+		m := b.Pick(members)
+		require.NotEqual(t, members[0], m)
+		seen[m] = true
+	}
+	require.Len(t, seen, 2)
+	require.Nil(t, b.Pick(nil))
+}
+
+func TestPool_EvictsFailingMembers(t *testing.T) {
+	failing := &stubConn{err: errors.New("broken pipe")}
+	busy := &stubConn{err: Error{Code: ErrorServerBusy, Message: "busy"}}
+	p := NewPool([]Conn{failing, busy}, WithBalancer(&RoundRobinBalancer{}), WithEviction(2))
+
+	for i := 0; i < 4; i++ {
+		_, _ = p.Invoke(context.Background(), "call", nil)
+	}
+	// Error responses don't count as failures.
+	members := p.Members()
+	require.Len(t, members, 1)
+	require.Equal(t, busy, members[0].Conn)
+}
+
+func TestDialPool(t *testing.T) {
+	var dials atomic.Int64
+	dial := func(ctx context.Context, addr string) (Conn, error) {
+		dials.Inc()
+		srvConn, cliConn := net.Pipe()
+		srv := NewClient(srvConn, EchoHandler)
+		t.Cleanup(func() { _ = srv.Close() })
+		return NewClient(cliConn, nil), nil
+	}
+
+	p, err := DialPool(context.Background(), "backend", 2, dial, WithEviction(0))
+	require.NoError(t, err)
+	members := p.Members()
+	require.Len(t, members, 2)
+	require.Equal(t, "backend", members[0].Addr)
+
+	// Closed members are evicted and redialed.
+	require.NoError(t, members[0].Conn.(*Client).Close())
+	require.Eventually(t, func() bool {
+		current := p.Members()
+		return dials.Load() == 3 && len(current) == 2 && current[0] != members[0] && current[1] != members[0]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = p.Invoke(context.Background(), MethodEcho, nil)
+	require.NoError(t, err)
+
+	// Members dialed by the pool aren't managed by Resolve.
+	require.NoError(t, p.Resolve(context.Background(), StaticResolver(nil), dial))
+	require.Len(t, p.Members(), 2)
+
+	require.NoError(t, p.Close())
+	require.Empty(t, p.Members())
+	for _, m := range members[1:] {
+		select {
+		case <-m.Conn.(*Client).Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("member not closed")
+		}
+	}
+}
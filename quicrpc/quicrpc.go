@@ -0,0 +1,103 @@
+// Package quicrpc runs JSON-RPC 2.0 over the streams of a multiplexed
+// transport such as QUIC, either with one stream for the whole connection
+// or with a new stream for each call.
+//
+// quicrpc doesn't depend on a QUIC implementation. Streams are opened and
+// accepted through functions, which adapt a quic-go connection in a line:
+//
+//	open := func(ctx context.Context) (io.ReadWriteCloser, error) {
+//		return qconn.OpenStreamSync(ctx)
+//	}
+//	accept := func(ctx context.Context) (io.ReadWriteCloser, error) {
+//		return qconn.AcceptStream(ctx)
+//	}
+//
+// A stream per connection behaves like a TCP connection and allows both
+// sides to call each other. A stream per call avoids head-of-line blocking
+// between calls on lossy links, at the cost of opening a stream for each
+// call.
+package quicrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// OpenFunc opens a new outgoing stream.
+type OpenFunc func(ctx context.Context) (io.ReadWriteCloser, error)
+
+// AcceptFunc waits for the peer to open a stream and returns it.
+type AcceptFunc func(ctx context.Context) (io.ReadWriteCloser, error)
+
+// NewClient opens a single stream and returns a Client using it for every
+// call, in both directions. handler will be invoked for each request
+// received over the stream.
+func NewClient(ctx context.Context, open OpenFunc, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) (*jsonrpc2.Client, error) {
+	s, err := open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	return jsonrpc2.NewClient(s, handler, opts...), nil
+}
+
+// CallConn is a jsonrpc2.Conn which opens a new stream for each call and
+// closes it once the call completes. The server can't call back over a
+// CallConn.
+type CallConn struct {
+	open OpenFunc
+	opts []jsonrpc2.ClientOpt
+}
+
+var _ jsonrpc2.Conn = (*CallConn)(nil)
+
+// NewCallConn returns a CallConn which opens streams with open. opts are
+// passed to the Client created for each stream.
+func NewCallConn(open OpenFunc, opts ...jsonrpc2.ClientOpt) *CallConn {
+	return &CallConn{open: open, opts: opts}
+}
+
+// Invoke opens a stream, invokes an RPC over it, and closes the stream once
+// the response is received.
+func (c *CallConn) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	cli, err := NewClient(ctx, c.open, nil, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	return cli.Invoke(ctx, method, msg)
+}
+
+// Notify opens a stream, sends a notification over it, and closes the
+// stream.
+func (c *CallConn) Notify(method string, msg interface{}) error {
+	cli, err := NewClient(context.Background(), c.open, nil, c.opts...)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	return cli.Notify(method, msg)
+}
+
+// Serve accepts streams until accept fails or ctx is done, serving each
+// stream with handler. Serve works with peers using either NewClient or a
+// CallConn. The error from accept is returned.
+func Serve(ctx context.Context, accept AcceptFunc, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) error {
+	for {
+		s, err := accept(ctx)
+		if err != nil {
+			return err
+		}
+		cli := jsonrpc2.NewClient(s, handler, opts...)
+		go func() {
+			select {
+			case <-cli.Done():
+			case <-ctx.Done():
+				_ = cli.Close()
+			}
+		}()
+	}
+}
@@ -0,0 +1,81 @@
+package quicrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// fakeTransport hands streams opened by one side to the other, like a QUIC
+// connection.
+type fakeTransport struct {
+	streams chan net.Conn
+	opened  atomic.Int64
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{streams: make(chan net.Conn)}
+}
+
+func (t *fakeTransport) open(ctx context.Context) (io.ReadWriteCloser, error) {
+	local, remote := net.Pipe()
+	select {
+	case t.streams <- remote:
+		t.opened.Inc()
+		return local, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *fakeTransport) accept(ctx context.Context) (io.ReadWriteCloser, error) {
+	select {
+	case s := <-t.streams:
+		return s, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func serveEcho(t *testing.T, tr *fakeTransport) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go Serve(ctx, tr.accept, jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Params)
+	}))
+}
+
+func TestNewClient(t *testing.T) {
+	tr := newFakeTransport()
+	serveEcho(t, tr)
+
+	cli, err := NewClient(context.Background(), tr.open, nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	for i := 0; i < 3; i++ {
+		res, err := cli.Invoke(context.Background(), "echo", i)
+		require.NoError(t, err)
+		require.JSONEq(t, string(rune('0'+i)), string(res))
+	}
+	require.Equal(t, int64(1), tr.opened.Load())
+}
+
+func TestCallConn(t *testing.T) {
+	tr := newFakeTransport()
+	serveEcho(t, tr)
+
+	conn := NewCallConn(tr.open)
+	for i := 0; i < 3; i++ {
+		res, err := conn.Invoke(context.Background(), "echo", "hello")
+		require.NoError(t, err)
+		require.JSONEq(t, `"hello"`, string(res))
+	}
+	require.Equal(t, int64(3), tr.opened.Load())
+}
@@ -0,0 +1,156 @@
+package jsonrpc2
+
+import (
+	"sync"
+	"time"
+)
+
+// MethodLimitWarning is the method of the notification sent to warn callers
+// that they are approaching a limit, so they can back off before requests
+// are rejected. Its params are a LimitWarning.
+const MethodLimitWarning = "rpc.limitWarning"
+
+// LimitWarning is sent as the params of a MethodLimitWarning notification.
+type LimitWarning struct {
+	// Limit names the limit, such as "requests".
+	Limit string `json:"limit"`
+
+	// Usage is the caller's current usage, which has reached Soft. Requests
+	// are rejected once usage exceeds Hard.
+	Usage int64 `json:"usage"`
+	Soft  int64 `json:"soft"`
+	Hard  int64 `json:"hard"`
+
+	// ResetAfterMs is how long until usage resets, if it does.
+	ResetAfterMs int64 `json:"resetAfterMs,omitempty"`
+}
+
+// SendLimitWarning sends warning to the peer of c as a MethodLimitWarning
+// notification. Quota sends warnings itself; SendLimitWarning is for
+// warning about other limits enforced by the application.
+func SendLimitWarning(c *Client, warning LimitWarning) error {
+	return c.Notify(MethodLimitWarning, warning)
+}
+
+// DefaultQuotaName is the name of a Quota's limit when Quota.Name is empty.
+const DefaultQuotaName = "requests"
+
+// Quota is a Handler which limits how many requests each connection may
+// make per window before passing them to Handler. Requests over the limit
+// are rejected with a busy error asking the caller to retry once the window
+// resets, and notifications over the limit are dropped.
+//
+// If Warn is set, callers are also sent a MethodLimitWarning notification
+// once per window, before the response to the request which reaches Warn,
+// so they can slow down before being rejected. Callers can handle it to
+// back off:
+//
+//	mux.HandleFunc(jsonrpc2.MethodLimitWarning, func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+//		var warning jsonrpc2.LimitWarning
+//		if err := r.DecodeParams(&warning); err == nil {
+//			limiter.SlowDown(time.Duration(warning.ResetAfterMs) * time.Millisecond)
+//		}
+//	})
+//
+// Requests are counted per Request.Client, so requests without a
+// connection aren't limited.
+type Quota struct {
+	// Handler handles requests within the limit.
+	Handler Handler
+
+	// Name names the limit in warnings. If empty, DefaultQuotaName is used.
+	Name string
+
+	// Limit is the maximum number of requests, including notifications, a
+	// connection may make per Window.
+	Limit int64
+
+	// Window is how often usage resets. If zero, usage never resets, so
+	// Limit applies to the lifetime of each connection and requests over
+	// it are rejected with a busy error without a retry delay.
+	Window time.Duration
+
+	// Warn is the soft limit at which callers are sent a MethodLimitWarning
+	// notification. If zero, or not less than Limit, no warnings are sent.
+	Warn int64
+
+	// Clock is used to measure windows. If nil, SystemClock is used.
+	Clock Clock
+
+	mut   sync.Mutex
+	usage map[*Client]*quotaUsage
+}
+
+// quotaUsage is a connection's usage in the current window.
+type quotaUsage struct {
+	start time.Time
+	count int64
+}
+
+// ServeRPC implements Handler.
+func (q *Quota) ServeRPC(w ResponseWriter, r *Request) {
+	if r.Client == nil {
+		q.Handler.ServeRPC(w, r)
+		return
+	}
+
+	clock := q.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	now := clock.Now()
+
+	q.mut.Lock()
+	if q.usage == nil {
+		q.usage = make(map[*Client]*quotaUsage)
+	}
+	u, ok := q.usage[r.Client]
+	if !ok {
+		u = &quotaUsage{start: now}
+		q.usage[r.Client] = u
+		go q.forget(r.Client)
+	}
+	var resetAfter time.Duration
+	if q.Window > 0 {
+		if now.Sub(u.start) >= q.Window {
+			u.start, u.count = now, 0
+		}
+		resetAfter = q.Window - now.Sub(u.start)
+	}
+	u.count++
+	count := u.count
+	q.mut.Unlock()
+
+	if count > q.Limit {
+		if !r.Notification {
+			busy := Error{Code: ErrorServerBusy, Message: "quota exceeded"}
+			if resetAfter > 0 {
+				busy = NewBusyError(resetAfter)
+			}
+			_ = w.WriteError(ErrorServerBusy, busy)
+		}
+		return
+	}
+	if q.Warn > 0 && q.Warn < q.Limit && count == q.Warn {
+		name := q.Name
+		if name == "" {
+			name = DefaultQuotaName
+		}
+		_ = SendLimitWarning(r.Client, LimitWarning{
+			Limit:        name,
+			Usage:        count,
+			Soft:         q.Warn,
+			Hard:         q.Limit,
+			ResetAfterMs: int64(resetAfter / time.Millisecond),
+		})
+	}
+	q.Handler.ServeRPC(w, r)
+}
+
+// forget removes the usage of c once it closes.
+func (q *Quota) forget(c *Client) {
+	<-c.Done()
+	q.mut.Lock()
+	delete(q.usage, c)
+	q.mut.Unlock()
+}
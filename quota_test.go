@@ -0,0 +1,105 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	quota := &Quota{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage(true) }),
+		Limit:   3,
+		Warn:    2,
+		Window:  time.Minute,
+		Clock:   clock,
+	}
+
+	warnings := make(chan LimitWarning, 2)
+	cliMux := NewServeMux()
+	cliMux.HandleFunc(MethodLimitWarning, func(w ResponseWriter, r *Request) {
+		var warning LimitWarning
+		if err := r.DecodeParams(&warning); err == nil {
+			warnings <- warning
+		}
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, quota)
+	defer srv.Close()
+	cli := NewClient(cliConn, cliMux)
+	defer cli.Close()
+
+	ctx := context.Background()
+	_, err := cli.Invoke(ctx, "work", nil)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	// Reaching the soft limit sends a warning before the response.
+	clock.now = clock.now.Add(15 * time.Second)
+	_, err = cli.Invoke(ctx, "work", nil)
+	require.NoError(t, err)
+	require.Equal(t, LimitWarning{Limit: DefaultQuotaName, Usage: 2, Soft: 2, Hard: 3, ResetAfterMs: 45000}, <-warnings)
+
+	_, err = cli.Invoke(ctx, "work", nil)
+	require.NoError(t, err)
+
+	// Requests over the hard limit are rejected until the window resets.
+	_, err = cli.Invoke(ctx, "work", nil)
+	d, busy := RetryAfter(err)
+	require.True(t, busy)
+	require.Equal(t, 45*time.Second, d)
+
+	clock.now = clock.now.Add(45 * time.Second)
+	_, err = cli.Invoke(ctx, "work", nil)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+func TestQuota_NoWindow(t *testing.T) {
+	quota := &Quota{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage(true) }),
+		Limit:   1,
+	}
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, quota)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	_, err := cli.Invoke(context.Background(), "work", nil)
+	require.NoError(t, err)
+
+	_, err = cli.Invoke(context.Background(), "work", nil)
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorServerBusy, rpcErr.Code)
+	_, ok := RetryAfter(err)
+	require.False(t, ok)
+}
+
+func TestQuota_ForgetsClosedClients(t *testing.T) {
+	quota := &Quota{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage(true) }),
+		Limit:   1,
+	}
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, quota)
+	cli := NewClient(cliConn, nil)
+	_, err := cli.Invoke(context.Background(), "work", nil)
+	require.NoError(t, err)
+	require.NoError(t, cli.Close())
+	<-srv.Done()
+
+	require.Eventually(t, func() bool {
+		quota.mut.Lock()
+		defer quota.mut.Unlock()
+		return len(quota.usage) == 0
+	}, time.Second, 10*time.Millisecond)
+}
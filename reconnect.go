@@ -0,0 +1,300 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DialFunc creates a new Client, such as by calling Dial.
+type DialFunc func(ctx context.Context) (*Client, error)
+
+// ReconnectPolicy decides what happens to calls made while a
+// ReconnectingClient is disconnected.
+type ReconnectPolicy int
+
+const (
+	// FailWhileDisconnected fails calls made while disconnected with
+	// ErrConnClosed.
+	FailWhileDisconnected ReconnectPolicy = iota
+
+	// WaitForReconnect makes calls made while disconnected wait until the
+	// connection is re-established or their context is done.
+	WaitForReconnect
+)
+
+// ReconnectOpt is an option function that can be passed to
+// NewReconnectingClient.
+type ReconnectOpt func(*ReconnectingClient)
+
+// WithReconnectPolicy sets what happens to calls made while disconnected.
+// The default is FailWhileDisconnected.
+func WithReconnectPolicy(p ReconnectPolicy) ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		rc.policy = p
+	}
+}
+
+// WithReconnectBackoff sets the minimum and maximum delay between dial
+// attempts. The delay doubles after each failed dial or short-lived
+// connection, and is reset once a connection stays up for at least max.
+// Defaults to 100ms and 30s.
+func WithReconnectBackoff(min, max time.Duration) ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		rc.minBackoff, rc.maxBackoff = min, max
+	}
+}
+
+// WithReconnectJitter sets how much delays between dial attempts are
+// randomized, as a fraction of the delay. With a jitter of 0.2, a delay of
+// 1s is randomized between 0.8s and 1.2s. The default is 0.2.
+func WithReconnectJitter(jitter float64) ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		if jitter >= 0 && jitter <= 1 {
+			rc.jitter = jitter
+		}
+	}
+}
+
+// WithReconnectLogger sets the ReconnectingClient to use a logger.
+func WithReconnectLogger(l Logger) ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		if l != nil {
+			rc.log = l
+		}
+	}
+}
+
+// WithReconnectClock sets the Clock used to wait between dial attempts.
+func WithReconnectClock(clock Clock) ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		if clock != nil {
+			rc.clock = clock
+		}
+	}
+}
+
+// WithReconnectStateListener sets a function to call when the
+// ReconnectingClient changes state. It moves between StateConnecting and
+// StateReady as connections are lost and re-established, and ends in
+// StateClosed once closed. f is called from a single goroutine and must not
+// block.
+func WithReconnectStateListener(f func(from, to ConnState)) ReconnectOpt {
+	return func(rc *ReconnectingClient) {
+		rc.stateListener = f
+	}
+}
+
+// ReconnectingClient is a Conn which keeps a Client connected, dialing a new
+// one whenever the current Client closes.
+//
+// Calls which are waiting for a response when the connection is lost fail
+// with ErrConnClosed and are not retried, since the server may have handled
+// them. Calls made while disconnected fail or wait for the connection to be
+// re-established, depending on the ReconnectPolicy.
+type ReconnectingClient struct {
+	log        Logger
+	dial       DialFunc
+	policy     ReconnectPolicy
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+	clock      Clock
+
+	stateListener func(from, to ConnState)
+	affinity      bool
+
+	mut           sync.Mutex
+	affinityToken string
+	state         ConnState
+	cli           *Client
+	changed       chan struct{} // Closed and replaced when cli changes.
+	closed        bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ Conn = (*ReconnectingClient)(nil)
+
+// NewReconnectingClient creates a ReconnectingClient and starts dialing in
+// the background.
+func NewReconnectingClient(dial DialFunc, opts ...ReconnectOpt) *ReconnectingClient {
+	rc := &ReconnectingClient{
+		log:        NewNopLogger(),
+		dial:       dial,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		jitter:     0.2,
+		clock:      SystemClock,
+
+		changed: make(chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(rc)
+	}
+	go rc.run()
+	return rc
+}
+
+// Client returns the current Client. If disconnected, Client fails with
+// ErrConnClosed or waits for a new connection, depending on the
+// ReconnectPolicy.
+func (rc *ReconnectingClient) Client(ctx context.Context) (*Client, error) {
+	for {
+		rc.mut.Lock()
+		cli, closed, changed := rc.cli, rc.closed, rc.changed
+		rc.mut.Unlock()
+
+		if closed {
+			return nil, ErrConnClosed
+		}
+		if cli != nil {
+			select {
+			case <-cli.Done():
+				// The connection closed but run hasn't noticed yet.
+			default:
+				return cli, nil
+			}
+		}
+		if rc.policy == FailWhileDisconnected {
+			return nil, ErrConnClosed
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Invoke invokes an RPC over the current connection.
+func (rc *ReconnectingClient) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	cli, err := rc.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cli.Invoke(ctx, method, msg)
+}
+
+// Notify sends a notification over the current connection. Notify never
+// waits for a connection; ErrConnClosed is returned while disconnected.
+func (rc *ReconnectingClient) Notify(method string, msg interface{}) error {
+	rc.mut.Lock()
+	cli := rc.cli
+	rc.mut.Unlock()
+
+	if cli == nil {
+		return ErrConnClosed
+	}
+	return cli.Notify(method, msg)
+}
+
+// Close closes the current connection and stops reconnecting.
+func (rc *ReconnectingClient) Close() error {
+	rc.mut.Lock()
+	if !rc.closed {
+		rc.closed = true
+		close(rc.stop)
+	}
+	rc.mut.Unlock()
+
+	<-rc.done
+	return nil
+}
+
+// Done returns a channel that is closed once the ReconnectingClient has been
+// closed.
+func (rc *ReconnectingClient) Done() <-chan struct{} {
+	return rc.done
+}
+
+// State returns the current state of the ReconnectingClient.
+func (rc *ReconnectingClient) State() ConnState {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+	return rc.state
+}
+
+// setClient sets the current Client and moves to state. It must only be
+// called by run, so that the state listener is called in order.
+func (rc *ReconnectingClient) setClient(cli *Client, state ConnState) {
+	rc.mut.Lock()
+	from := rc.state
+	rc.state = state
+	rc.cli = cli
+	close(rc.changed)
+	rc.changed = make(chan struct{})
+	rc.mut.Unlock()
+
+	if rc.stateListener != nil && from != state {
+		rc.stateListener(from, state)
+	}
+}
+
+func (rc *ReconnectingClient) run() {
+	defer close(rc.done)
+	defer rc.setClient(nil, StateClosed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-rc.stop
+		cancel()
+	}()
+
+	backoff := rc.minBackoff
+	for {
+		dialCtx := ctx
+		if token := rc.AffinityToken(); token != "" {
+			dialCtx = WithAffinityToken(ctx, token)
+		}
+		cli, err := rc.dial(dialCtx)
+		if err == nil {
+			connected := rc.clock.Now()
+			rc.setClient(cli, StateReady)
+			if rc.affinity {
+				rc.fetchAffinityToken(ctx, cli)
+			}
+
+			select {
+			case <-rc.stop:
+				_ = cli.Close()
+				return
+			case <-cli.Done():
+			}
+			rc.setClient(nil, StateConnecting)
+
+			if rc.clock.Now().Sub(connected) >= rc.maxBackoff {
+				backoff = rc.minBackoff
+			}
+			LevelWarn.Log(rc.log, "msg", "connection lost, reconnecting", "backoff", backoff)
+		} else {
+			LevelWarn.Log(rc.log, "msg", "failed to dial, retrying", "err", err, "backoff", backoff)
+		}
+
+		select {
+		case <-rc.stop:
+			return
+		case <-rc.clock.After(rc.withJitter(backoff)):
+		}
+
+		backoff *= 2
+		if backoff > rc.maxBackoff {
+			backoff = rc.maxBackoff
+		}
+	}
+}
+
+// withJitter randomizes d by up to rc.jitter in either direction.
+func (rc *ReconnectingClient) withJitter(d time.Duration) time.Duration {
+	if rc.jitter == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + rc.jitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,97 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectingClient(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := NewServeMux()
+	mux.HandleFunc("ping", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("pong") })
+
+	var (
+		mut  sync.Mutex
+		clis []*Client
+	)
+	srv := &Server{Handler: mux, OnClient: func(c *Client) {
+		mut.Lock()
+		clis = append(clis, c)
+		mut.Unlock()
+	}}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	rc := NewReconnectingClient(func(ctx context.Context) (*Client, error) {
+		return Dial(lis.Addr().String(), nil)
+	}, WithReconnectPolicy(WaitForReconnect), WithReconnectBackoff(10*time.Millisecond, time.Second))
+	defer rc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := rc.Invoke(ctx, "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"pong"`, string(res))
+
+	old, err := rc.Client(ctx)
+	require.NoError(t, err)
+
+	// Drop the connection from the server side. Calls made once the client
+	// notices wait for the new connection.
+	require.Eventually(t, func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return len(clis) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	mut.Lock()
+	require.NoError(t, clis[0].Close())
+	mut.Unlock()
+	<-old.Done()
+
+	res, err = rc.Invoke(ctx, "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"pong"`, string(res))
+
+	require.NoError(t, rc.Close())
+	_, err = rc.Invoke(ctx, "ping", nil)
+	require.ErrorIs(t, err, ErrConnClosed)
+}
+
+func TestReconnectingClient_FailWhileDisconnected(t *testing.T) {
+	dials := make(chan struct{}, 10)
+	rc := NewReconnectingClient(func(ctx context.Context) (*Client, error) {
+		dials <- struct{}{}
+		return nil, ErrConnClosed
+	}, WithReconnectBackoff(time.Millisecond, 10*time.Millisecond))
+	defer rc.Close()
+
+	<-dials
+	_, err := rc.Invoke(context.Background(), "ping", nil)
+	require.ErrorIs(t, err, ErrConnClosed)
+
+	// Dialing is retried.
+	<-dials
+}
+
+func TestReconnectingClient_WaitTimeout(t *testing.T) {
+	rc := NewReconnectingClient(func(ctx context.Context) (*Client, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithReconnectPolicy(WaitForReconnect))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := rc.Invoke(ctx, "ping", nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// Close cancels the dial in progress.
+	require.NoError(t, rc.Close())
+}
@@ -0,0 +1,44 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"runtime/debug"
+)
+
+// Recover returns Middleware which recovers from panics in handlers.
+// Without it, a panicking handler crashes the process. The panic and its
+// stack trace are logged to logger, which may be nil, and requests which
+// expect a response are answered with ErrorInternal. The panic value isn't
+// sent to the caller.
+//
+// If the handler wrote a response before panicking, that response is sent.
+func Recover(logger Logger) Middleware {
+	if logger == nil {
+		logger = NewNopLogger()
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				LevelError.Log(logger, "msg", "handler panicked", "method", r.Method, "panic", v, "stack", string(debug.Stack()))
+				if !r.Notification {
+					_ = w.WriteError(ErrorInternal, errors.New("internal error"))
+				}
+			}()
+			next.ServeRPC(w, r)
+		})
+	}
+}
+
+// WithRecovery sets whether the Client recovers from panics in its handler,
+// as if the handler was wrapped with Recover using the Client's logger.
+// Servers can enable it for every connection through ClientOpts. Recovery
+// is disabled by default.
+func WithRecovery(enabled bool) ClientOpt {
+	return func(c *Client) {
+		c.recover = enabled
+	}
+}
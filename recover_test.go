@@ -0,0 +1,66 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	var (
+		mut    sync.Mutex
+		logged []map[interface{}]interface{}
+	)
+	logger := LoggerFunc(func(kv ...interface{}) error {
+		entry := make(map[interface{}]interface{})
+		for i := 0; i+1 < len(kv); i += 2 {
+			entry[kv[i]] = kv[i+1]
+		}
+		mut.Lock()
+		defer mut.Unlock()
+		logged = append(logged, entry)
+		return nil
+	})
+
+	h := Recover(logger)(HandlerFunc(func(w ResponseWriter, r *Request) {
+		panic("boom")
+	}))
+
+	reply, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "explode", "id": 1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "internal error"}, "id": 1}`, string(reply))
+
+	reply, err = ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "explode"}`))
+	require.NoError(t, err)
+	require.Nil(t, reply)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Len(t, logged, 2)
+	require.Equal(t, LevelError, logged[0][LevelKey])
+	require.Equal(t, "boom", logged[0]["panic"])
+	require.Equal(t, "explode", logged[0]["method"])
+	require.Contains(t, logged[0]["stack"], "recover_test.go")
+}
+
+func TestWithRecovery(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		panic("boom")
+	}), WithRecovery(true))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	_, err := cli.Invoke(context.Background(), "explode", nil)
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInternal, rpcErr.Code)
+
+	// The connection keeps working.
+	_, err = cli.Invoke(context.Background(), "explode", nil)
+	require.ErrorAs(t, err, &rpcErr)
+}
@@ -0,0 +1,104 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterService registers the exported methods of receiver which have the
+// form
+//
+//	func (t *T) MethodName(ctx context.Context, args *Args) (*Reply, error)
+//
+// as RPCs named name.MethodName, similar to net/rpc. Args and Reply may be
+// any types which can be decoded from and encoded to JSON, and needn't be
+// pointers. Exported methods of other forms are ignored.
+//
+// Each request's params are decoded into a new Args, and requests whose
+// params can't be decoded get an ErrorInvalidParams error without calling
+// the method. Params, replies, and errors are handled as with Method,
+// including jsonrpc tags on Args.
+//
+// RegisterService returns an error without registering anything if
+// receiver has no methods of the required form, or if any of its RPCs is
+// already registered.
+func (m *ServeMux) RegisterService(name string, receiver interface{}) error {
+	if name == "" {
+		return errors.New("jsonrpc2: service name is empty")
+	}
+	rv := reflect.ValueOf(receiver)
+	if !rv.IsValid() {
+		return fmt.Errorf("jsonrpc2: service %s has a nil receiver", name)
+	}
+
+	handlers := make(map[string]Handler)
+	rt := rv.Type()
+	for i := 0; i < rt.NumMethod(); i++ {
+		method := rt.Method(i)
+		if !method.IsExported() || !isServiceMethod(method.Type) {
+			continue
+		}
+		handlers[name+"."+method.Name] = serviceMethodHandler(rv.Method(i))
+	}
+	if len(handlers) == 0 {
+		return fmt.Errorf("jsonrpc2: service %s has no methods of the form func(context.Context, Args) (Reply, error)", name)
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	for method := range handlers {
+		if _, exist := m.routes[method]; exist {
+			return fmt.Errorf("jsonrpc2: method %s already registered", method)
+		}
+	}
+	for method, h := range handlers {
+		m.routes[method] = h
+		m.setTyped(method, h)
+	}
+	return nil
+}
+
+// isServiceMethod reports whether a method of type mt, which includes its
+// receiver, can be registered by RegisterService.
+func isServiceMethod(mt reflect.Type) bool {
+	return mt.NumIn() == 3 && mt.In(1) == typeOfContext &&
+		mt.NumOut() == 2 && mt.Out(1) == typeOfError
+}
+
+// serviceMethodHandler returns a TypedHandler which calls fn, a method
+// bound to its receiver.
+func serviceMethodHandler(fn reflect.Value) Handler {
+	argType := fn.Type().In(1)
+	isPtr := argType.Kind() == reflect.Ptr
+	if isPtr {
+		argType = argType.Elem()
+	}
+
+	h := HandlerFunc(func(w ResponseWriter, r *Request) {
+		args := reflect.New(argType)
+		if err := decodeTypedParams(r.Params, args.Interface()); err != nil {
+			if !r.Notification {
+				_ = w.WriteError(ErrorInvalidParams, err)
+			}
+			return
+		}
+		if !isPtr {
+			args = args.Elem()
+		}
+
+		out := fn.Call([]reflect.Value{reflect.ValueOf(r.Context()), args})
+		if r.Notification {
+			return
+		}
+		err, _ := out[1].Interface().(error)
+		writeResult(w, out[0].Interface(), err)
+	})
+	return typedHandler{Handler: h, params: fn.Type().In(1), result: fn.Type().Out(0)}
+}
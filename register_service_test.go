@@ -0,0 +1,90 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type arithArgs struct {
+	A, B int
+}
+
+type arithReply struct {
+	Result int
+}
+
+type arithService struct{}
+
+func (arithService) Multiply(ctx context.Context, args *arithArgs) (*arithReply, error) {
+	return &arithReply{Result: args.A * args.B}, nil
+}
+
+func (arithService) Divide(ctx context.Context, args arithArgs) (int, error) {
+	if args.B == 0 {
+		return 0, Error{Code: ErrorInvalidParams, Message: "divide by zero"}
+	}
+	return args.A / args.B, nil
+}
+
+func (arithService) Fail(ctx context.Context, args *arithArgs) (*arithReply, error) {
+	return nil, errors.New("failed")
+}
+
+// Helper doesn't have the form of a service method, so it isn't registered.
+func (arithService) Helper(a, b int) int { return a + b }
+
+func TestServeMux_RegisterService(t *testing.T) {
+	mux := NewServeMux()
+	require.NoError(t, mux.RegisterService("Arith", arithService{}))
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	res, err := cli.Invoke(ctx, "Arith.Multiply", arithArgs{A: 6, B: 7})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Result": 42}`, string(res))
+
+	res, err = cli.Invoke(ctx, "Arith.Divide", arithArgs{A: 6, B: 3})
+	require.NoError(t, err)
+	require.JSONEq(t, `2`, string(res))
+
+	var rpcErr Error
+	_, err = cli.Invoke(ctx, "Arith.Divide", arithArgs{A: 6})
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+	require.Equal(t, "divide by zero", rpcErr.Message)
+
+	_, err = cli.Invoke(ctx, "Arith.Multiply", "not args")
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+
+	_, err = cli.Invoke(ctx, "Arith.Fail", nil)
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInternal, rpcErr.Code)
+
+	_, err = cli.Invoke(ctx, "Arith.Helper", nil)
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorMethodNotFound, rpcErr.Code)
+}
+
+func TestServeMux_RegisterService_Errors(t *testing.T) {
+	mux := NewServeMux()
+	require.Error(t, mux.RegisterService("", arithService{}))
+	require.Error(t, mux.RegisterService("Nil", nil))
+	require.Error(t, mux.RegisterService("Empty", struct{}{}))
+
+	// Nothing is registered if any method conflicts.
+	mux.HandleFunc("Arith.Divide", func(w ResponseWriter, r *Request) {})
+	require.Error(t, mux.RegisterService("Arith", arithService{}))
+	_, ok := mux.Doc("Arith.Multiply")
+	require.False(t, ok)
+}
@@ -0,0 +1,79 @@
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Resolver looks up the addresses of equivalent backends, such as through
+// DNS or service discovery.
+type Resolver interface {
+	// Resolve returns the current addresses of the backends.
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// ResolverFunc is a function which implements Resolver.
+type ResolverFunc func(ctx context.Context) ([]string, error)
+
+// Resolve implements Resolver.
+func (f ResolverFunc) Resolve(ctx context.Context) ([]string, error) { return f(ctx) }
+
+// StaticResolver is a Resolver which always returns the same addresses.
+type StaticResolver []string
+
+// Resolve implements Resolver.
+func (r StaticResolver) Resolve(ctx context.Context) ([]string, error) { return r, nil }
+
+// Resolve updates the pool's members to match the addresses returned by r.
+// Addresses without a member are dialed with dial and added, and members
+// whose address is no longer returned are removed and closed if their Conn
+// implements io.Closer. Members added with Add, Dial, or NewPool are left
+// alone.
+//
+// Call Resolve periodically to follow changes to the backends. Resolve
+// must not be called concurrently for the same Pool. If dialing an address
+// fails, the other addresses are still dialed and the first error is
+// returned.
+func (p *Pool) Resolve(ctx context.Context, r Resolver, dial func(ctx context.Context, addr string) (Conn, error)) error {
+	addrs, err := r.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resolve: %w", err)
+	}
+
+	want := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		want[addr] = true
+	}
+
+	have := make(map[string]bool)
+	for _, m := range p.currentMembers() {
+		if m.Addr == "" || m.redial {
+			continue
+		}
+		if !want[m.Addr] {
+			p.Remove(m)
+			if c, ok := m.Conn.(io.Closer); ok {
+				_ = c.Close()
+			}
+			continue
+		}
+		have[m.Addr] = true
+	}
+
+	var firstErr error
+	for addr := range want {
+		if have[addr] {
+			continue
+		}
+		conn, err := dial(ctx, addr)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed dialing %s: %w", addr, err)
+			}
+			continue
+		}
+		p.add(&PoolMember{Conn: conn, Addr: addr, pool: p})
+	}
+	return firstErr
+}
@@ -0,0 +1,64 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+type closableConn struct {
+	stubConn
+	closed atomic.Bool
+}
+
+func (c *closableConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestPool_Resolve(t *testing.T) {
+	conns := make(map[string]*closableConn)
+	dial := func(ctx context.Context, addr string) (Conn, error) {
+		if addr == "bad:1" {
+			return nil, errors.New("refused")
+		}
+		c := &closableConn{}
+		conns[addr] = c
+		return c, nil
+	}
+
+	static := &stubConn{}
+	p := NewPool([]Conn{static})
+
+	require.NoError(t, p.Resolve(context.Background(), StaticResolver{"a:1", "b:1"}, dial))
+	require.Len(t, p.Members(), 3)
+
+	// b:1 goes away and c:1 appears. The member added directly stays.
+	err := p.Resolve(context.Background(), StaticResolver{"a:1", "c:1", "bad:1"}, dial)
+	require.Error(t, err)
+
+	var addrs []string
+	for _, m := range p.Members() {
+		addrs = append(addrs, m.Addr)
+	}
+	require.ElementsMatch(t, []string{"", "a:1", "c:1"}, addrs)
+	require.True(t, conns["b:1"].closed.Load())
+	require.False(t, conns["a:1"].closed.Load())
+}
+
+func TestPool_AddRemove(t *testing.T) {
+	p := NewPool(nil)
+	_, err := p.Invoke(context.Background(), "ping", nil)
+	require.Equal(t, ErrNoMembers, err)
+
+	m := p.Add(&stubConn{})
+	_, err = p.Invoke(context.Background(), "ping", nil)
+	require.NoError(t, err)
+
+	require.True(t, p.Remove(m))
+	require.False(t, p.Remove(m))
+	require.Empty(t, p.Members())
+}
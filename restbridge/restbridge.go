@@ -0,0 +1,280 @@
+// Package restbridge exposes JSON-RPC 2.0 methods as RESTful HTTP routes.
+//
+// A Bridge maps HTTP requests onto calls against a jsonrpc2.Conn, allowing
+// clients that can only speak plain HTTP (such as browsers without websockets
+// or simple scripts) to call into a JSON-RPC 2.0 service:
+//
+//	bridge := &restbridge.Bridge{
+//		Conn: cli,
+//		Routes: []restbridge.Route{
+//			{Method: "GET", Path: "/users/{id}", RPC: "getUser"},
+//			{Method: "POST", Path: "/users", RPC: "createUser"},
+//		},
+//	}
+//	http.ListenAndServe(":8080", bridge)
+package restbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// Route maps an HTTP route onto a JSON-RPC method.
+type Route struct {
+	// Method is the HTTP method to match, such as GET or POST. If empty, all
+	// HTTP methods are matched.
+	Method string
+
+	// Path is the path to match. Segments of the form {name} match any value
+	// and are passed as a named param to the RPC.
+	Path string
+
+	// RPC is the name of the JSON-RPC method to invoke.
+	RPC string
+
+	// Notify sends the RPC as a notification. The bridge responds with
+	// 202 Accepted as soon as the notification is sent.
+	Notify bool
+}
+
+// Bridge is an http.Handler that maps HTTP requests onto JSON-RPC calls.
+//
+// Params for the RPC are built as a JSON object from path segments, query
+// parameters, and the request body. Query parameters with a single value are
+// passed as a string, and query parameters with multiple values are passed as
+// a list of strings. If the request body is a JSON object, its fields are
+// merged in. If the request body is any other JSON value, it is passed as the
+// params as-is and the route must not have path segments or query
+// parameters.
+//
+// Successful calls respond with 200 OK and the RPC result as the body. Failed
+// calls respond with the status from ErrorStatus and a body of the form:
+//
+//	{"error": {"code": -32601, "message": "method not found"}}
+type Bridge struct {
+	// Conn is the backend connection to invoke RPCs against.
+	Conn jsonrpc2.Conn
+
+	// Routes to match against incoming requests. Routes are matched in order.
+	Routes []Route
+
+	// ErrorStatus maps an error from Conn to an HTTP status code. If nil,
+	// DefaultErrorStatus is used.
+	ErrorStatus func(err error) int
+
+	// MaxBodyBytes limits the size of request bodies. Requests with larger
+	// bodies are rejected with 413 Request Entity Too Large. If zero,
+	// DefaultMaxBodyBytes is used.
+	MaxBodyBytes int64
+}
+
+// DefaultMaxBodyBytes is the default limit for the size of request bodies.
+const DefaultMaxBodyBytes = 1 << 20
+
+// errBodyTooLarge is returned by buildParams when the request body is larger
+// than the limit.
+var errBodyTooLarge = errors.New("request body too large")
+
+// ServeHTTP implements http.Handler.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, vars, ok := b.match(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, &jsonrpc2.Error{
+			Code:    jsonrpc2.ErrorMethodNotFound,
+			Message: fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path),
+		})
+		return
+	}
+
+	maxBody := b.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodyBytes
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	}
+
+	params, err := buildParams(r, vars, maxBody)
+	if errors.Is(err, errBodyTooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, &jsonrpc2.Error{
+			Code:    jsonrpc2.ErrorInvalidRequest,
+			Message: err.Error(),
+		})
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, &jsonrpc2.Error{
+			Code:    jsonrpc2.ErrorInvalidParams,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if route.Notify {
+		if err := b.Conn.Notify(route.RPC, params); err != nil {
+			b.writeCallError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	result, err := b.Conn.Invoke(r.Context(), route.RPC, params)
+	if err != nil {
+		b.writeCallError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(result)
+}
+
+func (b *Bridge) match(r *http.Request) (route Route, vars map[string]string, ok bool) {
+	reqSegments := splitPath(r.URL.Path)
+
+Routes:
+	for _, route := range b.Routes {
+		if route.Method != "" && !strings.EqualFold(route.Method, r.Method) {
+			continue
+		}
+
+		routeSegments := splitPath(route.Path)
+		if len(routeSegments) != len(reqSegments) {
+			continue
+		}
+
+		vars := make(map[string]string)
+		for i, seg := range routeSegments {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				vars[seg[1:len(seg)-1]] = reqSegments[i]
+				continue
+			}
+			if seg != reqSegments[i] {
+				continue Routes
+			}
+		}
+		return route, vars, true
+	}
+
+	return Route{}, nil, false
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// buildParams builds the params for an RPC from an HTTP request. Query
+// parameters and body fields may not override path segments. maxBody is the
+// limit the request body has been wrapped with.
+func buildParams(r *http.Request, vars map[string]string, maxBody int64) (json.RawMessage, error) {
+	params := make(map[string]interface{})
+	for k, v := range vars {
+		params[k] = v
+	}
+	for k, vv := range r.URL.Query() {
+		if _, ok := vars[k]; ok {
+			return nil, fmt.Errorf("query parameter %q conflicts with path segment", k)
+		}
+		if len(vv) == 1 {
+			params[k] = vv[0]
+		} else {
+			params[k] = vv
+		}
+	}
+
+	var body json.RawMessage
+	if r.Body != nil {
+		bb, err := io.ReadAll(r.Body)
+		if err != nil && int64(len(bb)) >= maxBody {
+			return nil, errBodyTooLarge
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+		body = bytes.TrimSpace(bb)
+	}
+
+	if len(body) > 0 {
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("request body is not valid json")
+		}
+
+		if body[0] != '{' {
+			if len(params) > 0 {
+				return nil, fmt.Errorf("request body must be an object when using path or query parameters")
+			}
+			return body, nil
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, err
+		}
+		for k, v := range fields {
+			if _, ok := vars[k]; ok {
+				return nil, fmt.Errorf("body field %q conflicts with path segment", k)
+			}
+			params[k] = v
+		}
+	}
+
+	return json.Marshal(params)
+}
+
+func (b *Bridge) writeCallError(w http.ResponseWriter, err error) {
+	statusFunc := b.ErrorStatus
+	if statusFunc == nil {
+		statusFunc = DefaultErrorStatus
+	}
+
+	var rpcErr jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		rpcErr = jsonrpc2.Error{Code: jsonrpc2.ErrorInternal, Message: err.Error()}
+	}
+	writeError(w, statusFunc(err), &rpcErr)
+}
+
+func writeError(w http.ResponseWriter, status int, err *jsonrpc2.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error *jsonrpc2.Error `json:"error"`
+	}{err})
+}
+
+// DefaultErrorStatus maps errors from a Conn to HTTP status codes:
+//
+//   - ErrorParse, ErrorInvalidRequest, and ErrorInvalidParams map to 400 Bad Request.
+//   - ErrorMethodNotFound maps to 404 Not Found.
+//   - Other RPC errors map to 500 Internal Server Error.
+//   - Context deadlines map to 504 Gateway Timeout.
+//   - All other errors, such as transport errors, map to 502 Bad Gateway.
+func DefaultErrorStatus(err error) int {
+	var rpcErr jsonrpc2.Error
+	switch {
+	case errors.As(err, &rpcErr):
+		switch rpcErr.Code {
+		case jsonrpc2.ErrorParse, jsonrpc2.ErrorInvalidRequest, jsonrpc2.ErrorInvalidParams:
+			return http.StatusBadRequest
+		case jsonrpc2.ErrorMethodNotFound:
+			return http.StatusNotFound
+		default:
+			return http.StatusInternalServerError
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
@@ -0,0 +1,163 @@
+package restbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn implements jsonrpc2.Conn, echoing back the method and params.
+type fakeConn struct {
+	err      error
+	notified chan string
+}
+
+func (c *fakeConn) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return json.Marshal(map[string]interface{}{"method": method, "params": msg})
+}
+
+func (c *fakeConn) Notify(method string, msg interface{}) error {
+	c.notified <- method
+	return c.err
+}
+
+func TestBridge(t *testing.T) {
+	conn := &fakeConn{notified: make(chan string, 1)}
+	srv := httptest.NewServer(&Bridge{
+		Conn: conn,
+		Routes: []Route{
+			{Method: "GET", Path: "/users/{id}", RPC: "getUser"},
+			{Method: "PUT", Path: "/users/{id}", RPC: "updateUser"},
+			{Method: "POST", Path: "/users", RPC: "createUser"},
+			{Method: "POST", Path: "/sum", RPC: "sum"},
+			{Method: "POST", Path: "/events", RPC: "event", Notify: true},
+		},
+		MaxBodyBytes: 64,
+	})
+	defer srv.Close()
+
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		expect string
+	}{
+		{
+			name:   "path and query params",
+			method: "GET",
+			path:   "/users/42?fields=name&tag=a&tag=b",
+			status: http.StatusOK,
+			expect: `{"method": "getUser", "params": {"id": "42", "fields": "name", "tag": ["a", "b"]}}`,
+		},
+		{
+			name:   "object body",
+			method: "POST",
+			path:   "/users",
+			body:   `{"name": "Alice"}`,
+			status: http.StatusOK,
+			expect: `{"method": "createUser", "params": {"name": "Alice"}}`,
+		},
+		{
+			name:   "array body",
+			method: "POST",
+			path:   "/sum",
+			body:   `[1, 2, 3]`,
+			status: http.StatusOK,
+			expect: `{"method": "sum", "params": [1, 2, 3]}`,
+		},
+		{
+			name:   "invalid body",
+			method: "POST",
+			path:   "/users",
+			body:   `{`,
+			status: http.StatusBadRequest,
+			expect: `{"error": {"code": -32602, "message": "request body is not valid json"}}`,
+		},
+		{
+			name:   "body conflicts with path",
+			method: "PUT",
+			path:   "/users/42",
+			body:   `{"id": "other", "name": "Alice"}`,
+			status: http.StatusBadRequest,
+			expect: `{"error": {"code": -32602, "message": "body field \"id\" conflicts with path segment"}}`,
+		},
+		{
+			name:   "query conflicts with path",
+			method: "GET",
+			path:   "/users/42?id=other",
+			status: http.StatusBadRequest,
+			expect: `{"error": {"code": -32602, "message": "query parameter \"id\" conflicts with path segment"}}`,
+		},
+		{
+			name:   "body too large",
+			method: "POST",
+			path:   "/users",
+			body:   `{"name": "` + strings.Repeat("x", 64) + `"}`,
+			status: http.StatusRequestEntityTooLarge,
+			expect: `{"error": {"code": -32600, "message": "request body too large"}}`,
+		},
+		{
+			name:   "no route",
+			method: "DELETE",
+			path:   "/users/42",
+			status: http.StatusNotFound,
+			expect: `{"error": {"code": -32601, "message": "no route for DELETE /users/42"}}`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode)
+			require.JSONEq(t, tc.expect, string(body))
+		})
+	}
+
+	t.Run("notify", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+"/events", "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+		require.Equal(t, "event", <-conn.notified)
+	})
+}
+
+func TestDefaultErrorStatus(t *testing.T) {
+	tt := []struct {
+		err    error
+		expect int
+	}{
+		{err: jsonrpc2.Error{Code: jsonrpc2.ErrorInvalidParams}, expect: http.StatusBadRequest},
+		{err: jsonrpc2.Error{Code: jsonrpc2.ErrorMethodNotFound}, expect: http.StatusNotFound},
+		{err: jsonrpc2.Error{Code: -32000}, expect: http.StatusInternalServerError},
+		{err: context.DeadlineExceeded, expect: http.StatusGatewayTimeout},
+		{err: fmt.Errorf("connection reset"), expect: http.StatusBadGateway},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			require.Equal(t, tc.expect, DefaultErrorStatus(tc.err))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy is how a Client retries failed calls made with Invoke and Go,
+// set with WithRetryPolicy. Calls are retried when the request couldn't be
+// sent, or when the server replied with an error whose code is in Codes.
+// Calls which time out or are cancelled aren't retried, and neither are
+// calls made with WithNoRetry.
+//
+// Retried calls may be handled more than once, so a policy should only be
+// used for idempotent methods, or with WithNoRetry for the others.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a call is sent, including
+	// the first attempt. If zero, calls are sent up to 3 times.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry, which doubles
+	// after each retry up to MaxBackoff. If zero, it is 100ms, and MaxBackoff
+	// is 5s if zero.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Jitter randomizes each delay by up to this fraction of it, so clients
+	// which failed together don't retry together. With a jitter of 0.2, a
+	// delay of 1s is between 800ms and 1.2s. It must be between 0 and 1.
+	Jitter float64
+
+	// Codes are the error codes which are retried, such as ErrorServerBusy.
+	// If the server replied with a busy error asking to retry after a delay
+	// longer than the backoff, the server's delay is used.
+	Codes []int
+}
+
+// WithRetryPolicy sets the policy used to retry calls made with Invoke and
+// Go.
+// Calls aren't retried by default.
+func WithRetryPolicy(policy RetryPolicy) ClientOpt {
+	return func(c *Client) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = 3
+		}
+		if policy.InitialBackoff <= 0 {
+			policy.InitialBackoff = 100 * time.Millisecond
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = 5 * time.Second
+		}
+		if policy.Jitter < 0 || policy.Jitter > 1 {
+			policy.Jitter = 0
+		}
+		policy.Codes = append([]int(nil), policy.Codes...)
+		c.retryPolicy = &policy
+	}
+}
+
+// retryAttemptKey is the context key of the *retryAttempt for a call being
+// made by invokeWithRetry.
+type retryAttemptKey struct{}
+
+// retryAttempt records how an attempt at a call failed.
+type retryAttempt struct {
+	sendFailed bool
+}
+
+// markSendFailed records that the request for the call made with ctx
+// couldn't be sent.
+func markSendFailed(ctx context.Context) {
+	if a, ok := ctx.Value(retryAttemptKey{}).(*retryAttempt); ok {
+		a.sendFailed = true
+	}
+}
+
+// invokeWithRetry calls invoke until it succeeds or c's RetryPolicy says the
+// error shouldn't be retried.
+func (c *Client) invokeWithRetry(ctx context.Context, method string, invoke func(ctx context.Context) (json.RawMessage, error)) (json.RawMessage, error) {
+	policy := c.retryPolicy
+	if policy == nil || NoRetry(ctx) {
+		return invoke(ctx)
+	}
+
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		a := &retryAttempt{}
+		res, err := invoke(context.WithValue(ctx, retryAttemptKey{}, a))
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err, a) {
+			return res, err
+		}
+
+		wait := policy.withJitter(backoff)
+		if after, busy := RetryAfter(err); busy && after > wait {
+			wait = after
+		}
+		if deadline, ok := ctx.Deadline(); ok && c.clock.Now().Add(wait).After(deadline) {
+			return res, err
+		}
+		LevelDebug.Log(c.log, "msg", "retrying call", "method", method, "attempt", attempt, "err", err, "backoff", wait)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.done:
+			return res, err
+		case <-c.clock.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// retryable reports whether err, returned by attempt a, should be retried.
+func (p *RetryPolicy) retryable(err error, a *retryAttempt) bool {
+	var rpcErr Error
+	if errors.As(err, &rpcErr) {
+		for _, code := range p.Codes {
+			if rpcErr.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+	// A closed Client can't send the retry either.
+	return a.sendFailed && !errors.Is(err, ErrConnClosed)
+}
+
+func (p *RetryPolicy) withJitter(d time.Duration) time.Duration {
+	if p.Jitter == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+}
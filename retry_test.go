@@ -0,0 +1,125 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// failingWriteConn is a net.Conn whose first writes fail without writing.
+type failingWriteConn struct {
+	net.Conn
+	failures atomic.Int64
+}
+
+func (c *failingWriteConn) Write(p []byte) (int, error) {
+	if c.failures.Dec() >= 0 {
+		return 0, errors.New("write failed")
+	}
+	return c.Conn.Write(p)
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	var (
+		calls    atomic.Int64
+		failures atomic.Int64
+	)
+	mux := NewServeMux()
+	mux.HandleFunc("flaky", func(w ResponseWriter, r *Request) {
+		calls.Inc()
+		if failures.Dec() >= 0 {
+			_ = w.WriteError(ErrorServerBusy, errors.New("busy"))
+			return
+		}
+		_ = w.WriteMessage(true)
+	})
+	mux.HandleFunc("broken", func(w ResponseWriter, r *Request) {
+		calls.Inc()
+		_ = w.WriteError(ErrorInternal, errors.New("broken"))
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	conn := &failingWriteConn{Conn: cliConn}
+	cli := NewClient(conn, nil, WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         0.2,
+		Codes:          []int{ErrorServerBusy},
+	}))
+	defer cli.Close()
+	ctx := context.Background()
+
+	// Listed codes are retried.
+	failures.Store(2)
+	res, err := cli.Invoke(ctx, "flaky", nil)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(res))
+	require.Equal(t, int64(3), calls.Swap(0))
+
+	// Calls are sent at most MaxAttempts times.
+	failures.Store(3)
+	_, err = cli.Invoke(ctx, "flaky", nil)
+	var rpcErr Error
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, ErrorServerBusy, rpcErr.Code)
+	require.Equal(t, int64(3), calls.Swap(0))
+
+	// Other codes aren't retried.
+	_, err = cli.Invoke(ctx, "broken", nil)
+	require.Error(t, err)
+	require.Equal(t, int64(1), calls.Swap(0))
+
+	// Calls can opt out.
+	failures.Store(1)
+	_, err = cli.InvokeWith(ctx, "flaky", nil, WithNoRetry())
+	require.Error(t, err)
+	require.Equal(t, int64(1), calls.Swap(0))
+
+	// Requests which couldn't be sent are retried.
+	failures.Store(0)
+	conn.failures.Store(2)
+	res, err = cli.Invoke(ctx, "flaky", nil)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(res))
+	require.Equal(t, int64(1), calls.Swap(0))
+}
+
+func TestWithRetryPolicy_Closed(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	defer srvConn.Close()
+	cli := NewClient(cliConn, nil, WithRetryPolicy(RetryPolicy{InitialBackoff: time.Hour}))
+	require.NoError(t, cli.Close())
+
+	_, err := cli.Invoke(context.Background(), "call", nil)
+	require.ErrorIs(t, err, ErrConnClosed)
+}
+
+func TestWithRetryPolicy_Go(t *testing.T) {
+	var failures atomic.Int64
+	failures.Store(1)
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if failures.Dec() >= 0 {
+			_ = w.WriteError(ErrorServerBusy, errors.New("busy"))
+			return
+		}
+		_ = w.WriteMessage(true)
+	}))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithRetryPolicy(RetryPolicy{
+		InitialBackoff: time.Millisecond,
+		Codes:          []int{ErrorServerBusy},
+	}))
+	defer cli.Close()
+
+	call := <-cli.Go(context.Background(), "flaky", nil).Done
+	require.NoError(t, call.Err)
+	require.Equal(t, "true", string(call.Result))
+}
@@ -0,0 +1,277 @@
+// Package schemareg publishes the schemas of JSON-RPC 2.0 methods to a
+// registry and validates calls against them.
+//
+// A Registry stores a Schema for each method. Memory is an in-process
+// registry which can also be served to other services through its Handler,
+// and Remote is a Registry backed by such an endpoint. Validate is a
+// jsonrpc2.Handler which checks params and results against the schemas in a
+// Registry and counts mismatches.
+//
+// schemareg doesn't interpret schemas itself. Validation is done by a
+// Validator, which may wrap any JSON Schema implementation.
+package schemareg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/crtv-io/jsonrpc2"
+	"go.uber.org/atomic"
+)
+
+// Methods served by Memory.Handler and called by Remote.
+const (
+	MethodPublish = "schema.publish"
+	MethodLookup  = "schema.lookup"
+)
+
+// ErrNotFound is returned by Lookup when a method has no schema.
+var ErrNotFound = errors.New("schema not found")
+
+// errorNotFound is the error code sent by Memory.Handler when a method has no
+// schema. Server error codes used across the module are listed in the
+// jsonrpc2 package.
+const errorNotFound = -32004
+
+// Schema describes the params and result of a method.
+type Schema struct {
+	Method string `json:"method"`
+
+	// Version identifies this revision of the schema. Publishing a schema
+	// replaces the previous version for the method.
+	Version string `json:"version"`
+
+	// Params and Result are schema documents, such as JSON Schemas, for the
+	// method's params and result. Either may be empty to skip validating
+	// that direction.
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// Registry stores the current schema for each method.
+type Registry interface {
+	// Publish stores s as the current schema for s.Method.
+	Publish(ctx context.Context, s Schema) error
+
+	// Lookup returns the current schema for method. ErrNotFound is returned
+	// if method has no schema.
+	Lookup(ctx context.Context, method string) (Schema, error)
+}
+
+// Memory is an in-memory Registry. The zero value is ready to use.
+type Memory struct {
+	mut     sync.RWMutex
+	schemas map[string]Schema
+}
+
+var _ Registry = (*Memory)(nil)
+
+// Publish implements Registry.
+func (m *Memory) Publish(ctx context.Context, s Schema) error {
+	if s.Method == "" {
+		return errors.New("schema has no method")
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if m.schemas == nil {
+		m.schemas = make(map[string]Schema)
+	}
+	m.schemas[s.Method] = s
+	return nil
+}
+
+// Lookup implements Registry.
+func (m *Memory) Lookup(ctx context.Context, method string) (Schema, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	s, ok := m.schemas[method]
+	if !ok {
+		return Schema{}, ErrNotFound
+	}
+	return s, nil
+}
+
+// Handler returns a jsonrpc2.Handler serving MethodPublish and MethodLookup
+// from m, which allows m to be used as a registry by other services through
+// Remote.
+func (m *Memory) Handler() jsonrpc2.Handler {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc(MethodPublish, func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var s Schema
+		if err := r.DecodeParams(&s); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		if err := m.Publish(r.Context(), s); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		_ = w.WriteMessage(true)
+	})
+	mux.HandleFunc(MethodLookup, func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var method string
+		if err := r.DecodeParams(&method); err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInvalidParams, err)
+			return
+		}
+		s, err := m.Lookup(r.Context(), method)
+		if errors.Is(err, ErrNotFound) {
+			_ = w.WriteError(errorNotFound, err)
+			return
+		} else if err != nil {
+			_ = w.WriteError(jsonrpc2.ErrorInternal, err)
+			return
+		}
+		_ = w.WriteMessage(s)
+	})
+	return mux
+}
+
+// Remote is a Registry served by another service, such as one using
+// Memory.Handler.
+type Remote struct {
+	Conn jsonrpc2.Conn
+}
+
+var _ Registry = (*Remote)(nil)
+
+// Publish implements Registry.
+func (r *Remote) Publish(ctx context.Context, s Schema) error {
+	_, err := r.Conn.Invoke(ctx, MethodPublish, s)
+	return err
+}
+
+// Lookup implements Registry.
+func (r *Remote) Lookup(ctx context.Context, method string) (Schema, error) {
+	res, err := r.Conn.Invoke(ctx, MethodLookup, method)
+	var rpcErr jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == errorNotFound {
+		return Schema{}, ErrNotFound
+	} else if err != nil {
+		return Schema{}, err
+	}
+
+	var s Schema
+	if err := json.Unmarshal(res, &s); err != nil {
+		return Schema{}, fmt.Errorf("invalid schema: %w", err)
+	}
+	return s, nil
+}
+
+// Validator checks that value conforms to schema.
+type Validator func(schema, value json.RawMessage) error
+
+// Mismatches counts values which failed validation for a method.
+type Mismatches struct {
+	Params int64
+	Result int64
+}
+
+// Validate is a jsonrpc2.Handler which validates params and results against
+// the schemas in Registry before and after passing calls to Handler. Methods
+// without a schema aren't validated.
+//
+// By default mismatches are only counted. If Enforce is set, requests with
+// invalid params are rejected with ErrorInvalidParams, and invalid results
+// are replaced with an ErrorInternal error.
+//
+// The schema is looked up for every call, so Registry should be local, such
+// as a Memory kept in sync with a remote registry.
+type Validate struct {
+	Handler   jsonrpc2.Handler
+	Registry  Registry
+	Validator Validator
+	Enforce   bool
+
+	mut        sync.Mutex
+	mismatches map[string]*mismatchCounts
+}
+
+type mismatchCounts struct {
+	params, result atomic.Int64
+}
+
+// Mismatches returns the number of values which failed validation, keyed by
+// method.
+func (v *Validate) Mismatches() map[string]Mismatches {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+
+	out := make(map[string]Mismatches, len(v.mismatches))
+	for method, c := range v.mismatches {
+		out[method] = Mismatches{Params: c.params.Load(), Result: c.result.Load()}
+	}
+	return out
+}
+
+func (v *Validate) counts(method string) *mismatchCounts {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+
+	if v.mismatches == nil {
+		v.mismatches = make(map[string]*mismatchCounts)
+	}
+	c, ok := v.mismatches[method]
+	if !ok {
+		c = &mismatchCounts{}
+		v.mismatches[method] = c
+	}
+	return c
+}
+
+// ServeRPC implements jsonrpc2.Handler.
+func (v *Validate) ServeRPC(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+	s, err := v.Registry.Lookup(r.Context(), r.Method)
+	if err != nil {
+		v.Handler.ServeRPC(w, r)
+		return
+	}
+
+	if len(s.Params) > 0 {
+		params := r.Params
+		if len(params) == 0 {
+			params = json.RawMessage("null")
+		}
+		if err := v.Validator(s.Params, params); err != nil {
+			v.counts(r.Method).params.Inc()
+			if v.Enforce {
+				if !r.Notification {
+					_ = w.WriteError(jsonrpc2.ErrorInvalidParams, fmt.Errorf("params do not match schema %s: %w", s.Version, err))
+				}
+				return
+			}
+		}
+	}
+
+	if len(s.Result) > 0 && !r.Notification {
+		w = &resultValidator{ResponseWriter: w, v: v, method: r.Method, schema: s}
+	}
+	v.Handler.ServeRPC(w, r)
+}
+
+// resultValidator validates results written by a handler.
+type resultValidator struct {
+	jsonrpc2.ResponseWriter
+	v      *Validate
+	method string
+	schema Schema
+}
+
+func (w *resultValidator) WriteMessage(msg interface{}) error {
+	result, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := w.v.Validator(w.schema.Result, result); err != nil {
+		w.v.counts(w.method).result.Inc()
+		if w.v.Enforce {
+			return w.ResponseWriter.WriteError(jsonrpc2.ErrorInternal, fmt.Errorf("result does not match schema %s", w.schema.Version))
+		}
+	}
+	return w.ResponseWriter.WriteMessage(json.RawMessage(result))
+}
@@ -0,0 +1,120 @@
+package schemareg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+// typeValidator treats schemas as a JSON string naming the expected JSON
+// type of the value.
+func typeValidator(schema, value json.RawMessage) error {
+	var want string
+	if err := json.Unmarshal(schema, &want); err != nil {
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal(value, &v); err != nil {
+		return err
+	}
+
+	var got string
+	switch v.(type) {
+	case float64:
+		got = "number"
+	case string:
+		got = "string"
+	default:
+		got = fmt.Sprintf("%T", v)
+	}
+	if got != want {
+		return fmt.Errorf("expected %s, got %s", want, got)
+	}
+	return nil
+}
+
+func pipe(t *testing.T, h jsonrpc2.Handler) *jsonrpc2.Client {
+	t.Helper()
+	srvConn, cliConn := net.Pipe()
+	srv := jsonrpc2.NewClient(srvConn, h)
+	t.Cleanup(func() { _ = srv.Close() })
+	cli := jsonrpc2.NewClient(cliConn, nil)
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func TestRemote(t *testing.T) {
+	var reg Memory
+	remote := &Remote{Conn: pipe(t, reg.Handler())}
+
+	ctx := context.Background()
+	s := Schema{Method: "double", Version: "1", Params: json.RawMessage(`"number"`)}
+	require.NoError(t, remote.Publish(ctx, s))
+
+	got, err := remote.Lookup(ctx, "double")
+	require.NoError(t, err)
+	require.Equal(t, s, got)
+
+	_, err = remote.Lookup(ctx, "missing")
+	require.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestValidate(t *testing.T) {
+	var reg Memory
+	require.NoError(t, reg.Publish(context.Background(), Schema{
+		Method:  "double",
+		Version: "1",
+		Params:  json.RawMessage(`"number"`),
+		Result:  json.RawMessage(`"number"`),
+	}))
+	require.NoError(t, reg.Publish(context.Background(), Schema{
+		Method:  "broken",
+		Version: "1",
+		Result:  json.RawMessage(`"number"`),
+	}))
+
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var n float64
+		if r.Method == "broken" || r.DecodeParams(&n) != nil {
+			_ = w.WriteMessage("not a number")
+			return
+		}
+		_ = w.WriteMessage(n * 2)
+	})
+
+	for _, enforce := range []bool{false, true} {
+		v := &Validate{Handler: handler, Registry: &reg, Validator: typeValidator, Enforce: enforce}
+		cli := pipe(t, v)
+
+		res, err := cli.Invoke(context.Background(), "double", 2)
+		require.NoError(t, err)
+		require.Equal(t, "4", string(res))
+		require.Equal(t, Mismatches{}, v.Mismatches()["double"])
+
+		_, err = cli.Invoke(context.Background(), "double", "two")
+		var rpcErr jsonrpc2.Error
+		if enforce {
+			require.True(t, errors.As(err, &rpcErr))
+			require.Equal(t, jsonrpc2.ErrorInvalidParams, rpcErr.Code)
+			require.Equal(t, Mismatches{Params: 1}, v.Mismatches()["double"])
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, Mismatches{Params: 1, Result: 1}, v.Mismatches()["double"])
+		}
+
+		_, err = cli.Invoke(context.Background(), "broken", nil)
+		if enforce {
+			require.True(t, errors.As(err, &rpcErr))
+			require.Equal(t, jsonrpc2.ErrorInternal, rpcErr.Code)
+		} else {
+			require.NoError(t, err)
+		}
+		require.Equal(t, Mismatches{Result: 1}, v.Mismatches()["broken"])
+	}
+}
@@ -11,6 +11,13 @@ import (
 type ServeMux struct {
 	mut    sync.RWMutex
 	routes map[string]Handler
+	docs   map[string]MethodDoc    // Set by Document.
+	typed  map[string]TypedHandler // Routes registered with a TypedHandler.
+
+	middleware []Middleware
+	// chain is the mux's middleware wrapped around route, or nil if there
+	// is no middleware.
+	chain Handler
 }
 
 // NewServeMux allocates and returns a new ServeMux.
@@ -21,6 +28,12 @@ func NewServeMux() *ServeMux {
 // Handle registers the handler for a given method. If a handler already exists
 // for method, Handle panics.
 func (m *ServeMux) Handle(method string, handler Handler) {
+	m.handle(method, handler, handler)
+}
+
+// handle registers handler for method. orig is the handler before any
+// middleware was applied, which is checked for types.
+func (m *ServeMux) handle(method string, handler, orig Handler) {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
@@ -28,6 +41,20 @@ func (m *ServeMux) Handle(method string, handler Handler) {
 		panic("method " + method + " already registered")
 	}
 	m.routes[method] = handler
+	m.setTyped(method, orig)
+}
+
+// setTyped records h as the TypedHandler for method if it is one. m.mut
+// must be held.
+func (m *ServeMux) setTyped(method string, h Handler) {
+	th, ok := h.(TypedHandler)
+	if !ok {
+		return
+	}
+	if m.typed == nil {
+		m.typed = make(map[string]TypedHandler)
+	}
+	m.typed[method] = th
 }
 
 // HandleFunc registers the handler function for the given method.
@@ -35,14 +62,39 @@ func (m *ServeMux) HandleFunc(method string, handler func(rw ResponseWriter, r *
 	m.Handle(method, HandlerFunc(handler))
 }
 
+// Use adds middleware which wraps every request served by the mux,
+// including requests for methods which aren't registered. Middleware added
+// first is the outermost. Use middleware on a ServiceGroup, or Chain, to
+// wrap only some methods.
+func (m *ServeMux) Use(mw ...Middleware) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.middleware = append(m.middleware, mw...)
+	m.chain = Chain(HandlerFunc(m.route), m.middleware...)
+}
+
 // ServeRPC implements Handler. ServeRPC will find a registered route matching the
 // incoming request and invoke it if one exists. When a route wasn't found,
 // ErrorMethodNotFound is returned to the caller.
 func (m *ServeMux) ServeRPC(w ResponseWriter, req *Request) {
 	m.mut.RLock()
-	defer m.mut.RUnlock()
+	chain := m.chain
+	m.mut.RUnlock()
 
+	if chain != nil {
+		chain.ServeRPC(w, req)
+		return
+	}
+	m.route(w, req)
+}
+
+// route calls the handler registered for the request's method.
+func (m *ServeMux) route(w ResponseWriter, req *Request) {
+	m.mut.RLock()
 	route, ok := m.routes[req.Method]
+	m.mut.RUnlock()
+
 	if ok {
 		route.ServeRPC(w, req)
 		return
@@ -1,9 +1,13 @@
 package jsonrpc2
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"sync"
+	"time"
 
 	"go.uber.org/atomic"
 )
@@ -21,19 +25,86 @@ type Server struct {
 	// OnClientDisconnect may be used to handle disconnected clients.
 	OnClientDisconnect func(c *Client)
 
+	// ClientOpts are passed to NewClient for each new connection.
+	ClientOpts []ClientOpt
+
+	// ConnFilter may be provided to reject connections before a Client is
+	// created for them. It is called with the remote address of each new
+	// connection, and the connection is closed if it returns an error.
+	// AllowCIDRs and DenyCIDRs create filters from lists of CIDR ranges.
+	ConnFilter func(addr net.Addr) error
+
+	// OnAccept may be provided to wrap or reject connections before a Client
+	// is created for them, such as to add TLS, throttling, or metrics. It
+	// is called after ConnFilter, and the connection it returns is used in
+	// place of conn. If it returns an error, conn is closed.
+	OnAccept func(conn net.Conn) (net.Conn, error)
+
+	// FirstMessageTimeout is the maximum amount of time to wait for the
+	// first message from a new connection, measured from when its Client is
+	// created. Connections which don't send a message in time are closed, so
+	// idle connections don't hold resources. If zero, there is no timeout.
+	FirstMessageTimeout time.Duration
+
+	// Sniff may be provided to choose how each connection is served from
+	// the first bytes it sends, such as to serve clients using different
+	// framings, or different tenants, on a single port. It is called after
+	// the TLS handshake with up to SniffSize bytes from a single read, which
+	// SniffRequest parses. The bytes are still read by the connection's
+	// Client. If Sniff returns an error, the connection is closed.
+	//
+	// Connections which send nothing within FirstMessageTimeout, or
+	// DefaultSniffTimeout if it is zero, are closed.
+	Sniff func(prefix []byte) (Route, error)
+
+	// SniffSize is the maximum number of bytes passed to Sniff. If zero,
+	// DefaultSniffSize is used.
+	SniffSize int
+
+	// HandshakeTimeout is the maximum amount of time to wait for a TLS
+	// handshake to complete for connections accepted from a TLS listener.
+	// Connections which don't complete the handshake in time are closed. If
+	// zero, there is no timeout.
+	HandshakeTimeout time.Duration
+
+	// Logger is used to log connection errors. If nil, no logs are written.
+	Logger Logger
+
+	// CloseConcurrency limits how many clients Close closes at once. If
+	// zero, DefaultCloseConcurrency is used.
+	CloseConcurrency int
+
+	// BaseContext may be provided to set the base context of connections
+	// accepted from lis. If nil, context.Background is used. The server
+	// derives its own context from it, which is cancelled by Close, or
+	// once Shutdown has closed every connection.
+	BaseContext func(lis net.Listener) context.Context
+
+	// ConnContext may be provided to modify the context of a new
+	// connection, which is derived from the server's context. It becomes
+	// the parent of the connection's Client.Context, and so of the contexts
+	// of requests received over it. The Client is closed once it is done.
+	ConnContext func(ctx context.Context, conn net.Conn) context.Context
+
 	mut       sync.Mutex
 	listeners map[*net.Listener]struct{}
 	clis      map[*Client]struct{}
+	cancels   []context.CancelFunc
 	shutDown  atomic.Bool
 }
 
-// Serve starts serving connections from a listener. Each new connection
-// will be created as a Client and will start processing messages.
+// Serve starts serving connections from a listener. Each connection which
+// passes ConnFilter, OnAccept, and Sniff will be created as a Client and
+// will start processing messages. HandshakeTimeout only applies to TLS
+// connections, such as those accepted from a TLS listener or wrapped by
+// OnAccept; other connections have no handshake timeout.
 //
 // lis will be closed when Serve exits.
 //
-// If s.OnConn is non-nil, it will be invoked for each connection received.
+// If s.OnClient is non-nil, it will be invoked for each Client created.
 func (s *Server) Serve(lis net.Listener) error {
+	ctx := s.newServeContext(lis)
+
 	lis = &onceCloseListener{Listener: lis}
 	defer lis.Close()
 
@@ -52,25 +123,167 @@ func (s *Server) Serve(lis net.Listener) error {
 		if err != nil {
 			return err
 		}
-		go s.onConn(conn, hdlr)
+		go s.onConn(ctx, conn, hdlr)
+	}
+}
+
+// newServeContext returns the server's context for connections accepted
+// from lis. It is cancelled right away if the server has been closed.
+func (s *Server) newServeContext(lis net.Listener) context.Context {
+	base := context.Background()
+	if s.BaseContext != nil {
+		base = s.BaseContext(lis)
+	}
+	ctx, cancel := context.WithCancel(base)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.shutDown.Load() {
+		cancel()
+	} else {
+		s.cancels = append(s.cancels, cancel)
+	}
+	return ctx
+}
+
+// cancelContexts cancels the contexts of the server's connections.
+func (s *Server) cancelContexts() {
+	s.mut.Lock()
+	cancels := s.cancels
+	s.cancels = nil
+	s.mut.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
 	}
 }
 
-func (s *Server) onConn(conn net.Conn, handler Handler) {
+func (s *Server) onConn(ctx context.Context, conn net.Conn, handler Handler) {
+	if s.ConnFilter != nil {
+		if err := s.ConnFilter(conn.RemoteAddr()); err != nil {
+			if s.Logger != nil {
+				LevelDebug.Log(s.Logger, "msg", "rejected connection", "remote", conn.RemoteAddr(), "err", err)
+			}
+			_ = conn.Close()
+			return
+		}
+	}
+
+	if s.OnAccept != nil {
+		wrapped, err := s.OnAccept(conn)
+		if err != nil {
+			if s.Logger != nil {
+				LevelDebug.Log(s.Logger, "msg", "rejected connection", "remote", conn.RemoteAddr(), "err", err)
+			}
+			_ = conn.Close()
+			return
+		}
+		conn = wrapped
+	}
+
+	if tc, ok := conn.(*tls.Conn); ok && s.HandshakeTimeout > 0 {
+		if err := handshake(tc, s.HandshakeTimeout); err != nil {
+			if s.Logger != nil {
+				LevelDebug.Log(s.Logger, "msg", "tls handshake failed", "remote", conn.RemoteAddr(), "err", err)
+			}
+			_ = conn.Close()
+			return
+		}
+	}
+
+	opts := s.ClientOpts
+	var newFramer func(rw io.ReadWriter) Framer
+	if s.Sniff != nil {
+		sniffed, route, err := s.sniff(conn)
+		if err != nil {
+			if s.Logger != nil {
+				LevelDebug.Log(s.Logger, "msg", "rejected connection", "remote", conn.RemoteAddr(), "err", err)
+			}
+			_ = conn.Close()
+			return
+		}
+		conn = sniffed
+		if route.Handler != nil {
+			handler = route.Handler
+		}
+		if len(route.ClientOpts) > 0 {
+			opts = append(opts[:len(opts):len(opts)], route.ClientOpts...)
+		}
+		newFramer = route.NewFramer
+	}
+
+	if s.ConnContext != nil {
+		ctx = s.ConnContext(ctx, conn)
+	}
+	// Options set by the user come later, so they can override the base
+	// context.
+	opts = append([]ClientOpt{WithBaseContext(ctx)}, opts...)
+
 	// Create a conn
-	cli := NewClient(conn, handler)
+	var cli *Client
+	if newFramer != nil {
+		cli = NewFramedClient(newFramer(conn), handler, opts...)
+	} else {
+		cli = NewClient(conn, handler, opts...)
+	}
 	if s.OnClient != nil {
 		go s.OnClient(cli)
 	}
-	s.trackClient(cli, true)
+	if !s.trackClient(cli, true) {
+		_ = cli.closeTransport()
+	}
 	defer s.trackClient(cli, false)
 
+	if s.FirstMessageTimeout > 0 {
+		s.awaitFirstMessage(cli)
+	}
 	<-cli.Done()
 	if s.OnClientDisconnect != nil {
 		go s.OnClientDisconnect(cli)
 	}
 }
 
+// awaitFirstMessage closes cli if it doesn't read a message within
+// s.FirstMessageTimeout.
+func (s *Server) awaitFirstMessage(cli *Client) {
+	timer := time.NewTimer(s.FirstMessageTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-cli.firstMessage:
+	case <-cli.Done():
+	case <-timer.C:
+		if s.Logger != nil {
+			LevelDebug.Log(s.Logger, "msg", "closing connection without a first message", "timeout", s.FirstMessageTimeout)
+		}
+		_ = cli.closeTransport()
+	}
+}
+
+// handshake runs the TLS handshake for tc, failing if it doesn't complete
+// within timeout.
+func handshake(tc *tls.Conn, timeout time.Duration) error {
+	if err := tc.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	return tc.SetDeadline(time.Time{})
+}
+
+// InFlight returns the number of requests being handled across all of the
+// server's connections, such as for ReadLoad.
+func (s *Server) InFlight() int64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var n int64
+	for c := range s.clis {
+		n += c.inflight.Load()
+	}
+	return n
+}
+
 func (s *Server) trackListener(lis *net.Listener, add bool) bool {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -88,45 +301,136 @@ func (s *Server) trackListener(lis *net.Listener, add bool) bool {
 	return true
 }
 
-func (s *Server) trackClient(c *Client, add bool) {
+func (s *Server) trackClient(c *Client, add bool) bool {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 	if s.clis == nil {
 		s.clis = make(map[*Client]struct{})
 	}
 	if add {
+		if s.shutDown.Load() {
+			return false
+		}
 		s.clis[c] = struct{}{}
 	} else {
 		delete(s.clis, c)
 	}
+	return true
 }
 
-// Close closes the server. All listeners will be stopped.
+// DefaultCloseConcurrency is the default for Server.CloseConcurrency.
+const DefaultCloseConcurrency = 16
+
+// Close closes the server. All listeners are stopped and every connected
+// client is closed without waiting for running handlers; use Shutdown to
+// close gracefully. Up to CloseConcurrency clients are closed at once.
+//
+// The errors from closing listeners and clients are joined into the
+// returned error, which errors.Is and errors.As can match against each of
+// them.
 func (s *Server) Close() error {
 	s.mut.Lock()
-	defer s.mut.Unlock()
 	s.shutDown.Store(true)
+	var errs []error
+	for lis := range s.listeners {
+		if err := (*lis).Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	clis := make([]*Client, 0, len(s.clis))
+	for cli := range s.clis {
+		clis = append(clis, cli)
+	}
+	s.mut.Unlock()
+
+	limit := s.CloseConcurrency
+	if limit <= 0 {
+		limit = DefaultCloseConcurrency
+	}
+	var (
+		wg     sync.WaitGroup
+		errMut sync.Mutex
+		sem    = make(chan struct{}, limit)
+	)
+	for _, cli := range clis {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(cli *Client) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := cli.closeTransport(); err != nil {
+				errMut.Lock()
+				errs = append(errs, err)
+				errMut.Unlock()
+			}
+		}(cli)
+	}
+	wg.Wait()
+	s.cancelContexts()
+
+	return joinErrors(errs...)
+}
 
+// shutdownPollInterval is how often Shutdown checks for idle clients.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown gracefully shuts down the server. Listeners are closed
+// immediately so no new connections are accepted. Shutdown then waits for
+// every connected client to finish running handlers and to send pending
+// responses before closing the clients.
+//
+// Requests which haven't started running by the time Shutdown is called are
+// rejected with an ErrorShuttingDown error instead of running, and
+// notifications are dropped. Handlers which are already running finish
+// normally.
+//
+// If ctx is done before all clients are idle, the remaining clients are
+// closed anyway and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mut.Lock()
+	s.shutDown.Store(true)
+	clis := make([]*Client, 0, len(s.clis))
+	for cli := range s.clis {
+		cli.draining.Store(true)
+		clis = append(clis, cli)
+	}
 	var firstError error
+	for lis := range s.listeners {
+		if err := (*lis).Close(); err != nil && firstError == nil {
+			firstError = err
+		}
+	}
+	s.mut.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
 
-	if s.listeners != nil {
-		for lis := range s.listeners {
-			err := (*lis).Close()
-			if err != nil && firstError != nil {
-				firstError = err
+	var ctxErr error
+Wait:
+	for _, cli := range clis {
+		for !cli.idle() {
+			select {
+			case <-ctx.Done():
+				ctxErr = ctx.Err()
+				break Wait
+			case <-ticker.C:
 			}
 		}
 	}
 
-	if s.clis != nil {
-		for cli := range s.clis {
-			err := cli.Close()
-			if err != nil && firstError != nil {
-				firstError = err
-			}
+	for _, cli := range clis {
+		cli.flushResponses()
+		if err := cli.closeTransport(); err != nil && firstError == nil {
+			firstError = err
 		}
 	}
+	s.cancelContexts()
 
+	if ctxErr != nil {
+		return ctxErr
+	}
 	return firstError
 }
 
@@ -0,0 +1,367 @@
+package jsonrpc2
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestServer_HandshakeTimeout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := Server{HandshakeTimeout: 100 * time.Millisecond}
+	go srv.Serve(tls.NewListener(lis, &tls.Config{}))
+	defer srv.Close()
+
+	// Connect without ever starting the handshake. The server should close
+	// the connection once the timeout passes.
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "server did not close connection")
+	}
+}
+
+func TestServer_FirstMessageTimeout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := Server{FirstMessageTimeout: 100 * time.Millisecond}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	// An idle connection is closed once the timeout passes.
+	idle, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer idle.Close()
+
+	require.NoError(t, idle.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = idle.Read(make([]byte, 1))
+	require.Error(t, err)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "server did not close connection")
+	}
+
+	// A connection which sends a message in time stays open.
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+	require.NoError(t, cli.Notify("hello", nil))
+
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-cli.Done():
+		t.Fatal("client closed after sending a message")
+	default:
+	}
+}
+
+func TestServer_OnAccept(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	accepted := make(chan *countingConn, 1)
+	reject := atomic.NewBool(true)
+	srv := Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage("ok") }),
+		OnAccept: func(conn net.Conn) (net.Conn, error) {
+			if reject.Load() {
+				return nil, errors.New("rejected")
+			}
+			wrapped := &countingConn{Conn: conn}
+			accepted <- wrapped
+			return wrapped, nil
+		},
+	}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	// Rejected connections are closed.
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "server did not close connection")
+	}
+
+	// Accepted connections are served through the wrapped conn.
+	reject.Store(false)
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+	res, err := cli.Invoke(context.Background(), "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"ok"`, string(res))
+	require.NotZero(t, (<-accepted).writes.Load())
+}
+
+func TestDialTLS_Timeout(t *testing.T) {
+	// Accept connections but never respond to the handshake.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = DialTLS(ctx, lis.Addr().String(), &tls.Config{InsecureSkipVerify: true}, nil)
+	require.Error(t, err)
+}
+
+// errListener is a net.Listener which accepts no connections and fails to
+// close with err.
+type errListener struct {
+	err    error
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *errListener) Accept() (net.Conn, error) {
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *errListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return l.err
+}
+
+func (l *errListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestServer_Close(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errA, errB := errors.New("a"), errors.New("b")
+	srv := Server{CloseConcurrency: 2}
+	go srv.Serve(lis)
+	go srv.Serve(&errListener{err: errA, closed: make(chan struct{})})
+	go srv.Serve(&errListener{err: errB, closed: make(chan struct{})})
+
+	var clis []*Client
+	for i := 0; i < 5; i++ {
+		cli, err := Dial(lis.Addr().String(), nil)
+		require.NoError(t, err)
+		defer cli.Close()
+		clis = append(clis, cli)
+	}
+	require.Eventually(t, func() bool {
+		srv.mut.Lock()
+		defer srv.mut.Unlock()
+		return len(srv.listeners) == 3 && len(srv.clis) == len(clis)
+	}, time.Second, 10*time.Millisecond)
+
+	err = srv.Close()
+	require.ErrorIs(t, err, errA)
+	require.ErrorIs(t, err, errB)
+
+	for _, cli := range clis {
+		select {
+		case <-cli.Done():
+		case <-time.After(time.Second):
+			t.Fatal("client not closed")
+		}
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	require.NoError(t, joinErrors())
+
+	errA := errors.New("a")
+	require.Equal(t, errA, joinErrors(errA))
+
+	errB := errors.New("b")
+	err := joinErrors(errA, errB)
+	require.Equal(t, "a\nb", err.Error())
+	require.ErrorIs(t, err, errA)
+	require.ErrorIs(t, err, errB)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-release
+		_ = w.WriteMessage("done")
+	})}
+	go srv.Serve(lis)
+
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	type result struct {
+		resp json.RawMessage
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := cli.Invoke(context.Background(), "wait", nil)
+		results <- result{resp, err}
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+
+	// New connections are refused once listeners are closed.
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned before handler finished")
+	default:
+	}
+
+	close(release)
+	require.NoError(t, <-shutdown)
+
+	res := <-results
+	require.NoError(t, res.err)
+	require.Equal(t, `"done"`, string(res.resp))
+}
+
+func TestServer_Shutdown_RejectsNewRequests(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var ran atomic.Bool
+	srv := Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method != "wait" {
+			ran.Store(true)
+			_ = w.WriteMessage("ran")
+			return
+		}
+		close(started)
+		<-release
+		_ = w.WriteMessage("done")
+	})}
+	go srv.Serve(lis)
+
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	results := make(chan error, 1)
+	go func() {
+		_, err := cli.Invoke(context.Background(), "wait", nil)
+		results <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+
+	// Clients are marked as shutting down before listeners are closed.
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = cli.Invoke(context.Background(), "other", nil)
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorShuttingDown, rpcErr.Code)
+	require.False(t, ran.Load(), "handler ran during shutdown")
+
+	close(release)
+	require.NoError(t, <-shutdown)
+	require.NoError(t, <-results)
+}
+
+func TestServer_Shutdown_Timeout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(started)
+		<-r.Context().Done()
+	})}
+	go srv.Serve(lis)
+
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	go func() { _, _ = cli.Invoke(context.Background(), "wait", nil) }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, srv.Shutdown(ctx))
+
+	select {
+	case <-cli.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client wasn't closed")
+	}
+}
+
+func TestServer_InFlight(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		started <- struct{}{}
+		<-release
+		_ = w.WriteMessage(true)
+	})}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		cli, err := Dial(lis.Addr().String(), nil)
+		require.NoError(t, err)
+		defer cli.Close()
+		go func() {
+			_, err := cli.Invoke(context.Background(), "work", nil)
+			errs <- err
+		}()
+	}
+	<-started
+	<-started
+	require.Equal(t, int64(2), srv.InFlight())
+
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+}
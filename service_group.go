@@ -0,0 +1,84 @@
+package jsonrpc2
+
+// Service is implemented by types which register a group of related
+// handlers. Dependencies shared by the handlers, such as database pools or
+// caches, are held by the type implementing Service and passed to its
+// constructor, so a missing dependency is caught when the service is built
+// rather than when a request arrives:
+//
+//	type UserService struct{ db *sql.DB }
+//
+//	var _ jsonrpc2.Service = (*UserService)(nil)
+//
+//	func NewUserService(db *sql.DB) *UserService { return &UserService{db: db} }
+//
+//	func (s *UserService) RegisterRPC(g *jsonrpc2.ServiceGroup) {
+//		g.HandleFunc("get", s.get)
+//		g.HandleFunc("list", s.list)
+//	}
+//
+//	mux.Register("users.", NewUserService(db))
+type Service interface {
+	// RegisterRPC registers the service's handlers with g.
+	RegisterRPC(g *ServiceGroup)
+}
+
+// ServiceGroup registers handlers on a ServeMux under a common method prefix.
+// A ServiceGroup can be created through the Group method on a ServeMux.
+type ServiceGroup struct {
+	mux        *ServeMux
+	prefix     string
+	middleware []Middleware
+}
+
+// Group returns a ServiceGroup which registers handlers on m with prefix
+// prepended to their method names. The prefix includes any separator, such
+// as "users." or "users/".
+func (m *ServeMux) Group(prefix string) *ServiceGroup {
+	return &ServiceGroup{mux: m, prefix: prefix}
+}
+
+// Register registers the handlers of s under prefix. It is shorthand for
+// s.RegisterRPC(m.Group(prefix)).
+func (m *ServeMux) Register(prefix string, s Service) {
+	s.RegisterRPC(m.Group(prefix))
+}
+
+// Prefix returns the prefix prepended to methods registered with g.
+func (g *ServiceGroup) Prefix() string {
+	return g.prefix
+}
+
+// Use adds middleware which wraps handlers registered with g afterwards,
+// including handlers registered through nested groups created afterwards.
+// Middleware added first is the outermost.
+func (g *ServiceGroup) Use(mw ...Middleware) {
+	g.middleware = append(g.middleware, mw...)
+}
+
+// Handle registers the handler for the given method, prefixed with the
+// group's prefix. If a handler already exists for the method, Handle panics.
+func (g *ServiceGroup) Handle(method string, handler Handler) {
+	g.mux.handle(g.prefix+method, Chain(handler, g.middleware...), handler)
+}
+
+// HandleFunc registers the handler function for the given method, prefixed
+// with the group's prefix.
+func (g *ServiceGroup) HandleFunc(method string, handler func(w ResponseWriter, r *Request)) {
+	g.Handle(method, HandlerFunc(handler))
+}
+
+// Group returns a nested ServiceGroup whose prefix is appended to g's prefix.
+// The nested group starts with g's middleware.
+func (g *ServiceGroup) Group(prefix string) *ServiceGroup {
+	return &ServiceGroup{
+		mux:        g.mux,
+		prefix:     g.prefix + prefix,
+		middleware: append([]Middleware(nil), g.middleware...),
+	}
+}
+
+// Register registers the handlers of s under prefix, appended to g's prefix.
+func (g *ServiceGroup) Register(prefix string, s Service) {
+	s.RegisterRPC(g.Group(prefix))
+}
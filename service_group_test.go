@@ -0,0 +1,47 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type counterService struct {
+	counts map[string]int
+}
+
+var _ Service = (*counterService)(nil)
+
+func (s *counterService) RegisterRPC(g *ServiceGroup) {
+	g.HandleFunc("inc", func(w ResponseWriter, r *Request) {
+		s.counts[g.Prefix()]++
+		_ = w.WriteMessage(s.counts[g.Prefix()])
+	})
+}
+
+func TestServiceGroup(t *testing.T) {
+	svc := &counterService{counts: make(map[string]int)}
+
+	mux := NewServeMux()
+	mux.Register("a.", svc)
+	mux.Group("v1/").Register("b.", svc)
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	for _, method := range []string{"a.inc", "a.inc", "v1/b.inc"} {
+		_, err := cli.Invoke(context.Background(), method, nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, map[string]int{"a.": 2, "v1/b.": 1}, svc.counts)
+
+	_, err := cli.Invoke(context.Background(), "inc", nil)
+	require.Error(t, err)
+
+	require.Panics(t, func() { mux.Register("a.", svc) })
+}
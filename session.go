@@ -0,0 +1,455 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SessionMuxOpt is an option function that can be passed to NewSessionMux.
+type SessionMuxOpt func(*SessionMux)
+
+// WithSessionMuxLogger sets the SessionMux to use a logger.
+func WithSessionMuxLogger(l Logger) SessionMuxOpt {
+	return func(m *SessionMux) {
+		if l != nil {
+			m.log = l
+		}
+	}
+}
+
+// WithSessionAcceptor sets the function used to accept sessions opened by the
+// other side of the connection. accept is called with the ID of the new
+// session and returns the Handler to use for it, along with any options for
+// its Client. If accept returns a nil Handler, the session is rejected.
+//
+// Without an acceptor, sessions may only be opened locally with Open.
+func WithSessionAcceptor(accept func(id string) (Handler, []ClientOpt)) SessionMuxOpt {
+	return func(m *SessionMux) {
+		m.accept = accept
+	}
+}
+
+// WithSessionWindow sets the receive window for each session in bytes. The
+// other side of the connection may have up to n bytes of messages in flight
+// for a session before it must wait for the session to read them. Windows
+// smaller than the initial window of 64KiB are ignored.
+//
+// Messages larger than the window can't be sent, so both sides of the
+// connection should use the same window.
+func WithSessionWindow(n int) SessionMuxOpt {
+	return func(m *SessionMux) {
+		if n > initialSessionWindow {
+			m.window = n
+		}
+	}
+}
+
+// initialSessionWindow is the window that both sides of a SessionMux start
+// with for each session.
+const initialSessionWindow = 64 * 1024
+
+// sessionEnvelopeOverhead is the room left for the envelope around a message
+// when limiting the size of frames read by a SessionMux.
+const sessionEnvelopeOverhead = 4 * 1024
+
+// SessionMux multiplexes multiple logical JSON-RPC 2.0 sessions over a single
+// connection. Each session is exposed as its own Client, with an independent
+// ID space and Handler.
+//
+// Frames sent over the connection are wrapped in an envelope identifying the
+// session they belong to:
+//
+//	{"channel": "tenant-a", "message": {"jsonrpc": "2.0", ...}}
+//
+// When a session is closed, the other side is informed with:
+//
+//	{"channel": "tenant-a", "close": true}
+//
+// Each session has its own flow control window, so that bulk traffic on one
+// session can't starve other sessions sharing the connection. Each side may
+// send up to 64KiB of messages for a session before it must wait for the
+// other side to grant more window as it reads them:
+//
+//	{"channel": "tenant-a", "window": 32768}
+//
+// A message may only be sent once it fits in the remaining window, so
+// messages larger than the window can't be sent. Sessions whose other side
+// sends more than its window are closed, and frames too large for any
+// window close the connection.
+//
+// Both sides of the connection must use a SessionMux.
+type SessionMux struct {
+	log    Logger
+	accept func(id string) (Handler, []ClientOpt)
+	window int
+
+	f     Framer
+	txMut sync.Mutex
+
+	mut      sync.Mutex
+	sessions map[string]*sessionFramer
+	clients  map[string]*Client
+	closed   bool
+
+	closeOnce sync.Once
+	closeErr  error
+	done      chan struct{}
+}
+
+// sessionEnvelope is the wire format used by a SessionMux.
+type sessionEnvelope struct {
+	Channel string          `json:"channel"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Close   bool            `json:"close,omitempty"`
+	Window  int             `json:"window,omitempty"`
+}
+
+// NewSessionMux creates a SessionMux and starts reading frames from rw.
+//
+// If rw implements io.Closer, it will be closed when the SessionMux is closed.
+func NewSessionMux(rw io.ReadWriter, opts ...SessionMuxOpt) *SessionMux {
+	m := &SessionMux{
+		log:    NewNopLogger(),
+		window: initialSessionWindow,
+
+		sessions: make(map[string]*sessionFramer),
+		clients:  make(map[string]*Client),
+		done:     make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(m)
+	}
+
+	// No session may be sent more than its window at once, so larger frames
+	// are never valid.
+	f := NewStreamFramer(rw).(*streamFramer)
+	f.SetMaxFrameSize(m.window + sessionEnvelopeOverhead)
+	m.f = f
+
+	go m.processFrames()
+	return m
+}
+
+// Open opens a new session with the given ID. handler will be invoked for
+// each request received over the session. Open fails if a session with the
+// same ID is already open.
+func (m *SessionMux) Open(id string, handler Handler, opts ...ClientOpt) (*Client, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.closed {
+		return nil, fmt.Errorf("session mux closed")
+	}
+	if _, exist := m.sessions[id]; exist {
+		return nil, fmt.Errorf("session %s already open", id)
+	}
+	return m.openLocked(id, handler, opts), nil
+}
+
+func (m *SessionMux) openLocked(id string, handler Handler, opts []ClientOpt) *Client {
+	sf := &sessionFramer{
+		mux:      m,
+		id:       id,
+		ready:    make(chan struct{}, 1),
+		credited: make(chan struct{}, 1),
+		credit:   initialSessionWindow,
+		closed:   make(chan struct{}),
+	}
+	m.sessions[id] = sf
+
+	// Grant the rest of our receive window to the other side.
+	if extra := m.window - initialSessionWindow; extra > 0 {
+		go func() {
+			_ = m.writeEnvelope(sessionEnvelope{Channel: id, Window: extra})
+		}()
+	}
+
+	cli := newClient(newFramedTransport(sf), handler, opts...)
+	m.clients[id] = cli
+	return cli
+}
+
+// Session returns the Client for an open session.
+func (m *SessionMux) Session(id string) (*Client, bool) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	cli, ok := m.clients[id]
+	return cli, ok
+}
+
+// Close closes all sessions and the underlying connection.
+func (m *SessionMux) Close() error {
+	m.closeOnce.Do(func() {
+		m.mut.Lock()
+		m.closed = true
+		sessions := m.sessions
+		m.sessions = make(map[string]*sessionFramer)
+		m.clients = make(map[string]*Client)
+		m.mut.Unlock()
+
+		for _, sf := range sessions {
+			sf.shutdown()
+		}
+		if c, ok := m.f.(io.Closer); ok {
+			m.closeErr = c.Close()
+		}
+	})
+	return m.closeErr
+}
+
+// Done returns a channel that is closed when the underlying connection has
+// closed.
+func (m *SessionMux) Done() <-chan struct{} {
+	return m.done
+}
+
+// processFrames runs in the background and routes incoming frames to their
+// sessions.
+func (m *SessionMux) processFrames() {
+	defer close(m.done)
+	defer m.Close()
+
+	for {
+		frame, err := m.f.ReadFrame()
+		if err != nil {
+			LevelInfo.Log(m.log, "msg", "closing session mux", "err", err)
+			return
+		}
+
+		var env sessionEnvelope
+		if err := json.Unmarshal(frame, &env); err != nil {
+			LevelWarn.Log(m.log, "msg", "dropping invalid session frame", "err", err)
+			continue
+		}
+
+		if env.Close {
+			m.remoteClose(env.Channel)
+			continue
+		}
+
+		sf := m.lookup(env.Channel)
+		if sf == nil {
+			LevelWarn.Log(m.log, "msg", "dropping frame for unknown session", "channel", env.Channel)
+			continue
+		}
+		if env.Window > 0 {
+			sf.grant(env.Window)
+		}
+		if env.Message != nil {
+			if err := sf.enqueue(env.Message); err != nil {
+				LevelWarn.Log(m.log, "msg", "closing session", "channel", env.Channel, "err", err)
+				_ = m.localClose(sf)
+			}
+		}
+	}
+}
+
+// lookup finds the session for id, accepting a new session if one doesn't
+// exist and an acceptor is configured.
+func (m *SessionMux) lookup(id string) *sessionFramer {
+	m.mut.Lock()
+	sf, ok := m.sessions[id]
+	closed := m.closed
+	m.mut.Unlock()
+
+	if ok {
+		return sf
+	}
+	if closed || m.accept == nil {
+		return nil
+	}
+
+	handler, opts := m.accept(id)
+	if handler == nil {
+		return nil
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	// The session may have been opened locally while accept was running.
+	if sf, ok := m.sessions[id]; ok {
+		return sf
+	}
+	if m.closed {
+		return nil
+	}
+	m.openLocked(id, handler, opts)
+	return m.sessions[id]
+}
+
+// remoteClose handles the other side closing a session.
+func (m *SessionMux) remoteClose(id string) {
+	m.mut.Lock()
+	sf, ok := m.sessions[id]
+	if ok {
+		delete(m.sessions, id)
+		delete(m.clients, id)
+	}
+	m.mut.Unlock()
+
+	if ok {
+		sf.shutdown()
+	}
+}
+
+// localClose handles a session being closed locally.
+func (m *SessionMux) localClose(sf *sessionFramer) error {
+	m.mut.Lock()
+	cur, ok := m.sessions[sf.id]
+	if ok && cur == sf {
+		delete(m.sessions, sf.id)
+		delete(m.clients, sf.id)
+	}
+	m.mut.Unlock()
+
+	sf.shutdown()
+	if !ok || cur != sf {
+		return nil
+	}
+	return m.writeEnvelope(sessionEnvelope{Channel: sf.id, Close: true})
+}
+
+func (m *SessionMux) writeEnvelope(env sessionEnvelope) error {
+	// Messages are written without escaping HTML characters, so that they
+	// take no more of the other side's window than they were charged for.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(env); err != nil {
+		return err
+	}
+
+	m.txMut.Lock()
+	defer m.txMut.Unlock()
+	return m.f.WriteFrame(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// sessionFramer is a Framer for an individual session within a SessionMux.
+type sessionFramer struct {
+	mux *SessionMux
+	id  string
+
+	// Receiving side. unacked tracks bytes received that haven't been granted
+	// back to the other side, which bounds queue to the window. consumed
+	// tracks bytes read since the last window update.
+	recvMut  sync.Mutex
+	queue    [][]byte
+	unacked  int
+	consumed int
+	ready    chan struct{}
+
+	// Sending side. credit is the number of bytes that may be sent before
+	// waiting for a window update.
+	sendMut  sync.Mutex
+	credit   int
+	credited chan struct{}
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (sf *sessionFramer) ReadFrame() ([]byte, error) {
+	for {
+		sf.recvMut.Lock()
+		if len(sf.queue) > 0 {
+			frame := sf.queue[0]
+			sf.queue[0] = nil
+			sf.queue = sf.queue[1:]
+
+			// Grant window back to the other side once half the window has been
+			// consumed, or once every queued frame has been read, so that a
+			// sender waiting for room for a large message isn't left waiting.
+			sf.consumed += len(frame)
+			var update int
+			if sf.consumed >= sf.mux.window/2 || len(sf.queue) == 0 {
+				update, sf.consumed = sf.consumed, 0
+				sf.unacked -= update
+			}
+			sf.recvMut.Unlock()
+
+			if update > 0 {
+				_ = sf.mux.writeEnvelope(sessionEnvelope{Channel: sf.id, Window: update})
+			}
+			return frame, nil
+		}
+		sf.recvMut.Unlock()
+
+		select {
+		case <-sf.ready:
+		case <-sf.closed:
+			return nil, io.EOF
+		}
+	}
+}
+
+func (sf *sessionFramer) WriteFrame(frame []byte) error {
+	if len(frame) > sf.mux.window {
+		return fmt.Errorf("message of %d bytes exceeds session window of %d bytes", len(frame), sf.mux.window)
+	}
+
+	for {
+		sf.sendMut.Lock()
+		if sf.credit >= len(frame) {
+			sf.credit -= len(frame)
+			sf.sendMut.Unlock()
+			break
+		}
+		sf.sendMut.Unlock()
+
+		select {
+		case <-sf.credited:
+		case <-sf.closed:
+			return io.ErrClosedPipe
+		}
+	}
+
+	select {
+	case <-sf.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+	return sf.mux.writeEnvelope(sessionEnvelope{Channel: sf.id, Message: frame})
+}
+
+// enqueue queues a frame received from the other side. An error is returned
+// if the frame doesn't fit in what is left of the other side's window.
+func (sf *sessionFramer) enqueue(frame []byte) error {
+	sf.recvMut.Lock()
+	if sf.unacked+len(frame) > sf.mux.window {
+		sf.recvMut.Unlock()
+		return fmt.Errorf("peer exceeded session window of %d bytes", sf.mux.window)
+	}
+	sf.unacked += len(frame)
+	sf.queue = append(sf.queue, frame)
+	sf.recvMut.Unlock()
+
+	select {
+	case sf.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// grant adds n bytes of credit for sending frames.
+func (sf *sessionFramer) grant(n int) {
+	sf.sendMut.Lock()
+	sf.credit += n
+	sf.sendMut.Unlock()
+
+	select {
+	case sf.credited <- struct{}{}:
+	default:
+	}
+}
+
+func (sf *sessionFramer) Close() error {
+	return sf.mux.localClose(sf)
+}
+
+func (sf *sessionFramer) shutdown() {
+	sf.closeOnce.Do(func() { close(sf.closed) })
+}
@@ -0,0 +1,281 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionMux(t *testing.T) {
+	left, right := net.Pipe()
+
+	echo := func(prefix string) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			var s string
+			_ = json.Unmarshal(r.Params, &s)
+			_ = w.WriteMessage(prefix + s)
+		})
+	}
+
+	server := NewSessionMux(right, WithSessionAcceptor(func(id string) (Handler, []ClientOpt) {
+		if id == "rejected" {
+			return nil, nil
+		}
+		return echo(id + ":"), nil
+	}))
+	defer server.Close()
+
+	client := NewSessionMux(left)
+	defer client.Close()
+
+	a, err := client.Open("a", nil)
+	require.NoError(t, err)
+	b, err := client.Open("b", nil)
+	require.NoError(t, err)
+
+	_, err = client.Open("a", nil)
+	require.Error(t, err, "sessions may not be opened twice")
+
+	ctx := context.Background()
+
+	resp, err := a.Invoke(ctx, "echo", "hello")
+	require.NoError(t, err)
+	require.Equal(t, `"a:hello"`, string(resp))
+
+	resp, err = b.Invoke(ctx, "echo", "world")
+	require.NoError(t, err)
+	require.Equal(t, `"b:world"`, string(resp))
+
+	// Closing a session should close the remote side of the session.
+	remoteA, ok := server.Session("a")
+	require.True(t, ok)
+	require.NoError(t, a.Close())
+
+	select {
+	case <-remoteA.Done():
+	case <-time.After(time.Second):
+		require.FailNow(t, "remote session did not close")
+	}
+
+	// Other sessions should be unaffected.
+	resp, err = b.Invoke(ctx, "echo", "again")
+	require.NoError(t, err)
+	require.Equal(t, `"b:again"`, string(resp))
+}
+
+func TestSessionMux_Close(t *testing.T) {
+	left, right := net.Pipe()
+
+	server := NewSessionMux(right, WithSessionAcceptor(func(id string) (Handler, []ClientOpt) {
+		return DefaultHandler, nil
+	}))
+	client := NewSessionMux(left)
+
+	sess, err := client.Open("a", nil)
+	require.NoError(t, err)
+	require.NoError(t, sess.Notify("ping", nil))
+
+	require.NoError(t, server.Close())
+
+	for _, done := range []<-chan struct{}{client.Done(), sess.Done()} {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			require.FailNow(t, "session mux did not close")
+		}
+	}
+}
+
+func TestSessionMux_FlowControl(t *testing.T) {
+	left, right := net.Pipe()
+
+	client := NewSessionMux(left)
+	defer client.Close()
+
+	// Act as the other side manually so window updates can be controlled.
+	peer := NewStreamFramer(right)
+	received := make(chan sessionEnvelope, 100)
+	go func() {
+		for {
+			frame, err := peer.ReadFrame()
+			if err != nil {
+				close(received)
+				return
+			}
+			var env sessionEnvelope
+			_ = json.Unmarshal(frame, &env)
+			received <- env
+		}
+	}()
+
+	bulk, err := client.Open("bulk", nil)
+	require.NoError(t, err)
+	interactive, err := client.Open("interactive", nil)
+	require.NoError(t, err)
+
+	// Send notifications on bulk until the window is exhausted.
+	payload := strings.Repeat("x", 16*1024)
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		for i := 0; i < 5; i++ {
+			_ = bulk.Notify("bulk", payload)
+		}
+	}()
+
+	waitFrames := func(n int, channel string) {
+		for i := 0; i < n; i++ {
+			select {
+			case env := <-received:
+				require.Equal(t, channel, env.Channel)
+			case <-time.After(time.Second):
+				require.FailNow(t, "expected frame")
+			}
+		}
+	}
+
+	// 3 messages fit in the initial window. The 4th must wait for room.
+	waitFrames(3, "bulk")
+	select {
+	case <-blocked:
+		require.FailNow(t, "bulk session should be blocked on window")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Interactive sessions should not be affected.
+	require.NoError(t, interactive.Notify("ping", nil))
+	waitFrames(1, "interactive")
+
+	// Granting window should unblock the bulk session.
+	bb, err := json.Marshal(sessionEnvelope{Channel: "bulk", Window: 64 * 1024})
+	require.NoError(t, err)
+	require.NoError(t, peer.WriteFrame(bb))
+
+	waitFrames(2, "bulk")
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		require.FailNow(t, "bulk session should be unblocked")
+	}
+
+	// Messages larger than the window can never be sent.
+	err = bulk.Notify("bulk", strings.Repeat("x", 64*1024))
+	require.Error(t, err)
+}
+
+func TestSessionMux_WindowExceeded(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+
+	accepted := make(chan struct{}, 1)
+	server := NewSessionMux(right, WithSessionAcceptor(func(id string) (Handler, []ClientOpt) {
+		select {
+		case accepted <- struct{}{}:
+		default:
+		}
+		return HandlerFunc(func(w ResponseWriter, r *Request) {}), nil
+	}))
+	defer server.Close()
+
+	// Misbehave by ignoring window updates. The server grants back at most
+	// two messages, but can't send the update until we read from the
+	// connection, so the later messages overrun the 64KiB window.
+	payload := strings.Repeat("x", 16*1024)
+	frame, err := json.Marshal(sessionEnvelope{
+		Channel: "bulk",
+		Message: json.RawMessage(`{"jsonrpc": "2.0", "method": "bulk", "params": "` + payload + `"}`),
+	})
+	require.NoError(t, err)
+
+	peer := NewStreamFramer(left)
+	go func() {
+		for i := 0; i < 7; i++ {
+			if err := peer.WriteFrame(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Don't read until the session is gone, otherwise the window update
+	// could be delivered in time.
+	<-accepted
+	require.Eventually(t, func() bool {
+		_, ok := server.Session("bulk")
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for {
+		bb, err := peer.ReadFrame()
+		require.NoError(t, err)
+
+		var env sessionEnvelope
+		require.NoError(t, json.Unmarshal(bb, &env))
+		require.Equal(t, "bulk", env.Channel)
+		if env.Close {
+			break
+		}
+	}
+}
+
+func TestSessionMux_MessageExceedsWindow(t *testing.T) {
+	left, right := net.Pipe()
+	defer left.Close()
+
+	accepted := make(chan struct{}, 1)
+	server := NewSessionMux(right, WithSessionAcceptor(func(id string) (Handler, []ClientOpt) {
+		select {
+		case accepted <- struct{}{}:
+		default:
+		}
+		return HandlerFunc(func(w ResponseWriter, r *Request) {}), nil
+	}))
+	defer server.Close()
+
+	open, err := json.Marshal(sessionEnvelope{
+		Channel: "bulk",
+		Message: json.RawMessage(`{"jsonrpc": "2.0", "method": "ping"}`),
+	})
+	require.NoError(t, err)
+
+	// A single message larger than the window closes the session, even
+	// though none of the window was used.
+	payload := strings.Repeat("x", 64*1024)
+	large, err := json.Marshal(sessionEnvelope{
+		Channel: "bulk",
+		Message: json.RawMessage(`{"jsonrpc": "2.0", "method": "bulk", "params": "` + payload + `"}`),
+	})
+	require.NoError(t, err)
+
+	peer := NewStreamFramer(left)
+	go func() {
+		_ = peer.WriteFrame(open)
+		_ = peer.WriteFrame(large)
+	}()
+
+	<-accepted
+	for {
+		bb, err := peer.ReadFrame()
+		require.NoError(t, err)
+
+		var env sessionEnvelope
+		require.NoError(t, json.Unmarshal(bb, &env))
+		if env.Close {
+			break
+		}
+	}
+
+	// Frames too large for any window close the connection.
+	go func() {
+		_ = peer.WriteFrame([]byte(`{"channel": "other", "message": "` + strings.Repeat("x", 128*1024) + `"}`))
+	}()
+	select {
+	case <-server.Done():
+	case <-time.After(time.Second):
+		require.FailNow(t, "session mux did not close")
+	}
+}
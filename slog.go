@@ -0,0 +1,61 @@
+//go:build go1.21
+// +build go1.21
+
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// NewSlogLogger returns a Logger which logs to l. Entries are logged at the
+// slog level matching their Level, or at info if they have none, with the
+// value of their "msg" key as the message.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return LoggerFunc(func(keyvals ...interface{}) error {
+		var (
+			lvl   = slog.LevelInfo
+			msg   string
+			attrs = make([]slog.Attr, 0, len(keyvals)/2)
+		)
+		for i := 0; i < len(keyvals); i += 2 {
+			key := fmt.Sprint(keyvals[i])
+			if i+1 == len(keyvals) {
+				attrs = append(attrs, slog.Any("!BADKEY", keyvals[i]))
+				break
+			}
+			val := keyvals[i+1]
+
+			switch v := val.(type) {
+			case Level:
+				if key == LevelKey {
+					lvl = slogLevel(v)
+					continue
+				}
+			case string:
+				if key == "msg" && msg == "" {
+					msg = v
+					continue
+				}
+			}
+			attrs = append(attrs, slog.Any(key, val))
+		}
+
+		l.LogAttrs(context.Background(), lvl, msg, attrs...)
+		return nil
+	})
+}
+
+func slogLevel(lvl Level) slog.Level {
+	switch lvl {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package jsonrpc2
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := NewSlogLogger(slog.New(h))
+
+	LevelWarn.Log(l, "msg", "closing client", "err", errors.New("eof"))
+	LevelDebug.Log(l, "msg", "filtered out")
+	_ = l.Log("msg", "no level", "odd")
+
+	require.Equal(t, "level=WARN msg=\"closing client\" err=eof\n"+
+		"level=INFO msg=\"no level\" !BADKEY=odd\n", buf.String())
+}
@@ -0,0 +1,120 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultSniffSize is the number of bytes read from new connections for
+// Server.Sniff when Server.SniffSize is zero.
+const DefaultSniffSize = 4096
+
+// DefaultSniffTimeout is how long to wait for the first bytes of a new
+// connection for Server.Sniff when Server.FirstMessageTimeout is zero.
+const DefaultSniffTimeout = 10 * time.Second
+
+// Route is how a connection is served, as chosen by Server.Sniff.
+type Route struct {
+	// Handler handles requests from the connection. If nil, the server's
+	// Handler is used.
+	Handler Handler
+
+	// NewFramer may be provided to frame messages on the connection, such
+	// as with NewHeaderFramer. If nil, NewStreamFramer is used.
+	NewFramer func(rw io.ReadWriter) Framer
+
+	// ClientOpts are passed to NewClient after the server's ClientOpts, so
+	// they may override them.
+	ClientOpts []ClientOpt
+}
+
+// SniffedRequest describes the first request read from a connection.
+type SniffedRequest struct {
+	// Version is the value of the request's "jsonrpc" member, which is
+	// "2.0" for JSON-RPC 2.0 requests and empty for JSON-RPC 1.0 requests.
+	Version string
+
+	Method string
+
+	// Batch is set if the request is the first in a batch.
+	Batch bool
+
+	// Framed is set if the request was preceded by headers, as sent by
+	// clients using a HeaderFramer.
+	Framed bool
+}
+
+// SniffRequest parses the first request in prefix, the first bytes read from
+// a connection, for use in Server.Sniff. Anything before the first JSON
+// object or array, such as headers, is skipped. ok is false if prefix
+// doesn't hold a complete request.
+func SniffRequest(prefix []byte) (req SniffedRequest, ok bool) {
+	start := bytes.IndexAny(prefix, "{[")
+	if start < 0 {
+		return req, false
+	}
+	req.Framed = start > 0 && bytes.Contains(prefix[:start], []byte("\r\n\r\n"))
+	dec := json.NewDecoder(bytes.NewReader(prefix[start:]))
+
+	if prefix[start] == '[' {
+		req.Batch = true
+		if _, err := dec.Token(); err != nil || !dec.More() {
+			return req, false
+		}
+	}
+
+	var msg struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+	}
+	if err := dec.Decode(&msg); err != nil {
+		return req, false
+	}
+	req.Version, req.Method = msg.JSONRPC, msg.Method
+	return req, true
+}
+
+// sniff reads the first bytes from conn and returns the route s.Sniff
+// chooses for them, along with a conn which replays the bytes read.
+func (s *Server) sniff(conn net.Conn) (net.Conn, Route, error) {
+	size := s.SniffSize
+	if size <= 0 {
+		size = DefaultSniffSize
+	}
+	timeout := s.FirstMessageTimeout
+	if timeout <= 0 {
+		timeout = DefaultSniffTimeout
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, Route{}, err
+	}
+	// Only a single read is done, so clients aren't required to send a
+	// full SniffSize bytes before being served.
+	prefix := make([]byte, size)
+	n, err := conn.Read(prefix)
+	if n == 0 && err != nil {
+		return nil, Route{}, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, Route{}, err
+	}
+	prefix = prefix[:n]
+
+	route, err := s.Sniff(prefix)
+	if err != nil {
+		return nil, Route{}, err
+	}
+	return &prefixConn{Conn: conn, r: io.MultiReader(bytes.NewReader(prefix), conn)}, route, nil
+}
+
+// prefixConn is a net.Conn which replays bytes already read from Conn.
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) { return c.r.Read(p) }
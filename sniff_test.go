@@ -0,0 +1,123 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffRequest(t *testing.T) {
+	tt := []struct {
+		name   string
+		prefix string
+		expect SniffedRequest
+		ok     bool
+	}{
+		{"2.0", `{"jsonrpc": "2.0", "method": "sum", "id": 1}`, SniffedRequest{Version: "2.0", Method: "sum"}, true},
+		{"1.0", `{"method": "sum", "params": [1, 2], "id": 1}`, SniffedRequest{Method: "sum"}, true},
+		{"batch", `[{"jsonrpc": "2.0", "method": "a"}, {"jsonrpc": "2.0", "method": "b"}]`, SniffedRequest{Version: "2.0", Method: "a", Batch: true}, true},
+		{"headers", "Content-Length: 37\r\n\r\n{\"jsonrpc\": \"2.0\", \"method\": \"sum\"}", SniffedRequest{Version: "2.0", Method: "sum", Framed: true}, true},
+		{"truncated", `{"jsonrpc": "2.0", "meth`, SniffedRequest{}, false},
+		{"empty batch", `[]`, SniffedRequest{Batch: true}, false},
+		{"not json", `hello`, SniffedRequest{}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, ok := SniffRequest([]byte(tc.prefix))
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expect, req)
+		})
+	}
+}
+
+func TestServer_Sniff(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	reply := func(res string) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage(res) })
+	}
+	srv := Server{
+		Handler: reply("stream"),
+		Sniff: func(prefix []byte) (Route, error) {
+			req, ok := SniffRequest(prefix)
+			switch {
+			case !ok:
+				return Route{}, errors.New("unrecognized request")
+			case req.Framed:
+				return Route{
+					Handler:   reply("framed"),
+					NewFramer: func(rw io.ReadWriter) Framer { return NewHeaderFramer(rw) },
+				}, nil
+			default:
+				return Route{}, nil
+			}
+		},
+	}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	// Stream clients are served by the server's handler.
+	cli, err := Dial(lis.Addr().String(), nil)
+	require.NoError(t, err)
+	defer cli.Close()
+	res, err := cli.Invoke(ctx, "framing", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"stream"`, string(res))
+
+	// Header-framed clients are routed to their own handler and framer,
+	// which still read the sniffed request.
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	framed := NewFramedClient(NewHeaderFramer(conn), nil)
+	defer framed.Close()
+	res, err = framed.Invoke(ctx, "framing", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"framed"`, string(res))
+
+	// Connections Sniff rejects are closed.
+	conn, err = net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "server did not close connection")
+	}
+}
+
+func TestServer_Sniff_Timeout(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := Server{
+		FirstMessageTimeout: 50 * time.Millisecond,
+		Sniff:               func(prefix []byte) (Route, error) { return Route{}, nil },
+	}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	// Connections which send nothing are closed.
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		require.False(t, netErr.Timeout(), "server did not close connection")
+	}
+}
@@ -0,0 +1,68 @@
+package jsonrpc2
+
+import "fmt"
+
+// ConnState is the state of a connection. States only move forward, from
+// StateConnecting to StateClosed, except for a ReconnectingClient, which
+// returns to StateConnecting each time its connection is lost.
+type ConnState int32
+
+const (
+	// StateConnecting means the connection is being established.
+	StateConnecting ConnState = iota
+	// StateReady means the connection is established and may be used.
+	StateReady
+	// StateClosing means the connection is shutting down, either because it
+	// was closed or because the peer went away.
+	StateClosing
+	// StateClosed means the connection has stopped reading messages.
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateReady:
+		return "ready"
+	case StateClosing:
+		return "closing"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int32(s))
+	}
+}
+
+// WithStateListener sets a function to call when the Client changes state.
+// A Client is created in StateReady, so f is first called with
+// StateConnecting and StateReady before the constructor returns. f is called
+// from the goroutine causing the transition and must not block; in
+// particular, it must not wait for the Client to close.
+func WithStateListener(f func(from, to ConnState)) ClientOpt {
+	return func(c *Client) {
+		c.stateListener = f
+	}
+}
+
+// State returns the current state of the Client.
+func (c *Client) State() ConnState {
+	return ConnState(c.state.Load())
+}
+
+// setState moves the Client forward to state to and calls the state
+// listener. It does nothing if the Client is already in or past to.
+func (c *Client) setState(to ConnState) {
+	for {
+		from := ConnState(c.state.Load())
+		if from >= to {
+			return
+		}
+		if c.state.CAS(int32(from), int32(to)) {
+			if c.stateListener != nil {
+				c.stateListener(from, to)
+			}
+			return
+		}
+	}
+}
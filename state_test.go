@@ -0,0 +1,71 @@
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_StateListener(t *testing.T) {
+	for _, closedBy := range []string{"client", "peer"} {
+		t.Run(closedBy, func(t *testing.T) {
+			transitions := make(chan string, 10)
+			listener := func(from, to ConnState) {
+				transitions <- fmt.Sprintf("%s->%s", from, to)
+			}
+
+			srvConn, cliConn := net.Pipe()
+			srv := NewClient(srvConn, nil)
+			cli := NewClient(cliConn, nil, WithStateListener(listener))
+			require.Equal(t, StateReady, cli.State())
+
+			if closedBy == "client" {
+				require.NoError(t, cli.Close())
+				require.NoError(t, srv.Close())
+			} else {
+				require.NoError(t, srv.Close())
+				<-cli.Done()
+				require.NoError(t, cli.Close())
+			}
+
+			require.Equal(t, "connecting->ready", <-transitions)
+			require.Equal(t, "ready->closing", <-transitions)
+			require.Equal(t, "closing->closed", <-transitions)
+			require.Equal(t, StateClosed, cli.State())
+			require.Empty(t, transitions)
+		})
+	}
+}
+
+func TestReconnectingClient_State(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, nil)
+	defer srv.Close()
+
+	transitions := make(chan string, 10)
+	dialed := false
+	rc := NewReconnectingClient(func(ctx context.Context) (*Client, error) {
+		if dialed {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		dialed = true
+		return NewClient(cliConn, nil), nil
+	}, WithReconnectStateListener(func(from, to ConnState) {
+		transitions <- fmt.Sprintf("%s->%s", from, to)
+	}))
+
+	require.Equal(t, "connecting->ready", <-transitions)
+	require.Equal(t, StateReady, rc.State())
+
+	// Losing the connection moves back to connecting.
+	require.NoError(t, srv.Close())
+	require.Equal(t, "ready->connecting", <-transitions)
+
+	require.NoError(t, rc.Close())
+	require.Equal(t, "connecting->closed", <-transitions)
+	require.Equal(t, StateClosed, rc.State())
+}
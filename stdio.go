@@ -0,0 +1,195 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// NewStdioClient creates a Client which reads messages from in and writes
+// messages to out, such as a program's os.Stdin and os.Stdout. This is the
+// usual transport for language servers and plugins launched by a host
+// process.
+//
+// When the Client is closed, out and then in are closed if they implement
+// io.Closer.
+func NewStdioClient(in io.Reader, out io.Writer, handler Handler, opts ...ClientOpt) *Client {
+	return NewClient(&stdio{r: in, w: out}, handler, opts...)
+}
+
+// stdio combines a reader and writer into an io.ReadWriteCloser.
+type stdio struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (s *stdio) Read(p []byte) (int, error)  { return s.r.Read(p) }
+func (s *stdio) Write(p []byte) (int, error) { return s.w.Write(p) }
+
+func (s *stdio) Close() error {
+	var werr, rerr error
+	if c, ok := s.w.(io.Closer); ok {
+		werr = c.Close()
+	}
+	if c, ok := s.r.(io.Closer); ok {
+		rerr = c.Close()
+	}
+	if werr != nil {
+		return werr
+	}
+	return rerr
+}
+
+// exitGracePeriod is how long CommandClient.Invoke waits for the process to
+// exit after the connection closes, so that the exit error can be returned.
+// Processes usually exit just after their stdout is closed.
+const exitGracePeriod = 100 * time.Millisecond
+
+// CommandClient is a Client connected to the stdin and stdout of a child
+// process. CommandClient does not restart the process when it exits; see the
+// subprocess package for a supervisor which does.
+type CommandClient struct {
+	cli *Client
+	cmd *exec.Cmd
+
+	exited  chan struct{}
+	exitErr error
+}
+
+var _ Conn = (*CommandClient)(nil)
+
+// StartCommand starts cmd and returns a CommandClient which speaks JSON-RPC
+// 2.0 over its stdin and stdout. cmd must not have Stdin or Stdout set.
+// handler will be invoked for each request received from the process.
+func StartCommand(cmd *exec.Cmd, handler Handler, opts ...ClientOpt) (*CommandClient, error) {
+	if cmd.Stdin != nil || cmd.Stdout != nil {
+		return nil, errors.New("jsonrpc2: command already has Stdin or Stdout set")
+	}
+
+	// os.Pipe is used instead of cmd.StdinPipe and cmd.StdoutPipe, since
+	// cmd.Wait closes those pipes and would race with the Client reading
+	// the last messages from stdout.
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout = stdinR, stdoutW
+
+	err = cmd.Start()
+
+	// The child has its own copies of its ends of the pipes.
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, fmt.Errorf("failed to start process: %w", err)
+	}
+
+	c := &CommandClient{
+		cli:    NewStdioClient(stdoutR, stdinW, handler, opts...),
+		cmd:    cmd,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		c.exitErr = cmd.Wait()
+		close(c.exited)
+	}()
+	return c, nil
+}
+
+// Client returns the Client connected to the process.
+func (c *CommandClient) Client() *Client {
+	return c.cli
+}
+
+// Process returns the underlying process.
+func (c *CommandClient) Process() *os.Process {
+	return c.cmd.Process
+}
+
+// Exited returns a channel that is closed once the process has exited.
+func (c *CommandClient) Exited() <-chan struct{} {
+	return c.exited
+}
+
+// Wait waits for the process to exit and returns its exit error, as returned
+// by exec.Cmd.Wait.
+func (c *CommandClient) Wait() error {
+	<-c.exited
+	return c.exitErr
+}
+
+// Invoke invokes an RPC on the process. If the process exits before
+// responding, the returned error wraps ErrConnClosed and describes how the
+// process exited.
+func (c *CommandClient) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	res, err := c.cli.Invoke(ctx, method, msg)
+	if errors.Is(err, ErrConnClosed) {
+		return nil, c.exitError(ctx, err)
+	}
+	return res, err
+}
+
+// Notify sends a notification to the process.
+func (c *CommandClient) Notify(method string, msg interface{}) error {
+	err := c.cli.Notify(method, msg)
+	if errors.Is(err, ErrConnClosed) {
+		return c.exitError(context.Background(), err)
+	}
+	return err
+}
+
+// exitError adds the exit error of the process to err if the process exits
+// within exitGracePeriod.
+func (c *CommandClient) exitError(ctx context.Context, err error) error {
+	t := time.NewTimer(exitGracePeriod)
+	defer t.Stop()
+
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		return err
+	case <-t.C:
+		return err
+	}
+	if c.exitErr == nil {
+		return fmt.Errorf("%w: process exited", err)
+	}
+	return fmt.Errorf("%w: process exited: %v", err, c.exitErr)
+}
+
+// Stop asks the process to exit by closing its stdin, and kills it if it
+// hasn't exited once ctx is done. Stop returns the exit error of the
+// process.
+func (c *CommandClient) Stop(ctx context.Context) error {
+	_ = c.cli.Close()
+
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		_ = c.cmd.Process.Kill()
+		<-c.exited
+	}
+	return c.exitErr
+}
+
+// Close stops the process, killing it if it doesn't exit within 5 seconds.
+// Unlike Stop, Close doesn't return the exit error of the process.
+func (c *CommandClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = c.Stop(ctx)
+	return nil
+}
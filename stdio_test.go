@@ -0,0 +1,75 @@
+package jsonrpc2
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperProcess isn't a real test. It's used as the child process for
+// the CommandClient tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("JSONRPC2_WANT_HELPER") != "1" {
+		return
+	}
+
+	cli := NewStdioClient(os.Stdin, os.Stdout, HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch r.Method {
+		case "echo":
+			_ = w.WriteMessage(r.Params)
+		case "crash":
+			os.Exit(3)
+		}
+	}))
+	<-cli.Done()
+	os.Exit(0)
+}
+
+func helperCommand() *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "JSONRPC2_WANT_HELPER=1")
+	return cmd
+}
+
+func TestCommandClient(t *testing.T) {
+	cc, err := StartCommand(helperCommand(), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := cc.Invoke(ctx, "echo", "hello")
+	require.NoError(t, err)
+	require.JSONEq(t, `"hello"`, string(res))
+
+	// Closing stdin asks the process to exit cleanly.
+	require.NoError(t, cc.Stop(ctx))
+}
+
+func TestCommandClient_Exit(t *testing.T) {
+	cc, err := StartCommand(helperCommand(), nil)
+	require.NoError(t, err)
+	defer cc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = cc.Invoke(ctx, "crash", nil)
+	require.ErrorIs(t, err, ErrConnClosed)
+	require.Contains(t, err.Error(), "exit status 3")
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, cc.Wait(), &exitErr)
+	require.Equal(t, 3, exitErr.ExitCode())
+}
+
+func TestStartCommand_StdioSet(t *testing.T) {
+	cmd := helperCommand()
+	cmd.Stdout = os.Stderr
+	_, err := StartCommand(cmd, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,334 @@
+// Package subprocess runs a child process which speaks JSON-RPC 2.0 over its
+// stdin and stdout, restarting it with backoff whenever it exits.
+//
+// This is the common pattern for plugin hosts, where plugins are separate
+// executables launched and supervised by the host:
+//
+//	p := subprocess.New(func() *exec.Cmd {
+//		return exec.Command("./my-plugin")
+//	}, handler)
+//	defer p.Close()
+//
+//	res, err := p.Invoke(ctx, "greet", "world")
+package subprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// ErrNotRunning is returned when the process is not currently running.
+var ErrNotRunning = errors.New("process not running")
+
+// ErrStopped is returned when the Process has been closed.
+var ErrStopped = errors.New("process stopped")
+
+// State is the state of a Process.
+type State int
+
+const (
+	// StateStarting means the process is being started.
+	StateStarting State = iota
+	// StateRunning means the process is running and its Client may be used.
+	StateRunning
+	// StateBackoff means the process exited and is waiting to be restarted.
+	StateBackoff
+	// StateStopped means the Process was closed and will not be restarted.
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateBackoff:
+		return "backoff"
+	case StateStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Health describes the current health of a Process.
+type Health struct {
+	State State
+	// Since is when the Process entered State.
+	Since time.Time
+	// PID of the running process, or 0 if the process is not running.
+	PID int
+	// Restarts is the number of times the process has been restarted.
+	Restarts int
+	// LastError is the error that caused the last exit or failed start.
+	LastError error
+}
+
+// Opt is an option function that can be passed to New.
+type Opt func(*Process)
+
+// WithLogger sets the Process to use a logger.
+func WithLogger(l jsonrpc2.Logger) Opt {
+	return func(p *Process) {
+		if l != nil {
+			p.log = l
+		}
+	}
+}
+
+// WithClientOpts sets options to use for each Client created for the
+// process.
+func WithClientOpts(opts ...jsonrpc2.ClientOpt) Opt {
+	return func(p *Process) {
+		p.clientOpts = opts
+	}
+}
+
+// WithBackoff sets the minimum and maximum delay between restarts. The delay
+// doubles after each consecutive crash, and is reset once the process stays
+// up for at least max. Defaults to 100ms and 30s.
+func WithBackoff(min, max time.Duration) Opt {
+	return func(p *Process) {
+		p.minBackoff, p.maxBackoff = min, max
+	}
+}
+
+// WithStopTimeout sets how long Close waits for the process to exit after
+// closing its stdin before killing it. Defaults to 5s.
+func WithStopTimeout(d time.Duration) Opt {
+	return func(p *Process) {
+		p.stopTimeout = d
+	}
+}
+
+// Process supervises a child process which speaks JSON-RPC 2.0 over stdio.
+// Process implements jsonrpc2.Conn, forwarding calls to the running process.
+type Process struct {
+	log         jsonrpc2.Logger
+	newCmd      func() *exec.Cmd
+	handler     jsonrpc2.Handler
+	clientOpts  []jsonrpc2.ClientOpt
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	stopTimeout time.Duration
+
+	mut     sync.Mutex
+	health  Health
+	cli     *jsonrpc2.Client
+	changed chan struct{} // Closed and replaced on state changes.
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ jsonrpc2.Conn = (*Process)(nil)
+
+// New creates a Process and starts it in the background. newCmd is called to
+// create the command each time the process is started; the returned command
+// must not have Stdin or Stdout set. handler will be invoked for each request
+// received from the process.
+func New(newCmd func() *exec.Cmd, handler jsonrpc2.Handler, opts ...Opt) *Process {
+	p := &Process{
+		log:         jsonrpc2.NewNopLogger(),
+		newCmd:      newCmd,
+		handler:     handler,
+		minBackoff:  100 * time.Millisecond,
+		maxBackoff:  30 * time.Second,
+		stopTimeout: 5 * time.Second,
+
+		health:  Health{State: StateStarting, Since: time.Now()},
+		changed: make(chan struct{}),
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	go p.run()
+	return p
+}
+
+// Health returns the current health of the Process.
+func (p *Process) Health() Health {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return p.health
+}
+
+// State returns the current state of the Process.
+func (p *Process) State() State {
+	return p.Health().State
+}
+
+// Client waits for the process to be running and returns its Client. The
+// returned Client is closed when the process exits.
+func (p *Process) Client(ctx context.Context) (*jsonrpc2.Client, error) {
+	for {
+		p.mut.Lock()
+		var (
+			state   = p.health.State
+			cli     = p.cli
+			changed = p.changed
+		)
+		p.mut.Unlock()
+
+		switch state {
+		case StateRunning:
+			// The connection may close slightly before the process exit is
+			// observed. Wait for the state to change if it has.
+			select {
+			case <-cli.Done():
+			default:
+				return cli, nil
+			}
+		case StateStopped:
+			return nil, ErrStopped
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Invoke waits for the process to be running and invokes an RPC on it.
+func (p *Process) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	cli, err := p.Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cli.Invoke(ctx, method, msg)
+}
+
+// Notify sends a notification to the process. ErrNotRunning is returned if
+// the process is not currently running.
+func (p *Process) Notify(method string, msg interface{}) error {
+	p.mut.Lock()
+	cli := p.cli
+	p.mut.Unlock()
+
+	if cli == nil {
+		return ErrNotRunning
+	}
+	return cli.Notify(method, msg)
+}
+
+// Close stops the process and prevents it from being restarted. The
+// process' stdin is closed to ask it to exit, and it is killed if it does
+// not exit within the stop timeout.
+func (p *Process) Close() error {
+	p.mut.Lock()
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	p.mut.Unlock()
+
+	<-p.done
+	return nil
+}
+
+// Done returns a channel that is closed once the Process has been closed
+// and the process has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Process) setState(state State, cli *jsonrpc2.Client, pid int, err error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	p.health.State = state
+	p.health.Since = time.Now()
+	p.health.PID = pid
+	if err != nil {
+		p.health.LastError = err
+	}
+	p.cli = cli
+
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// run starts the process and restarts it until the Process is closed.
+func (p *Process) run() {
+	defer close(p.done)
+	defer p.setState(StateStopped, nil, 0, nil)
+
+	backoff := p.minBackoff
+	for {
+		started := time.Now()
+		err := p.runOnce()
+
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		if time.Since(started) >= p.maxBackoff {
+			backoff = p.minBackoff
+		}
+		jsonrpc2.LevelWarn.Log(p.log, "msg", "process exited, restarting", "err", err, "backoff", backoff)
+		p.setState(StateBackoff, nil, 0, err)
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+
+		p.mut.Lock()
+		p.health.Restarts++
+		p.mut.Unlock()
+		p.setState(StateStarting, nil, 0, nil)
+	}
+}
+
+// runOnce starts the process and waits for it to exit. The returned error
+// describes why the process exited.
+func (p *Process) runOnce() error {
+	cc, err := jsonrpc2.StartCommand(p.newCmd(), p.handler, p.clientOpts...)
+	if err != nil {
+		return err
+	}
+	defer cc.Client().Close()
+	p.setState(StateRunning, cc.Client(), cc.Process().Pid, nil)
+
+	select {
+	case <-cc.Exited():
+		err := cc.Wait()
+		if err == nil {
+			err = errors.New("process exited")
+		}
+		return err
+	case <-p.stop:
+	}
+
+	// Ask the process to exit by closing its stdin, and kill it if it
+	// doesn't exit in time.
+	ctx, cancel := context.WithTimeout(context.Background(), p.stopTimeout)
+	defer cancel()
+	err = cc.Stop(ctx)
+	if ctx.Err() != nil {
+		jsonrpc2.LevelWarn.Log(p.log, "msg", "process did not exit in time, killed")
+	}
+	return err
+}
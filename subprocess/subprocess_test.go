@@ -0,0 +1,75 @@
+package subprocess
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperProcess isn't a real test. It's used as the child process for
+// other tests in this package.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("SUBPROCESS_WANT_HELPER") != "1" {
+		return
+	}
+
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		switch r.Method {
+		case "echo":
+			_ = w.WriteMessage(r.Params)
+		case "crash":
+			os.Exit(3)
+		}
+	})
+
+	cli := jsonrpc2.NewStdioClient(os.Stdin, os.Stdout, handler)
+	<-cli.Done()
+	os.Exit(0)
+}
+
+func helperCommand() *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "SUBPROCESS_WANT_HELPER=1")
+	return cmd
+}
+
+func TestProcess(t *testing.T) {
+	p := New(helperCommand, nil, WithBackoff(10*time.Millisecond, time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := p.Invoke(ctx, "echo", "hello")
+	require.NoError(t, err)
+	require.JSONEq(t, `"hello"`, string(res))
+
+	health := p.Health()
+	require.Equal(t, StateRunning, health.State)
+	require.NotZero(t, health.PID)
+	require.Equal(t, 0, health.Restarts)
+
+	// Crash the process and wait for it to be restarted.
+	cli, err := p.Client(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cli.Notify("crash", nil))
+	<-cli.Done()
+
+	res, err = p.Invoke(ctx, "echo", "again")
+	require.NoError(t, err)
+	require.JSONEq(t, `"again"`, string(res))
+
+	health = p.Health()
+	require.Equal(t, 1, health.Restarts)
+	require.Error(t, health.LastError)
+
+	require.NoError(t, p.Close())
+	require.Equal(t, StateStopped, p.State())
+
+	_, err = p.Invoke(ctx, "echo", "closed")
+	require.Equal(t, ErrStopped, err)
+}
@@ -0,0 +1,269 @@
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TimeFormat is how a Time is encoded in JSON.
+type TimeFormat string
+
+const (
+	// TimeRFC3339 encodes times as RFC 3339 strings with nanoseconds, such
+	// as "2006-01-02T15:04:05.999999999Z", as encoding/json does for
+	// time.Time. It is the default.
+	TimeRFC3339 TimeFormat = "rfc3339"
+
+	// TimeUnixMillis encodes times as the integer number of milliseconds
+	// since the Unix epoch.
+	TimeUnixMillis TimeFormat = "unixMillis"
+)
+
+// DurationFormat is how a Duration is encoded in JSON.
+type DurationFormat string
+
+const (
+	// DurationNanos encodes durations as an integer number of nanoseconds,
+	// as encoding/json does for time.Duration. It is the default.
+	DurationNanos DurationFormat = "nanos"
+
+	// DurationMillis encodes durations as a number of milliseconds.
+	DurationMillis DurationFormat = "millis"
+
+	// DurationString encodes durations as strings accepted by
+	// time.ParseDuration, such as "1m30s".
+	DurationString DurationFormat = "string"
+)
+
+// Time is a time.Time which is encoded in JSON as set by Format. Times are
+// decoded from either format, and Format is set to the format found, so
+// that decoded times are sent back the way they were received.
+type Time struct {
+	time.Time
+	Format TimeFormat
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Time) MarshalJSON() ([]byte, error) {
+	switch t.Format {
+	case "", TimeRFC3339:
+		return t.Time.MarshalJSON()
+	case TimeUnixMillis:
+		return strconv.AppendInt(nil, t.UnixMilli(), 10), nil
+	default:
+		return nil, fmt.Errorf("jsonrpc2: unknown time format %q", t.Format)
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		t.Format = TimeRFC3339
+		return t.Time.UnmarshalJSON(data)
+	}
+
+	ms, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("time must be an RFC 3339 string or integer Unix milliseconds, got %s", data)
+	}
+	t.Time, t.Format = time.UnixMilli(ms), TimeUnixMillis
+	return nil
+}
+
+// Duration is a time.Duration which is encoded in JSON as set by Format.
+// Durations are decoded from strings, such as "1m30s", or from numbers in
+// the unit of Format, which should be set before decoding if it isn't
+// DurationNanos. Format is set to DurationString when a string is decoded.
+type Duration struct {
+	time.Duration
+	Format DurationFormat
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	switch d.Format {
+	case "", DurationNanos:
+		return strconv.AppendInt(nil, int64(d.Duration), 10), nil
+	case DurationMillis:
+		return strconv.AppendFloat(nil, float64(d.Duration)/float64(time.Millisecond), 'f', -1, 64), nil
+	case DurationString:
+		return json.Marshal(d.String())
+	default:
+		return nil, fmt.Errorf("jsonrpc2: unknown duration format %q", d.Format)
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		d.Duration, d.Format = parsed, DurationString
+		return nil
+	}
+
+	switch d.Format {
+	case "", DurationNanos, DurationString:
+		n, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("duration must be a string or integer nanoseconds, got %s", data)
+		}
+		d.Duration = time.Duration(n)
+	case DurationMillis:
+		ms, err := strconv.ParseFloat(string(data), 64)
+		if err != nil {
+			return fmt.Errorf("duration must be a string or number of milliseconds, got %s", data)
+		}
+		if math.Abs(ms) > math.MaxInt64/float64(time.Millisecond) {
+			return fmt.Errorf("duration %s ms is out of range", data)
+		}
+		d.Duration = time.Duration(ms * float64(time.Millisecond))
+	default:
+		return fmt.Errorf("jsonrpc2: unknown duration format %q", d.Format)
+	}
+	return nil
+}
+
+// TimeEncoding is a convention for encoding times and durations, which the
+// two sides of a connection can agree on with NegotiateTimeEncoding.
+type TimeEncoding struct {
+	TimeFormat     TimeFormat     `json:"time"`
+	DurationFormat DurationFormat `json:"duration"`
+}
+
+// Time returns t to be encoded as set by e.
+func (e TimeEncoding) Time(t time.Time) Time {
+	return Time{Time: t, Format: e.TimeFormat}
+}
+
+// Duration returns d to be encoded as set by e.
+func (e TimeEncoding) Duration(d time.Duration) Duration {
+	return Duration{Duration: d, Format: e.DurationFormat}
+}
+
+func (e TimeEncoding) valid() bool {
+	switch e.TimeFormat {
+	case "", TimeRFC3339, TimeUnixMillis:
+	default:
+		return false
+	}
+	switch e.DurationFormat {
+	case "", DurationNanos, DurationMillis, DurationString:
+	default:
+		return false
+	}
+	return true
+}
+
+// WithTimeEncoding sets the Client's TimeEncoding until another is
+// negotiated. The default uses TimeRFC3339 and DurationNanos, matching
+// encoding/json.
+func WithTimeEncoding(e TimeEncoding) ClientOpt {
+	return func(c *Client) {
+		c.timeEncoding.Store(e)
+	}
+}
+
+// TimeEncoding returns the convention for encoding times and durations on
+// the connection, as set by WithTimeEncoding or agreed on with
+// NegotiateTimeEncoding. Handlers can use it to encode results for the
+// caller:
+//
+//	enc := r.Client.TimeEncoding()
+//	_ = w.WriteMessage(Result{Expires: enc.Time(expires)})
+func (c *Client) TimeEncoding() TimeEncoding {
+	e, _ := c.timeEncoding.Load().(TimeEncoding)
+	return e
+}
+
+// MethodTimeEncoding is the method used by NegotiateTimeEncoding to agree
+// on a TimeEncoding with a TimeEncodingHandler.
+const MethodTimeEncoding = "rpc.timeEncoding"
+
+type timeEncodingParams struct {
+	Encodings []TimeEncoding `json:"encodings"`
+}
+
+// TimeEncodingHandler returns a Handler for MethodTimeEncoding which agrees
+// to the first of the caller's proposed encodings which is in supported, or
+// which is valid if supported is empty. The agreed encoding is used by the
+// TimeEncoding of the connection's Client:
+//
+//	mux.Handle(jsonrpc2.MethodTimeEncoding, jsonrpc2.TimeEncodingHandler())
+func TimeEncodingHandler(supported ...TimeEncoding) Handler {
+	supported = append([]TimeEncoding(nil), supported...)
+	accepts := func(e TimeEncoding) bool {
+		if !e.valid() {
+			return false
+		}
+		if len(supported) == 0 {
+			return true
+		}
+		for _, s := range supported {
+			if s == e {
+				return true
+			}
+		}
+		return false
+	}
+
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var params timeEncodingParams
+		if err := r.DecodeParams(&params); err != nil {
+			if !r.Notification {
+				_ = w.WriteError(ErrorInvalidParams, err)
+			}
+			return
+		}
+		for _, e := range params.Encodings {
+			if !accepts(e) {
+				continue
+			}
+			if r.Client != nil {
+				r.Client.timeEncoding.Store(e)
+			}
+			if !r.Notification {
+				_ = w.WriteMessage(e)
+			}
+			return
+		}
+		if !r.Notification {
+			_ = w.WriteError(ErrorInvalidParams, fmt.Errorf("no supported time encoding in %v", params.Encodings))
+		}
+	})
+}
+
+// NegotiateTimeEncoding proposes preferred encodings, most preferred first,
+// to a TimeEncodingHandler on the other side of cli. The encoding the peer
+// agrees to is returned and used by cli's TimeEncoding.
+func NegotiateTimeEncoding(ctx context.Context, cli *Client, preferred ...TimeEncoding) (TimeEncoding, error) {
+	res, err := cli.Invoke(ctx, MethodTimeEncoding, timeEncodingParams{Encodings: preferred})
+	if err != nil {
+		return TimeEncoding{}, err
+	}
+	var agreed TimeEncoding
+	if err := json.Unmarshal(res, &agreed); err != nil || !agreed.valid() {
+		return TimeEncoding{}, fmt.Errorf("invalid time encoding negotiation result: %s", res)
+	}
+	cli.timeEncoding.Store(agreed)
+	return agreed, nil
+}
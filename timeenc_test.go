@@ -0,0 +1,109 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTime_JSON(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 250*int(time.Millisecond), time.UTC)
+
+	tt := []struct {
+		format TimeFormat
+		expect string
+	}{
+		{"", `"2024-03-01T12:30:00.25Z"`},
+		{TimeRFC3339, `"2024-03-01T12:30:00.25Z"`},
+		{TimeUnixMillis, `1709296200250`},
+	}
+	for _, tc := range tt {
+		data, err := json.Marshal(Time{Time: ts, Format: tc.format})
+		require.NoError(t, err)
+		require.Equal(t, tc.expect, string(data))
+
+		// Either format is decoded, and remembered.
+		var decoded Time
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.True(t, ts.Equal(decoded.Time))
+		if tc.format != "" {
+			require.Equal(t, tc.format, decoded.Format)
+		}
+	}
+
+	var decoded Time
+	require.Error(t, json.Unmarshal([]byte(`true`), &decoded))
+	_, err := json.Marshal(Time{Format: "julian"})
+	require.Error(t, err)
+}
+
+func TestDuration_JSON(t *testing.T) {
+	d := 90*time.Second + 500*time.Microsecond
+
+	tt := []struct {
+		format DurationFormat
+		expect string
+	}{
+		{"", `90000500000`},
+		{DurationNanos, `90000500000`},
+		{DurationMillis, `90000.5`},
+		{DurationString, `"1m30.0005s"`},
+	}
+	for _, tc := range tt {
+		data, err := json.Marshal(Duration{Duration: d, Format: tc.format})
+		require.NoError(t, err)
+		require.Equal(t, tc.expect, string(data))
+
+		// Numbers are read in the unit of the Format being decoded into.
+		decoded := Duration{Format: tc.format}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, d, decoded.Duration)
+	}
+
+	decoded := Duration{Format: DurationMillis}
+	require.Error(t, json.Unmarshal([]byte(`1e300`), &decoded))
+	require.Error(t, json.Unmarshal([]byte(`"soon"`), &decoded))
+}
+
+func TestNegotiateTimeEncoding(t *testing.T) {
+	unixMillis := TimeEncoding{TimeFormat: TimeUnixMillis, DurationFormat: DurationMillis}
+	readable := TimeEncoding{TimeFormat: TimeRFC3339, DurationFormat: DurationString}
+
+	mux := NewServeMux()
+	mux.Handle(MethodTimeEncoding, TimeEncodingHandler(readable))
+	mux.HandleFunc("expiry", func(w ResponseWriter, r *Request) {
+		enc := r.Client.TimeEncoding()
+		_ = w.WriteMessage([]interface{}{enc.Time(time.Unix(60, 0).UTC()), enc.Duration(time.Minute)})
+	})
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithTimeEncoding(unixMillis))
+	defer cli.Close()
+	ctx := context.Background()
+
+	res, err := cli.Invoke(ctx, "expiry", nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `["1970-01-01T00:01:00Z", 60000000000]`, string(res))
+
+	_, err = NegotiateTimeEncoding(ctx, cli, unixMillis)
+	var rpcErr Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, ErrorInvalidParams, rpcErr.Code)
+	require.Equal(t, unixMillis, cli.TimeEncoding())
+
+	agreed, err := NegotiateTimeEncoding(ctx, cli, unixMillis, readable)
+	require.NoError(t, err)
+	require.Equal(t, readable, agreed)
+	require.Equal(t, readable, cli.TimeEncoding())
+	require.Equal(t, readable, srv.TimeEncoding())
+
+	res, err = cli.Invoke(ctx, "expiry", nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `["1970-01-01T00:01:00Z", "1m0s"]`, string(res))
+}
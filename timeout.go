@@ -0,0 +1,103 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errHandlerTimeout is returned by the ResponseWriter of a handler whose
+// request timed out.
+var errHandlerTimeout = errors.New("jsonrpc2: handler timed out")
+
+// Timeout returns Middleware which limits handlers to d. The request's
+// context is cancelled after d so handlers can stop early, and if the
+// handler hasn't written a response by then, the caller gets an
+// ErrorTimeout error:
+//
+//	{"code": -32001, "message": "request timed out"}
+//
+// The handler runs on its own goroutine so that the timeout error can be
+// sent while it is still running. Writes made by the handler after the
+// timeout fail. If the handler panics before the timeout, the panic is
+// raised again on the caller's goroutine so Recover sees it.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w}
+			done := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					p := recover()
+					if p != nil && tw.timedOut() {
+						// Nobody is waiting for the handler any more.
+						panic(p)
+					}
+					done <- p
+				}()
+				next.ServeRPC(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case p := <-done:
+				if p != nil {
+					panic(p)
+				}
+			case <-ctx.Done():
+				if tw.timeout() && !r.Notification {
+					_ = w.WriteError(ErrorTimeout, Error{Message: "request timed out"})
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter is the ResponseWriter passed to handlers by Timeout. It
+// fails writes once the request has timed out.
+type timeoutWriter struct {
+	w ResponseWriter
+
+	mut     sync.Mutex
+	wrote   bool
+	expired bool
+}
+
+func (tw *timeoutWriter) WriteMessage(msg interface{}) error {
+	tw.mut.Lock()
+	defer tw.mut.Unlock()
+	if tw.expired {
+		return errHandlerTimeout
+	}
+	tw.wrote = true
+	return tw.w.WriteMessage(msg)
+}
+
+func (tw *timeoutWriter) WriteError(errCode int, err error) error {
+	tw.mut.Lock()
+	defer tw.mut.Unlock()
+	if tw.expired {
+		return errHandlerTimeout
+	}
+	tw.wrote = true
+	return tw.w.WriteError(errCode, err)
+}
+
+// timeout marks the request as timed out and reports whether the timeout
+// error should be written, which is when the handler hasn't written a
+// response.
+func (tw *timeoutWriter) timeout() bool {
+	tw.mut.Lock()
+	defer tw.mut.Unlock()
+	tw.expired = true
+	return !tw.wrote
+}
+
+func (tw *timeoutWriter) timedOut() bool {
+	tw.mut.Lock()
+	defer tw.mut.Unlock()
+	return tw.expired
+}
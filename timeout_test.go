@@ -0,0 +1,51 @@
+package jsonrpc2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	type result struct {
+		ctxErr   error
+		writeErr error
+	}
+	results := make(chan result, 1)
+	release := make(chan struct{})
+
+	h := Timeout(20 * time.Millisecond)(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method == "fast" {
+			_ = w.WriteMessage("done")
+			return
+		}
+		<-r.Context().Done()
+		<-release
+		results <- result{ctxErr: r.Context().Err(), writeErr: w.WriteMessage("late")}
+	}))
+
+	reply, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "slow", "id": 1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32001, "message": "request timed out"}, "id": 1}`, string(reply))
+
+	close(release)
+	res := <-results
+	require.Equal(t, context.DeadlineExceeded, res.ctxErr)
+	require.Equal(t, errHandlerTimeout, res.writeErr)
+
+	reply, err = ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "fast", "id": 2}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "result": "done", "id": 2}`, string(reply))
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	h := Chain(HandlerFunc(func(w ResponseWriter, r *Request) {
+		panic("boom")
+	}), Recover(nil), Timeout(time.Second))
+
+	reply, err := ServeFrame(context.Background(), h, []byte(`{"jsonrpc": "2.0", "method": "explode", "id": 1}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "internal error"}, "id": 1}`, string(reply))
+}
@@ -0,0 +1,51 @@
+package jsonrpc2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DialTLS creates a connection to the target server using TLS. The dial and
+// TLS handshake must complete before ctx is done, which allows callers to
+// bound the time spent connecting to an unresponsive server. Handler will be
+// invoked for each request received from the other side.
+//
+// To authenticate with a client certificate, set Certificates in config.
+func DialTLS(ctx context.Context, target string, config *tls.Config, handler Handler, opts ...ClientOpt) (*Client, error) {
+	d := tls.Dialer{Config: config}
+	nc, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing to server: %w", err)
+	}
+	return NewClient(nc, handler, opts...), nil
+}
+
+// ServeTLS serves connections from lis over TLS using config, which must
+// have at least one certificate or set GetCertificate. To require clients
+// to present a verified certificate, set ClientAuth to
+// tls.RequireAndVerifyClientCert and ClientCAs to the trusted CAs.
+//
+// Connections which fail the handshake are closed. Set HandshakeTimeout to
+// bound how long clients have to complete it.
+//
+// lis will be closed when ServeTLS exits.
+func (s *Server) ServeTLS(lis net.Listener, config *tls.Config) error {
+	if config == nil || (len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil) {
+		_ = lis.Close()
+		return errors.New("jsonrpc2: tls config has no certificates")
+	}
+	return s.Serve(tls.NewListener(lis, config))
+}
+
+// ListenAndServeTLS listens on the TCP address addr and serves connections
+// over TLS using config. See ServeTLS for details.
+func (s *Server) ListenAndServeTLS(addr string, config *tls.Config) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(lis, config)
+}
@@ -0,0 +1,109 @@
+package jsonrpc2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCert creates a self-signed certificate valid for 127.0.0.1, which
+// can be used as both a server and client certificate.
+func newTestCert(t *testing.T, name string) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestServer_ServeTLS(t *testing.T) {
+	srvCert, srvX509 := newTestCert(t, "server")
+	cliCert, cliX509 := newTestCert(t, "client")
+
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(srvX509)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cliX509)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := NewServeMux()
+	mux.HandleFunc("ping", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("pong") })
+	srv := &Server{Handler: mux, HandshakeTimeout: 5 * time.Second}
+	go srv.ServeTLS(lis, &tls.Config{
+		Certificates: []tls.Certificate{srvCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("client certificate", func(t *testing.T) {
+		cli, err := DialTLS(ctx, lis.Addr().String(), &tls.Config{
+			RootCAs:      serverCAs,
+			Certificates: []tls.Certificate{cliCert},
+		}, nil)
+		require.NoError(t, err)
+		defer cli.Close()
+
+		res, err := cli.Invoke(ctx, "ping", nil)
+		require.NoError(t, err)
+		require.Equal(t, `"pong"`, string(res))
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		cli, err := DialTLS(ctx, lis.Addr().String(), &tls.Config{RootCAs: serverCAs}, nil)
+		if err == nil {
+			// With TLS 1.3, the server rejects the certificate after the
+			// client's side of the handshake has finished.
+			defer cli.Close()
+			_, err = cli.Invoke(ctx, "ping", nil)
+		}
+		require.Error(t, err)
+	})
+
+	t.Run("untrusted server", func(t *testing.T) {
+		_, err := DialTLS(ctx, lis.Addr().String(), &tls.Config{
+			Certificates: []tls.Certificate{cliCert},
+		}, nil)
+		require.Error(t, err)
+	})
+}
+
+func TestServer_ServeTLS_NoCertificates(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &Server{}
+	require.Error(t, srv.ServeTLS(lis, &tls.Config{}))
+}
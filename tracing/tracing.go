@@ -0,0 +1,270 @@
+// Package tracing instruments Clients and handlers with distributed tracing,
+// creating a span for each call made and each request handled.
+//
+// tracing does not depend on OpenTelemetry. Spans are created by a Tracer
+// and trace context is carried by a Propagator, both provided by the
+// application, which mirror OpenTelemetry's trace.Tracer and
+// propagation.TextMapPropagator closely enough to be adapted in a few
+// lines:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, kind tracing.SpanKind, attrs ...tracing.Attribute) (context.Context, tracing.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name,
+//			trace.WithSpanKind(trace.SpanKind(kind)), // SpanKindServer and SpanKindClient match OpenTelemetry's values
+//			trace.WithAttributes(toKeyValues(attrs)...))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelPropagator struct{ propagation.TextMapPropagator }
+//
+//	func (p otelPropagator) Inject(ctx context.Context, md jsonrpc2.Metadata) {
+//		p.TextMapPropagator.Inject(ctx, propagation.MapCarrier(md))
+//	}
+//
+//	func (p otelPropagator) Extract(ctx context.Context, md jsonrpc2.Metadata) context.Context {
+//		return p.TextMapPropagator.Extract(ctx, propagation.MapCarrier(md))
+//	}
+//
+// Clients trace outgoing calls with Interceptor, and handlers are traced with
+// Middleware:
+//
+//	tracer := otelTracer{otel.Tracer("jsonrpc2")}
+//	prop := otelPropagator{propagation.TraceContext{}}
+//
+//	handler := jsonrpc2.Chain(mux, tracing.Middleware(tracer, prop))
+//	cli := jsonrpc2.NewClient(conn, handler,
+//		jsonrpc2.WithRequestMetadata(true),
+//		jsonrpc2.WithClientInterceptor(tracing.Interceptor(tracer, prop)))
+//
+// Trace context is sent as request metadata, so with the W3C Trace Context
+// propagator requests carry the "traceparent" and "tracestate" keys:
+//
+//	{"jsonrpc": "2.0", "method": "sum", "params": [1, 2], "meta": {"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "id": 1}
+//
+// Metadata is an extension to JSON-RPC 2.0, so trace context is only sent by
+// Clients created with jsonrpc2.WithRequestMetadata(true).
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// Attribute keys set on spans. The keys without a jsonrpc prefix follow the
+// OpenTelemetry semantic conventions for RPC spans.
+const (
+	AttrSystem       = "rpc.system"
+	AttrMethod       = "rpc.method"
+	AttrVersion      = "rpc.jsonrpc.version"
+	AttrRequestID    = "rpc.jsonrpc.request_id"
+	AttrErrorCode    = "rpc.jsonrpc.error_code"
+	AttrErrorMessage = "rpc.jsonrpc.error_message"
+	AttrRequestSize  = "rpc.jsonrpc.request_size"
+	AttrResponseSize = "rpc.jsonrpc.response_size"
+	AttrBatchSize    = "rpc.jsonrpc.batch_size"
+
+	// AttrTagPrefix prefixes the keys of call tags, set with
+	// jsonrpc2.WithCallTag, recorded on client spans.
+	AttrTagPrefix = "rpc.jsonrpc.tag."
+)
+
+// SpanKind is the role of a span in a call.
+type SpanKind int
+
+// The values of SpanKind match OpenTelemetry's trace.SpanKind.
+const (
+	SpanKindServer SpanKind = 2
+	SpanKindClient SpanKind = 3
+)
+
+// Attribute is a key-value pair describing a span. Values are strings,
+// ints, or bools.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer creates spans.
+type Tracer interface {
+	// Start starts a span named name as a child of the span in ctx, if any,
+	// and returns a context holding the new span.
+	Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is an operation being traced.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+
+	// SetError marks the span as failed with err.
+	SetError(err error)
+
+	End()
+}
+
+// Propagator sends trace context across calls as request metadata.
+type Propagator interface {
+	// Inject writes the trace context of ctx into md.
+	Inject(ctx context.Context, md jsonrpc2.Metadata)
+
+	// Extract returns a copy of ctx holding the trace context read from md.
+	Extract(ctx context.Context, md jsonrpc2.Metadata) context.Context
+}
+
+// batchSpanName is the name of spans for batch commits.
+const batchSpanName = "jsonrpc2.batch"
+
+// Interceptor returns a ClientInterceptor which traces outgoing calls with
+// a client span named after the method. If p is non-nil, the trace context
+// of each call is sent as request metadata.
+//
+// Batch commits get a single span, and their trace context isn't sent since
+// the batch has already been encoded. The request ID of outgoing calls isn't
+// recorded, since it is assigned after the interceptor runs. Interceptors
+// added after this one see the params as a json.RawMessage.
+func Interceptor(t Tracer, p Propagator) jsonrpc2.ClientInterceptor {
+	return func(ctx context.Context, call *jsonrpc2.ClientCall, invoker jsonrpc2.Invoker) (json.RawMessage, error) {
+		if call.Batch != nil {
+			attrs := append(baseAttrs(AttrBatchSize, len(call.Batch)), tagAttrs(call.Tags)...)
+			ctx, span := t.Start(ctx, batchSpanName, SpanKindClient, attrs...)
+			defer span.End()
+			res, err := invoker(ctx, call)
+			if err != nil {
+				setError(span, err)
+			}
+			return res, err
+		}
+
+		// Encode the params here to measure them. The encoded params are
+		// passed on so they aren't encoded twice.
+		params, err := json.Marshal(call.Params)
+		if err != nil {
+			return nil, err
+		}
+		call.Params = json.RawMessage(params)
+
+		attrs := append(baseAttrs(AttrMethod, call.Method), Attribute{Key: AttrRequestSize, Value: len(params)})
+		ctx, span := t.Start(ctx, call.Method, SpanKindClient, append(attrs, tagAttrs(call.Tags)...)...)
+		defer span.End()
+
+		if p != nil {
+			md := make(jsonrpc2.Metadata)
+			p.Inject(ctx, md)
+			if len(md) > 0 {
+				ctx = jsonrpc2.WithMetadata(ctx, md)
+			}
+		}
+
+		res, err := invoker(ctx, call)
+		if err != nil {
+			setError(span, err)
+			return res, err
+		}
+		if !call.Notification {
+			span.SetAttributes(Attribute{Key: AttrResponseSize, Value: len(res)})
+		}
+		return res, nil
+	}
+}
+
+// Middleware returns Middleware which traces requests with a server span
+// named after the method. If p is non-nil, the span continues the trace
+// context sent as request metadata.
+func Middleware(t Tracer, p Propagator) jsonrpc2.Middleware {
+	return func(next jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			ctx := r.Context()
+			if p != nil && r.Meta != nil {
+				ctx = p.Extract(ctx, r.Meta)
+			}
+
+			attrs := append(baseAttrs(AttrMethod, r.Method), Attribute{Key: AttrRequestSize, Value: len(r.Params)})
+			if !r.ID.IsUndefined() {
+				attrs = append(attrs, Attribute{Key: AttrRequestID, Value: r.ID.String()})
+			}
+			ctx, span := t.Start(ctx, r.Method, SpanKindServer, attrs...)
+			defer span.End()
+
+			next.ServeRPC(&spanWriter{w: w, span: span}, r.WithContext(ctx))
+		})
+	}
+}
+
+// spanWriter records the response written by a handler on its span.
+type spanWriter struct {
+	w    jsonrpc2.ResponseWriter
+	span Span
+}
+
+func (sw *spanWriter) WriteMessage(msg interface{}) error {
+	// Encode the result here to measure it, and pass it on encoded.
+	res, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := sw.w.WriteMessage(json.RawMessage(res)); err != nil {
+		return err
+	}
+	sw.span.SetAttributes(Attribute{Key: AttrResponseSize, Value: len(res)})
+	return nil
+}
+
+func (sw *spanWriter) WriteError(errCode int, err error) error {
+	if werr := sw.w.WriteError(errCode, err); werr != nil {
+		return werr
+	}
+
+	msg := err.Error()
+	var rpcErr jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		msg = rpcErr.Message
+	}
+	sw.span.SetAttributes(
+		Attribute{Key: AttrErrorCode, Value: errCode},
+		Attribute{Key: AttrErrorMessage, Value: msg},
+	)
+	sw.span.SetError(err)
+	return nil
+}
+
+func baseAttrs(key string, value interface{}) []Attribute {
+	return []Attribute{
+		{Key: AttrSystem, Value: "jsonrpc"},
+		{Key: AttrVersion, Value: "2.0"},
+		{Key: key, Value: value},
+	}
+}
+
+// tagAttrs returns attributes for tags, sorted by key.
+func tagAttrs(tags jsonrpc2.CallTags) []Attribute {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]Attribute, len(keys))
+	for i, k := range keys {
+		attrs[i] = Attribute{Key: AttrTagPrefix + k, Value: tags[k]}
+	}
+	return attrs
+}
+
+// setError records err on span, along with its code and message if it is
+// a JSON-RPC error.
+func setError(span Span, err error) {
+	var rpcErr jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		span.SetAttributes(
+			Attribute{Key: AttrErrorCode, Value: rpcErr.Code},
+			Attribute{Key: AttrErrorMessage, Value: rpcErr.Message},
+		)
+	}
+	span.SetError(err)
+}
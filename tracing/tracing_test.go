@@ -0,0 +1,224 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+type testSpan struct {
+	name   string
+	kind   SpanKind
+	parent string
+
+	mut   sync.Mutex
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...Attribute) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) SetError(err error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.err = err
+}
+
+func (s *testSpan) End() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.ended = true
+}
+
+// testTracer records spans, using span names as trace context.
+type testTracer struct {
+	mut   sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	s := &testSpan{name: name, kind: kind, parent: parent, attrs: make(map[string]interface{})}
+	s.SetAttributes(attrs...)
+
+	t.mut.Lock()
+	t.spans = append(t.spans, s)
+	t.mut.Unlock()
+	return context.WithValue(ctx, spanKey{}, name), s
+}
+
+func (t *testTracer) find(name string, kind SpanKind) *testSpan {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	for _, s := range t.spans {
+		if s.name == name && s.kind == kind {
+			return s
+		}
+	}
+	return nil
+}
+
+type testPropagator struct{}
+
+func (testPropagator) Inject(ctx context.Context, md jsonrpc2.Metadata) {
+	if name, ok := ctx.Value(spanKey{}).(string); ok {
+		md["traceparent"] = name
+	}
+}
+
+func (testPropagator) Extract(ctx context.Context, md jsonrpc2.Metadata) context.Context {
+	if tp := md.Get("traceparent"); tp != "" {
+		return context.WithValue(ctx, spanKey{}, "remote:"+tp)
+	}
+	return ctx
+}
+
+func newPair(t *testing.T, tracer Tracer, mux *jsonrpc2.ServeMux) *jsonrpc2.Client {
+	t.Helper()
+	left, right := net.Pipe()
+	srv := jsonrpc2.NewClient(right, jsonrpc2.Chain(mux, Middleware(tracer, testPropagator{})))
+	cli := jsonrpc2.NewClient(left, nil,
+		jsonrpc2.WithRequestMetadata(true),
+		jsonrpc2.WithClientInterceptor(Interceptor(tracer, testPropagator{})))
+	t.Cleanup(func() {
+		_ = cli.Close()
+		_ = srv.Close()
+	})
+	return cli
+}
+
+func TestTracing(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("sum", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		var nums []int
+		_ = r.DecodeParams(&nums)
+		var sum int
+		for _, n := range nums {
+			sum += n
+		}
+		_ = w.WriteMessage(sum)
+	})
+
+	tracer := &testTracer{}
+	cli := newPair(t, tracer, mux)
+
+	res, err := cli.Invoke(context.Background(), "sum", []int{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, "6", string(res))
+
+	client := tracer.find("sum", SpanKindClient)
+	require.NotNil(t, client)
+	require.True(t, client.ended)
+	require.Equal(t, "jsonrpc", client.attrs[AttrSystem])
+	require.Equal(t, "sum", client.attrs[AttrMethod])
+	require.Equal(t, len("[1,2,3]"), client.attrs[AttrRequestSize])
+	require.Equal(t, 1, client.attrs[AttrResponseSize])
+	require.NoError(t, client.err)
+
+	server := tracer.find("sum", SpanKindServer)
+	require.NotNil(t, server)
+	require.True(t, server.ended)
+	require.Equal(t, "remote:sum", server.parent)
+	require.Equal(t, "1", server.attrs[AttrRequestID])
+	require.Equal(t, len("[1,2,3]"), server.attrs[AttrRequestSize])
+	require.Equal(t, 1, server.attrs[AttrResponseSize])
+}
+
+func TestTracing_Error(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("fail", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteError(jsonrpc2.ErrorInvalidParams, errors.New("bad params"))
+	})
+
+	tracer := &testTracer{}
+	cli := newPair(t, tracer, mux)
+
+	_, err := cli.Invoke(context.Background(), "fail", nil)
+	require.Error(t, err)
+
+	for _, kind := range []SpanKind{SpanKindClient, SpanKindServer} {
+		span := tracer.find("fail", kind)
+		require.NotNil(t, span)
+		require.Error(t, span.err)
+		require.Equal(t, jsonrpc2.ErrorInvalidParams, span.attrs[AttrErrorCode])
+		require.Equal(t, "bad params", span.attrs[AttrErrorMessage])
+	}
+}
+
+func TestTracing_NoMetadata(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("ping", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage("pong")
+	})
+
+	// Without WithRequestMetadata, trace context isn't sent.
+	tracer := &testTracer{}
+	left, right := net.Pipe()
+	srv := jsonrpc2.NewClient(right, jsonrpc2.Chain(mux, Middleware(tracer, testPropagator{})))
+	defer srv.Close()
+	cli := jsonrpc2.NewClient(left, nil, jsonrpc2.WithClientInterceptor(Interceptor(tracer, testPropagator{})))
+	defer cli.Close()
+
+	_, err := cli.Invoke(context.Background(), "ping", nil)
+	require.NoError(t, err)
+	server := tracer.find("ping", SpanKindServer)
+	require.NotNil(t, server)
+	require.Equal(t, "", server.parent)
+}
+
+func TestTracing_Batch(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("ping", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage("pong")
+	})
+
+	tracer := &testTracer{}
+	cli := newPair(t, tracer, mux)
+
+	b := cli.Batch()
+	_, _ = b.Invoke("ping", nil)
+	_, _ = b.Invoke("ping", nil)
+	require.NoError(t, b.Commit(context.Background()))
+
+	span := tracer.find(batchSpanName, SpanKindClient)
+	require.NotNil(t, span)
+	require.Equal(t, 2, span.attrs[AttrBatchSize])
+	require.True(t, span.ended)
+}
+
+func TestTracing_CallTags(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("ping", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage("pong")
+	})
+
+	tracer := &testTracer{}
+	cli := newPair(t, tracer, mux)
+
+	ctx := jsonrpc2.WithCallTag(context.Background(), "component", "indexer")
+	_, err := cli.Invoke(ctx, "ping", nil)
+	require.NoError(t, err)
+
+	client := tracer.find("ping", SpanKindClient)
+	require.NotNil(t, client)
+	require.Equal(t, "indexer", client.attrs[AttrTagPrefix+"component"])
+
+	// Tags aren't sent to the server.
+	server := tracer.find("ping", SpanKindServer)
+	require.NotNil(t, server)
+	require.NotContains(t, server.attrs, AttrTagPrefix+"component")
+}
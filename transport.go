@@ -2,6 +2,7 @@ package jsonrpc2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,38 +23,124 @@ func (te *transportError) Error() string {
 
 // transport is a transport for JSON-RPC 2.0 message.
 type transport struct {
-	rw io.ReadWriter
+	f   Framer
+	enc encoderConfig
+
+	// maxFrameSize limits the size of frames from Framers which can't
+	// limit it themselves, as set by WithMaxResponseSize.
+	maxFrameSize int
 }
 
 // newTransport can read and write JSON-RPC 2.0 messages over a ReadWriter.
 func newTransport(rw io.ReadWriter) *transport {
-	return &transport{rw: rw}
+	return newFramedTransport(NewStreamFramer(rw))
+}
+
+// newFramedTransport can read and write JSON-RPC 2.0 messages using a Framer.
+func newFramedTransport(f Framer) *transport {
+	return &transport{f: f}
 }
 
-// ReadMessage reads the next txMessage from the transport.
+// ReadMessage reads the next txMessage from the transport. Frames which
+// are too large are returned as a message failing the calls they answer.
 func (t *transport) ReadMessage() (txMessage, error) {
-	var msg txMessage
-	err := json.NewDecoder(t.rw).Decode(&msg)
+	frame, err := t.readFrame()
 	if err != nil {
-		var se *json.SyntaxError
-		if errors.As(err, &se) {
-			err = &transportError{Err: err}
-		}
-
-		var ue *json.UnmarshalTypeError
-		if errors.As(err, &ue) {
-			err = &transportError{Err: err}
+		var tooLarge *FrameTooLargeError
+		if errors.As(err, &tooLarge) {
+			return tooLargeMessage(tooLarge), nil
 		}
+		return txMessage{}, err
+	}
+	msg, err := decodeMessage(frame)
+	if sf, ok := t.f.(*streamFramer); ok {
+		sf.release()
 	}
 	return msg, err
 }
 
+// ReadFrame reads the next frame from the transport without decoding it. The
+// frame is copied, so it remains valid after later reads.
+func (t *transport) ReadFrame() ([]byte, error) {
+	frame, err := t.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	frame = append([]byte(nil), frame...)
+	if sf, ok := t.f.(*streamFramer); ok {
+		sf.release()
+	}
+	return frame, nil
+}
+
+// readFrame reads the next frame from the Framer, checking it against
+// maxFrameSize.
+func (t *transport) readFrame() ([]byte, error) {
+	frame, err := t.f.ReadFrame()
+	if err != nil || t.maxFrameSize <= 0 || len(frame) <= t.maxFrameSize {
+		return frame, err
+	}
+	var skipped skippedFrame
+	_, _ = skipped.Write(frame)
+	if sf, ok := t.f.(*streamFramer); ok {
+		sf.release()
+	}
+	return nil, skipped.err(t.maxFrameSize)
+}
+
+// decodeMessage decodes a frame read from the transport. The frame was read
+// successfully, so the connection is still usable even if the frame couldn't
+// be decoded; decode errors are returned as a *transportError.
+func decodeMessage(frame []byte) (txMessage, error) {
+	var msg txMessage
+	if err := unmarshalTxMessage(frame, &msg); err != nil {
+		return msg, &transportError{Err: err}
+	}
+	return msg, nil
+}
+
 // SendMessage sends a message over the transport.
 func (t *transport) SendMessage(msg txMessage) error {
-	return json.NewEncoder(t.rw).Encode(&msg)
+	frame, err := t.encode(&msg)
+	if err != nil {
+		return err
+	}
+	return t.f.WriteFrame(frame)
 }
 
-func (t *transport) SendError(id id, err *Error) error {
+// encode encodes msg as a frame.
+func (t *transport) encode(msg *txMessage) ([]byte, error) {
+	frame, err := marshalTxMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return t.enc.format(frame)
+}
+
+// SendMessages sends multiple messages over the transport. If the Framer
+// supports it, all messages are written at once.
+func (t *transport) SendMessages(msgs []txMessage) error {
+	frames := make([][]byte, 0, len(msgs))
+	for i := range msgs {
+		frame, err := t.encode(&msgs[i])
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame)
+	}
+
+	if fw, ok := t.f.(interface{ WriteFrames([][]byte) error }); ok {
+		return fw.WriteFrames(frames)
+	}
+	for _, frame := range frames {
+		if err := t.f.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transport) SendError(id ID, err *Error) error {
 	return t.SendMessage(txMessage{
 		Objects: []*txObject{{
 			Response: &txResponse{ID: id, Error: err},
@@ -61,10 +148,10 @@ func (t *transport) SendError(id id, err *Error) error {
 	})
 }
 
-// Close closes the transport. If the rw given to newTransport implements
-// io.Closer, it will be closed.
+// Close closes the transport. If the Framer implements io.Closer, it will be
+// closed.
 func (t *transport) Close() error {
-	if c, ok := t.rw.(io.Closer); ok {
+	if c, ok := t.f.(io.Closer); ok {
 		return c.Close()
 	}
 	return nil
@@ -77,24 +164,49 @@ type txMessage struct {
 }
 
 func (m *txMessage) UnmarshalJSON(bb []byte) error {
-	// Most messages won't be batched, so try the non-batched form first.
-	var obj txObject
-	if err := json.Unmarshal(bb, &obj); err == nil {
+	if trimmed := bytes.TrimLeft(bb, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '[' {
+		var obj txObject
+		if err := json.Unmarshal(bb, &obj); err != nil {
+			return err
+		}
 		m.Batched = false
 		m.Objects = []*txObject{&obj}
 		return nil
 	}
 
-	// Fallback to trying a batch.
-	var objs []*txObject
-	err := json.Unmarshal(bb, &objs)
-	if err == nil {
-		m.Batched = true
-		m.Objects = objs
-		return nil
+	var elems []json.RawMessage
+	if err := json.Unmarshal(bb, &elems); err != nil {
+		return err
+	}
+	if len(elems) == 0 {
+		return fmt.Errorf("invalid json-rpc 2.0 message: empty batch")
 	}
 
-	return err
+	// Invalid objects within a batch don't invalidate the whole batch, so
+	// that the other objects can still be handled.
+	m.Batched = true
+	m.Objects = make([]*txObject, 0, len(elems))
+	for _, elem := range elems {
+		var obj txObject
+		if err := json.Unmarshal(elem, &obj); err != nil {
+			obj = invalidObject(elem, err)
+		}
+		m.Objects = append(m.Objects, &obj)
+	}
+	return nil
+}
+
+// invalidObject returns the object for a batch entry which couldn't be
+// decoded. If the entry's ID can still be read, the error is replied to
+// with that ID rather than null.
+func invalidObject(elem []byte, err error) txObject {
+	var probe struct {
+		ID ID `json:"id"`
+	}
+	if json.Unmarshal(elem, &probe) == nil && !probe.ID.IsUndefined() && !probe.ID.IsNull() {
+		err = &invalidRequestError{ID: probe.ID, Err: err}
+	}
+	return txObject{Invalid: err}
 }
 
 func (m *txMessage) MarshalJSON() ([]byte, error) {
@@ -108,34 +220,81 @@ func (m *txMessage) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m.Objects[0])
 }
 
-// txObjects are either requests or responses.
+// txObjects are either requests or responses. Invalid is set instead for
+// objects within a batch that could not be decoded.
 type txObject struct {
 	Request  *txRequest
 	Response *txResponse
+	Invalid  error
+}
+
+// txEnvelope is the union of request and response fields, which objects are
+// decoded into in a single pass before determining which kind of object was
+// sent based on which keys were present.
+type txEnvelope struct {
+	Version string          `json:"jsonrpc"`
+	Method  *string         `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Meta    Metadata        `json:"meta"`
+	ID      ID              `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
 }
 
 func (m *txObject) UnmarshalJSON(bb []byte) error {
-	var (
-		req  txRequest
-		resp txResponse
-	)
-
-	reqErr := json.Unmarshal(bb, &req)
-	if reqErr == nil {
-		m.Request = &req
-		return nil
+	var env txEnvelope
+
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&env); err != nil {
+		return fmt.Errorf("invalid json-rpc 2.0 message: %w", err)
 	}
+	return m.fromEnvelope(&env)
+}
 
-	respErr := json.Unmarshal(bb, &resp)
-	if respErr == nil {
-		m.Response = &resp
-		return nil
+// fromEnvelope sets m to the request or response held by env.
+func (m *txObject) fromEnvelope(env *txEnvelope) error {
+	if env.Version != "2.0" {
+		return fmt.Errorf("invalid json-rpc 2.0 message: invalid jsonrpc version: %s", env.Version)
 	}
 
-	return fmt.Errorf("invalid json-rpc 2.0 message: %s for request and %s for response", reqErr, respErr)
+	switch {
+	case env.Method != nil:
+		if env.Result != nil || env.Error != nil {
+			return fmt.Errorf("invalid json-rpc 2.0 message: request may not have result or error")
+		}
+		m.Request = &txRequest{
+			Notification: env.ID.IsUndefined(),
+			ID:           env.ID,
+			Method:       *env.Method,
+			Params:       env.Params,
+			Meta:         env.Meta,
+		}
+		return nil
+
+	case env.Result != nil || env.Error != nil:
+		if env.Params != nil || env.Meta != nil {
+			return fmt.Errorf("invalid json-rpc 2.0 message: response may not have params or meta")
+		}
+		if len(env.Result) > 0 && env.Error != nil {
+			return fmt.Errorf("invalid json-rpc 2.0 message: only one of result and error may be set")
+		}
+		m.Response = &txResponse{
+			ID:     env.ID,
+			Result: env.Result,
+			Error:  env.Error,
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("invalid json-rpc 2.0 message: one of method, result, or error must be set")
+	}
 }
 
 func (o *txObject) MarshalJSON() ([]byte, error) {
+	if o.Invalid != nil {
+		return nil, fmt.Errorf("invalid object: %w", o.Invalid)
+	}
 	if o.Request != nil && o.Response != nil {
 		return nil, fmt.Errorf("invalid object: only request or response may be set")
 	}
@@ -153,35 +312,15 @@ func (o *txObject) MarshalJSON() ([]byte, error) {
 type txRequest struct {
 	// If Notification is true, then ID must be nil.
 	Notification bool
-	ID           id
+	ID           ID
 	Method       string
 	Params       json.RawMessage
-}
+	Meta         Metadata
 
-func (r *txRequest) UnmarshalJSON(bb []byte) error {
-	type plain struct {
-		Version string          `json:"jsonrpc"`
-		Method  string          `json:"method"`
-		Params  json.RawMessage `json:"params"`
-		ID      id              `json:"id"`
-	}
-	var p plain
-
-	dec := json.NewDecoder(bytes.NewReader(bb))
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&p); err != nil {
-		return err
-	}
-
-	if p.Version != "2.0" {
-		return fmt.Errorf("invalid jsonrpc version: %s", p.Version)
-	}
-
-	r.Notification = p.ID.IsUndefined()
-	r.Method = p.Method
-	r.Params = p.Params
-	r.ID = p.ID
-	return nil
+	// ctx is the request's context if it can be cancelled with
+	// MethodCancelRequest, and cancel cancels it.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (r *txRequest) MarshalJSON() ([]byte, error) {
@@ -190,23 +329,27 @@ func (r *txRequest) MarshalJSON() ([]byte, error) {
 			Version string          `json:"jsonrpc"`
 			Method  string          `json:"method"`
 			Params  json.RawMessage `json:"params"`
+			Meta    Metadata        `json:"meta,omitempty"`
 		}
 		var n notification
 		n.Version = "2.0"
 		n.Method = r.Method
 		n.Params = r.Params
+		n.Meta = r.Meta
 		return json.Marshal(n)
 	} else {
 		type plain struct {
 			Version string          `json:"jsonrpc"`
 			Method  string          `json:"method"`
 			Params  json.RawMessage `json:"params"`
-			ID      id              `json:"id"`
+			Meta    Metadata        `json:"meta,omitempty"`
+			ID      ID              `json:"id"`
 		}
 		var p plain
 		p.Version = "2.0"
 		p.Method = r.Method
 		p.Params = r.Params
+		p.Meta = r.Meta
 		p.ID = r.ID
 		return json.Marshal(p)
 	}
@@ -214,40 +357,13 @@ func (r *txRequest) MarshalJSON() ([]byte, error) {
 
 type txResponse struct {
 	// ID must be nil the request couldn't be parsed.
-	ID     id
+	ID     ID
 	Result json.RawMessage
 	Error  *Error
-}
-
-func (r *txResponse) UnmarshalJSON(bb []byte) error {
-	type plain struct {
-		Version string          `json:"jsonrpc"`
-		Result  json.RawMessage `json:"result"`
-		Error   *Error          `json:"error"`
-		ID      id              `json:"id"`
-	}
-	var p plain
-
-	dec := json.NewDecoder(bytes.NewReader(bb))
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&p); err != nil {
-		return err
-	}
 
-	if p.Version != "2.0" {
-		return fmt.Errorf("invalid jsonrpc version: %s", p.Version)
-	}
-
-	if len(p.Result) > 0 && p.Error != nil {
-		return fmt.Errorf("only one of result and error may be set")
-	} else if p.Result == nil && p.Error == nil {
-		return fmt.Errorf("one of result or error must be set")
-	}
-
-	r.ID = p.ID
-	r.Result = p.Result
-	r.Error = p.Error
-	return nil
+	// err is set instead of Result or Error for responses which couldn't be
+	// read.
+	err error
 }
 
 func (r *txResponse) MarshalJSON() ([]byte, error) {
@@ -261,7 +377,7 @@ func (r *txResponse) MarshalJSON() ([]byte, error) {
 		type plain struct {
 			Version string          `json:"jsonrpc"`
 			Result  json.RawMessage `json:"result"`
-			ID      *id             `json:"id,omitempty"`
+			ID      *ID             `json:"id,omitempty"`
 		}
 		var p plain
 		p.Version = "2.0"
@@ -275,7 +391,7 @@ func (r *txResponse) MarshalJSON() ([]byte, error) {
 		type plain struct {
 			Version string `json:"jsonrpc"`
 			Error   *Error `json:"error"`
-			ID      *id    `json:"id,omitempty"`
+			ID      *ID    `json:"id,omitempty"`
 		}
 		var p plain
 		p.Version = "2.0"
@@ -1,6 +1,7 @@
 package jsonrpc2
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -14,66 +15,74 @@ const (
 	idTypeNumber
 )
 
-// id represents a JSON-RPC 2.0 id. The zero value is an undefined id.
-type id struct {
+// ID represents a JSON-RPC 2.0 id. The zero value is an undefined ID, used by
+// notifications.
+type ID struct {
 	value   string
 	ty      idType
 	defined bool
 }
 
-func newUndefinedID() id { return id{} }
+func newUndefinedID() ID { return ID{} }
 
-func newNullID() id {
-	return id{ty: idTypeNull, defined: true}
+// NewNullID returns a null ID.
+func NewNullID() ID {
+	return ID{ty: idTypeNull, defined: true}
 }
 
-func newStringID(value string) id {
-	return id{value: value, ty: idTypeString, defined: true}
+// NewStringID returns a string ID.
+func NewStringID(value string) ID {
+	return ID{value: value, ty: idTypeString, defined: true}
 }
 
-func newNumberID(value int64) id {
-	return id{value: strconv.FormatInt(value, 10), ty: idTypeNumber, defined: true}
+// NewNumberID returns a numeric ID.
+func NewNumberID(value int64) ID {
+	return ID{value: strconv.FormatInt(value, 10), ty: idTypeNumber, defined: true}
 }
 
-func (v *id) IsNull() bool      { return v.ty == idTypeNull }
-func (v *id) IsString() bool    { return v.ty == idTypeString }
-func (v *id) IsNumber() bool    { return v.ty == idTypeNumber }
-func (v *id) IsUndefined() bool { return !v.defined }
-func (v *id) String() string    { return v.value }
+func (v ID) IsNull() bool      { return v.defined && v.ty == idTypeNull }
+func (v ID) IsString() bool    { return v.ty == idTypeString }
+func (v ID) IsNumber() bool    { return v.ty == idTypeNumber }
+func (v ID) IsUndefined() bool { return !v.defined }
+func (v ID) String() string    { return v.value }
 
-func (v *id) UnmarshalJSON(bb []byte) error {
+func (v *ID) UnmarshalJSON(bb []byte) error {
 	v.defined = true
 
-	// Try unmarshaling an *int first. This covers null types
-	// and numeric IDs.
-	var numericVal *int64
-	if err := json.Unmarshal(bb, &numericVal); err == nil {
-		if numericVal == nil {
-			*v = newNullID()
-			return nil
-		}
-		*v = newNumberID(*numericVal)
-		return nil
+	var raw interface{}
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return fmt.Errorf("id must be string, number, or null")
 	}
 
-	// Fall back to string.
-	var stringVal string
-	if err := json.Unmarshal(bb, &stringVal); err == nil {
-		*v = newStringID(stringVal)
-		return nil
+	switch raw := raw.(type) {
+	case nil:
+		*v = NewNullID()
+	case string:
+		*v = NewStringID(raw)
+	case json.Number:
+		// Numbers which fit in an int64 are normalized. Others, such as
+		// 1.5 or numbers too large for an int64, keep the text they were
+		// sent with so they can be echoed back exactly.
+		if n, err := raw.Int64(); err == nil {
+			*v = NewNumberID(n)
+		} else {
+			*v = ID{value: raw.String(), ty: idTypeNumber, defined: true}
+		}
+	default:
+		return fmt.Errorf("id must be string, number, or null")
 	}
-
-	return fmt.Errorf("id must be string, number, or null")
+	return nil
 }
 
-func (v id) MarshalJSON() ([]byte, error) {
+func (v ID) MarshalJSON() ([]byte, error) {
 	switch v.ty {
 	case idTypeNumber:
-		val, err := strconv.Atoi(v.value)
-		if err != nil {
-			return nil, fmt.Errorf("invalid numeric id: %w", err)
+		if !json.Valid([]byte(v.value)) {
+			return nil, fmt.Errorf("invalid numeric id: %q", v.value)
 		}
-		return json.Marshal(val)
+		return []byte(v.value), nil
 	case idTypeString:
 		return json.Marshal(v.value)
 	case idTypeNull:
@@ -1,38 +1,57 @@
 package jsonrpc2
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 func Test_id_Unmarshal(t *testing.T) {
 	tt := []struct {
 		name   string
 		input  string
-		expect id
+		expect ID
 	}{
 		{
 			name:   "null",
 			input:  `null`,
-			expect: newNullID(),
+			expect: NewNullID(),
 		},
 		{
 			name:   "number",
 			input:  `12345`,
-			expect: newNumberID(12345),
+			expect: NewNumberID(12345),
 		},
 		{
 			name:   "string",
 			input:  `"hello"`,
-			expect: newStringID("hello"),
+			expect: NewStringID("hello"),
+		},
+		{
+			name:   "numeric string",
+			input:  `"12345"`,
+			expect: NewStringID("12345"),
+		},
+		{
+			name:   "large number",
+			input:  `18446744073709551616`,
+			expect: ID{value: "18446744073709551616", ty: idTypeNumber, defined: true},
+		},
+		{
+			name:   "fraction",
+			input:  `1.5`,
+			expect: ID{value: "1.5", ty: idTypeNumber, defined: true},
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			var actual id
+			var actual ID
 			err := json.Unmarshal([]byte(tc.input), &actual)
 			require.NoError(t, err)
 			require.Equal(t, actual, tc.expect)
@@ -43,24 +62,29 @@ func Test_id_Unmarshal(t *testing.T) {
 func Test_id_Marshal(t *testing.T) {
 	tt := []struct {
 		name   string
-		input  id
+		input  ID
 		expect string
 	}{
 		{
 			name:   "null",
-			input:  newNullID(),
+			input:  NewNullID(),
 			expect: `null`,
 		},
 		{
 			name:   "number",
-			input:  newNumberID(12345),
+			input:  NewNumberID(12345),
 			expect: `12345`,
 		},
 		{
 			name:   "string",
-			input:  newStringID("hello"),
+			input:  NewStringID("hello"),
 			expect: `"hello"`,
 		},
+		{
+			name:   "large number",
+			input:  ID{value: "18446744073709551616", ty: idTypeNumber, defined: true},
+			expect: `18446744073709551616`,
+		},
 	}
 
 	for _, tc := range tt {
@@ -71,3 +95,149 @@ func Test_id_Marshal(t *testing.T) {
 		})
 	}
 }
+
+func Test_id_Unmarshal_Invalid(t *testing.T) {
+	for _, input := range []string{`true`, `[1]`, `{"id": 1}`} {
+		var id ID
+		require.Error(t, json.Unmarshal([]byte(input), &id), input)
+	}
+}
+
+func TestClient_StringIDs(t *testing.T) {
+	// Respond to requests out of order, so responses must be matched to
+	// requests by ID.
+	release := make(chan struct{})
+	mux := NewServeMux()
+	mux.HandleFunc("wait", func(w ResponseWriter, r *Request) {
+		<-release
+		_ = w.WriteMessage("wait")
+	})
+	mux.HandleFunc("now", func(w ResponseWriter, r *Request) {
+		_ = w.WriteMessage("now")
+	})
+
+	ids := make(chan ID, 2)
+	var n atomic.Int64
+	gen := func() ID {
+		id := NewStringID(fmt.Sprintf("6f1c2d3e-0000-4000-8000-%012d", n.Inc()))
+		ids <- id
+		return id
+	}
+
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, mux)
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithIDGenerator(gen))
+	defer cli.Close()
+
+	ctx := context.Background()
+	waited := make(chan error, 1)
+	go func() {
+		res, err := cli.Invoke(ctx, "wait", nil)
+		if err == nil && string(res) != `"wait"` {
+			err = fmt.Errorf("unexpected result %s", res)
+		}
+		waited <- err
+	}()
+	<-ids
+
+	res, err := cli.Invoke(ctx, "now", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"now"`, string(res))
+	require.True(t, (<-ids).IsString())
+
+	close(release)
+	require.NoError(t, <-waited)
+}
+
+func TestClient_EchoesNumericIDs(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage(true) }))
+	defer srv.Close()
+	defer cliConn.Close()
+
+	// IDs which don't fit in an int64, and string IDs which look like
+	// numbers, are echoed back exactly.
+	for _, id := range []string{`18446744073709551616`, `1.5`, `"12345"`} {
+		_, err := cliConn.Write([]byte(`{"jsonrpc": "2.0", "method": "ok", "id": ` + id + `}`))
+		require.NoError(t, err)
+
+		var resp struct {
+			ID json.RawMessage `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(cliConn).Decode(&resp))
+		require.Equal(t, id, string(resp.ID))
+	}
+}
+
+func TestPrefixedIDs(t *testing.T) {
+	gen := PrefixedIDs("indexer-")
+	require.Equal(t, NewStringID("indexer-1"), gen())
+	require.Equal(t, NewStringID("indexer-2"), gen())
+}
+
+func TestRandomIDs(t *testing.T) {
+	gen := RandomIDs()
+	a, b := gen(), gen()
+	require.True(t, a.IsString())
+	require.NotEqual(t, a, b)
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, a.String())
+}
+
+func TestClient_InvalidGeneratedIDs(t *testing.T) {
+	var next ID
+	srvConn, cliConn := net.Pipe()
+	srv := NewClient(srvConn, HandlerFunc(func(w ResponseWriter, r *Request) { _ = w.WriteMessage(true) }))
+	defer srv.Close()
+	cli := NewClient(cliConn, nil, WithIDGenerator(func() ID { return next }))
+	defer cli.Close()
+
+	ctx := context.Background()
+	for _, id := range []ID{{}, NewNullID()} {
+		next = id
+		_, err := cli.Invoke(ctx, "ok", nil)
+		require.Error(t, err)
+		_, err = cli.Batch().Invoke("ok", nil)
+		require.Error(t, err)
+	}
+
+	// IDs still in use by a pending request are rejected.
+	next = NewStringID("dup")
+	b := cli.Batch()
+	_, err := b.Invoke("ok", nil)
+	require.NoError(t, err)
+	_, err = cli.Invoke(ctx, "ok", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already in use")
+	require.NoError(t, b.Commit(ctx))
+
+	res, err := cli.Invoke(ctx, "ok", nil)
+	require.NoError(t, err)
+	require.Equal(t, "true", string(res))
+}
+
+func TestClient_SendsNumericIDs(t *testing.T) {
+	srvConn, cliConn := net.Pipe()
+	defer srvConn.Close()
+	cli := NewClient(cliConn, nil)
+	defer cli.Close()
+
+	// Servers which only accept integer IDs, such as many blockchain nodes,
+	// work with the default SequentialIDs.
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cli.Invoke(context.Background(), "eth_blockNumber", nil)
+		errs <- err
+	}()
+
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	dec := json.NewDecoder(srvConn)
+	require.NoError(t, dec.Decode(&req))
+	require.Equal(t, `1`, string(req.ID))
+
+	_, err := srvConn.Write([]byte(`{"jsonrpc": "2.0", "result": "0x10", "id": 1}`))
+	require.NoError(t, err)
+	require.NoError(t, <-errs)
+}
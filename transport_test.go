@@ -1,7 +1,10 @@
 package jsonrpc2
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -33,7 +36,7 @@ func TestTransport_Marshal(t *testing.T) {
 			name: "request with id",
 			input: &txRequest{
 				Notification: false,
-				ID:           newStringID("12345"),
+				ID:           NewStringID("12345"),
 				Method:       "hello",
 				Params:       json.RawMessage(`{}`),
 			},
@@ -61,7 +64,7 @@ func TestTransport_Marshal(t *testing.T) {
 		{
 			name: "success response",
 			input: &txResponse{
-				ID:     newNullID(),
+				ID:     NewNullID(),
 				Result: json.RawMessage(`{}`),
 			},
 			expect: `{
@@ -73,7 +76,7 @@ func TestTransport_Marshal(t *testing.T) {
 		{
 			name: "error response",
 			input: &txResponse{
-				ID: newStringID("12345"),
+				ID: NewStringID("12345"),
 				Error: &Error{
 					Code:    ErrorInternal,
 					Message: "some error",
@@ -121,7 +124,7 @@ func TestTransport_Marshal(t *testing.T) {
 			input: &txObject{
 				Response: &txResponse{
 					Result: json.RawMessage(`[]`),
-					ID:     newNullID(),
+					ID:     NewNullID(),
 				},
 			},
 			expect: `{
@@ -136,7 +139,7 @@ func TestTransport_Marshal(t *testing.T) {
 			input: &txMessage{
 				Objects: []*txObject{{
 					Request: &txRequest{
-						ID:     newStringID("1"),
+						ID:     NewStringID("1"),
 						Method: "hello",
 						Params: json.RawMessage(`[]`),
 					},
@@ -155,7 +158,7 @@ func TestTransport_Marshal(t *testing.T) {
 				Batched: true,
 				Objects: []*txObject{{
 					Request: &txRequest{
-						ID:     newStringID("1"),
+						ID:     NewStringID("1"),
 						Method: "hello",
 						Params: json.RawMessage(`[]`),
 					},
@@ -195,17 +198,39 @@ func TestTransport_Unmarshal(t *testing.T) {
 				"id": null
 			}`,
 			unmarshal: func(bb []byte) (interface{}, error) {
-				var msg txRequest
+				var msg txObject
 				err := json.Unmarshal(bb, &msg)
-				return &msg, err
+				return msg.Request, err
 			},
 			expect: &txRequest{
 				Notification: false,
-				ID:           newNullID(),
+				ID:           NewNullID(),
 				Method:       "hello",
 				Params:       json.RawMessage(`[0,1,2]`),
 			},
 		},
+		{
+			name: "request with meta",
+			input: `{
+				"jsonrpc": "2.0",
+				"method": "hello",
+				"params": {},
+				"meta": {"identity": "alice"},
+				"id": 1
+			}`,
+			unmarshal: func(bb []byte) (interface{}, error) {
+				var msg txObject
+				err := json.Unmarshal(bb, &msg)
+				return msg.Request, err
+			},
+			expect: &txRequest{
+				Notification: false,
+				ID:           NewNumberID(1),
+				Method:       "hello",
+				Params:       json.RawMessage(`{}`),
+				Meta:         Metadata{"identity": "alice"},
+			},
+		},
 		{
 			name: "request with id",
 			input: `{
@@ -215,13 +240,13 @@ func TestTransport_Unmarshal(t *testing.T) {
 				"id": "12345"
 			}`,
 			unmarshal: func(bb []byte) (interface{}, error) {
-				var msg txRequest
+				var msg txObject
 				err := json.Unmarshal(bb, &msg)
-				return &msg, err
+				return msg.Request, err
 			},
 			expect: &txRequest{
 				Notification: false,
-				ID:           newStringID("12345"),
+				ID:           NewStringID("12345"),
 				Method:       "hello",
 				Params:       json.RawMessage(`{}`),
 			},
@@ -234,9 +259,9 @@ func TestTransport_Unmarshal(t *testing.T) {
 				"params": {}
 			}`,
 			unmarshal: func(bb []byte) (interface{}, error) {
-				var msg txRequest
+				var msg txObject
 				err := json.Unmarshal(bb, &msg)
-				return &msg, err
+				return msg.Request, err
 			},
 			expect: &txRequest{
 				Notification: true,
@@ -253,12 +278,12 @@ func TestTransport_Unmarshal(t *testing.T) {
 				"result": {}
 			}`,
 			unmarshal: func(bb []byte) (interface{}, error) {
-				var msg txResponse
+				var msg txObject
 				err := json.Unmarshal(bb, &msg)
-				return &msg, err
+				return msg.Response, err
 			},
 			expect: &txResponse{
-				ID:     newNullID(),
+				ID:     NewNullID(),
 				Result: json.RawMessage(`{}`),
 			},
 		},
@@ -273,12 +298,12 @@ func TestTransport_Unmarshal(t *testing.T) {
 				}
 			}`,
 			unmarshal: func(bb []byte) (interface{}, error) {
-				var msg txResponse
+				var msg txObject
 				err := json.Unmarshal(bb, &msg)
-				return &msg, err
+				return msg.Response, err
 			},
 			expect: &txResponse{
-				ID: newStringID("12345"),
+				ID: NewStringID("12345"),
 				Error: &Error{
 					Code:    ErrorInternal,
 					Message: "some error",
@@ -338,7 +363,7 @@ func TestTransport_Unmarshal(t *testing.T) {
 			expect: &txObject{
 				Response: &txResponse{
 					Result: json.RawMessage(`[]`),
-					ID:     newNullID(),
+					ID:     NewNullID(),
 				},
 			},
 		},
@@ -359,7 +384,7 @@ func TestTransport_Unmarshal(t *testing.T) {
 			expect: &txMessage{
 				Objects: []*txObject{{
 					Request: &txRequest{
-						ID:     newStringID("1"),
+						ID:     NewStringID("1"),
 						Method: "hello",
 						Params: json.RawMessage(`[]`),
 					},
@@ -383,7 +408,7 @@ func TestTransport_Unmarshal(t *testing.T) {
 				Batched: true,
 				Objects: []*txObject{{
 					Request: &txRequest{
-						ID:     newStringID("1"),
+						ID:     NewStringID("1"),
 						Method: "hello",
 						Params: json.RawMessage(`[]`),
 					},
@@ -400,3 +425,117 @@ func TestTransport_Unmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestTransport_UnmarshalInvalid(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+	}{
+		{name: "bad version", input: `{"jsonrpc": "1.0", "method": "hello"}`},
+		{name: "missing method", input: `{"jsonrpc": "2.0", "id": 1}`},
+		{name: "request with result", input: `{"jsonrpc": "2.0", "method": "hello", "result": 1}`},
+		{name: "response with params", input: `{"jsonrpc": "2.0", "result": 1, "params": []}`},
+		{name: "result and error", input: `{"jsonrpc": "2.0", "result": 1, "error": {"code": 1, "message": ""}}`},
+		{name: "unknown field", input: `{"jsonrpc": "2.0", "method": "hello", "extra": true}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var obj txObject
+			require.Error(t, json.Unmarshal([]byte(tc.input), &obj))
+		})
+	}
+}
+
+func TestTransport_ReadMessage(t *testing.T) {
+	input := `{"jsonrpc": "2.0", "method": "a", "params": "{}]\"", "id": 1}` +
+		"\n\t" + `[{"jsonrpc": "2.0", "method": "b", "params": []}]` +
+		`{"jsonrpc": "2.0", "result": {"nested": [1, {"x": "}"}]}, "id": "2"}`
+
+	tx := newTransport(bytes.NewBufferString(input))
+
+	msg, err := tx.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "a", msg.Objects[0].Request.Method)
+	require.Equal(t, json.RawMessage(`"{}]\""`), msg.Objects[0].Request.Params)
+
+	msg, err = tx.ReadMessage()
+	require.NoError(t, err)
+	require.True(t, msg.Batched)
+	require.Equal(t, "b", msg.Objects[0].Request.Method)
+
+	msg, err = tx.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, NewStringID("2"), msg.Objects[0].Response.ID)
+
+	_, err = tx.ReadMessage()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestTransport_ReadMessage_Invalid(t *testing.T) {
+	tx := newTransport(bytes.NewBufferString(`garbage {"jsonrpc": "2.0", "method": "a"}`))
+
+	_, err := tx.ReadMessage()
+	var txErr *transportError
+	require.ErrorAs(t, err, &txErr)
+
+	// The transport should recover and read the next message.
+	msg, err := tx.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "a", msg.Objects[0].Request.Method)
+}
+
+func TestTransport_MaxRetained(t *testing.T) {
+	large := `{"jsonrpc": "2.0", "method": "a", "params": "` + strings.Repeat("x", 128*1024) + `"}`
+	small := `{"jsonrpc": "2.0", "method": "b"}`
+
+	tx := newTransport(bytes.NewBufferString(large + small + large))
+	tx.f.(*streamFramer).SetMaxRetainedBuffer(16 * 1024)
+
+	for i := 0; i < 3; i++ {
+		_, err := tx.ReadMessage()
+		require.NoError(t, err)
+	}
+
+	stats := tx.f.(*streamFramer).BufferStats()
+	require.Equal(t, 0, stats.Retained)
+	require.Greater(t, stats.Peak, 128*1024)
+	require.Greater(t, stats.Releases, uint64(0))
+}
+
+func BenchmarkTxObject_Unmarshal(b *testing.B) {
+	inputs := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "request",
+			input: `{"jsonrpc": "2.0", "method": "sum", "params": [1, 2, 3], "id": 1}`,
+		},
+		{
+			name:  "notification",
+			input: `{"jsonrpc": "2.0", "method": "update", "params": {"value": 5}}`,
+		},
+		{
+			name:  "response",
+			input: `{"jsonrpc": "2.0", "result": {"value": 6}, "id": 1}`,
+		},
+		{
+			name:  "error response",
+			input: `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": "1"}`,
+		},
+	}
+
+	for _, in := range inputs {
+		bb := []byte(in.input)
+		b.Run(in.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var obj txObject
+				if err := json.Unmarshal(bb, &obj); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
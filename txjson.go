@@ -0,0 +1,25 @@
+//go:build !(goexperiment.jsonv2 && jsonrpc2_jsonv2)
+
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// unmarshalTxMessage decodes a frame read from the transport into m.
+func unmarshalTxMessage(frame []byte, m *txMessage) error {
+	return json.Unmarshal(frame, m)
+}
+
+// marshalTxMessage encodes m as a frame.
+func marshalTxMessage(m *txMessage) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// isSyntaxError reports whether err is from decoding malformed JSON.
+func isSyntaxError(err error) bool {
+	var se *json.SyntaxError
+	return errors.As(err, &se) || errors.Is(err, io.ErrUnexpectedEOF)
+}
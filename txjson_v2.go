@@ -0,0 +1,401 @@
+//go:build goexperiment.jsonv2 && jsonrpc2_jsonv2
+
+package jsonrpc2
+
+// This file decodes and encodes transport messages with the streaming
+// encoding/json/jsontext API instead of encoding/json. It is experimental,
+// and only built with GOEXPERIMENT=jsonv2 and the jsonrpc2_jsonv2 build tag:
+//
+//	GOEXPERIMENT=jsonv2 go build -tags jsonrpc2_jsonv2
+//
+// Messages are decoded and encoded token by token, without decoding each
+// object into an envelope first or going through the MarshalJSON and
+// UnmarshalJSON methods of each part. Params and results are still copied
+// out of the frame, since handlers keep them after the read buffer is
+// reused. The wire format is the same as with encoding/json.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"encoding/json/jsontext"
+)
+
+type txDecoder struct {
+	buf bytes.Buffer
+	dec jsontext.Decoder
+}
+
+var txDecoders = sync.Pool{New: func() interface{} { return new(txDecoder) }}
+
+// Decoding matches encoding/json, which allows duplicate names and invalid
+// UTF-8.
+var txDecodeOptions = []jsontext.Options{jsontext.AllowDuplicateNames(true), jsontext.AllowInvalidUTF8(true)}
+
+// unmarshalTxMessage decodes a frame read from the transport into m.
+func unmarshalTxMessage(frame []byte, m *txMessage) error {
+	d := txDecoders.Get().(*txDecoder)
+	defer func() {
+		// Don't keep the frame alive from the pool.
+		d.buf = bytes.Buffer{}
+		d.dec.Reset(&d.buf)
+		txDecoders.Put(d)
+	}()
+	// The Decoder reads directly from a bytes.Buffer without copying it.
+	d.buf = *bytes.NewBuffer(frame)
+	d.dec.Reset(&d.buf, txDecodeOptions...)
+	dec := &d.dec
+
+	if dec.PeekKind() != jsontext.KindBeginArray {
+		var obj txObject
+		if err := decodeTxObject(dec, &obj); err != nil {
+			// A syntax error anywhere in the frame takes precedence, as with
+			// encoding/json, which checks the whole frame first.
+			if skipErr := skipRest(dec, 0); skipErr != nil {
+				return skipErr
+			}
+			return err
+		}
+		if err := expectEOF(dec); err != nil {
+			return err
+		}
+		m.Batched = false
+		m.Objects = []*txObject{&obj}
+		return nil
+	}
+
+	if _, err := dec.ReadToken(); err != nil {
+		return err
+	}
+	// Invalid objects within a batch don't invalidate the whole batch, so
+	// that the other objects can still be handled.
+	m.Batched = true
+	m.Objects = nil
+	for dec.PeekKind() != jsontext.KindEndArray {
+		start := dec.InputOffset()
+		var obj txObject
+		if err := decodeTxObject(dec, &obj); err != nil {
+			if isSyntaxError(err) {
+				return err
+			}
+			if skipErr := skipRest(dec, 1); skipErr != nil {
+				return skipErr
+			}
+			elem := bytes.TrimLeft(frame[start:dec.InputOffset()], " \t\r\n,")
+			obj = invalidObject(elem, err)
+		}
+		m.Objects = append(m.Objects, &obj)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return err
+	}
+	if err := expectEOF(dec); err != nil {
+		return err
+	}
+	if len(m.Objects) == 0 {
+		return fmt.Errorf("invalid json-rpc 2.0 message: empty batch")
+	}
+	return nil
+}
+
+// skipRest reads the rest of the value which failed to decode, until dec
+// is back at depth. Syntax errors found on the way are returned.
+func skipRest(dec *jsontext.Decoder, depth int) error {
+	for dec.StackDepth() > depth {
+		if _, err := dec.ReadToken(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expectEOF fails if there is anything but whitespace after the frame's
+// value.
+func expectEOF(dec *jsontext.Decoder) error {
+	if dec.PeekKind() == jsontext.KindInvalid {
+		if _, err := dec.ReadToken(); !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	}
+	return &jsontext.SyntacticError{ByteOffset: dec.InputOffset(), Err: errors.New("invalid data after top-level value")}
+}
+
+// envelopeFields are the names of the members of txEnvelope.
+var envelopeFields = []string{"jsonrpc", "method", "params", "meta", "id", "result", "error"}
+
+// envelopeField returns the txEnvelope member that name is for, matching
+// names case-insensitively as encoding/json does.
+func envelopeField(name string) string {
+	for _, f := range envelopeFields {
+		if name == f {
+			return f
+		}
+	}
+	for _, f := range envelopeFields {
+		if strings.EqualFold(name, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// decodeTxObject decodes the next object from dec into m. If it fails, dec
+// may be left partway through the object.
+func decodeTxObject(dec *jsontext.Decoder, m *txObject) error {
+	if kind := dec.PeekKind(); kind != jsontext.KindBeginObject {
+		if err := dec.SkipValue(); err != nil {
+			return err
+		}
+		return fmt.Errorf("invalid json-rpc 2.0 message: expected an object, got %v", kind)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return err
+	}
+
+	var env txEnvelope
+	for dec.PeekKind() != jsontext.KindEndObject {
+		name, err := dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		field := envelopeField(name.String())
+		if field == "" {
+			return fmt.Errorf("invalid json-rpc 2.0 message: json: unknown field %q", name.String())
+		}
+
+		v, err := dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		if err := env.decodeField(field, v); err != nil {
+			return fmt.Errorf("invalid json-rpc 2.0 message: %w", err)
+		}
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return err
+	}
+	return m.fromEnvelope(&env)
+}
+
+// decodeField decodes v into the member of env named field. v is only
+// valid until the next read, so it is copied where it's kept.
+func (env *txEnvelope) decodeField(field string, v jsontext.Value) error {
+	switch field {
+	case "jsonrpc":
+		s, err := decodeString(field, v)
+		if s != nil {
+			env.Version = *s
+		}
+		return err
+	case "method":
+		s, err := decodeString(field, v)
+		if s != nil {
+			env.Method = s
+		}
+		return err
+	case "params":
+		env.Params = append(json.RawMessage(nil), v...)
+	case "result":
+		env.Result = append(json.RawMessage(nil), v...)
+	case "id":
+		return env.ID.UnmarshalJSON(v)
+	case "meta":
+		return json.Unmarshal(v, &env.Meta)
+	case "error":
+		env.Error = nil
+		if v.Kind() != jsontext.KindNull {
+			env.Error = new(Error)
+			return json.Unmarshal(v, env.Error)
+		}
+	}
+	return nil
+}
+
+// decodeString decodes a string member, which is left unset for null.
+func decodeString(field string, v jsontext.Value) (*string, error) {
+	switch v.Kind() {
+	case jsontext.KindNull:
+		return nil, nil
+	case jsontext.KindString:
+		unquoted, err := jsontext.AppendUnquote(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		s := string(unquoted)
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("json: cannot unmarshal %v into field %s of type string", v.Kind(), field)
+	}
+}
+
+type txEncoder struct {
+	buf bytes.Buffer
+	enc jsontext.Encoder
+}
+
+var txEncoders = sync.Pool{New: func() interface{} { return new(txEncoder) }}
+
+// Encoding matches encoding/json, which escapes HTML characters and the
+// line and paragraph separators, and doesn't check params and results for
+// duplicate names.
+var txEncodeOptions = []jsontext.Options{
+	jsontext.EscapeForHTML(true),
+	jsontext.EscapeForJS(true),
+	jsontext.AllowDuplicateNames(true),
+	jsontext.AllowInvalidUTF8(true),
+}
+
+// marshalTxMessage encodes m as a frame.
+func marshalTxMessage(m *txMessage) ([]byte, error) {
+	e := txEncoders.Get().(*txEncoder)
+	defer txEncoders.Put(e)
+	e.buf.Reset()
+	e.enc.Reset(&e.buf, txEncodeOptions...)
+
+	if err := encodeTxMessage(&e.enc, m); err != nil {
+		return nil, err
+	}
+	// The Encoder ends each value with a newline, which isn't part of the
+	// frame.
+	return append([]byte(nil), bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))...), nil
+}
+
+func encodeTxMessage(enc *jsontext.Encoder, m *txMessage) error {
+	if !m.Batched {
+		if len(m.Objects) != 1 {
+			return fmt.Errorf("must be one object for a non-batched message")
+		}
+		return encodeTxObject(enc, m.Objects[0])
+	}
+
+	if m.Objects == nil {
+		return enc.WriteToken(jsontext.Null)
+	}
+	if err := enc.WriteToken(jsontext.BeginArray); err != nil {
+		return err
+	}
+	for _, obj := range m.Objects {
+		if err := encodeTxObject(enc, obj); err != nil {
+			return err
+		}
+	}
+	return enc.WriteToken(jsontext.EndArray)
+}
+
+func encodeTxObject(enc *jsontext.Encoder, o *txObject) error {
+	if o.Invalid != nil {
+		return fmt.Errorf("invalid object: %w", o.Invalid)
+	}
+	if o.Request != nil && o.Response != nil {
+		return fmt.Errorf("invalid object: only request or response may be set")
+	}
+	if o.Request == nil && o.Response == nil {
+		return fmt.Errorf("invalid object: either request or response must be set")
+	}
+
+	// Members are written in the same order as the MarshalJSON methods.
+	if err := enc.WriteToken(jsontext.BeginObject); err != nil {
+		return err
+	}
+	if err := writeMember(enc, "jsonrpc", jsontext.String("2.0")); err != nil {
+		return err
+	}
+
+	if r := o.Request; r != nil {
+		if err := writeMember(enc, "method", jsontext.String(r.Method)); err != nil {
+			return err
+		}
+		if err := writeRawMember(enc, "params", r.Params); err != nil {
+			return err
+		}
+		if len(r.Meta) > 0 {
+			meta, err := json.Marshal(r.Meta)
+			if err != nil {
+				return err
+			}
+			if err := writeRawMember(enc, "meta", meta); err != nil {
+				return err
+			}
+		}
+		if !r.Notification {
+			if err := writeID(enc, r.ID); err != nil {
+				return err
+			}
+		}
+		return enc.WriteToken(jsontext.EndObject)
+	}
+
+	r := o.Response
+	if len(r.Result) > 0 && r.Error != nil {
+		return fmt.Errorf("only one of result and error may be set")
+	} else if r.Result == nil && r.Error == nil {
+		return fmt.Errorf("one of result or error must be set")
+	}
+	if r.Error == nil {
+		if err := writeRawMember(enc, "result", r.Result); err != nil {
+			return err
+		}
+	} else {
+		rpcErr, err := json.Marshal(r.Error)
+		if err != nil {
+			return err
+		}
+		if err := writeRawMember(enc, "error", rpcErr); err != nil {
+			return err
+		}
+	}
+	if !r.ID.IsUndefined() {
+		if err := writeID(enc, r.ID); err != nil {
+			return err
+		}
+	}
+	return enc.WriteToken(jsontext.EndObject)
+}
+
+func writeMember(enc *jsontext.Encoder, name string, value jsontext.Token) error {
+	if err := enc.WriteToken(jsontext.String(name)); err != nil {
+		return err
+	}
+	return enc.WriteToken(value)
+}
+
+// writeRawMember writes a member holding raw JSON, which is null if empty.
+func writeRawMember(enc *jsontext.Encoder, name string, value []byte) error {
+	if err := enc.WriteToken(jsontext.String(name)); err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		return enc.WriteToken(jsontext.Null)
+	}
+	return enc.WriteValue(value)
+}
+
+func writeID(enc *jsontext.Encoder, id ID) error {
+	if err := enc.WriteToken(jsontext.String("id")); err != nil {
+		return err
+	}
+	switch id.ty {
+	case idTypeNumber:
+		if err := enc.WriteValue(jsontext.Value(id.value)); err != nil {
+			return fmt.Errorf("invalid numeric id: %q", id.value)
+		}
+		return nil
+	case idTypeString:
+		return enc.WriteToken(jsontext.String(id.value))
+	default:
+		return enc.WriteToken(jsontext.Null)
+	}
+}
+
+// isSyntaxError reports whether err is from decoding malformed JSON.
+func isSyntaxError(err error) bool {
+	var se *json.SyntaxError
+	var syntactic *jsontext.SyntacticError
+	return errors.As(err, &se) || errors.As(err, &syntactic) || errors.Is(err, io.ErrUnexpectedEOF)
+}
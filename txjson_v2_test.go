@@ -0,0 +1,155 @@
+//go:build goexperiment.jsonv2 && jsonrpc2_jsonv2
+
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTxJSONv2_Unmarshal checks that the json/v2 decoder accepts and rejects
+// the same frames as the encoding/json methods.
+func TestTxJSONv2_Unmarshal(t *testing.T) {
+	inputs := []string{
+		`{"jsonrpc": "2.0", "method": "sum", "params": [1, 2, 3], "id": 1}`,
+		`{"jsonrpc": "2.0", "method": "sum", "params": { "a" : 1 }, "id": "abc"}`,
+		`{"jsonrpc": "2.0", "method": "update"}`,
+		`{"jsonrpc": "2.0", "method": "a", "meta": {"k": "v"}, "id": null}`,
+		`{"JSONRPC": "2.0", "Method": "a", "ID": 1}`,
+		`{"jsonrpc": "2.0", "result": null, "id": 1}`,
+		`{"jsonrpc": "2.0", "result": {"value": " <>"}, "id": 1.5}`,
+		`{"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": "1"}`,
+		`[{"jsonrpc": "2.0", "method": "a", "id": 1}, {"jsonrpc": "2.0", "method": "b"}]`,
+		`[{"jsonrpc": "2.0", "method": "a", "id": 1}, 5, {"jsonrpc": "1.0"}, {"jsonrpc": "2.0", "result": 1, "id": 2}]`,
+		`[{"jsonrpc": "1.0", "method": "a", "id": 3} , {"id": "x", "jsonrpc": "2.0", "method": 5}]`,
+
+		// Invalid messages.
+		`[]`,
+		`5`,
+		`{"jsonrpc": "1.0", "method": "hello"}`,
+		`{"jsonrpc": "2.0", "method": "hello", "extra": true}`,
+		`{"jsonrpc": "2.0", "method": 5}`,
+		`{"jsonrpc": "2.0", "result": 1, "error": {"code": 1, "message": ""}}`,
+		`{"jsonrpc": "2.0", "method": "a"} {}`,
+		`{"jsonrpc": "2.0", "method": "a", "params": [}`,
+		`[{"jsonrpc": "2.0", "method": "a"}, {]`,
+		`{"jsonrpc": "2.0", "method": "a"`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			var want, got txMessage
+			wantErr := json.Unmarshal([]byte(input), &want)
+			gotErr := unmarshalTxMessage([]byte(input), &got)
+			if wantErr != nil {
+				require.Error(t, gotErr)
+				if isSyntaxError(wantErr) != isSyntaxError(gotErr) {
+					t.Fatalf("got error %v, want %v", gotErr, wantErr)
+				}
+				return
+			}
+			require.NoError(t, gotErr)
+
+			require.Equal(t, want.Batched, got.Batched)
+			require.Len(t, got.Objects, len(want.Objects))
+			for i, obj := range got.Objects {
+				wantObj := want.Objects[i]
+				if (wantObj.Invalid != nil) != (obj.Invalid != nil) {
+					t.Fatalf("object %d: got invalid %v, want %v", i, obj.Invalid, wantObj.Invalid)
+				}
+				if obj.Invalid != nil {
+					require.Equal(t, invalidID(wantObj.Invalid), invalidID(obj.Invalid))
+				}
+				wantObj.Invalid, obj.Invalid = nil, nil
+				require.Equal(t, wantObj, obj)
+			}
+		})
+	}
+}
+
+// TestTxJSONv2_Marshal checks that the json/v2 encoder writes the same
+// frames as the encoding/json methods.
+func TestTxJSONv2_Marshal(t *testing.T) {
+	inputs := []*txMessage{
+		{Objects: []*txObject{{Request: &txRequest{
+			ID:     NewNumberID(1),
+			Method: "<sum>",
+			Params: json.RawMessage(`[1, 2, 3]`),
+		}}}},
+		{Objects: []*txObject{{Request: &txRequest{
+			Notification: true,
+			Method:       "update",
+			Meta:         Metadata{"k": " "},
+		}}}},
+		{Objects: []*txObject{{Response: &txResponse{
+			ID:     NewStringID("a&b"),
+			Result: json.RawMessage(`null`),
+		}}}},
+		{Objects: []*txObject{{Response: &txResponse{
+			ID:    NewNullID(),
+			Error: &Error{Code: ErrorMethodNotFound, Message: "not found"},
+		}}}},
+		{Batched: true, Objects: []*txObject{
+			{Request: &txRequest{ID: NewNumberID(1), Method: "a"}},
+			{Response: &txResponse{ID: NewNumberID(2), Result: json.RawMessage(`{"x": 1}`)}},
+		}},
+
+		// Invalid messages.
+		{Objects: []*txObject{{Response: &txResponse{ID: NewNumberID(1)}}}},
+		{Objects: []*txObject{{}}},
+		{Objects: []*txObject{{}, {}}},
+	}
+
+	for _, input := range inputs {
+		want, wantErr := json.Marshal(input)
+		got, gotErr := marshalTxMessage(input)
+		if wantErr != nil {
+			require.Error(t, gotErr)
+			continue
+		}
+		require.NoError(t, gotErr)
+		require.Equal(t, string(want), string(got))
+	}
+}
+
+func BenchmarkTxJSONv2_Unmarshal(b *testing.B) {
+	frame := []byte(`{"jsonrpc": "2.0", "method": "sum", "params": [1, 2, 3], "meta": {"k": "v"}, "id": 1}`)
+	codecs := map[string]func(frame []byte, m *txMessage) error{
+		"v1": func(frame []byte, m *txMessage) error { return json.Unmarshal(frame, m) },
+		"v2": unmarshalTxMessage,
+	}
+	for name, unmarshal := range codecs {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var msg txMessage
+				if err := unmarshal(frame, &msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTxJSONv2_Marshal(b *testing.B) {
+	msg := &txMessage{Objects: []*txObject{{Response: &txResponse{
+		ID:     NewNumberID(1),
+		Result: json.RawMessage(`{"value": [1, 2, 3]}`),
+	}}}}
+	codecs := map[string]func(m *txMessage) ([]byte, error){
+		"v1": func(m *txMessage) ([]byte, error) { return json.Marshal(m) },
+		"v2": marshalTxMessage,
+	}
+	for name, marshal := range codecs {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := marshal(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package udprpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+)
+
+// DefaultReplyWindow is the default for how long a Server remembers replies.
+const DefaultReplyWindow = 30 * time.Second
+
+// DefaultMaxReplies is the default limit for the number of replies a Server
+// remembers.
+const DefaultMaxReplies = 4096
+
+// Server serves JSON-RPC 2.0 requests received as UDP datagrams. Each
+// datagram is handled on its own goroutine, so requests may be handled in
+// any order.
+//
+// Requests are deduplicated by the sender's address and the request ID.
+// A retransmitted request which is still being handled is dropped, and one
+// which has been handled gets the remembered reply. Clients must not reuse
+// IDs within the reply window.
+type Server struct {
+	// Handler is invoked for each request. Request.Client is nil, since
+	// there is no connection to call back over.
+	Handler jsonrpc2.Handler
+
+	// MaxDatagramSize limits the size of replies. Replies which don't fit
+	// are replaced with an ErrorInternal reply. If zero,
+	// DefaultMaxDatagramSize is used.
+	MaxDatagramSize int
+
+	// ReplyWindow is how long replies are remembered. If zero,
+	// DefaultReplyWindow is used.
+	ReplyWindow time.Duration
+
+	// MaxReplies limits the number of remembered replies, including
+	// requests still being handled. New requests are rejected with a busy
+	// error while the limit is reached. If zero, DefaultMaxReplies is used.
+	MaxReplies int
+
+	// ReportLoad sends a snapshot of the server's load, as read by
+	// jsonrpc2.ReadLoad, with the busy errors sent while MaxReplies is
+	// reached.
+	ReportLoad bool
+
+	mut     sync.Mutex
+	replies map[string]*reply
+}
+
+// reply is the remembered reply to a request.
+type reply struct {
+	frame   []byte
+	done    bool
+	expires time.Time
+}
+
+// ListenAndServe listens on the UDP address addr and serves requests
+// received on it.
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return s.Serve(conn)
+}
+
+// Serve serves requests received on conn until reading from conn fails,
+// such as when it is closed.
+func (s *Server) Serve(conn net.PacketConn) error {
+	handler := s.Handler
+	if handler == nil {
+		handler = jsonrpc2.DefaultHandler
+	}
+	maxSize := s.MaxDatagramSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDatagramSize
+	}
+
+	buf := make([]byte, maxUDPPayload)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		frame := append([]byte(nil), buf[:n]...)
+
+		key, resend, isNew := s.track(addr, frame)
+		if !isNew {
+			if resend != nil {
+				_, _ = conn.WriteTo(resend, addr)
+			}
+			continue
+		}
+		go func() {
+			out, err := jsonrpc2.ServeFrame(context.Background(), handler, frame)
+			if err == nil && len(out) > maxSize {
+				out, err = errorReply(frame, jsonrpc2.Error{
+					Code:    jsonrpc2.ErrorInternal,
+					Message: "reply exceeds maximum datagram size",
+				})
+			}
+			s.finish(key, out)
+			if err == nil && out != nil {
+				_, _ = conn.WriteTo(out, addr)
+			}
+		}()
+	}
+}
+
+// track records that a datagram from addr is being handled. isNew is false
+// if the datagram repeats a request which is being or has been handled, in
+// which case resend is the remembered reply, if any. If too many replies are
+// remembered, isNew is false and resend is a busy error.
+func (s *Server) track(addr net.Addr, frame []byte) (key string, resend []byte, isNew bool) {
+	msgs, _, err := jsonrpc2.Parse(frame)
+	if err != nil {
+		// Let ServeFrame reply with the parse error.
+		return "", nil, true
+	}
+	var ids []string
+	for _, m := range msgs {
+		if m.Kind == jsonrpc2.KindRequest {
+			ids = append(ids, idKey(m.ID))
+		}
+	}
+	if len(ids) == 0 {
+		// Notifications are sent once, so there is nothing to deduplicate.
+		return "", nil, true
+	}
+	sort.Strings(ids)
+	key = addr.String() + " " + strings.Join(ids, ",")
+
+	limit := s.MaxReplies
+	if limit <= 0 {
+		limit = DefaultMaxReplies
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	now := time.Now()
+	if s.replies == nil {
+		s.replies = make(map[string]*reply)
+	}
+	if r, ok := s.replies[key]; ok && (!r.done || now.Before(r.expires)) {
+		return key, r.frame, false
+	}
+	if len(s.replies) >= limit {
+		for k, r := range s.replies {
+			if r.done && !now.Before(r.expires) {
+				delete(s.replies, k)
+			}
+		}
+	}
+	if len(s.replies) >= limit {
+		busyErr := jsonrpc2.NewBusyError(time.Second)
+		if s.ReportLoad {
+			var inFlight int64
+			for _, r := range s.replies {
+				if !r.done {
+					inFlight++
+				}
+			}
+			busyErr = jsonrpc2.NewBusyErrorWithLoad(time.Second, jsonrpc2.ReadLoad(inFlight))
+		}
+		busy, _ := errorReply(frame, busyErr)
+		return "", busy, false
+	}
+	s.replies[key] = &reply{}
+	return key, nil, true
+}
+
+// finish remembers the reply to the request tracked under key.
+func (s *Server) finish(key string, frame []byte) {
+	if key == "" {
+		return
+	}
+	window := s.ReplyWindow
+	if window <= 0 {
+		window = DefaultReplyWindow
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if r, ok := s.replies[key]; ok {
+		r.frame, r.done, r.expires = frame, true, time.Now().Add(window)
+	}
+}
+
+// errorReply returns an error reply to the request in frame. Batches get a
+// single reply with a null ID.
+func errorReply(frame []byte, rpcErr jsonrpc2.Error) ([]byte, error) {
+	id := jsonrpc2.NewNullID()
+	if msgs, batched, err := jsonrpc2.Parse(frame); err == nil && !batched && len(msgs) == 1 {
+		id = msgs[0].ID
+	}
+	return jsonrpc2.Encode([]jsonrpc2.Message{{Kind: jsonrpc2.KindResponse, ID: id, Error: &rpcErr}}, false)
+}
+
+// idKey returns a key for id which distinguishes string and number IDs.
+func idKey(id jsonrpc2.ID) string {
+	if id.IsString() {
+		return fmt.Sprintf("%q", id.String())
+	}
+	return id.String()
+}
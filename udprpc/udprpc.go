@@ -0,0 +1,275 @@
+// Package udprpc runs JSON-RPC 2.0 over UDP, with one message per datagram.
+//
+// UDP avoids the setup cost of a TCP connection, which suits discovery and
+// status queries on a LAN, but datagrams may be lost or duplicated. A Client
+// retransmits each request until it gets a response, and a Server remembers
+// the replies it sent so that a retransmitted request gets the same reply
+// instead of being handled twice:
+//
+//	srv := &udprpc.Server{Handler: mux}
+//	go srv.ListenAndServe(":7000")
+//
+//	cli, err := udprpc.Dial("192.168.1.20:7000")
+//	res, err := cli.Invoke(ctx, "status", nil)
+//
+// Messages must fit in a single datagram. Requests and replies which exceed
+// the maximum datagram size fail rather than being fragmented.
+//
+// Notifications are sent once and never retransmitted, since the Client
+// can't know whether they arrived.
+package udprpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"go.uber.org/atomic"
+)
+
+// DefaultMaxDatagramSize is the default limit for the size of datagrams. It
+// is the largest UDP payload which fits in a single Ethernet frame, which
+// avoids IP fragmentation.
+const DefaultMaxDatagramSize = 1472
+
+// maxUDPPayload is the largest payload of a UDP datagram over IPv4.
+const maxUDPPayload = 65507
+
+var (
+	// ErrDatagramTooLarge is returned when a message doesn't fit in a
+	// datagram of the maximum size.
+	ErrDatagramTooLarge = errors.New("udprpc: message exceeds maximum datagram size")
+
+	// ErrNoResponse is returned by Invoke when every attempt to send a
+	// request went unanswered.
+	ErrNoResponse = errors.New("udprpc: no response")
+)
+
+// ClientOpt is an option function that can be passed to NewClient.
+type ClientOpt func(*Client)
+
+// WithRetransmit sets how long the Client waits for a response before
+// retransmitting a request. The wait starts at interval and doubles with
+// each attempt, up to max. The defaults are 250ms and 2s.
+func WithRetransmit(interval, max time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.interval = interval
+		c.maxInterval = max
+	}
+}
+
+// WithMaxAttempts sets how many times a request is sent before Invoke gives
+// up with ErrNoResponse. The default is 5.
+func WithMaxAttempts(n int) ClientOpt {
+	return func(c *Client) {
+		c.maxAttempts = n
+	}
+}
+
+// WithMaxDatagramSize sets the largest request the Client sends, in bytes.
+// Larger requests fail with ErrDatagramTooLarge. The default is
+// DefaultMaxDatagramSize.
+func WithMaxDatagramSize(n int) ClientOpt {
+	return func(c *Client) {
+		c.maxSize = n
+	}
+}
+
+// Client sends requests to a single UDP server. Client implements
+// jsonrpc2.Conn. The server can't call back over a Client.
+type Client struct {
+	conn net.PacketConn
+	addr net.Addr
+
+	interval    time.Duration
+	maxInterval time.Duration
+	maxAttempts int
+	maxSize     int
+
+	// idPrefix is random so that IDs don't repeat across Clients, which
+	// would make the server treat a new request as a retransmission.
+	idPrefix string
+	nextID   atomic.Int64
+
+	mut     sync.Mutex
+	waiters map[jsonrpc2.ID]chan *jsonrpc2.Message
+
+	done chan struct{}
+}
+
+var _ jsonrpc2.Conn = (*Client)(nil)
+
+// Dial creates a Client which sends requests to the server at addr from a
+// new local UDP socket.
+func Dial(addr string, opts ...ClientOpt) (*Client, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve address: %w", err)
+	}
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	return NewClient(conn, raddr, opts...), nil
+}
+
+// NewClient creates a Client which sends requests to addr over conn and
+// starts reading responses. Datagrams from other addresses are ignored. conn
+// is closed when the Client is closed.
+func NewClient(conn net.PacketConn, addr net.Addr, opts ...ClientOpt) *Client {
+	var prefix [4]byte
+	_, _ = rand.Read(prefix[:])
+
+	c := &Client{
+		conn: conn,
+		addr: addr,
+
+		interval:    250 * time.Millisecond,
+		maxInterval: 2 * time.Second,
+		maxAttempts: 5,
+		maxSize:     DefaultMaxDatagramSize,
+
+		idPrefix: hex.EncodeToString(prefix[:]),
+		waiters:  make(map[jsonrpc2.ID]chan *jsonrpc2.Message),
+
+		done: make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	go c.readResponses()
+	return c
+}
+
+// Invoke sends a request and waits for its response, retransmitting the
+// request if no response arrives in time. RPC-level errors are returned as
+// a jsonrpc2.Error.
+func (c *Client) Invoke(ctx context.Context, method string, msg interface{}) (json.RawMessage, error) {
+	id := jsonrpc2.NewStringID(fmt.Sprintf("%s-%d", c.idPrefix, c.nextID.Inc()))
+	frame, err := c.encode(jsonrpc2.Message{Kind: jsonrpc2.KindRequest, ID: id, Method: method}, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	respCh := make(chan *jsonrpc2.Message, 1)
+	c.mut.Lock()
+	c.waiters[id] = respCh
+	c.mut.Unlock()
+	defer func() {
+		c.mut.Lock()
+		delete(c.waiters, id)
+		c.mut.Unlock()
+	}()
+
+	wait := c.interval
+	for attempt := 0; attempt < c.maxAttempts; attempt++ {
+		if _, err := c.conn.WriteTo(frame, c.addr); err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-c.done:
+			timer.Stop()
+			return nil, jsonrpc2.ErrConnClosed
+		case resp := <-respCh:
+			timer.Stop()
+			if resp.Error != nil {
+				return nil, *resp.Error
+			}
+			return resp.Result, nil
+		case <-timer.C:
+		}
+
+		if wait *= 2; wait > c.maxInterval {
+			wait = c.maxInterval
+		}
+	}
+	return nil, ErrNoResponse
+}
+
+// Notify sends a notification in a single datagram.
+func (c *Client) Notify(method string, msg interface{}) error {
+	frame, err := c.encode(jsonrpc2.Message{Kind: jsonrpc2.KindNotification, Method: method}, msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.done:
+		return jsonrpc2.ErrConnClosed
+	default:
+	}
+	_, err = c.conn.WriteTo(frame, c.addr)
+	return err
+}
+
+// Close closes the Client's socket. Calls waiting for a response fail with
+// jsonrpc2.ErrConnClosed.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) encode(m jsonrpc2.Message, params interface{}) ([]byte, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	m.Params = body
+
+	frame, err := jsonrpc2.Encode([]jsonrpc2.Message{m}, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) > c.maxSize {
+		return nil, ErrDatagramTooLarge
+	}
+	return frame, nil
+}
+
+// readResponses delivers responses to waiting calls until the socket is
+// closed. Duplicate responses and responses to calls which gave up are
+// dropped.
+func (c *Client) readResponses() {
+	defer close(c.done)
+
+	buf := make([]byte, maxUDPPayload)
+	for {
+		n, addr, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if addr.String() != c.addr.String() {
+			continue
+		}
+
+		msgs, _, err := jsonrpc2.Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		for i := range msgs {
+			if msgs[i].Kind != jsonrpc2.KindResponse {
+				continue
+			}
+			c.mut.Lock()
+			respCh, ok := c.waiters[msgs[i].ID]
+			c.mut.Unlock()
+			if !ok {
+				continue
+			}
+			select {
+			case respCh <- &msgs[i]:
+			default:
+			}
+		}
+	}
+}
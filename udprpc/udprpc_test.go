@@ -0,0 +1,161 @@
+package udprpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// lossyConn drops the first drop datagrams written to it.
+type lossyConn struct {
+	net.PacketConn
+	drop atomic.Int64
+}
+
+func (c *lossyConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if c.drop.Dec() >= 0 {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func startServer(t *testing.T, srv *Server, dropReplies int64) net.Addr {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	lossy := &lossyConn{PacketConn: conn}
+	lossy.drop.Store(dropReplies)
+	go func() { _ = srv.Serve(lossy) }()
+	return conn.LocalAddr()
+}
+
+func dial(t *testing.T, addr net.Addr, opts ...ClientOpt) *Client {
+	t.Helper()
+
+	cli, err := Dial(addr.String(), append([]ClientOpt{WithRetransmit(20*time.Millisecond, 50*time.Millisecond)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func TestClient_Invoke(t *testing.T) {
+	mux := jsonrpc2.NewServeMux()
+	mux.HandleFunc("echo", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Params)
+	})
+	mux.HandleFunc("fail", func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteError(jsonrpc2.ErrorInternal, errors.New("failed"))
+	})
+	cli := dial(t, startServer(t, &Server{Handler: mux}, 0))
+
+	res, err := cli.Invoke(context.Background(), "echo", "hello")
+	require.NoError(t, err)
+	require.Equal(t, `"hello"`, string(res))
+
+	_, err = cli.Invoke(context.Background(), "fail", nil)
+	var rpcErr jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorInternal, rpcErr.Code)
+}
+
+func TestClient_Retransmit(t *testing.T) {
+	calls := atomic.NewInt64(0)
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(calls.Inc())
+	})
+
+	// The first two replies are lost. The retransmitted requests get the
+	// remembered reply instead of being handled again.
+	cli := dial(t, startServer(t, &Server{Handler: handler}, 2))
+
+	res, err := cli.Invoke(context.Background(), "count", nil)
+	require.NoError(t, err)
+	require.Equal(t, "1", string(res))
+	require.Equal(t, int64(1), calls.Load())
+}
+
+func TestClient_NoResponse(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cli := dial(t, conn.LocalAddr(), WithMaxAttempts(2))
+	_, err = cli.Invoke(context.Background(), "ping", nil)
+	require.Equal(t, ErrNoResponse, err)
+}
+
+func TestDatagramSize(t *testing.T) {
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(strings.Repeat("x", 200))
+	})
+	cli := dial(t, startServer(t, &Server{Handler: handler, MaxDatagramSize: 100}, 0), WithMaxDatagramSize(100))
+
+	_, err := cli.Invoke(context.Background(), "big", strings.Repeat("x", 200))
+	require.Equal(t, ErrDatagramTooLarge, err)
+
+	_, err = cli.Invoke(context.Background(), "big", nil)
+	var rpcErr jsonrpc2.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, jsonrpc2.ErrorInternal, rpcErr.Code)
+}
+
+func TestServer_MaxReplies(t *testing.T) {
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(nil)
+	})
+	cli := dial(t, startServer(t, &Server{Handler: handler, MaxReplies: 1}, 0))
+
+	_, err := cli.Invoke(context.Background(), "a", nil)
+	require.NoError(t, err)
+
+	_, err = cli.Invoke(context.Background(), "b", nil)
+	_, busy := jsonrpc2.RetryAfter(err)
+	require.True(t, busy)
+	_, ok := jsonrpc2.BusyLoad(err)
+	require.False(t, ok)
+}
+
+func TestServer_ReportLoad(t *testing.T) {
+	handler := jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(nil)
+	})
+	cli := dial(t, startServer(t, &Server{Handler: handler, MaxReplies: 1, ReportLoad: true}, 0))
+
+	_, err := cli.Invoke(context.Background(), "a", nil)
+	require.NoError(t, err)
+
+	_, err = cli.Invoke(context.Background(), "b", nil)
+	load, ok := jsonrpc2.BusyLoad(err)
+	require.True(t, ok)
+	require.NotZero(t, load.Goroutines)
+	require.NotZero(t, load.HeapBytes)
+	require.Zero(t, load.InFlight)
+}
+
+func TestClient_Close(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cli := dial(t, conn.LocalAddr(), WithRetransmit(time.Second, time.Second))
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cli.Invoke(context.Background(), "ping", nil)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(t, cli.Close())
+	require.ErrorIs(t, <-errs, jsonrpc2.ErrConnClosed)
+	require.ErrorIs(t, cli.Notify("ping", nil), jsonrpc2.ErrConnClosed)
+}
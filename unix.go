@@ -0,0 +1,61 @@
+package jsonrpc2
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// DialUnix creates a connection to the server listening on the unix domain
+// socket at path. Handler will be invoked for each request received from
+// the other side.
+func DialUnix(path string, handler Handler, opts ...ClientOpt) (*Client, error) {
+	var d net.Dialer
+	nc, err := d.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing to server: %w", err)
+	}
+	return NewClient(nc, handler, opts...), nil
+}
+
+// ListenAndServeUnix listens on the unix domain socket at path and serves
+// connections from it. The socket file is created with the permissions in
+// perm and removed when the server is closed. A stale socket file left
+// behind by a process which didn't shut down cleanly is replaced, but
+// ListenAndServeUnix fails if another server is listening on path.
+func (s *Server) ListenAndServeUnix(path string, perm os.FileMode) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		_ = lis.Close()
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// removeStaleSocket removes the socket file at path if nothing is listening
+// on it.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if nc, err := net.Dial("unix", path); err == nil {
+		_ = nc.Close()
+		return fmt.Errorf("%s is already in use", path)
+	}
+	return os.Remove(path)
+}
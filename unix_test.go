@@ -0,0 +1,52 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenAndServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rpc.sock")
+
+	// Leave a stale socket behind, as a crashed server would.
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	mux := NewServeMux()
+	mux.HandleFunc("ping", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("pong") })
+	srv := &Server{Handler: mux}
+
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServeUnix(path, 0600) }()
+
+	var cli *Client
+	require.Eventually(t, func() bool {
+		cli, err = DialUnix(path, nil)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer cli.Close()
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	res, err := cli.Invoke(context.Background(), "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"pong"`, string(res))
+
+	// A second server can't take over the socket.
+	require.Error(t, (&Server{}).ListenAndServeUnix(path, 0600))
+
+	require.NoError(t, srv.Close())
+	require.Error(t, <-served)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
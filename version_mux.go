@@ -0,0 +1,231 @@
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MethodNegotiateVersion is the method used by NegotiateVersion to agree on
+// an API version with a VersionMux.
+const MethodNegotiateVersion = "rpc.negotiateVersion"
+
+// VersionedMethod returns the version-qualified name of method, such as
+// "v2.getUser".
+func VersionedMethod(version int, method string) string {
+	return "v" + strconv.Itoa(version) + "." + method
+}
+
+// parseVersionedMethod splits a version-qualified method name. ok is false
+// if method isn't version-qualified.
+func parseVersionedMethod(method string) (version int, name string, ok bool) {
+	if !strings.HasPrefix(method, "v") {
+		return 0, "", false
+	}
+	dot := strings.IndexByte(method, '.')
+	if dot < 2 {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(method[1:dot])
+	if err != nil || version < 1 {
+		return 0, "", false
+	}
+	return version, method[dot+1:], true
+}
+
+type negotiateParams struct {
+	Versions []int `json:"versions"`
+}
+
+type negotiateResult struct {
+	Version int `json:"version"`
+}
+
+// VersionMux is an RPC request multiplexer which routes methods by API
+// version, allowing method contracts to change without breaking older
+// callers.
+//
+// Handlers are registered for a method at the version it was introduced or
+// last changed. Callers select a version either by qualifying the method,
+// as in "v2.getUser", or by negotiating a version for their connection with
+// NegotiateVersion and calling unqualified methods. A call for version n is
+// served by the handler registered at the highest version no greater than n,
+// so methods which didn't change in v2 keep serving v2 callers with their v1
+// handler.
+type VersionMux struct {
+	// DefaultVersion is the version used for unqualified methods on
+	// connections which haven't negotiated a version. If zero, the latest
+	// registered version is used.
+	DefaultVersion int
+
+	mut      sync.RWMutex
+	routes   map[string]map[int]Handler
+	versions []int // Sorted list of registered versions.
+	conns    map[*Client]int
+}
+
+// NewVersionMux allocates and returns a new VersionMux.
+func NewVersionMux() *VersionMux {
+	return &VersionMux{
+		routes: make(map[string]map[int]Handler),
+		conns:  make(map[*Client]int),
+	}
+}
+
+// Handle registers the handler for method at version, which must be at least
+// 1. If a handler already exists for method at version, Handle panics.
+func (m *VersionMux) Handle(version int, method string, handler Handler) {
+	if version < 1 {
+		panic("version must be at least 1")
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	byVersion, ok := m.routes[method]
+	if !ok {
+		byVersion = make(map[int]Handler)
+		m.routes[method] = byVersion
+	}
+	if _, exist := byVersion[version]; exist {
+		panic("method " + VersionedMethod(version, method) + " already registered")
+	}
+	byVersion[version] = handler
+
+	i := sort.SearchInts(m.versions, version)
+	if i == len(m.versions) || m.versions[i] != version {
+		m.versions = append(m.versions, 0)
+		copy(m.versions[i+1:], m.versions[i:])
+		m.versions[i] = version
+	}
+}
+
+// HandleFunc registers the handler function for method at version.
+func (m *VersionMux) HandleFunc(version int, method string, handler func(w ResponseWriter, r *Request)) {
+	m.Handle(version, method, HandlerFunc(handler))
+}
+
+// ServeRPC implements Handler.
+func (m *VersionMux) ServeRPC(w ResponseWriter, r *Request) {
+	if r.Method == MethodNegotiateVersion {
+		m.negotiate(w, r)
+		return
+	}
+
+	version, method, ok := parseVersionedMethod(r.Method)
+	if !ok {
+		method = r.Method
+		version = m.connVersion(r.Client)
+	}
+
+	h := m.lookup(version, method)
+	if h == nil {
+		if !r.Notification {
+			_ = w.WriteError(ErrorMethodNotFound, fmt.Errorf("method %s not found", r.Method))
+		}
+		return
+	}
+	h.ServeRPC(w, r)
+}
+
+// connVersion returns the version to use for unqualified methods called
+// over cli.
+func (m *VersionMux) connVersion(cli *Client) int {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	if v, ok := m.conns[cli]; ok {
+		return v
+	}
+	if m.DefaultVersion != 0 {
+		return m.DefaultVersion
+	}
+	if len(m.versions) == 0 {
+		return 0
+	}
+	return m.versions[len(m.versions)-1]
+}
+
+// lookup returns the handler registered for method at the highest version
+// no greater than version.
+func (m *VersionMux) lookup(version int, method string) Handler {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	byVersion := m.routes[method]
+	var (
+		best    Handler
+		bestVer int
+	)
+	for v, h := range byVersion {
+		if v <= version && v > bestVer {
+			best, bestVer = h, v
+		}
+	}
+	return best
+}
+
+// negotiate picks the highest version supported by both sides and uses it
+// for unqualified methods for the rest of the connection.
+func (m *VersionMux) negotiate(w ResponseWriter, r *Request) {
+	var params negotiateParams
+	if err := r.DecodeParams(&params); err != nil {
+		if !r.Notification {
+			_ = w.WriteError(ErrorInvalidParams, err)
+		}
+		return
+	}
+
+	m.mut.Lock()
+	chosen := 0
+	for _, v := range params.Versions {
+		i := sort.SearchInts(m.versions, v)
+		if i < len(m.versions) && m.versions[i] == v && v > chosen {
+			chosen = v
+		}
+	}
+	_, known := m.conns[r.Client]
+	if chosen != 0 && r.Client != nil {
+		m.conns[r.Client] = chosen
+	}
+	m.mut.Unlock()
+
+	if chosen != 0 && r.Client != nil && !known {
+		// Forget the connection's version once it closes.
+		go func(cli *Client) {
+			<-cli.Done()
+			m.mut.Lock()
+			delete(m.conns, cli)
+			m.mut.Unlock()
+		}(r.Client)
+	}
+
+	if r.Notification {
+		return
+	}
+	if chosen == 0 {
+		_ = w.WriteError(ErrorInvalidParams, fmt.Errorf("no supported version in %v", params.Versions))
+		return
+	}
+	_ = w.WriteMessage(negotiateResult{Version: chosen})
+}
+
+// NegotiateVersion agrees on an API version with a VersionMux on the other
+// side of conn, choosing the highest of supported that the server also
+// supports. Unqualified methods invoked over conn afterwards are served at
+// the returned version.
+func NegotiateVersion(ctx context.Context, conn Conn, supported ...int) (int, error) {
+	res, err := conn.Invoke(ctx, MethodNegotiateVersion, negotiateParams{Versions: supported})
+	if err != nil {
+		return 0, err
+	}
+	var result negotiateResult
+	if err := json.Unmarshal(res, &result); err != nil {
+		return 0, fmt.Errorf("invalid version negotiation result: %w", err)
+	}
+	return result.Version, nil
+}
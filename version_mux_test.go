@@ -0,0 +1,87 @@
+package jsonrpc2
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionMux(t *testing.T) {
+	mux := NewVersionMux()
+	mux.HandleFunc(1, "getUser", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("getUser v1") })
+	mux.HandleFunc(2, "getUser", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("getUser v2") })
+	mux.HandleFunc(1, "ping", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("ping v1") })
+	mux.HandleFunc(3, "newThing", func(w ResponseWriter, r *Request) { _ = w.WriteMessage("newThing v3") })
+
+	newConn := func(t *testing.T) *Client {
+		srvConn, cliConn := net.Pipe()
+		srv := NewClient(srvConn, mux)
+		t.Cleanup(func() { _ = srv.Close() })
+		cli := NewClient(cliConn, nil)
+		t.Cleanup(func() { _ = cli.Close() })
+		return cli
+	}
+
+	invoke := func(t *testing.T, cli *Client, method string) string {
+		t.Helper()
+		res, err := cli.Invoke(context.Background(), method, nil)
+		require.NoError(t, err)
+		return string(res)
+	}
+
+	t.Run("qualified", func(t *testing.T) {
+		cli := newConn(t)
+		require.Equal(t, `"getUser v1"`, invoke(t, cli, "v1.getUser"))
+		require.Equal(t, `"getUser v2"`, invoke(t, cli, "v2.getUser"))
+		// v3 didn't change getUser or ping, so older handlers are used.
+		require.Equal(t, `"getUser v2"`, invoke(t, cli, "v3.getUser"))
+		require.Equal(t, `"ping v1"`, invoke(t, cli, "v3.ping"))
+
+		_, err := cli.Invoke(context.Background(), "v2.newThing", nil)
+		require.Error(t, err)
+	})
+
+	t.Run("unqualified uses latest", func(t *testing.T) {
+		cli := newConn(t)
+		require.Equal(t, `"getUser v2"`, invoke(t, cli, "getUser"))
+		require.Equal(t, `"newThing v3"`, invoke(t, cli, "newThing"))
+	})
+
+	t.Run("negotiated", func(t *testing.T) {
+		cli := newConn(t)
+		v, err := NegotiateVersion(context.Background(), cli, 1, 4)
+		require.NoError(t, err)
+		require.Equal(t, 1, v)
+		require.Equal(t, `"getUser v1"`, invoke(t, cli, "getUser"))
+
+		// Other connections are unaffected.
+		require.Equal(t, `"getUser v2"`, invoke(t, newConn(t), "getUser"))
+
+		_, err = NegotiateVersion(context.Background(), cli, 5)
+		require.Error(t, err)
+	})
+}
+
+func TestParseVersionedMethod(t *testing.T) {
+	tt := []struct {
+		method  string
+		version int
+		name    string
+		ok      bool
+	}{
+		{method: "v2.getUser", version: 2, name: "getUser", ok: true},
+		{method: "v10.a.b", version: 10, name: "a.b", ok: true},
+		{method: "getUser"},
+		{method: "v.getUser"},
+		{method: "v0.getUser"},
+		{method: "values.get"},
+	}
+	for _, tc := range tt {
+		version, name, ok := parseVersionedMethod(tc.method)
+		require.Equal(t, tc.ok, ok, tc.method)
+		require.Equal(t, tc.version, version, tc.method)
+		require.Equal(t, tc.name, name, tc.method)
+	}
+}
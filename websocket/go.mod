@@ -0,0 +1,11 @@
+module github.com/crtv-io/jsonrpc2/websocket
+
+go 1.16
+
+require (
+  github.com/crtv-io/jsonrpc2 v0.0.0
+  github.com/gorilla/websocket v1.4.2
+  github.com/stretchr/testify v1.7.0
+)
+
+replace github.com/crtv-io/jsonrpc2 => ../
@@ -0,0 +1,232 @@
+// Package websocket implements JSON-RPC 2.0 over Gorilla websockets.
+//
+// Each JSON-RPC 2.0 payload is sent as exactly one websocket message, and
+// each websocket message received must hold exactly one payload. Messages
+// split across continuation frames are reassembled before being decoded.
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/gorilla/websocket"
+)
+
+// Websocket message types which may be passed to NewFramer.
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+// Websocket close codes which may be passed to Client.CloseWithStatus. See
+// RFC 6455 section 7.4.1 for the full list.
+const (
+	CloseNormalClosure     = websocket.CloseNormalClosure
+	CloseGoingAway         = websocket.CloseGoingAway
+	CloseProtocolError     = websocket.CloseProtocolError
+	CloseAbnormalClosure   = websocket.CloseAbnormalClosure
+	ClosePolicyViolation   = websocket.ClosePolicyViolation
+	CloseMessageTooBig     = websocket.CloseMessageTooBig
+	CloseInternalServerErr = websocket.CloseInternalServerErr
+	CloseServiceRestart    = websocket.CloseServiceRestart
+	CloseTryAgainLater     = websocket.CloseTryAgainLater
+)
+
+// closeTimeout bounds the time spent sending a close frame.
+const closeTimeout = time.Second
+
+// maxCloseReason is the longest reason which fits in a close frame.
+const maxCloseReason = 123
+
+// DefaultMaxMessageSize is the default limit for the size of messages read
+// by a Framer, in bytes.
+const DefaultMaxMessageSize = 16 << 20
+
+// maxRetainedBuffer is the largest read buffer a Framer keeps between
+// messages.
+const maxRetainedBuffer = 64 * 1024
+
+// CloseError is the error a Client closes with when the websocket is closed
+// by the peer, returned by Client.Err. Connections which drop without a
+// close frame have the code CloseAbnormalClosure.
+//
+//	if cerr := (*websocket.CloseError)(nil); errors.As(cli.Err(), &cerr) && cerr.Code == websocket.ClosePolicyViolation {
+//		log.Printf("rejected by server: %s", cerr.Reason)
+//	}
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// NewClient creates a client from a Gorilla websocket. Payloads are sent as
+// text messages. Closing the Client will close the underlying websocket,
+// with a CloseNormalClosure status unless Client.CloseWithStatus is used.
+// Messages larger than DefaultMaxMessageSize close the websocket; use
+// NewFramer with WithMaxMessageSize for a different limit.
+func NewClient(conn *websocket.Conn, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) *jsonrpc2.Client {
+	return jsonrpc2.NewFramedClient(NewFramer(conn, TextMessage), handler, opts...)
+}
+
+// Dial connects to a websocket JSON-RPC 2.0 server at url. The dial and
+// websocket handshake must complete within handshakeTimeout and before ctx is
+// done. A handshakeTimeout of zero means no timeout.
+func Dial(ctx context.Context, url string, handshakeTimeout time.Duration, handler jsonrpc2.Handler, opts ...jsonrpc2.ClientOpt) (*jsonrpc2.Client, error) {
+	d := websocket.Dialer{HandshakeTimeout: handshakeTimeout}
+	conn, _, err := d.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed dialing to server: %w", err)
+	}
+	return NewClient(conn, handler, opts...), nil
+}
+
+// Server is an http.Handler which upgrades requests to websockets and serves
+// JSON-RPC 2.0 over them.
+type Server struct {
+	// Handler is the handler to invoke when receiving a JSON-RPC request.
+	Handler jsonrpc2.Handler
+
+	// OnClient may be provided to handle new connections.
+	OnClient func(c *jsonrpc2.Client)
+
+	// ClientOpts are passed to NewClient for each new connection.
+	ClientOpts []jsonrpc2.ClientOpt
+
+	// HandshakeTimeout is the maximum amount of time to spend completing the
+	// websocket upgrade. If zero, there is no timeout. Use the timeouts on
+	// http.Server to bound the time spent reading the upgrade request.
+	HandshakeTimeout time.Duration
+
+	// CheckOrigin checks the Origin header of upgrade requests. If nil,
+	// requests with an Origin host different from the Host header are
+	// rejected.
+	CheckOrigin func(r *http.Request) bool
+
+	// MaxMessageSize is the largest message read from each connection, in
+	// bytes. If zero, DefaultMaxMessageSize is used.
+	MaxMessageSize int64
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: s.HandshakeTimeout,
+		CheckOrigin:      s.CheckOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already responded to the request.
+		return
+	}
+
+	f := NewFramer(conn, TextMessage, WithMaxMessageSize(s.MaxMessageSize))
+	cli := jsonrpc2.NewFramedClient(f, s.Handler, s.ClientOpts...)
+	if s.OnClient != nil {
+		s.OnClient(cli)
+	}
+}
+
+// FramerOpt is an option function that can be passed to NewFramer.
+type FramerOpt func(*framer)
+
+// WithMaxMessageSize sets the largest message the Framer reads, in bytes.
+// The websocket is closed with CloseMessageTooBig if the peer sends a larger
+// message. The default is DefaultMaxMessageSize.
+func WithMaxMessageSize(n int64) FramerOpt {
+	return func(f *framer) {
+		if n > 0 {
+			f.conn.SetReadLimit(n)
+		}
+	}
+}
+
+// NewFramer returns a jsonrpc2.Framer which maps each frame to a websocket
+// message. Frames are written as messages of messageType, which must be
+// TextMessage or BinaryMessage. The returned Framer implements io.Closer,
+// and ReadFrame returns a *CloseError once the peer closes the websocket.
+//
+// NewFramer sets the read limit of conn to DefaultMaxMessageSize, unless
+// WithMaxMessageSize is given.
+//
+// Use NewFramer with jsonrpc2.NewFramedClient to create a client that sends
+// binary messages:
+//
+//	cli := jsonrpc2.NewFramedClient(websocket.NewFramer(conn, websocket.BinaryMessage), handler)
+func NewFramer(conn *websocket.Conn, messageType int, opts ...FramerOpt) jsonrpc2.Framer {
+	f := &framer{conn: conn, messageType: messageType}
+	conn.SetReadLimit(DefaultMaxMessageSize)
+	for _, o := range opts {
+		o(f)
+	}
+	return f
+}
+
+type framer struct {
+	conn        *websocket.Conn
+	messageType int
+
+	readBuf  bytes.Buffer
+	writeMtx sync.Mutex
+}
+
+func (f *framer) ReadFrame() ([]byte, error) {
+	// Drop the buffer once the previous frame is done with if it grew to
+	// fit a large message.
+	if f.readBuf.Cap() > maxRetainedBuffer {
+		f.readBuf = bytes.Buffer{}
+	}
+
+	// NextReader only returns text and binary messages, and its reader spans
+	// all continuation frames of the message.
+	_, r, err := f.conn.NextReader()
+	if err != nil {
+		var cerr *websocket.CloseError
+		if errors.As(err, &cerr) {
+			return nil, &CloseError{Code: cerr.Code, Reason: cerr.Text}
+		}
+		return nil, err
+	}
+
+	f.readBuf.Reset()
+	if _, err := f.readBuf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return f.readBuf.Bytes(), nil
+}
+
+func (f *framer) WriteFrame(frame []byte) error {
+	f.writeMtx.Lock()
+	defer f.writeMtx.Unlock()
+	return f.conn.WriteMessage(f.messageType, frame)
+}
+
+func (f *framer) Close() error {
+	return f.CloseWithStatus(CloseNormalClosure, "")
+}
+
+// CloseWithStatus sends a close frame with code and reason and closes the
+// websocket. Reasons longer than a close frame allows are truncated. The
+// websocket is closed even if the close frame can't be sent, such as when
+// the peer has already gone away.
+func (f *framer) CloseWithStatus(code int, reason string) error {
+	for len(reason) > maxCloseReason {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = f.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+	return f.conn.Close()
+}
@@ -0,0 +1,261 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crtv-io/jsonrpc2"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	var (
+		upgrader websocket.Upgrader
+	)
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		require.NoError(t, err)
+
+		NewClient(conn, jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			require.Equal(t, "test", r.Method)
+			err := w.WriteMessage("Hello, world!")
+			require.NoError(t, err)
+		}))
+	})
+
+	testSrv := httptest.NewServer(handler)
+	t.Cleanup(testSrv.Close)
+
+	// Retrieve the client and server websockets.
+	clientWS, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s", testSrv.Listener.Addr().String()), nil)
+	require.NoError(t, err)
+
+	cli := NewClient(clientWS, jsonrpc2.DefaultHandler)
+	resp, err := cli.Invoke(context.Background(), "test", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"Hello, world!"`, string(resp))
+
+	clientWS.Close()
+}
+
+func TestFramer(t *testing.T) {
+	var upgrader websocket.Upgrader
+
+	type received struct {
+		messageType int
+		data        string
+	}
+	serverMessages := make(chan received, 2)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// Send two requests as separate messages, the first split across
+		// continuation frames.
+		w, err := conn.NextWriter(websocket.TextMessage)
+		require.NoError(t, err)
+		_, _ = w.Write([]byte(`{"jsonrpc": "2.0", `))
+		_, _ = w.Write([]byte(`"method": "a", "id": 1}`))
+		require.NoError(t, w.Close())
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "method": "b", "id": 2}`)))
+
+		for i := 0; i < 2; i++ {
+			mt, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			serverMessages <- received{messageType: mt, data: string(data)}
+		}
+	})
+
+	testSrv := httptest.NewServer(handler)
+	t.Cleanup(testSrv.Close)
+
+	clientWS, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s", testSrv.Listener.Addr().String()), nil)
+	require.NoError(t, err)
+
+	cli := jsonrpc2.NewFramedClient(NewFramer(clientWS, BinaryMessage), jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+		_ = w.WriteMessage(r.Method)
+	}))
+	defer cli.Close()
+
+	results := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg := <-serverMessages
+		require.Equal(t, websocket.BinaryMessage, msg.messageType)
+
+		msgs, _, err := jsonrpc2.Parse([]byte(msg.data))
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		results[string(msgs[0].Result)] = true
+	}
+	require.Equal(t, map[string]bool{`"a"`: true, `"b"`: true}, results)
+}
+
+func TestServer(t *testing.T) {
+	srv := &Server{
+		Handler: jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			_ = w.WriteMessage(r.Method)
+		}),
+		HandshakeTimeout: time.Second,
+	}
+	testSrv := httptest.NewServer(srv)
+	t.Cleanup(testSrv.Close)
+
+	cli, err := Dial(context.Background(), "ws://"+testSrv.Listener.Addr().String(), time.Second, nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	resp, err := cli.Invoke(context.Background(), "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"ping"`, string(resp))
+}
+
+func TestDial_HandshakeTimeout(t *testing.T) {
+	// Accept connections but never respond to the upgrade request.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	start := time.Now()
+	_, err = Dial(context.Background(), "ws://"+lis.Addr().String(), 100*time.Millisecond, nil)
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestClient_CloseWithStatus(t *testing.T) {
+	srv := &Server{
+		Handler: jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			_ = r.Client.CloseWithStatus(ClosePolicyViolation, "forbidden method")
+		}),
+	}
+	testSrv := httptest.NewServer(srv)
+	t.Cleanup(testSrv.Close)
+
+	cli, err := Dial(context.Background(), "ws://"+testSrv.Listener.Addr().String(), time.Second, nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Invoke(context.Background(), "admin", nil)
+	require.Error(t, err)
+	<-cli.Done()
+
+	var cerr *CloseError
+	require.ErrorAs(t, cli.Err(), &cerr)
+	require.Equal(t, ClosePolicyViolation, cerr.Code)
+	require.Equal(t, "forbidden method", cerr.Reason)
+}
+
+func TestClient_CloseNormal(t *testing.T) {
+	clients := make(chan *jsonrpc2.Client, 1)
+	srv := &Server{OnClient: func(c *jsonrpc2.Client) { clients <- c }}
+	testSrv := httptest.NewServer(srv)
+	t.Cleanup(testSrv.Close)
+
+	cli, err := Dial(context.Background(), "ws://"+testSrv.Listener.Addr().String(), time.Second, nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	require.NoError(t, (<-clients).Close())
+	<-cli.Done()
+
+	var cerr *CloseError
+	require.ErrorAs(t, cli.Err(), &cerr)
+	require.Equal(t, CloseNormalClosure, cerr.Code)
+}
+
+func TestFramer_CloseReasonTruncated(t *testing.T) {
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		_ = NewFramer(conn, TextMessage).(interface {
+			CloseWithStatus(int, string) error
+		}).CloseWithStatus(CloseGoingAway, strings.Repeat("é", 100))
+	})
+	testSrv := httptest.NewServer(handler)
+	t.Cleanup(testSrv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+testSrv.Listener.Addr().String(), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Reasons are cut at a rune boundary to fit in a close frame.
+	_, err = NewFramer(conn, TextMessage).ReadFrame()
+	var cerr *CloseError
+	require.ErrorAs(t, err, &cerr)
+	require.Equal(t, CloseGoingAway, cerr.Code)
+	require.Equal(t, strings.Repeat("é", 61), cerr.Reason)
+}
+
+func TestServer_MaxMessageSize(t *testing.T) {
+	srv := &Server{
+		Handler: jsonrpc2.HandlerFunc(func(w jsonrpc2.ResponseWriter, r *jsonrpc2.Request) {
+			_ = w.WriteMessage(r.Method)
+		}),
+		MaxMessageSize: 1024,
+	}
+	testSrv := httptest.NewServer(srv)
+	t.Cleanup(testSrv.Close)
+
+	cli, err := Dial(context.Background(), "ws://"+testSrv.Listener.Addr().String(), time.Second, nil)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := cli.Invoke(ctx, "ping", nil)
+	require.NoError(t, err)
+	require.Equal(t, `"ping"`, string(resp))
+
+	// The server closes the websocket rather than reading the message.
+	_, err = cli.Invoke(ctx, "ping", strings.Repeat("x", 2048))
+	require.Error(t, err)
+
+	select {
+	case <-cli.Done():
+	case <-ctx.Done():
+		require.FailNow(t, "client should close")
+	}
+}
+
+func TestFramer_ReleasesLargeBuffer(t *testing.T) {
+	var upgrader websocket.Upgrader
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`"`+strings.Repeat("x", 1<<20)+`"`))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`1`))
+		_, _, _ = conn.ReadMessage()
+	})
+
+	testSrv := httptest.NewServer(handler)
+	t.Cleanup(testSrv.Close)
+
+	clientWS, _, err := websocket.DefaultDialer.Dial("ws://"+testSrv.Listener.Addr().String(), nil)
+	require.NoError(t, err)
+	f := NewFramer(clientWS, TextMessage).(*framer)
+	defer f.Close()
+
+	frame, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.Len(t, frame, 1<<20+2)
+
+	frame, err = f.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, "1", string(frame))
+	require.LessOrEqual(t, f.readBuf.Cap(), maxRetainedBuffer)
+}